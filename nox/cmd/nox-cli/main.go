@@ -0,0 +1,1046 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	pb "nox/proto"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	serverAddr string
+	useTLS     bool
+	certPath   string
+	keyPath    string
+	caPath     string
+	authToken  string
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "nox-cli",
+	Short: "A gRPC client for the Nox IDS engine.",
+	Long:  `Nox CLI is a tool to interact with the nox gRPC API for threat hunting and data exploration`,
+}
+
+var searchCmd = &cobra.Command{
+	Use:   "search",
+	Short: "Search for process execution events.",
+	Run: func(cmd *cobra.Command, args []string) {
+		startTimeStr, _ := cmd.Flags().GetString("start-time")
+		endTimeStr, _ := cmd.Flags().GetString("end-time")
+		filters, _ := cmd.Flags().GetStringToString("filter")
+
+		var startTime, endTime time.Time
+		var err error
+
+		if startTimeStr != "" {
+			startTime, err = time.Parse(time.RFC3339, startTimeStr)
+			if err != nil {
+				log.Fatalf("Invalid start-time format. Use RFC3339(e.g., '2023-01-01T15:04:05Z'): %v", err)
+			}
+		}
+
+		if endTimeStr != "" {
+			endTime, err = time.Parse(time.RFC3339, endTimeStr)
+			if err != nil {
+				log.Fatalf("Invalid end-time format. Use RFC3339 (e.g., '2023-01-01T15:04:05Z'): %v", err)
+			}
+		}
+
+		c, conn := connect()
+		defer conn.Close()
+
+		req := &pb.SearchRequest{
+			StartTime: timestamppb.New(startTime),
+			EndTime:   timestamppb.New(endTime),
+			Filters:   filters,
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		res, err := c.SearchEvents(ctx, req)
+		if err != nil {
+			log.Fatalf("Could not perform search: %v", err)
+		}
+
+		if len(res.ProcessEvents) == 0 {
+			log.Println("No matching events found.")
+			return
+		}
+
+		log.Printf("Found %d events: ", len(res.ProcessEvents))
+		for _, event := range res.ProcessEvents {
+			fmt.Printf("  - Time: %s, PID: %s, PPID: %s, UID: %s, Cmd: %s\n",
+				event.Timestamp.AsTime().Format(time.RFC822), event.Pid, event.Ppid, event.Uid, event.Command)
+		}
+	},
+}
+
+var ancestryCmd = &cobra.Command{
+	Use:   "ancestry [pid]",
+	Short: "Get the process ancestry for a given PID",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		pid := args[0]
+		c, conn := connect()
+		defer conn.Close()
+
+		req := &pb.PIDRequest{Pid: pid}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		res, err := c.GetProcessAncestry(ctx, req)
+		if err != nil {
+			log.Fatalf("Could not get process ancestry: %v", err)
+		}
+
+		log.Printf("Process Ancestry for PID %s (newest first): ", pid)
+		for _, event := range res.Events {
+			fmt.Printf("  - PID: %-7s PPID: %-7s Cmd: %s\n", event.Pid, event.Ppid, event.Command)
+		}
+	},
+}
+
+var topCmd = &cobra.Command{
+	Use:   "top [field]",
+	Short: "Get the top N most frequent values for a field",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		field := args[0]
+		n, _ := cmd.Flags().GetInt32("n")
+
+		c, conn := connect()
+		defer conn.Close()
+
+		req := &pb.TopNRequest{Field: field, N: n}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		res, err := c.GetTopEvents(ctx, req)
+		if err != nil {
+			log.Fatalf("Could not get top events: %v", err)
+		}
+
+		log.Printf("Top %d values for field '%s': ", n, field)
+		for _, result := range res.Results {
+			fmt.Printf("  - %-30s Count: %d\n", result.Item, result.Count)
+		}
+	},
+}
+
+const (
+	colorRed   = "\033[31m"
+	colorDim   = "\033[2m"
+	colorReset = "\033[0m"
+)
+
+var timelineCmd = &cobra.Command{
+	Use:   "timeline",
+	Short: "Show a chronological timeline of logins, processes and alerts for a user, IP or host",
+	Run: func(cmd *cobra.Command, args []string) {
+		user, _ := cmd.Flags().GetString("user")
+		ip, _ := cmd.Flags().GetString("ip")
+		host, _ := cmd.Flags().GetString("host")
+		limit, _ := cmd.Flags().GetInt32("limit")
+		gap, _ := cmd.Flags().GetDuration("gap")
+		startTimeStr, _ := cmd.Flags().GetString("start-time")
+		endTimeStr, _ := cmd.Flags().GetString("end-time")
+
+		var startTime, endTime time.Time
+		var err error
+
+		if startTimeStr != "" {
+			startTime, err = time.Parse(time.RFC3339, startTimeStr)
+			if err != nil {
+				log.Fatalf("Invalid start-time format. Use RFC3339(e.g., '2023-01-01T15:04:05Z'): %v", err)
+			}
+		}
+
+		if endTimeStr != "" {
+			endTime, err = time.Parse(time.RFC3339, endTimeStr)
+			if err != nil {
+				log.Fatalf("Invalid end-time format. Use RFC3339 (e.g., '2023-01-01T15:04:05Z'): %v", err)
+			}
+		}
+
+		c, conn := connect()
+		defer conn.Close()
+
+		req := &pb.TimelineRequest{
+			StartTime:           timestamppb.New(startTime),
+			EndTime:             timestamppb.New(endTime),
+			User:                user,
+			SourceIp:            ip,
+			Host:                host,
+			Limit:               limit,
+			GapThresholdSeconds: int64(gap.Seconds()),
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		res, err := c.GetEntityTimeline(ctx, req)
+		if err != nil {
+			log.Fatalf("Could not get entity timeline: %v", err)
+		}
+
+		if len(res.Entries) == 0 {
+			log.Println("No activity found for entity.")
+			return
+		}
+
+		log.Printf("Timeline with %d entries (oldest first): ", len(res.Entries))
+		for _, entry := range res.Entries {
+			switch entry.Kind {
+			case "gap":
+				fmt.Printf("%s  ... %s ...%s\n", colorDim, entry.Summary, colorReset)
+			case "alert":
+				fmt.Printf("%s  %s  %-12s %s%s\n", colorRed,
+					entry.Timestamp.AsTime().Format(time.RFC3339), strings.ToUpper(entry.Kind), entry.Summary, colorReset)
+			default:
+				fmt.Printf("  %s  %-12s %s\n",
+					entry.Timestamp.AsTime().Format(time.RFC3339), strings.ToUpper(entry.Kind), entry.Summary)
+			}
+		}
+	},
+}
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Show the learned behavioral baseline of a user or host",
+	Run: func(cmd *cobra.Command, args []string) {
+		user, _ := cmd.Flags().GetString("user")
+		host, _ := cmd.Flags().GetString("host")
+
+		c, conn := connect()
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		res, err := c.GetProfile(ctx, &pb.ProfileRequest{User: user, Host: host})
+		if err != nil {
+			log.Fatalf("Could not get profile: %v", err)
+		}
+
+		fmt.Printf("Profile for %s\n", res.Entity)
+		fmt.Printf("  First seen: %s\n", res.FirstSeen.AsTime().Format(time.RFC3339))
+		fmt.Printf("  Last seen:  %s\n", res.LastSeen.AsTime().Format(time.RFC3339))
+		if res.Learning {
+			fmt.Printf("  %sStill learning: deviations are not scored yet.%s\n", colorDim, colorReset)
+		}
+
+		if res.Logins > 0 {
+			fmt.Printf("\nLogins (decayed): %.1f\n", res.Logins)
+			fmt.Println("  Hour (UTC)  Share")
+			for hour, weight := range res.LoginHours {
+				if share := weight / res.Logins; share >= 0.005 {
+					fmt.Printf("  %02d:00       %5.1f%% %s\n", hour, share*100, strings.Repeat("#", int(share*50+0.5)))
+				}
+			}
+			printWeighted("Source ASNs", res.SourceAsns)
+		}
+
+		if res.Executions > 0 {
+			fmt.Printf("\nExecutions (decayed): %.1f, %.1f ± %.1f per active minute\n",
+				res.Executions, res.ExecRateMean, res.ExecRateStddev)
+			printWeighted("Parent > child processes", res.ProcessPairs)
+		}
+	},
+}
+
+var rulesCmd = &cobra.Command{
+	Use:   "rules [id]",
+	Short: "List the loaded detection rules, or show one rule's metadata",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		kind, _ := cmd.Flags().GetString("kind")
+		tag, _ := cmd.Flags().GetString("tag")
+		technique, _ := cmd.Flags().GetString("technique")
+		enabledOnly, _ := cmd.Flags().GetBool("enabled-only")
+
+		c, conn := connect()
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		res, err := c.ListRules(ctx, &pb.ListRulesRequest{
+			Kind:        kind,
+			Tag:         tag,
+			TechniqueId: technique,
+			EnabledOnly: enabledOnly,
+		})
+		if err != nil {
+			log.Fatalf("Could not list rules: %v", err)
+		}
+
+		if len(args) == 1 {
+			for _, rule := range res.Rules {
+				if rule.Id == args[0] {
+					printRule(rule)
+					return
+				}
+			}
+			log.Fatalf("No rule with id %q", args[0])
+		}
+
+		if len(res.Rules) == 0 {
+			log.Println("No matching rules found.")
+			return
+		}
+
+		fmt.Printf("%-36s %-12s %-4s %-9s %s\n", "ID", "KIND", "VER", "SEVERITY", "NAME")
+		for _, rule := range res.Rules {
+			line := fmt.Sprintf("%-36s %-12s v%-3d %-9s %s", rule.Id, rule.Kind, rule.Version, rule.Severity, rule.Name)
+			if rule.Mode != "" && rule.Mode != "active" {
+				fmt.Printf("%s%s (%s)%s\n", colorDim, line, rule.Mode, colorReset)
+				continue
+			}
+			fmt.Println(line)
+		}
+	},
+}
+
+var ruleEnableCmd = &cobra.Command{
+	Use:   "enable <id>",
+	Short: "Enable a detection rule",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		setRuleMode(args[0], "active")
+	},
+}
+
+var ruleDisableCmd = &cobra.Command{
+	Use:   "disable <id>",
+	Short: "Disable a detection rule, built-in ones included",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		setRuleMode(args[0], "disabled")
+	},
+}
+
+var ruleModeCmd = &cobra.Command{
+	Use:   "mode <id> <active|shadow|disabled>",
+	Short: "Set a detection rule's mode; shadow rules only record what they would alert on",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		setRuleMode(args[0], args[1])
+	},
+}
+
+func setRuleMode(id, mode string) {
+	c, conn := connect()
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	rule, err := c.SetRuleMode(ctx, &pb.RuleModeRequest{Id: id, Mode: mode})
+	if err != nil {
+		log.Fatalf("Could not change rule: %v", err)
+	}
+	fmt.Printf("%s (%s) is now %s\n", rule.Name, rule.Id, rule.Mode)
+}
+
+var ruleShadowReportCmd = &cobra.Command{
+	Use:   "shadow-report",
+	Short: "Compare how often shadow rules fire with the rules they replace",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		since, _ := cmd.Flags().GetDuration("since")
+
+		c, conn := connect()
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		res, err := c.GetShadowReport(ctx, &pb.ShadowReportRequest{
+			StartTime: timestamppb.New(time.Now().Add(-since)),
+		})
+		if err != nil {
+			log.Fatalf("Could not get shadow report: %v", err)
+		}
+
+		if len(res.Rules) == 0 {
+			log.Println("No rules in shadow mode.")
+			return
+		}
+
+		fmt.Printf("Alerts from %s to %s\n\n", res.StartTime.AsTime().Format(time.RFC3339), res.EndTime.AsTime().Format(time.RFC3339))
+		fmt.Printf("%-36s %8s %8s   %-36s %8s %8s\n", "SHADOW RULE", "ALERTS", "PER DAY", "REPLACES", "ALERTS", "PER DAY")
+		for _, rule := range res.Rules {
+			if rule.Replaces == "" {
+				fmt.Printf("%-36s %8d %8.1f   %s-%s\n", rule.Id, rule.ShadowAlerts, rule.ShadowPerDay, colorDim, colorReset)
+				continue
+			}
+			fmt.Printf("%-36s %8d %8.1f   %-36s %8d %8.1f\n", rule.Id, rule.ShadowAlerts, rule.ShadowPerDay,
+				rule.Replaces, rule.BaselineAlerts, rule.BaselinePerDay)
+		}
+	},
+}
+
+var ruleApplyCmd = &cobra.Command{
+	Use:   "apply -f rules.yaml",
+	Short: "Validate YAML rules and load them, replacing rules with the same id",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		path, _ := cmd.Flags().GetString("file")
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Could not read rules: %v", err)
+		}
+
+		c, conn := connect()
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		res, err := c.UpsertYAMLRule(ctx, &pb.UpsertRuleRequest{Yaml: string(data)})
+		if err != nil {
+			log.Fatalf("Could not apply rules: %v", err)
+		}
+
+		for _, warning := range res.Warnings {
+			fmt.Printf("%swarning: %s%s\n", colorDim, warning, colorReset)
+		}
+		for _, rule := range res.Rules {
+			fmt.Printf("%s (%s v%d) applied\n", rule.Name, rule.Id, rule.Version)
+		}
+	},
+}
+
+var ruleDeleteCmd = &cobra.Command{
+	Use:   "delete <id>",
+	Short: "Delete a YAML detection rule",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		c, conn := connect()
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		rule, err := c.DeleteRule(ctx, &pb.RuleRequest{Id: args[0]})
+		if err != nil {
+			log.Fatalf("Could not delete rule: %v", err)
+		}
+		fmt.Printf("%s (%s) deleted\n", rule.Name, rule.Id)
+	},
+}
+
+var alertsCmd = &cobra.Command{
+	Use:   "alerts [id]",
+	Short: "List raised alerts with their triage status, or show one alert's case",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		status, _ := cmd.Flags().GetString("status")
+		assignee, _ := cmd.Flags().GetString("assignee")
+		rule, _ := cmd.Flags().GetString("rule")
+		incident, _ := cmd.Flags().GetString("incident")
+		since, _ := cmd.Flags().GetDuration("since")
+		limit, _ := cmd.Flags().GetInt32("limit")
+
+		c, conn := connect()
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		req := &pb.ListAlertsRequest{
+			Status:     status,
+			Assignee:   assignee,
+			RuleId:     rule,
+			IncidentId: incident,
+			Limit:      limit,
+		}
+		if since > 0 {
+			req.StartTime = timestamppb.New(time.Now().Add(-since))
+		}
+
+		res, err := c.ListAlerts(ctx, req)
+		if err != nil {
+			log.Fatalf("Could not list alerts: %v", err)
+		}
+
+		if len(args) == 1 {
+			for _, alert := range res.Alerts {
+				if alert.Id == args[0] {
+					printAlert(alert)
+					return
+				}
+			}
+			log.Fatalf("No alert with id %q in the selected range", args[0])
+		}
+
+		if len(res.Alerts) == 0 {
+			log.Println("No matching alerts found.")
+			return
+		}
+
+		fmt.Printf("%-20s %-9s %-14s %-10s %-16s %s\n", "TIME", "SEVERITY", "STATUS", "ASSIGNEE", "INCIDENT", "ID")
+		for _, alert := range res.Alerts {
+			t := alert.Triage
+			fmt.Printf("%-20s %-9s %-14s %-10s %-16s %s\n", alert.Timestamp.AsTime().Format(time.RFC3339),
+				alert.Severity, t.Status, orDash(t.Assignee), orDash(t.IncidentId), alert.Id)
+		}
+	},
+}
+
+var alertUpdateCmd = &cobra.Command{
+	Use:   "update <id>",
+	Short: "Change an alert's status, assignee or tags, add a note, or merge it into an incident",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		req := &pb.UpdateAlertRequest{Id: args[0]}
+		req.Status, _ = cmd.Flags().GetString("status")
+		req.Assignee = assigneeFlag(cmd)
+		req.AddTags, _ = cmd.Flags().GetStringSlice("tag")
+		req.RemoveTags, _ = cmd.Flags().GetStringSlice("untag")
+		req.Note, _ = cmd.Flags().GetString("note")
+		req.IncidentId, _ = cmd.Flags().GetString("incident")
+
+		c, conn := connect()
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		alert, err := c.UpdateAlert(ctx, req)
+		if err != nil {
+			log.Fatalf("Could not update alert: %v", err)
+		}
+		printAlert(alert)
+	},
+}
+
+var incidentsCmd = &cobra.Command{
+	Use:   "incidents [id]",
+	Short: "List incidents, or show one incident's case",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		status, _ := cmd.Flags().GetString("status")
+		assignee, _ := cmd.Flags().GetString("assignee")
+		limit, _ := cmd.Flags().GetInt32("limit")
+
+		c, conn := connect()
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		res, err := c.ListIncidents(ctx, &pb.ListIncidentsRequest{Status: status, Assignee: assignee, Limit: limit})
+		if err != nil {
+			log.Fatalf("Could not list incidents: %v", err)
+		}
+
+		if len(args) == 1 {
+			for _, inc := range res.Incidents {
+				if inc.Id == args[0] {
+					printIncident(inc)
+					return
+				}
+			}
+			log.Fatalf("No incident with id %q", args[0])
+		}
+
+		if len(res.Incidents) == 0 {
+			log.Println("No matching incidents found.")
+			return
+		}
+
+		fmt.Printf("%-16s %-9s %-14s %-10s %6s  %s\n", "ID", "SEVERITY", "STATUS", "ASSIGNEE", "ALERTS", "TITLE")
+		for _, inc := range res.Incidents {
+			fmt.Printf("%-16s %-9s %-14s %-10s %6d  %s\n", inc.Id, inc.Severity, inc.Triage.Status,
+				orDash(inc.Triage.Assignee), len(inc.AlertIds), inc.Title)
+		}
+	},
+}
+
+var incidentCreateCmd = &cobra.Command{
+	Use:   "create <alert-id>...",
+	Short: "Merge related alerts into a new incident",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		req := &pb.CreateIncidentRequest{AlertIds: args}
+		req.Title, _ = cmd.Flags().GetString("title")
+		req.Assignee, _ = cmd.Flags().GetString("assign")
+		req.Tags, _ = cmd.Flags().GetStringSlice("tag")
+		req.Note, _ = cmd.Flags().GetString("note")
+
+		c, conn := connect()
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		inc, err := c.CreateIncident(ctx, req)
+		if err != nil {
+			log.Fatalf("Could not create incident: %v", err)
+		}
+		printIncident(inc)
+	},
+}
+
+var incidentUpdateCmd = &cobra.Command{
+	Use:   "update <id>",
+	Short: "Change an incident's status, assignee or tags, or add a note",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		req := &pb.UpdateIncidentRequest{Id: args[0]}
+		req.Status, _ = cmd.Flags().GetString("status")
+		req.Assignee = assigneeFlag(cmd)
+		req.AddTags, _ = cmd.Flags().GetStringSlice("tag")
+		req.RemoveTags, _ = cmd.Flags().GetStringSlice("untag")
+		req.Note, _ = cmd.Flags().GetString("note")
+
+		c, conn := connect()
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		inc, err := c.UpdateIncident(ctx, req)
+		if err != nil {
+			log.Fatalf("Could not update incident: %v", err)
+		}
+		printIncident(inc)
+	},
+}
+
+// assigneeFlag reads --assign and --unassign into an optional assignee.
+func assigneeFlag(cmd *cobra.Command) *string {
+	if unassign, _ := cmd.Flags().GetBool("unassign"); unassign {
+		none := ""
+		return &none
+	}
+	if cmd.Flags().Changed("assign") {
+		assignee, _ := cmd.Flags().GetString("assign")
+		return &assignee
+	}
+	return nil
+}
+
+var riskCmd = &cobra.Command{
+	Use:   "risk",
+	Short: "List users, source IPs and hosts by accumulated risk",
+	Run: func(cmd *cobra.Command, args []string) {
+		entityType, _ := cmd.Flags().GetString("type")
+		minScore, _ := cmd.Flags().GetFloat64("min-score")
+		limit, _ := cmd.Flags().GetInt32("limit")
+		verbose, _ := cmd.Flags().GetBool("alerts")
+
+		c, conn := connect()
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		res, err := c.GetRiskyEntities(ctx, &pb.RiskyEntitiesRequest{EntityType: entityType, MinScore: minScore, Limit: limit})
+		if err != nil {
+			log.Fatalf("Could not get risky entities: %v", err)
+		}
+
+		if len(res.Entities) == 0 {
+			log.Println("No risky entities found.")
+			return
+		}
+
+		fmt.Printf("%-5s %-32s %7s %6s  %s\n", "TYPE", "ENTITY", "SCORE", "ALERTS", "LAST ALERT")
+		for _, e := range res.Entities {
+			color := ""
+			if e.Score >= res.Threshold {
+				color = colorRed
+			}
+			fmt.Printf("%s%-5s %-32s %7.1f %6d  %s%s\n", color, e.EntityType, e.Entity, e.Score, len(e.Alerts),
+				e.UpdatedAt.AsTime().Format(time.RFC3339), colorReset)
+			if !verbose {
+				continue
+			}
+			for _, a := range e.Alerts {
+				fmt.Printf("      %s%s %+6.1f [%s] %s%s  %s\n", colorDim, a.Time.AsTime().Format(time.RFC3339), a.Score,
+					a.Severity, a.RuleName, colorReset, a.AlertId)
+			}
+		}
+		fmt.Printf("\n%sThreshold: %.0f%s\n", colorDim, res.Threshold, colorReset)
+	},
+}
+
+var actionsCmd = &cobra.Command{
+	Use:   "actions",
+	Short: "List the response actions alerts triggered and their outcomes",
+	Run: func(cmd *cobra.Command, args []string) {
+		req := &pb.ListResponseActionsRequest{}
+		req.AlertId, _ = cmd.Flags().GetString("alert")
+		req.ActionId, _ = cmd.Flags().GetString("action")
+		req.Status, _ = cmd.Flags().GetString("status")
+		req.Limit, _ = cmd.Flags().GetInt32("limit")
+
+		c, conn := connect()
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		res, err := c.ListResponseActions(ctx, req)
+		if err != nil {
+			log.Fatalf("Could not list response actions: %v", err)
+		}
+
+		if len(res.Actions) == 0 {
+			log.Println("No matching response actions found.")
+			return
+		}
+
+		fmt.Printf("%-16s %-20s %-16s %-20s  %s\n", "ID", "ACTION", "STATUS", "CREATED", "TARGET")
+		for _, a := range res.Actions {
+			color := ""
+			switch a.Status {
+			case "failed":
+				color = colorRed
+			case "dry-run", "rate-limited", "rejected":
+				color = colorDim
+			}
+			fmt.Printf("%s%-16s %-20s %-16s %-20s  %s%s\n", color, a.Id, a.ActionId, a.Status,
+				a.CreatedAt.AsTime().Format(time.RFC3339), a.Target, colorReset)
+			fmt.Printf("  %salert %s (%s, %s)%s\n", colorDim, a.AlertId, a.RuleName, a.Severity, colorReset)
+			if a.Error != "" {
+				fmt.Printf("  %serror: %s%s\n", colorRed, a.Error, colorReset)
+			}
+		}
+	},
+}
+
+var actionApproveCmd = &cobra.Command{
+	Use:   "approve <id>",
+	Short: "Run a response action that is waiting for approval",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		decideAction(cmd, args[0], true)
+	},
+}
+
+var actionRejectCmd = &cobra.Command{
+	Use:   "reject <id>",
+	Short: "Cancel a response action that is waiting for approval",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		decideAction(cmd, args[0], false)
+	},
+}
+
+func decideAction(cmd *cobra.Command, id string, approve bool) {
+	note, _ := cmd.Flags().GetString("note")
+
+	c, conn := connect()
+	defer conn.Close()
+
+	// an approved action runs before the call returns, so allow for its
+	// timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	req := &pb.ResponseActionDecision{Id: id, Note: note}
+	var res *pb.ResponseAction
+	var err error
+	if approve {
+		res, err = c.ApproveResponseAction(ctx, req)
+	} else {
+		res, err = c.RejectResponseAction(ctx, req)
+	}
+	if err != nil {
+		log.Fatalf("Could not decide on response action: %v", err)
+	}
+
+	fmt.Printf("%s (%s): %s\n", res.ActionId, res.Id, res.Status)
+	fmt.Printf("  Target:   %s\n", res.Target)
+	if res.Output != "" {
+		fmt.Printf("  Output:   %s\n", strings.TrimSpace(res.Output))
+	}
+	if res.Error != "" {
+		fmt.Printf("  %sError:    %s%s\n", colorRed, res.Error, colorReset)
+	}
+}
+
+func printRule(rule *pb.RuleInfo) {
+	fmt.Printf("%s (%s v%d)\n", rule.Name, rule.Id, rule.Version)
+	fmt.Printf("  Kind:     %s\n", rule.Kind)
+	fmt.Printf("  Author:   %s\n", rule.Author)
+	fmt.Printf("  Mode:     %s\n", rule.Mode)
+	if rule.Replaces != "" {
+		fmt.Printf("  Replaces: %s\n", rule.Replaces)
+	}
+	if rule.Severity != rule.DefaultSeverity {
+		fmt.Printf("  Severity: %s (overridden, default %s)\n", rule.Severity, rule.DefaultSeverity)
+	} else {
+		fmt.Printf("  Severity: %s\n", rule.Severity)
+	}
+	if rule.RiskWeight != 1 {
+		fmt.Printf("  Risk:     weight %g\n", rule.RiskWeight)
+	}
+	if rule.Description != "" {
+		fmt.Printf("\n%s\n", rule.Description)
+	}
+	printList("Techniques", rule.Techniques)
+	printList("Tags", rule.Tags)
+	printList("References", rule.References)
+	printList("False positives", rule.FalsePositives)
+}
+
+func printList(title string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	fmt.Printf("\n%s:\n", title)
+	for _, v := range values {
+		fmt.Printf("  - %s\n", v)
+	}
+}
+
+func printWeighted(title string, values []*pb.WeightedValue) {
+	if len(values) == 0 {
+		return
+	}
+	fmt.Printf("\n%s:\n", title)
+	for _, v := range values {
+		fmt.Printf("  %8.1f  %s\n", v.Weight, v.Value)
+	}
+}
+
+func printAlert(alert *pb.TriagedAlert) {
+	fmt.Printf("[%s] %s (%s)\n", alert.Severity, alert.RuleName, alert.Id)
+	fmt.Printf("  Time:     %s\n", alert.Timestamp.AsTime().Format(time.RFC3339))
+	fmt.Printf("  Source:   %s\n", alert.Source)
+	fmt.Printf("  Rule:     %s v%d\n", alert.RuleId, alert.RuleVersion)
+	if alert.RiskScore > 0 {
+		fmt.Printf("  Risk:     %.0f\n", alert.RiskScore)
+	}
+	if alert.Message != "" {
+		fmt.Printf("\n%s\n", alert.Message)
+	}
+	fmt.Println()
+	printTriage(alert.Triage)
+
+	if len(alert.Events) > 0 {
+		fmt.Printf("\nEvents:\n")
+		for _, event := range alert.Events {
+			fmt.Printf("  %s  %s  %s\n", event.Timestamp.AsTime().Format(time.RFC3339), event.EventType, orDash(event.Input))
+			if event.Raw != "" {
+				fmt.Printf("    %s\n", event.Raw)
+			}
+		}
+	}
+	if len(alert.Ancestry) > 0 {
+		// oldest ancestor first, down to the alerting process
+		fmt.Printf("\nProcess ancestry:\n")
+		for i := range alert.Ancestry {
+			proc := alert.Ancestry[len(alert.Ancestry)-1-i]
+			fmt.Printf("  %s%s (%s): %s\n", strings.Repeat("  ", i), proc.ProcessName, proc.Pid, proc.Command)
+		}
+	}
+}
+
+func printIncident(inc *pb.Incident) {
+	fmt.Printf("[%s] %s (%s)\n", inc.Severity, inc.Title, inc.Id)
+	fmt.Printf("  Created:  %s by %s\n", inc.CreatedAt.AsTime().Format(time.RFC3339), inc.CreatedBy)
+	printTriage(inc.Triage)
+	printList("Rules", inc.Rules)
+	printList("Alerts", inc.AlertIds)
+}
+
+func printTriage(t *pb.Triage) {
+	fmt.Printf("  Status:   %s\n", t.Status)
+	fmt.Printf("  Assignee: %s\n", orDash(t.Assignee))
+	if t.IncidentId != "" {
+		fmt.Printf("  Incident: %s\n", t.IncidentId)
+	}
+	if len(t.Tags) > 0 {
+		fmt.Printf("  Tags:     %s\n", strings.Join(t.Tags, ", "))
+	}
+	if len(t.Notes) > 0 {
+		fmt.Printf("\nNotes:\n")
+		for _, note := range t.Notes {
+			fmt.Printf("  %s%s %s:%s %s\n", colorDim, note.Time.AsTime().Format(time.RFC3339), note.Author, colorReset, note.Text)
+		}
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&serverAddr, "addr", "localhost:50051", "The server address in the format of host:port")
+	rootCmd.PersistentFlags().BoolVar(&useTLS, "tls", false, "Connect using TLS (implied by --ca, --cert and --key)")
+	rootCmd.PersistentFlags().StringVar(&certPath, "cert", "", "Client certificate for mTLS authentication")
+	rootCmd.PersistentFlags().StringVar(&keyPath, "key", "", "Client private key for mTLS authentication")
+	rootCmd.PersistentFlags().StringVar(&caPath, "ca", "", "CA certificate used to verify the server (defaults to the system roots)")
+	rootCmd.PersistentFlags().StringVar(&authToken, "token", os.Getenv("NOX_TOKEN"), "Bearer token for authentication (defaults to $NOX_TOKEN)")
+	rootCmd.MarkFlagsRequiredTogether("cert", "key")
+	searchCmd.Flags().String("start-time", "", "Start time in RFC3339 format")
+	searchCmd.Flags().String("end-time", "", "End time in RFC3339 format")
+	searchCmd.Flags().StringToString("filter", nil, "Metadata filters (e.g., --filter process_name=bash)")
+	topCmd.Flags().Int32P("n", "n", 10, "The number of top results to return")
+	timelineCmd.Flags().String("user", "", "Username to build the timeline for")
+	timelineCmd.Flags().String("ip", "", "Source IP address to build the timeline for")
+	timelineCmd.Flags().String("host", "", "Host name to build the timeline for")
+	timelineCmd.Flags().Int32("limit", 500, "Maximum number of entries to return")
+	timelineCmd.Flags().Duration("gap", 30*time.Minute, "Mark quiet periods longer than this duration")
+	timelineCmd.Flags().String("start-time", "", "Start time in RFC3339 format")
+	timelineCmd.Flags().String("end-time", "", "End time in RFC3339 format")
+	timelineCmd.MarkFlagsOneRequired("user", "ip", "host")
+	timelineCmd.MarkFlagsMutuallyExclusive("user", "ip", "host")
+	profileCmd.Flags().String("user", "", "Username whose baseline to show")
+	profileCmd.Flags().String("host", "", "Host whose baseline to show")
+	profileCmd.MarkFlagsOneRequired("user", "host")
+	profileCmd.MarkFlagsMutuallyExclusive("user", "host")
+	rulesCmd.Flags().String("kind", "", "Only rules of this kind: yaml, stateful or correlation")
+	rulesCmd.Flags().String("tag", "", "Only rules with this tag")
+	rulesCmd.Flags().String("technique", "", "Only rules mapped to this ATT&CK technique ID")
+	rulesCmd.Flags().Bool("enabled-only", false, "Leave out disabled rules")
+	ruleApplyCmd.Flags().StringP("file", "f", "", "YAML file with the rules to apply")
+	ruleApplyCmd.MarkFlagRequired("file")
+	ruleShadowReportCmd.Flags().Duration("since", 7*24*time.Hour, "Count alerts from this far back")
+	rulesCmd.AddCommand(ruleEnableCmd, ruleDisableCmd, ruleModeCmd, ruleApplyCmd, ruleDeleteCmd, ruleShadowReportCmd)
+	alertsCmd.Flags().String("status", "", "Only alerts with this status: new, acknowledged, in-progress, false-positive or resolved")
+	alertsCmd.Flags().String("assignee", "", "Only alerts assigned to this analyst")
+	alertsCmd.Flags().String("rule", "", "Only alerts raised by this rule ID")
+	alertsCmd.Flags().String("incident", "", "Only alerts merged into this incident")
+	alertsCmd.Flags().Duration("since", 24*time.Hour, "Only alerts from this far back, 0 for all")
+	alertsCmd.Flags().Int32("limit", 50, "Maximum number of alerts to return")
+	incidentsCmd.Flags().String("status", "", "Only incidents with this status")
+	incidentsCmd.Flags().String("assignee", "", "Only incidents assigned to this analyst")
+	incidentsCmd.Flags().Int32("limit", 50, "Maximum number of incidents to return")
+	for _, c := range []*cobra.Command{alertUpdateCmd, incidentUpdateCmd} {
+		c.Flags().String("status", "", "New status: new, acknowledged, in-progress, false-positive or resolved")
+		c.Flags().String("assign", "", "Assign to this analyst")
+		c.Flags().Bool("unassign", false, "Remove the assignee")
+		c.Flags().StringSlice("tag", nil, "Tags to add")
+		c.Flags().StringSlice("untag", nil, "Tags to remove")
+		c.Flags().String("note", "", "Note to add")
+		c.MarkFlagsMutuallyExclusive("assign", "unassign")
+	}
+	alertUpdateCmd.Flags().String("incident", "", "Merge the alert into this incident")
+	incidentCreateCmd.Flags().String("title", "", "Incident title, defaults to the most severe alert's rule and source")
+	incidentCreateCmd.Flags().String("assign", "", "Assign to this analyst")
+	incidentCreateCmd.Flags().StringSlice("tag", nil, "Tags to add")
+	incidentCreateCmd.Flags().String("note", "", "Note to add")
+	riskCmd.Flags().String("type", "", "Only entities of this type: user, ip or host")
+	riskCmd.Flags().Float64("min-score", 1, "Leave out entities with less risk")
+	riskCmd.Flags().Int32("limit", 20, "Maximum number of entities to return")
+	riskCmd.Flags().Bool("alerts", false, "List the alerts that make up each entity's risk")
+	actionsCmd.Flags().String("alert", "", "Only actions triggered by this alert ID")
+	actionsCmd.Flags().String("action", "", "Only runs of this action ID")
+	actionsCmd.Flags().String("status", "", "Only actions with this status, e.g. pending-approval or failed")
+	actionsCmd.Flags().Int32("limit", 50, "Maximum number of actions to return")
+	actionApproveCmd.Flags().String("note", "", "Why the action was approved")
+	actionRejectCmd.Flags().String("note", "", "Why the action was rejected")
+	actionsCmd.AddCommand(actionApproveCmd, actionRejectCmd)
+	alertsCmd.AddCommand(alertUpdateCmd)
+	incidentsCmd.AddCommand(incidentCreateCmd, incidentUpdateCmd)
+	rootCmd.AddCommand(searchCmd)
+	rootCmd.AddCommand(ancestryCmd)
+	rootCmd.AddCommand(topCmd)
+	rootCmd.AddCommand(timelineCmd)
+	rootCmd.AddCommand(profileCmd)
+	rootCmd.AddCommand(rulesCmd)
+	rootCmd.AddCommand(alertsCmd)
+	rootCmd.AddCommand(incidentsCmd)
+	rootCmd.AddCommand(riskCmd)
+	rootCmd.AddCommand(actionsCmd)
+}
+
+func connect() (pb.NoxServiceClient, *grpc.ClientConn) {
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+
+	secure := useTLS || caPath != "" || certPath != ""
+	if secure {
+		tlsConfig, err := clientTLSConfig()
+		if err != nil {
+			log.Fatalf("Invalid TLS configuration: %v", err)
+		}
+		opts[0] = grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
+	}
+
+	if authToken != "" {
+		if !secure {
+			log.Println("Warning: sending bearer token without TLS.")
+		}
+		opts = append(opts, grpc.WithPerRPCCredentials(tokenCredentials{token: authToken, secure: secure}))
+	}
+
+	conn, err := grpc.NewClient(serverAddr, opts...)
+	if err != nil {
+		log.Fatalf("Did not connect: %v", err)
+	}
+
+	return pb.NewNoxServiceClient(conn), conn
+}
+
+func clientTLSConfig() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caPath != "" {
+		pem, err := os.ReadFile(caPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caPath)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if certPath != "" {
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// tokenCredentials attaches a bearer token to every RPC.
+type tokenCredentials struct {
+	token  string
+	secure bool
+}
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + t.token}, nil
+}
+
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return t.secure
+}
+
+func main() {
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
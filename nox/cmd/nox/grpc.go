@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"nox/internal/server"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// apiMiddleware is shared by the gRPC server and the REST gateway so both
+// paths are secured, audited and limited the same way.
+type apiMiddleware struct {
+	tlsConfig *tls.Config
+	unary     []grpc.UnaryServerInterceptor
+	stream    []grpc.StreamServerInterceptor
+}
+
+func (m *apiMiddleware) serverOptions() []grpc.ServerOption {
+	var opts []grpc.ServerOption
+	if m.tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(m.tlsConfig)))
+	}
+
+	return append(opts,
+		grpc.ChainUnaryInterceptor(m.unary...),
+		grpc.ChainStreamInterceptor(m.stream...),
+	)
+}
+
+// newAPIMiddleware configures transport security, authentication, auditing
+// and query limits for the hunting API. With no certificate and no clients
+// file the API runs in plaintext without auth and refuses admin methods,
+// unless Insecure opens them too for local testing.
+func newAPIMiddleware(cfg GRPCConfig, logger, auditLogger *slog.Logger) (*apiMiddleware, error) {
+	m := &apiMiddleware{}
+
+	// metrics come first so rejected requests are counted with their code.
+	unary := []grpc.UnaryServerInterceptor{server.MetricsUnaryInterceptor()}
+	stream := []grpc.StreamServerInterceptor{server.MetricsStreamInterceptor()}
+
+	if cfg.TLSCertPath != "" {
+		tlsConfig, err := server.LoadServerTLSConfig(cfg.TLSCertPath, cfg.TLSKeyPath, cfg.ClientCAPath, cfg.RequireClientCert)
+		if err != nil {
+			return nil, err
+		}
+		m.tlsConfig = tlsConfig
+	} else if cfg.ClientCAPath != "" {
+		return nil, fmt.Errorf("client certificate auth requires a server certificate")
+	}
+
+	if cfg.ClientsPath == "" && cfg.ClientCAPath == "" {
+		if cfg.Insecure {
+			logger.Warn("gRPC authentication disabled, the hunting API and rule management are open to anyone who can reach them")
+		} else {
+			logger.Warn("gRPC authentication not configured, serving read-only queries and refusing admin methods")
+			guard := server.NewReadOnlyGuard(logger)
+			unary = append(unary, guard.UnaryInterceptor())
+			stream = append(stream, guard.StreamInterceptor())
+		}
+	} else {
+		var clients []server.ClientIdentity
+		if cfg.ClientsPath != "" {
+			var err error
+			clients, err = server.LoadClientIdentitiesFromFile(cfg.ClientsPath)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if cfg.TLSCertPath == "" {
+			logger.Warn("gRPC bearer tokens are sent in plaintext, configure NOX_GRPC_TLS_CERT to protect them")
+		}
+
+		authenticator := server.NewAuthenticator(clients, logger)
+		unary = append(unary, authenticator.UnaryInterceptor())
+		stream = append(stream, authenticator.StreamInterceptor())
+	}
+
+	// audit runs after auth so records carry the caller's identity, and
+	// before the limits so rejected queries are recorded too.
+	audit := server.NewAuditLogger(auditLogger)
+	unary = append(unary, audit.UnaryInterceptor())
+	stream = append(stream, audit.StreamInterceptor())
+
+	if cfg.RateLimit > 0 {
+		limiter := server.NewRateLimiter(cfg.RateLimit, cfg.RateBurst)
+		unary = append(unary, limiter.UnaryInterceptor())
+		stream = append(stream, limiter.StreamInterceptor())
+	}
+
+	unary = append(unary, cfg.Limits.UnaryInterceptor())
+	stream = append(stream, cfg.Limits.StreamInterceptor())
+
+	m.unary, m.stream = unary, stream
+	return m, nil
+}
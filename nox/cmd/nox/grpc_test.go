@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAPIMiddlewareWithoutAuth(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name     string
+		insecure bool
+		wantCode codes.Code
+	}{
+		{name: "admin methods refused by default", wantCode: codes.PermissionDenied},
+		{name: "admin methods open when insecure", insecure: true, wantCode: codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := newAPIMiddleware(GRPCConfig{Insecure: tt.insecure}, logger, logger)
+			if err != nil {
+				t.Fatalf("newAPIMiddleware: %v", err)
+			}
+
+			// run the request through the chain the way grpc.ChainUnaryInterceptor does.
+			info := &grpc.UnaryServerInfo{FullMethod: "/nox.NoxService/DeleteRule"}
+			handler := func(ctx context.Context, req any) (any, error) { return nil, nil }
+			for i := len(m.unary) - 1; i >= 0; i-- {
+				next, interceptor := handler, m.unary[i]
+				handler = func(ctx context.Context, req any) (any, error) {
+					return interceptor(ctx, req, info, next)
+				}
+			}
+
+			_, err = handler(context.Background(), nil)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("got code %s, want %s", code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	pb "nox/proto"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	healthCheckInterval = 10 * time.Second
+	healthCheckTimeout  = 2 * time.Second
+)
+
+var (
+	elasticsearchUp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "nox_elasticsearch_up",
+		Help: "Whether Elasticsearch answered the last health check (1) or not (0).",
+	})
+
+	ingesterUp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "nox_ingester_up",
+		Help: "Whether the log ingester is running (1) or has stopped (0).",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(elasticsearchUp)
+	prometheus.MustRegister(ingesterUp)
+}
+
+// monitorHealth keeps the gRPC health service in sync with the dependencies
+// nox needs to do useful work: Elasticsearch must answer pings and the
+// ingester must still be tailing its input.
+func (n *Nox) monitorHealth(ctx context.Context, hs *health.Server) {
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	hs.SetServingStatus(pb.NoxService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		ticker := time.NewTicker(healthCheckInterval)
+		defer ticker.Stop()
+
+		serving := false
+		for {
+			serving = n.checkHealth(ctx, hs, serving)
+
+			select {
+			case <-ctx.Done():
+				hs.Shutdown()
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// checkHealth probes the dependencies once and publishes the result,
+// logging when it differs from the previous one.
+func (n *Nox) checkHealth(ctx context.Context, hs *health.Server, wasServing bool) bool {
+	esReady := n.pingElasticsearch(ctx)
+	ingesting := n.ingesting.Load()
+	elasticsearchUp.Set(boolToFloat(esReady))
+	ingesterUp.Set(boolToFloat(ingesting))
+
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if esReady && ingesting {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+
+	serving := status == healthpb.HealthCheckResponse_SERVING
+	if serving != wasServing {
+		n.Logger.Info("Health status changed", "status", status.String(),
+			"elasticsearch_up", esReady, "ingester_up", ingesting)
+	}
+
+	hs.SetServingStatus("", status)
+	hs.SetServingStatus(pb.NoxService_ServiceDesc.ServiceName, status)
+	return serving
+}
+
+func (n *Nox) pingElasticsearch(ctx context.Context) bool {
+	pingCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	res, err := n.ESClient.Client.Ping(n.ESClient.Client.Ping.WithContext(pingCtx))
+	if err != nil {
+		return false
+	}
+	defer res.Body.Close()
+
+	return !res.IsError()
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
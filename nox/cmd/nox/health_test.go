@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"nox/internal/storage"
+	pb "nox/proto"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// newHealthNox returns a Nox whose Elasticsearch answers pings with code.
+func newHealthNox(t *testing.T, code int, ingesting bool) *Nox {
+	t.Helper()
+
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(es.Close)
+
+	client, err := storage.NewESClient(es.URL)
+	if err != nil {
+		t.Fatalf("NewESClient: %v", err)
+	}
+
+	n := &Nox{Logger: slog.New(slog.NewTextHandler(io.Discard, nil)), ESClient: client}
+	n.ingesting.Store(ingesting)
+	return n
+}
+
+func servingStatus(t *testing.T, hs *health.Server, service string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+
+	resp, err := hs.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatalf("Check(%q): %v", service, err)
+	}
+	return resp.Status
+}
+
+func TestCheckHealth(t *testing.T) {
+	tests := []struct {
+		name      string
+		esCode    int
+		ingesting bool
+		want      healthpb.HealthCheckResponse_ServingStatus
+		esUp      float64
+		ingestUp  float64
+	}{
+		{name: "healthy", esCode: http.StatusOK, ingesting: true, want: healthpb.HealthCheckResponse_SERVING, esUp: 1, ingestUp: 1},
+		{name: "elasticsearch down", esCode: http.StatusInternalServerError, ingesting: true, want: healthpb.HealthCheckResponse_NOT_SERVING, esUp: 0, ingestUp: 1},
+		{name: "ingester stopped", esCode: http.StatusOK, ingesting: false, want: healthpb.HealthCheckResponse_NOT_SERVING, esUp: 1, ingestUp: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newHealthNox(t, tt.esCode, tt.ingesting)
+			hs := health.NewServer()
+
+			serving := n.checkHealth(context.Background(), hs, false)
+			if serving != (tt.want == healthpb.HealthCheckResponse_SERVING) {
+				t.Fatalf("got serving %v, want status %s", serving, tt.want)
+			}
+
+			for _, service := range []string{"", pb.NoxService_ServiceDesc.ServiceName} {
+				if got := servingStatus(t, hs, service); got != tt.want {
+					t.Fatalf("got %q status %s, want %s", service, got, tt.want)
+				}
+			}
+
+			if got := testutil.ToFloat64(elasticsearchUp); got != tt.esUp {
+				t.Fatalf("got nox_elasticsearch_up %v, want %v", got, tt.esUp)
+			}
+			if got := testutil.ToFloat64(ingesterUp); got != tt.ingestUp {
+				t.Fatalf("got nox_ingester_up %v, want %v", got, tt.ingestUp)
+			}
+		})
+	}
+}
+
+func TestMonitorHealthShutsDownWithContext(t *testing.T) {
+	n := newHealthNox(t, http.StatusOK, true)
+	hs := health.NewServer()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n.monitorHealth(ctx, hs)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for servingStatus(t, hs, pb.NoxService_ServiceDesc.ServiceName) != healthpb.HealthCheckResponse_SERVING {
+		if time.Now().After(deadline) {
+			t.Fatalf("got NOT_SERVING, want SERVING once Elasticsearch answers and the ingester runs")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	n.wg.Wait()
+
+	if got := servingStatus(t, hs, pb.NoxService_ServiceDesc.ServiceName); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("got %s after shutdown, want NOT_SERVING", got)
+	}
+}
@@ -6,31 +6,40 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
+	"net/netip"
+	"nox/internal/baseline"
+	"nox/internal/enrich"
+	"nox/internal/eventtime"
 	"nox/internal/ingester"
+	"nox/internal/intel"
 	"nox/internal/model"
+	"nox/internal/pipeline"
+	"nox/internal/response"
 	"nox/internal/rules"
 	"nox/internal/server"
 	"nox/internal/storage"
 	"os"
 	"os/signal"
+	"runtime"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
-	"github.com/oschwald/geoip2-golang"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 
 	pb "nox/proto"
 )
 
 var (
-	eventsProcessedTotal = prometheus.NewCounter(prometheus.CounterOpts{
-		Name: "nox_events_processed_total",
-		Help: "Total number of events processed by the engine.",
-	})
-
 	alertsTriggeredTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "nox_alerts_triggered_total",
 		Help: "Total number of alerts triggered.",
@@ -40,14 +49,28 @@ var (
 		Name: "nox_alerts_by_severity_total",
 		Help: "Total number of alerts by severity",
 	}, []string{"severity"})
+
+	alertsSuppressedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "nox_alerts_suppressed_total",
+		Help: "Total number of rule matches suppressed by an exception.",
+	}, []string{"rule_name", "exception"})
+
+	shadowAlertsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "nox_shadow_alerts_total",
+		Help: "Total number of would-be alerts from rules in shadow mode.",
+	}, []string{"rule_name"})
 )
 
-const shutdownTimeout = 5 * time.Second
+const (
+	shutdownTimeout  = 5 * time.Second
+	defaultStatePath = "data/state.json"
+)
 
 func init() {
-	prometheus.MustRegister(eventsProcessedTotal)
 	prometheus.MustRegister(alertsTriggeredTotal)
 	prometheus.MustRegister(alertsBySeverityTotal)
+	prometheus.MustRegister(alertsSuppressedTotal)
+	prometheus.MustRegister(shadowAlertsTotal)
 }
 
 type ESConfig struct {
@@ -55,69 +78,177 @@ type ESConfig struct {
 }
 
 type GRPCConfig struct {
-	Addr string
+	Addr              string
+	TLSCertPath       string
+	TLSKeyPath        string
+	ClientCAPath      string
+	RequireClientCert bool
+	ClientsPath       string
+	Insecure          bool // without auth, also serve admin methods
+	AuditLogPath      string
+	RateLimit         float64 // requests per second per client
+	RateBurst         int
+	Limits            server.QueryLimits
+}
+
+type IntelConfig struct {
+	Feeds           []string // file paths or http(s) URLs, optionally prefixed with a format
+	RefreshInterval time.Duration
+}
+
+type EventTimeConfig struct {
+	AllowedLateness time.Duration
+	IdleTimeout     time.Duration // inputs silent for longer stop holding the watermark back
 }
 
 type MetricsConfig struct {
-	Addr string
+	Addr        string
+	GatewayAddr string // REST gateway, when TLS moves it off Addr
 }
 type Config struct {
-	RulesPath     string
-	IntelPath     string
-	LogPath       string
-	GeoIPDBPath   string
-	Elasticsearch ESConfig
-	GRPC          GRPCConfig
-	Metrics       MetricsConfig
-	BufferSize    int
+	RulesPath      string
+	OverridesPath  string // per-rule enable and severity overrides
+	ExceptionsPath string
+	ActionsPath    string // automated response actions
+	Response       response.Config
+	// SlowRuleThreshold logs rules that take longer to evaluate one event.
+	SlowRuleThreshold time.Duration
+	Intel             IntelConfig
+	LogPaths          []string
+	Enrich            enrich.Config
+	Elasticsearch     ESConfig
+	GRPC              GRPCConfig
+	Metrics           MetricsConfig
+	State             StateConfig
+	Pipeline          pipeline.Config
+	EventTime         EventTimeConfig
+	ImpossibleTravel  rules.ImpossibleTravelConfig
+	Risk              rules.RiskConfig
+	Baseline          baseline.Config
+	BufferSize        int
 }
 
 type Nox struct {
 	Config     *Config
 	Logger     *slog.Logger
 	ESClient   *storage.ESClient
-	GeoIPDB    *geoip2.Reader
+	enrichers  *enrich.Chain
+	intel      *intel.Manager
+	responder  *response.Responder
 	RuleEngine *rules.Engine
+	state      *rules.StateManager
 	wg         sync.WaitGroup
 	ingester   *ingester.Ingester
+	ingesting  atomic.Bool
+	clock      *eventtime.Clock
+	apiServer  *server.NoxAPIServer
+	middleware *apiMiddleware
+	auditFile  *os.File
 }
 
 func NewNox(cfg *Config, logger *slog.Logger) (*Nox, error) {
-	db, err := geoip2.Open(cfg.GeoIPDBPath)
-	if err != nil {
-		return nil, fmt.Errorf("error opening GeoIP database: %w", err)
-	}
-
 	esClient, err := storage.NewESClient(cfg.Elasticsearch.URL)
 	if err != nil {
-		db.Close()
 		return nil, fmt.Errorf("could not crete Elasticsearch client: %w", err)
 	}
 
 	yamlRules, err := rules.LoadRulesFromFile(cfg.RulesPath)
 	if err != nil {
-		db.Close()
 		return nil, fmt.Errorf("could not load detection rules: %w", err)
 	}
+	// the loader is lenient, so surface anything that will silently misbehave.
+	if findings, err := rules.LintFile(cfg.RulesPath); err == nil {
+		for _, f := range findings {
+			if f.Severity == rules.LintError {
+				logger.Warn("Detection rule problem, run `nox rules lint` for details", "path", cfg.RulesPath, "line", f.Line, "rule", f.Rule, "problem", f.Message)
+			}
+		}
+	}
+
+	overrides, err := rules.LoadRuleOverrides(cfg.OverridesPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not load rule overrides: %w", err)
+	}
 
-	ipWatchlist, err := rules.LoadIPWatchlistFromFile(cfg.IntelPath)
+	exceptions, err := rules.LoadExceptions(cfg.ExceptionsPath)
 	if err != nil {
-		logger.Warn("failed to load IP watchlist", "error", err)
+		return nil, fmt.Errorf("could not load detection exceptions: %w", err)
+	}
+	for _, x := range exceptions {
+		if x.Expired(time.Now()) {
+			logger.Warn("Detection exception has expired, remove or renew it", "exception", x.ID, "expires", x.Expires)
+		}
 	}
 
-	stateManager := rules.NewStateManager()
-	stateManager.IPWatchlist.Set(ipWatchlist)
+	intelManager := intel.NewManager(intel.ParseFeeds(cfg.Intel.Feeds), logger)
+	// a failed feed is already logged; detection starts with whatever loaded.
+	intelManager.Refresh(context.Background())
 
-	ruleEngine := rules.NewEngine(logger, stateManager, yamlRules)
-	appIngester := ingester.NewIngester(logger)
+	actions, err := response.LoadActions(cfg.ActionsPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not load response actions: %w", err)
+	}
+	if cfg.Response.DryRun && len(actions) > 0 {
+		logger.Warn("Response actions are in dry-run mode, they are recorded but not run", "actions", len(actions))
+	}
+	responder := response.NewResponder(actions, cfg.Response, esClient, logger.With("component", "response"))
+
+	stateManager := rules.NewBoundedStateManager(cfg.State.MaxEntries)
+	stateManager.Baselines = baseline.NewProfiler(cfg.Baseline)
+	restoreState(cfg.State, stateManager, logger)
+	stateManager.ThreatIntel.Set(intelManager)
+
+	enrichers := enrich.New(cfg.Enrich, logger)
+	ruleEngine := rules.NewEngine(logger, stateManager, yamlRules,
+		rules.WithImpossibleTravel(cfg.ImpossibleTravel),
+		rules.WithRuleOverrides(overrides),
+		rules.WithExceptions(exceptions),
+		rules.WithSlowRuleThreshold(cfg.SlowRuleThreshold),
+		rules.WithRisk(cfg.Risk),
+		rules.WithProcessHistory(enrichers.ProcessTree()),
+	)
+	clock := eventtime.NewClock(cfg.EventTime.AllowedLateness, cfg.EventTime.IdleTimeout)
+	appIngester := ingester.NewIngester(logger, clock.Reference)
+
+	auditLogger, auditFile := logger.With("component", "audit"), (*os.File)(nil)
+	if cfg.GRPC.AuditLogPath != "" {
+		auditFile, err = os.OpenFile(cfg.GRPC.AuditLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("could not open audit log: %w", err)
+		}
+		auditLogger = slog.New(slog.NewJSONHandler(auditFile, nil))
+	}
+
+	middleware, err := newAPIMiddleware(cfg.GRPC, logger, auditLogger)
+	if err != nil {
+		if auditFile != nil {
+			auditFile.Close()
+		}
+		return nil, fmt.Errorf("could not configure gRPC server: %w", err)
+	}
+
+	apiServer := server.NewNoxAPIServer(esClient,
+		server.WithProfiles(stateManager.Baselines),
+		server.WithRules(ruleEngine),
+		server.WithRuleAdmin(rules.NewRuleManager(ruleEngine, cfg.RulesPath, cfg.OverridesPath)),
+		server.WithRisk(ruleEngine),
+		server.WithResponder(responder),
+	)
 
 	return &Nox{
 		Config:     cfg,
 		Logger:     logger,
 		ESClient:   esClient,
-		GeoIPDB:    db,
+		enrichers:  enrichers,
+		intel:      intelManager,
+		responder:  responder,
 		RuleEngine: ruleEngine,
+		state:      stateManager,
 		ingester:   appIngester,
+		clock:      clock,
+		apiServer:  apiServer,
+		middleware: middleware,
+		auditFile:  auditFile,
 	}, nil
 
 }
@@ -145,7 +276,7 @@ func (n *Nox) Run(ctx context.Context) error {
 		return fmt.Errorf("elasticsearch not available")
 	}
 
-	indices := []string{"process_executed", "sshd_accepted_password", "sshd_failed_password"}
+	indices := []string{"process_executed", "sshd_accepted_password", "sshd_failed_password", storage.AlertIndex, storage.SuppressedAlertIndex, storage.ShadowAlertIndex, storage.IncidentIndex, storage.ResponseActionIndex}
 
 	for _, index := range indices {
 		err := n.ESClient.EnsureIndex(ctx, index)
@@ -156,41 +287,35 @@ func (n *Nox) Run(ctx context.Context) error {
 
 	n.Logger.Info("Elasticsearch indices are ready.")
 
-	eventChannel := make(chan model.Event, n.Config.BufferSize)
+	lineChannel := make(chan ingester.Line, n.Config.BufferSize)
 	alertChannel := make(chan model.Alert, n.Config.BufferSize/2)
 
 	// --- Start Background Services ---
 	n.startMetricsServer(ctx)
 	n.startGRPCServer(ctx)
-	n.startFileIngester(ctx, eventChannel)
+	n.startFileIngester(ctx, lineChannel)
 	n.startAlertHandler(ctx, alertChannel)
+	n.startStateSnapshotter(ctx)
+	n.startStateJanitor(ctx)
+	n.startIntelRefresher(ctx)
+	n.startResponder(ctx)
 
 	n.Logger.Info("Nox IDS engine started",
 		"version", "0.1.0",
-		"log_file", n.Config.LogPath,
+		"log_files", n.Config.LogPaths,
 		"buffer_size", n.Config.BufferSize,
+		"shards", n.Config.Pipeline.Shards,
 	)
 
+	events := pipeline.New(n.Config.Pipeline, n.Logger, n.ingester, n.enrichers, n.RuleEngine, n.ESClient, n.clock)
+
 	n.wg.Add(1)
 	go func() {
 		defer n.wg.Done()
-		defer close(alertChannel)
-		n.Logger.Info("Event processor started.")
-
-		for {
-			select {
-			case event, ok := <-eventChannel:
-				if !ok {
-					n.Logger.Info("Event channel close, stopping event processor.")
-					return
-				}
-
-				n.processEvent(event, alertChannel, ctx)
-			case <-ctx.Done():
-				n.Logger.Info("Context cancelled, stopping event processor")
-				return
-			}
-		}
+		n.Logger.Info("Event pipeline started.")
+		// runs until the ingester closes the line channel, then drains.
+		events.Run(ctx, lineChannel, alertChannel)
+		n.Logger.Info("Event pipeline stopped.")
 	}()
 
 	n.wg.Wait()
@@ -199,32 +324,124 @@ func (n *Nox) Run(ctx context.Context) error {
 
 func (n *Nox) Stop() {
 	n.Logger.Info("Stopping Nox services")
-	if n.GeoIPDB != nil {
-		n.GeoIPDB.Close()
+	// the event processor has stopped by now, so this captures the final state.
+	n.saveState()
+	if err := n.enrichers.Close(); err != nil {
+		n.Logger.Warn("failed to close enrichment databases", "error", err)
+	}
+	if n.auditFile != nil {
+		n.auditFile.Close()
 	}
 
 	n.Logger.Info("Shutdown complete..")
 }
 
+var rootCmd = &cobra.Command{
+	Use:   "nox",
+	Short: "The nox intrusion detection and threat hunting engine.",
+	Long:  `Running nox without a subcommand starts the detection engine, gRPC API and metrics server.`,
+	// errors from subcommands are user errors, not usage mistakes.
+	SilenceUsage: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		serve()
+	},
+}
+
 func main() {
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func serve() {
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelDebug,
 	}))
 	slog.SetDefault(logger)
 
 	cfg := &Config{
-		RulesPath:   getEnv("NOX_RULES_PATH", "detections/rules.yaml"),
-		IntelPath:   getEnv("NOX_INTEL_PATH", "intel/ip_watchlist.txt"),
-		LogPath:     "testdata/auth.log",
-		GeoIPDBPath: "testdata/GeoLite2-City.mmdb",
+		RulesPath:         getEnv("NOX_RULES_PATH", "detections/rules.yaml"),
+		OverridesPath:     getEnv("NOX_RULE_OVERRIDES_PATH", "detections/overrides.yaml"),
+		ExceptionsPath:    getEnv("NOX_EXCEPTIONS_PATH", "detections/exceptions.yaml"),
+		ActionsPath:       getEnv("NOX_ACTIONS_PATH", "detections/actions.yaml"),
+		SlowRuleThreshold: getEnvDuration("NOX_SLOW_RULE_THRESHOLD", 5*time.Millisecond),
+		Intel: IntelConfig{
+			Feeds:           getEnvList("NOX_INTEL_FEEDS", []string{getEnv("NOX_INTEL_PATH", "intel/ip_watchlist.txt")}),
+			RefreshInterval: getEnvDuration("NOX_INTEL_REFRESH_INTERVAL", 15*time.Minute),
+		},
+		LogPaths: getEnvList("NOX_LOG_PATHS", []string{"testdata/auth.log"}),
+		Enrich: enrich.Config{
+			GeoIPCityPath:      getEnv("NOX_GEOIP_CITY_PATH", "testdata/GeoLite2-City.mmdb"),
+			GeoIPASNPath:       getEnv("NOX_GEOIP_ASN_PATH", ""),
+			AssetInventoryPath: getEnv("NOX_ASSET_INVENTORY_PATH", ""),
+			UserDirectoryPath:  getEnv("NOX_USER_DIRECTORY_PATH", ""),
+			ReverseDNS:         getEnv("NOX_REVERSE_DNS", "false") == "true",
+			ReverseDNSTTL:      getEnvDuration("NOX_REVERSE_DNS_TTL", time.Hour),
+		},
 		Elasticsearch: ESConfig{
 			URL: "http://elasticsearch:9200",
 		},
 		GRPC: GRPCConfig{
-			Addr: ":50051",
+			Addr:              ":50051",
+			TLSCertPath:       getEnv("NOX_GRPC_TLS_CERT", ""),
+			TLSKeyPath:        getEnv("NOX_GRPC_TLS_KEY", ""),
+			ClientCAPath:      getEnv("NOX_GRPC_CLIENT_CA", ""),
+			RequireClientCert: getEnv("NOX_GRPC_REQUIRE_CLIENT_CERT", "false") == "true",
+			ClientsPath:       getEnv("NOX_GRPC_CLIENTS_PATH", ""),
+			Insecure:          getEnv("NOX_GRPC_INSECURE", "false") == "true",
+			AuditLogPath:      getEnv("NOX_AUDIT_LOG_PATH", ""),
+			RateLimit:         getEnvFloat("NOX_GRPC_RATE_LIMIT", 5),
+			RateBurst:         getEnvInt("NOX_GRPC_RATE_BURST", 20),
+			Limits: server.QueryLimits{
+				MaxTimeRange: getEnvDuration("NOX_MAX_QUERY_RANGE", 30*24*time.Hour),
+				MaxResults:   int32(getEnvInt("NOX_MAX_QUERY_RESULTS", 1000)),
+				Timeout:      getEnvDuration("NOX_QUERY_TIMEOUT", 30*time.Second),
+			},
 		},
 		Metrics: MetricsConfig{
-			Addr: ":9090",
+			Addr:        ":9090",
+			GatewayAddr: getEnv("NOX_GATEWAY_ADDR", ":9443"),
+		},
+		State: StateConfig{
+			Path:             getEnv("NOX_STATE_PATH", defaultStatePath),
+			SnapshotInterval: getEnvDuration("NOX_STATE_SNAPSHOT_INTERVAL", time.Minute),
+			MaxAge:           getEnvDuration("NOX_STATE_MAX_AGE", time.Hour),
+			MaxEntries:       getEnvInt("NOX_STATE_MAX_ENTRIES", rules.DefaultMaxStateEntries),
+			JanitorInterval:  30 * time.Second,
+		},
+		Pipeline: pipeline.Config{
+			Shards:         getEnvInt("NOX_PIPELINE_SHARDS", runtime.GOMAXPROCS(0)),
+			ParseWorkers:   runtime.GOMAXPROCS(0),
+			PersistWorkers: getEnvInt("NOX_PERSIST_WORKERS", 4),
+			BufferSize:     1000,
+			Host:           getEnv("NOX_HOSTNAME", localHostname()),
+		},
+		EventTime: EventTimeConfig{
+			AllowedLateness: getEnvDuration("NOX_ALLOWED_LATENESS", 30*time.Second),
+			IdleTimeout:     getEnvDuration("NOX_INPUT_IDLE_TIMEOUT", 5*time.Minute),
+		},
+		ImpossibleTravel: rules.ImpossibleTravelConfig{
+			MaxSpeedKmh:     getEnvFloat("NOX_TRAVEL_MAX_SPEED_KMH", 1000),
+			MinDistanceKm:   getEnvFloat("NOX_TRAVEL_MIN_DISTANCE_KM", 500),
+			LearningPeriod:  getEnvDuration("NOX_TRAVEL_LEARNING_PERIOD", 7*24*time.Hour),
+			AllowedASNs:     getEnvList("NOX_TRAVEL_ALLOWED_ASNS", nil),
+			AllowedNetworks: getEnvPrefixes("NOX_TRAVEL_ALLOWED_NETWORKS"),
+		},
+		Risk: rules.RiskConfig{
+			Threshold:        getEnvFloat("NOX_RISK_THRESHOLD", 100),
+			HalfLife:         getEnvDuration("NOX_RISK_HALF_LIFE", 24*time.Hour),
+			MaxContributions: 20,
+		},
+		Response: response.Config{
+			DryRun: getEnv("NOX_RESPONSE_DRY_RUN", "false") == "true",
+		},
+		Baseline: baseline.Config{
+			HalfLife:        getEnvDuration("NOX_BASELINE_HALF_LIFE", 14*24*time.Hour),
+			LearningPeriod:  getEnvDuration("NOX_BASELINE_LEARNING_PERIOD", 7*24*time.Hour),
+			MinObservations: getEnvInt("NOX_BASELINE_MIN_OBSERVATIONS", 20),
+			MinScore:        getEnvFloat("NOX_BASELINE_MIN_SCORE", 0.7),
+			ExecRateZ:       4,
+			MaxEntities:     getEnvInt("NOX_STATE_MAX_ENTRIES", rules.DefaultMaxStateEntries),
 		},
 		BufferSize: 1000,
 	}
@@ -255,6 +472,16 @@ func main() {
 	slog.Info("Nox IDS engine stopped")
 }
 
+// localHostname returns the short name sshd logs this host under.
+func localHostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	name, _, _ = strings.Cut(name, ".")
+	return name
+}
+
 func getEnv(key, fallback string) string {
 	if value, ok := os.LookupEnv(key); ok {
 		return value
@@ -263,72 +490,94 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
-func generateAlertID(alert model.Alert) string {
-	return fmt.Sprintf("%s-%d-%s",
-		alert.RuleName,
-		alert.Timestamp.Unix(),
-		alert.Source,
-	)
-}
-
-// --- Nox Methods (Engine Logic) ---
-func (n *Nox) processEvent(event model.Event, alertChannel chan<- model.Alert, ctx context.Context) {
-	eventsProcessedTotal.Inc()
-
-	if event.Source != "localhost" && event.Source != "" {
-		ip := net.ParseIP(event.Source)
-		if ip != nil && !ip.IsPrivate() {
-			if record, err := n.GeoIPDB.City(ip); err == nil {
-				if event.Metadata == nil {
-					event.Metadata = make(map[string]string)
-				}
+// getEnvList splits a comma-separated variable, ignoring empty items.
+func getEnvList(key string, fallback []string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
 
-				// add geographic metadata
-				event.Metadata["country"] = record.Country.IsoCode
-				event.Metadata["country_name"] = record.Country.Names["en"]
-				if len(record.City.Names) > 0 {
-					event.Metadata["city"] = record.City.Names["en"]
-				}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
 
-				// add coordinates for geographic analysis
-				if record.Location.Latitude != 0 && record.Location.Longitude != 0 {
-					event.Metadata["latitude"] = fmt.Sprintf("%.4f", record.Location.Latitude)
-					event.Metadata["longitude"] = fmt.Sprintf("%.4f", record.Location.Longitude)
-				}
+	if len(items) == 0 {
+		return fallback
+	}
+	return items
+}
 
+// getEnvPrefixes reads a comma-separated list of IPs and CIDR ranges,
+// skipping and logging invalid items.
+func getEnvPrefixes(key string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, item := range getEnvList(key, nil) {
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(item)
+			if addrErr != nil {
+				slog.Warn("ignoring invalid network environment variable", "key", key, "value", item)
+				continue
 			}
+			prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
 		}
+		prefixes = append(prefixes, prefix.Masked())
 	}
+	return prefixes
+}
 
-	n.Logger.Debug("Processing Event",
-		"type", event.EventType,
-		"source", event.Source,
-		"timestamp", event.Timestamp,
-	)
+func getEnvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+		slog.Warn("ignoring invalid integer environment variable", "key", key, "value", value)
+	}
 
-	triggeredAlerts := n.RuleEngine.EvaluateEvent(event)
+	return fallback
+}
 
-	for _, alert := range triggeredAlerts {
-		select {
-		case alertChannel <- alert:
-			// alert sent successfully
-		default:
-			slog.Warn("Alert channel full, dropping alert",
-				"rule_name", alert.RuleName,
-				"source", alert.Source,
-			)
+func getEnvFloat(key string, fallback float64) float64 {
+	if value, ok := os.LookupEnv(key); ok {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
 		}
+		slog.Warn("ignoring invalid number environment variable", "key", key, "value", value)
 	}
 
-	err := n.ESClient.IndexEvent(ctx, event)
-	if err != nil {
-		n.Logger.Error(
-			"failed to persist event",
-			"error", err,
-			"event_type", event.EventType,
-			"source", event.Source,
-		)
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		slog.Warn("ignoring invalid duration environment variable", "key", key, "value", value)
 	}
+
+	return fallback
+}
+
+// --- Nox Methods (Engine Logic) ---
+
+func (n *Nox) startIntelRefresher(ctx context.Context) {
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		n.intel.Run(ctx, n.Config.Intel.RefreshInterval)
+	}()
+}
+
+func (n *Nox) startResponder(ctx context.Context) {
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		n.responder.Run(ctx)
+	}()
 }
 
 func (n *Nox) startAlertHandler(ctx context.Context, alertChan <-chan model.Alert) {
@@ -343,6 +592,23 @@ func (n *Nox) startAlertHandler(ctx context.Context, alertChan <-chan model.Aler
 					n.Logger.Info("Alert channel closed, stopping alert handler.")
 					return
 				}
+
+				if alert.Shadow {
+					shadowAlertsTotal.WithLabelValues(alert.RuleName).Inc()
+					n.Logger.Debug("Shadow rule matched", "rule_name", alert.RuleName, "source", alert.Source)
+					if err := n.ESClient.IndexAlert(ctx, alert.ID(), alert); err != nil {
+						n.Logger.Error("failed to persist shadow alert", "error", err, "rule_name", alert.RuleName)
+					}
+					continue
+				}
+				if alert.IsSuppressed() {
+					alertsSuppressedTotal.WithLabelValues(alert.RuleName, alert.ExceptionID).Inc()
+					n.Logger.Debug("Alert suppressed by exception", "rule_name", alert.RuleName, "exception", alert.ExceptionID, "source", alert.Source)
+					if err := n.ESClient.IndexAlert(ctx, alert.ID(), alert); err != nil {
+						n.Logger.Error("failed to persist suppressed alert", "error", err, "exception", alert.ExceptionID)
+					}
+					continue
+				}
 				alertsTriggeredTotal.WithLabelValues(alert.RuleName).Inc()
 				alertsBySeverityTotal.WithLabelValues(alert.Severity).Inc()
 
@@ -351,8 +617,9 @@ func (n *Nox) startAlertHandler(ctx context.Context, alertChan <-chan model.Aler
 					logLevel = slog.LevelError
 				}
 
+				alertID := alert.ID()
 				logger := n.Logger.With(
-					"alert_id", generateAlertID(alert),
+					"alert_id", alertID,
 					"rule_name", alert.RuleName,
 					"severity", alert.Severity,
 					"source", alert.Source,
@@ -365,6 +632,12 @@ func (n *Nox) startAlertHandler(ctx context.Context, alertChan <-chan model.Aler
 
 				logger.Log(ctx, logLevel, alert.Message)
 
+				if err := n.ESClient.IndexAlert(ctx, alertID, alert); err != nil {
+					n.Logger.Error("failed to persist alert", "error", err, "alert_id", alertID)
+				}
+
+				n.responder.Handle(ctx, alertID, alert)
+
 			case <-ctx.Done():
 				n.Logger.Info("Context cancelled, stopping alert handler.")
 				return
@@ -374,20 +647,49 @@ func (n *Nox) startAlertHandler(ctx context.Context, alertChan <-chan model.Aler
 }
 
 func (n *Nox) startMetricsServer(ctx context.Context) {
-	// set up server config
-	server := &http.Server{
-		Addr:    n.Config.Metrics.Addr,
-		Handler: promhttp.Handler(),
+	// /metrics stays plain HTTP so Prometheus can scrape it without a client
+	// certificate. The REST gateway shares the port until TLS is on, then
+	// moves to its own listener behind the gRPC server's TLS config.
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+	gateway := server.NewGateway(n.apiServer, n.middleware.unary)
+
+	if n.middleware.tlsConfig == nil {
+		gateway.Register(mux)
+	} else {
+		gatewayMux := http.NewServeMux()
+		gateway.Register(gatewayMux)
+		n.serveHTTP(ctx, "Gateway", &http.Server{
+			Addr:      n.Config.Metrics.GatewayAddr,
+			Handler:   gatewayMux,
+			TLSConfig: n.middleware.tlsConfig,
+		})
 	}
-	n.wg.Add(1)
 
+	n.serveHTTP(ctx, "Metrics", &http.Server{
+		Addr:    n.Config.Metrics.Addr,
+		Handler: mux,
+	})
+}
+
+// serveHTTP runs httpServer, over TLS when it has a TLS config, until ctx
+// is done.
+func (n *Nox) serveHTTP(ctx context.Context, name string, httpServer *http.Server) {
+	n.wg.Add(1)
 	go func() {
 		defer n.wg.Done()
-		n.Logger.Info("Starting Metrics server", "address", server.Addr)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			slog.Error("Metrics server error", "error", err)
+		n.Logger.Info("Starting "+name+" server", "address", httpServer.Addr)
+
+		var err error
+		if httpServer.TLSConfig != nil {
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			slog.Error(name+" server error", "error", err)
 		}
-		n.Logger.Info("Metrics server stopped")
+		n.Logger.Info(name + " server stopped")
 	}()
 
 	n.wg.Add(1)
@@ -396,8 +698,8 @@ func (n *Nox) startMetricsServer(ctx context.Context) {
 		<-ctx.Done()
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 		defer cancel()
-		if err := server.Shutdown(shutdownCtx); err != nil {
-			slog.Error("Metrics server shutdown error", "error", err)
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			slog.Error(name+" server shutdown error", "error", err)
 		}
 	}()
 }
@@ -409,9 +711,13 @@ func (n *Nox) startGRPCServer(ctx context.Context) {
 		return
 	}
 
-	s := grpc.NewServer()
-	apiServer := server.NewNoxAPIServer(n.ESClient)
-	pb.RegisterNoxServiceServer(s, apiServer)
+	s := grpc.NewServer(n.middleware.serverOptions()...)
+	pb.RegisterNoxServiceServer(s, n.apiServer)
+
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(s, healthServer)
+	reflection.Register(s)
+	n.monitorHealth(ctx, healthServer)
 
 	n.wg.Add(1)
 
@@ -446,13 +752,27 @@ func (n *Nox) startGRPCServer(ctx context.Context) {
 	}()
 }
 
-func (n *Nox) startFileIngester(ctx context.Context, eventChannel chan<- model.Event) {
+func (n *Nox) startFileIngester(ctx context.Context, lineChannel chan<- ingester.Line) {
+	var inputs sync.WaitGroup
+	n.ingesting.Store(true)
+
+	for _, path := range n.Config.LogPaths {
+		inputs.Add(1)
+		go func() {
+			defer inputs.Done()
+			// any input stopping leaves the engine blind to part of its
+			// telemetry, so the ingester is reported down.
+			defer n.ingesting.Store(false)
+			if err := n.ingester.TailFile(ctx, path, lineChannel); err != nil {
+				n.Logger.Error("File ingester stopped with an error", "error", err, "path", path)
+			}
+		}()
+	}
+
 	n.wg.Add(1)
 	go func() {
 		defer n.wg.Done()
-		defer close(eventChannel)
-		if err := n.ingester.TailFile(ctx, n.Config.LogPath, eventChannel); err != nil {
-			n.Logger.Error("File ingester stopped with an error", "error", err)
-		}
+		inputs.Wait()
+		close(lineChannel)
 	}()
 }
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"nox/internal/enrich"
+	"nox/internal/ingester"
+	"nox/internal/model"
+	"nox/internal/rules"
+	"nox/internal/ruletest"
+	"nox/internal/storage"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var rulesCmd = &cobra.Command{
+	Use:   "rules",
+	Short: "Work with detection rules.",
+}
+
+var rulesTestCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Run declarative rule tests through the ingester and rule engine.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rulesPath, _ := cmd.Flags().GetString("rules")
+		testsPath, _ := cmd.Flags().GetString("tests")
+		junitPath, _ := cmd.Flags().GetString("junit")
+		exceptionsPath, _ := cmd.Flags().GetString("exceptions")
+
+		yamlRules, err := rules.LoadRulesFromFile(rulesPath)
+		if err != nil {
+			return err
+		}
+
+		exceptions, err := rules.LoadExceptions(exceptionsPath)
+		if err != nil {
+			return err
+		}
+
+		suites, err := ruletest.Load(testsPath)
+		if err != nil {
+			return err
+		}
+
+		runner := &ruletest.Runner{Rules: yamlRules, Options: []rules.EngineOption{rules.WithExceptions(exceptions)}}
+		var results []ruletest.SuiteResult
+		for _, suite := range suites {
+			results = append(results, runner.RunSuite(suite))
+		}
+
+		out := cmd.OutOrStdout()
+		summary := out
+		if junitPath == "-" {
+			// keep stdout clean for the report.
+			summary = cmd.ErrOrStderr()
+		}
+		total, failed := printTestResults(summary, results)
+
+		if junitPath != "" {
+			if err := writeJUnit(junitPath, out, results); err != nil {
+				return err
+			}
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d of %d rule tests failed", failed, total)
+		}
+		return nil
+	},
+}
+
+var rulesLintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check detection rules for mistakes the loader accepts silently.",
+	Long: `Lint validates the rules schema, severities, event types, fields and operators,
+checks technique IDs against the bundled ATT&CK list, and flags duplicate names
+and rules that can never fire. It exits non-zero when it finds an error, or any
+finding at all with --strict.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rulesPath, _ := cmd.Flags().GetString("rules")
+		strict, _ := cmd.Flags().GetBool("strict")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		findings, err := rules.LintFile(rulesPath)
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		if asJSON {
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(findings); err != nil {
+				return err
+			}
+		} else {
+			for _, f := range findings {
+				fmt.Fprintf(out, "%s:%s\n", rulesPath, f)
+			}
+		}
+
+		errors := 0
+		for _, f := range findings {
+			if f.Severity == rules.LintError {
+				errors++
+			}
+		}
+		warnings := len(findings) - errors
+		if !asJSON {
+			fmt.Fprintf(out, "%d errors, %d warnings\n", errors, warnings)
+		}
+
+		if errors > 0 || (strict && warnings > 0) {
+			return fmt.Errorf("%s has %d errors and %d warnings", rulesPath, errors, warnings)
+		}
+		return nil
+	},
+}
+
+var rulesCoverageCmd = &cobra.Command{
+	Use:   "coverage",
+	Short: "Show which MITRE ATT&CK techniques the rules cover, and how often they fire.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rulesPath, _ := cmd.Flags().GetString("rules")
+		esURL, _ := cmd.Flags().GetString("es")
+		since, _ := cmd.Flags().GetDuration("since")
+		navigatorPath, _ := cmd.Flags().GetString("navigator")
+
+		yamlRules, err := rules.LoadRulesFromFile(rulesPath)
+		if err != nil {
+			return err
+		}
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		engine := rules.NewEngine(logger, rules.NewStateManager(), yamlRules)
+
+		var counts map[string]int64
+		if since > 0 {
+			counts, err = countAlerts(cmd.Context(), esURL, since)
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "warning: alert counts unavailable: %v\n", err)
+			}
+		}
+
+		coverage := rules.BuildCoverage(engine.RuleTechniques(), counts)
+		if err := printCoverage(cmd.OutOrStdout(), coverage); err != nil {
+			return err
+		}
+
+		if navigatorPath != "" {
+			data, err := json.MarshalIndent(coverage.NavigatorLayer("nox detection coverage"), "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode navigator layer: %w", err)
+			}
+			if err := os.WriteFile(navigatorPath, data, 0o644); err != nil {
+				return fmt.Errorf("failed to write navigator layer: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "\nWrote ATT&CK Navigator layer to %s\n", navigatorPath)
+		}
+		return nil
+	},
+}
+
+var rulesProfileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Replay log files through the rules and show which rules are slowest.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rulesPath, _ := cmd.Flags().GetString("rules")
+		exceptionsPath, _ := cmd.Flags().GetString("exceptions")
+		logPaths, _ := cmd.Flags().GetStringSlice("log")
+		top, _ := cmd.Flags().GetInt("top")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		yamlRules, err := rules.LoadRulesFromFile(rulesPath)
+		if err != nil {
+			return err
+		}
+		exceptions, err := rules.LoadExceptions(exceptionsPath)
+		if err != nil {
+			return err
+		}
+
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		enricher := enrich.New(enrich.Config{}, logger)
+		engine := rules.NewEngine(logger, rules.NewStateManager(), yamlRules,
+			rules.WithExceptions(exceptions),
+			rules.WithProcessHistory(enricher.ProcessTree()),
+		)
+		replay := &replayer{
+			parser:   ingester.NewIngester(logger, time.Now),
+			enricher: enricher,
+			engine:   engine,
+		}
+
+		began := time.Now()
+		for _, path := range logPaths {
+			if err := replay.file(path); err != nil {
+				return err
+			}
+		}
+		elapsed := time.Since(began)
+
+		profiles := engine.Profile()
+		if top > 0 && len(profiles) > top {
+			profiles = profiles[:top]
+		}
+
+		out := cmd.OutOrStdout()
+		if asJSON {
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			return enc.Encode(profiles)
+		}
+
+		fmt.Fprintf(out, "Replayed %d events (%d lines, %d unparsed) in %s\n\n", replay.events, replay.lines, replay.unparsed, elapsed.Round(time.Millisecond))
+		return printProfiles(out, profiles)
+	},
+}
+
+// replayer feeds log lines through parsing, enrichment and detection the
+// way the pipeline does, one event at a time.
+type replayer struct {
+	parser   *ingester.Ingester
+	enricher *enrich.Chain
+	engine   *rules.Engine
+
+	lines, events, unparsed int
+}
+
+func (r *replayer) file(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		r.lines++
+		event, err := r.parser.ParseLog(scanner.Text())
+		if err != nil {
+			if !errors.Is(err, model.ErrIgnoredLine) {
+				r.unparsed++
+			}
+			continue
+		}
+
+		event.Input = path
+		r.enricher.Enrich(&event)
+		r.engine.EvaluateEvent(event)
+		r.events++
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return nil
+}
+
+func printProfiles(out io.Writer, profiles []rules.RuleProfile) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "RULE\tKIND\tEVALUATIONS\tMATCHES\tTOTAL\tMEAN\tMAX\n")
+	for _, p := range profiles {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\t%s\n", p.ID, p.Kind, p.Evaluations, p.Matches, p.Total, p.Mean(), p.Max)
+	}
+	return w.Flush()
+}
+
+func countAlerts(ctx context.Context, esURL string, since time.Duration) (map[string]int64, error) {
+	esClient, err := storage.NewESClient(esURL)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return esClient.CountAlertsByRule(ctx, time.Now().Add(-since), time.Time{})
+}
+
+func printCoverage(out io.Writer, coverage rules.Coverage) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "TACTIC\tCOVERED\tALERTS\n")
+	for _, tactic := range coverage.Tactics {
+		var alerts int64
+		for _, technique := range tactic.Techniques {
+			alerts += technique.Alerts
+		}
+		fmt.Fprintf(w, "%s\t%d/%d\t%d\n", tactic.Tactic.Name, tactic.Covered, tactic.Total, alerts)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, tactic := range coverage.Tactics {
+		if len(tactic.Techniques) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "\n%s (%s)\n", tactic.Tactic.Name, tactic.Tactic.ID)
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, technique := range tactic.Techniques {
+			fmt.Fprintf(w, "  %s\t%s\t%d alerts\t%s\n", technique.Technique.ID, technique.Technique.Name, technique.Alerts, strings.Join(technique.Rules, ", "))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(coverage.Unmapped) > 0 {
+		fmt.Fprintf(out, "\nRules without a technique: %s\n", strings.Join(coverage.Unmapped, ", "))
+	}
+	if len(coverage.Unknown) > 0 {
+		fmt.Fprintf(out, "\nTechniques not in the bundled ATT&CK list: %s\n", strings.Join(coverage.Unknown, ", "))
+	}
+	return nil
+}
+
+func printTestResults(w io.Writer, results []ruletest.SuiteResult) (total, failed int) {
+	for _, sr := range results {
+		for _, r := range sr.Results {
+			total++
+			if r.Passed() {
+				fmt.Fprintf(w, "PASS  %s/%s (%d alerts)\n", sr.Suite.Name, r.Case.Name, len(r.Alerts))
+				continue
+			}
+
+			failed++
+			fmt.Fprintf(w, "FAIL  %s/%s\n", sr.Suite.Name, r.Case.Name)
+			if r.Error != nil {
+				fmt.Fprintf(w, "      error: %v\n", r.Error)
+			}
+			for _, failure := range r.Failures {
+				fmt.Fprintf(w, "      %s\n", failure)
+			}
+		}
+	}
+
+	status := "ok"
+	if failed > 0 {
+		status = "FAILED"
+	}
+	fmt.Fprintf(w, "\n%s: %d passed, %d failed\n", status, total-failed, failed)
+	return total, failed
+}
+
+func writeJUnit(path string, stdout io.Writer, results []ruletest.SuiteResult) error {
+	if path == "-" {
+		return ruletest.WriteJUnit(stdout, results)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create junit report: %w", err)
+	}
+	if err := ruletest.WriteJUnit(f, results); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write junit report: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	rulesTestCmd.Flags().String("rules", getEnv("NOX_RULES_PATH", "detections/rules.yaml"), "Path of the YAML rules to test")
+	rulesTestCmd.Flags().String("tests", "detections/tests", "Rule test file, or directory of test files")
+	rulesTestCmd.Flags().String("junit", "", "Write a JUnit XML report to this path, or - for stdout")
+	rulesTestCmd.Flags().String("exceptions", getEnv("NOX_EXCEPTIONS_PATH", "detections/exceptions.yaml"), "Exceptions to apply, as in production")
+	rulesLintCmd.Flags().String("rules", getEnv("NOX_RULES_PATH", "detections/rules.yaml"), "Path of the YAML rules to lint")
+	rulesLintCmd.Flags().Bool("strict", false, "Fail on warnings as well as errors")
+	rulesLintCmd.Flags().Bool("json", false, "Print findings as JSON")
+	rulesProfileCmd.Flags().String("rules", getEnv("NOX_RULES_PATH", "detections/rules.yaml"), "Path of the YAML rules to profile")
+	rulesProfileCmd.Flags().String("exceptions", getEnv("NOX_EXCEPTIONS_PATH", "detections/exceptions.yaml"), "Exceptions to apply, as in production")
+	rulesProfileCmd.Flags().StringSlice("log", nil, "Log file to replay; repeat for several")
+	rulesProfileCmd.Flags().Int("top", 10, "Show only the N slowest rules, 0 for all")
+	rulesProfileCmd.Flags().Bool("json", false, "Print the profile as JSON")
+	rulesProfileCmd.MarkFlagRequired("log")
+	rulesCoverageCmd.Flags().String("rules", getEnv("NOX_RULES_PATH", "detections/rules.yaml"), "Path of the YAML rules")
+	rulesCoverageCmd.Flags().String("es", "http://localhost:9200", "Elasticsearch to count alerts in")
+	rulesCoverageCmd.Flags().Duration("since", 30*24*time.Hour, "Count alerts from this far back, 0 to skip counting")
+	rulesCoverageCmd.Flags().String("navigator", "", "Write an ATT&CK Navigator layer to this path")
+	rulesCmd.AddCommand(rulesTestCmd, rulesLintCmd, rulesCoverageCmd, rulesProfileCmd)
+	rootCmd.AddCommand(rulesCmd)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"nox/internal/rules"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type StateConfig struct {
+	Path             string
+	SnapshotInterval time.Duration
+	MaxAge           time.Duration // entries older than this are dropped on restore
+	MaxEntries       int           // per state store
+	JanitorInterval  time.Duration
+}
+
+// restoreState loads the last snapshot into state. A missing snapshot is
+// normal on first start; an unreadable one is logged and ignored so a bad
+// file never keeps the engine from starting.
+func restoreState(cfg StateConfig, state *rules.StateManager, logger *slog.Logger) {
+	if cfg.Path == "" {
+		return
+	}
+
+	snap, err := rules.LoadSnapshot(cfg.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		logger.Info("No detection state snapshot found, starting fresh", "path", cfg.Path)
+		return
+	}
+	if err != nil {
+		logger.Warn("Could not restore detection state, starting fresh", "path", cfg.Path, "error", err)
+		return
+	}
+
+	// expire relative to the event time the snapshot was taken at, so a
+	// replayed or delayed log doesn't lose its state to wall-clock age.
+	reference := snap.EventTime
+	if reference.IsZero() {
+		reference = time.Now()
+	}
+
+	expired := state.Restore(snap, reference.Add(-cfg.MaxAge))
+	logger.Info("Restored detection state",
+		"path", cfg.Path,
+		"taken_at", snap.TakenAt,
+		"event_time", snap.EventTime,
+		"expired_entries", expired,
+	)
+}
+
+func (n *Nox) saveState() {
+	if n.Config.State.Path == "" {
+		return
+	}
+
+	start := time.Now()
+	if err := rules.SaveSnapshot(n.Config.State.Path, n.state.Snapshot()); err != nil {
+		n.Logger.Error("Failed to snapshot detection state", "error", err)
+		return
+	}
+
+	n.Logger.Debug("Snapshotted detection state", "path", n.Config.State.Path, "duration", time.Since(start))
+}
+
+func (n *Nox) startStateSnapshotter(ctx context.Context) {
+	if n.Config.State.Path == "" || n.Config.State.SnapshotInterval <= 0 {
+		n.Logger.Warn("Detection state snapshots disabled, state will be lost on restart")
+		return
+	}
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		ticker := time.NewTicker(n.Config.State.SnapshotInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				n.saveState()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// startStateJanitor periodically expires rule state against the watermark.
+func (n *Nox) startStateJanitor(ctx context.Context) {
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		n.state.RunJanitor(ctx, n.Config.State.JanitorInterval, n.clock.Watermark)
+	}()
+}
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Inspect persisted detection state.",
+}
+
+var stateDumpCmd = &cobra.Command{
+	Use:   "dump",
+	Short: "Print the contents of a detection state snapshot.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, _ := cmd.Flags().GetString("path")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		snap, err := rules.LoadSnapshot(path)
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		if asJSON {
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			return enc.Encode(snap)
+		}
+
+		fmt.Fprintf(out, "Snapshot %s (version %d, taken %s, %s ago)\n\n",
+			path, snap.Version, snap.TakenAt.Format(time.RFC3339), time.Since(snap.TakenAt).Round(time.Second))
+
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "STATE\tENTRIES")
+		fmt.Fprintf(w, "failed_logins.attempts\t%d\n", len(snap.FailedLogins.Attempts))
+		fmt.Fprintf(w, "failed_logins.alerted\t%d\n", len(snap.FailedLogins.AlertedIPs))
+		fmt.Fprintf(w, "login_locations\t%d\n", len(snap.LoginLocations))
+		fmt.Fprintf(w, "login_travel\t%d\n", len(snap.LoginTravel))
+		fmt.Fprintf(w, "new_accounts\t%d\n", len(snap.NewAccounts))
+		fmt.Fprintf(w, "post_brute_force_logins\t%d\n", len(snap.PostBruteForceLogins))
+		fmt.Fprintf(w, "process_execution_history\t%d\n", len(snap.ProcessExecutionHistory.History))
+		fmt.Fprintf(w, "staged_payloads\t%d\n", len(snap.StagedPayloads))
+		fmt.Fprintf(w, "suspicious_logins\t%d\n", len(snap.SuspiciousLogins))
+		fmt.Fprintf(w, "password_spray.attempts\t%d\n", len(snap.PasswordSpray.Attempts))
+		fmt.Fprintf(w, "password_spray.alerted\t%d\n", len(snap.PasswordSpray.AlertedIPs))
+		fmt.Fprintf(w, "baselines\t%d\n", len(snap.Baselines))
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		if len(snap.LoginLocations) > 0 {
+			fmt.Fprintln(out, "\nLearned login countries:")
+			for _, user := range slices.Sorted(maps.Keys(snap.LoginLocations)) {
+				fmt.Fprintf(out, "  %s: %v\n", user, snap.LoginLocations[user])
+			}
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	stateDumpCmd.Flags().String("path", getEnv("NOX_STATE_PATH", defaultStatePath), "Path of the state snapshot to inspect")
+	stateDumpCmd.Flags().Bool("json", false, "Print the full snapshot as JSON")
+	stateCmd.AddCommand(stateDumpCmd)
+	rootCmd.AddCommand(stateCmd)
+}
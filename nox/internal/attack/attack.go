@@ -0,0 +1,106 @@
+// Package attack is a bundled copy of the MITRE ATT&CK Enterprise technique
+// list, so rules can be checked and mapped offline.
+package attack
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"regexp"
+	"strings"
+)
+
+// Version is the ATT&CK Enterprise release the bundled list is taken from.
+// Only techniques relevant to the platforms nox watches carry their
+// sub-techniques.
+const Version = "15"
+
+//go:embed enterprise.csv
+var enterpriseCSV string
+
+// Tactic is an ATT&CK tactic, in kill chain order in Tactics.
+type Tactic struct {
+	ID        string
+	ShortName string
+	Name      string
+}
+
+var Tactics = []Tactic{
+	{"TA0043", "reconnaissance", "Reconnaissance"},
+	{"TA0042", "resource-development", "Resource Development"},
+	{"TA0001", "initial-access", "Initial Access"},
+	{"TA0002", "execution", "Execution"},
+	{"TA0003", "persistence", "Persistence"},
+	{"TA0004", "privilege-escalation", "Privilege Escalation"},
+	{"TA0005", "defense-evasion", "Defense Evasion"},
+	{"TA0006", "credential-access", "Credential Access"},
+	{"TA0007", "discovery", "Discovery"},
+	{"TA0008", "lateral-movement", "Lateral Movement"},
+	{"TA0009", "collection", "Collection"},
+	{"TA0011", "command-and-control", "Command and Control"},
+	{"TA0010", "exfiltration", "Exfiltration"},
+	{"TA0040", "impact", "Impact"},
+}
+
+// Technique is a technique or sub-technique. Sub-techniques share their
+// parent's tactics.
+type Technique struct {
+	ID      string
+	Name    string
+	Tactics []string // tactic short names
+}
+
+// Parent returns the technique a sub-technique belongs to, or the ID itself.
+func (t Technique) Parent() string {
+	id, _, _ := strings.Cut(t.ID, ".")
+	return id
+}
+
+func (t Technique) IsSubTechnique() bool {
+	return strings.Contains(t.ID, ".")
+}
+
+var idPattern = regexp.MustCompile(`^T\d{4}(\.\d{3})?$`)
+
+// ValidID reports whether id is shaped like a technique ID, e.g. T1059.004.
+func ValidID(id string) bool {
+	return idPattern.MatchString(id)
+}
+
+// URL links to a technique's page on attack.mitre.org.
+func URL(id string) string {
+	return "https://attack.mitre.org/techniques/" + strings.Replace(id, ".", "/", 1) + "/"
+}
+
+var techniques, ordered = load()
+
+// Lookup returns a technique by ID.
+func Lookup(id string) (Technique, bool) {
+	t, ok := techniques[id]
+	return t, ok
+}
+
+// Techniques returns every bundled technique, sorted by ID.
+func Techniques() []Technique {
+	return ordered
+}
+
+func load() (map[string]Technique, []Technique) {
+	records, err := csv.NewReader(strings.NewReader(enterpriseCSV)).ReadAll()
+	if err != nil {
+		panic("attack: invalid bundled technique list: " + err.Error())
+	}
+
+	byID := make(map[string]Technique, len(records))
+	var list []Technique
+	for _, record := range records[1:] {
+		t := Technique{ID: record[0], Name: record[1]}
+		if record[2] != "" {
+			t.Tactics = strings.Split(record[2], ";")
+		} else if parent, ok := byID[t.Parent()]; ok {
+			t.Tactics = parent.Tactics
+		}
+		byID[t.ID] = t
+		list = append(list, t)
+	}
+	return byID, list
+}
@@ -0,0 +1,65 @@
+package attack
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBundledTechniques(t *testing.T) {
+	tactics := make(map[string]bool)
+	for _, tactic := range Tactics {
+		tactics[tactic.ShortName] = true
+	}
+
+	seen := make(map[string]bool)
+	for _, technique := range Techniques() {
+		if !ValidID(technique.ID) {
+			t.Fatalf("got malformed technique ID %q", technique.ID)
+		}
+		if seen[technique.ID] {
+			t.Fatalf("got duplicate technique %s", technique.ID)
+		}
+		seen[technique.ID] = true
+
+		if technique.IsSubTechnique() && !seen[technique.Parent()] {
+			t.Fatalf("got sub-technique %s before its parent", technique.ID)
+		}
+		if len(technique.Tactics) == 0 {
+			t.Fatalf("got no tactics for %s", technique.ID)
+		}
+		for _, tactic := range technique.Tactics {
+			if !tactics[tactic] {
+				t.Fatalf("got unknown tactic %q for %s", tactic, technique.ID)
+			}
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	technique, ok := Lookup("T1059.004")
+	if !ok {
+		t.Fatalf("got T1059.004 missing, want it bundled")
+	}
+	if technique.Name != "Unix Shell" || !slices.Equal(technique.Tactics, []string{"execution"}) {
+		t.Fatalf("got %+v, want Unix Shell inheriting execution", technique)
+	}
+	if _, ok := Lookup("T9999"); ok {
+		t.Fatalf("got T9999 found, want missing")
+	}
+}
+
+func TestValidID(t *testing.T) {
+	tests := map[string]bool{
+		"T1046":     true,
+		"T1059.004": true,
+		"T1059.4":   false,
+		"t1046":     false,
+		"TA0001":    false,
+		"":          false,
+	}
+	for id, want := range tests {
+		if got := ValidID(id); got != want {
+			t.Fatalf("ValidID(%q): got %v, want %v", id, got, want)
+		}
+	}
+}
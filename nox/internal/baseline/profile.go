@@ -0,0 +1,156 @@
+package baseline
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"time"
+)
+
+// Entity keys are prefixed with their kind so users and hosts with the same
+// name never share a profile.
+const (
+	userPrefix = "user:"
+	hostPrefix = "host:"
+)
+
+func UserEntity(name string) string { return userPrefix + name }
+func HostEntity(name string) string { return hostPrefix + name }
+
+// maxTrackedValues caps each frequency map. When full, the lightest value is
+// dropped, which is the one the decay would have retired first anyway.
+const maxTrackedValues = 256
+
+// pruneWeight is the decayed weight below which a value is forgotten.
+const pruneWeight = 0.01
+
+// Profile is the learned behaviour of one user or host. Weights are
+// exponentially decayed counts, so recent behaviour dominates and habits
+// that stop fade out over a few half-lives.
+type Profile struct {
+	Entity    string    `json:"entity"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	DecayedAt time.Time `json:"decayed_at"`
+	// Samples counts every event ever folded in, undecayed, for the
+	// learning gate.
+	Samples int `json:"samples"`
+
+	// users
+	Logins     float64            `json:"logins,omitempty"`
+	LoginHours [24]float64        `json:"login_hours"` // UTC hour of day
+	SourceASNs map[string]float64 `json:"source_asns,omitempty"`
+
+	// hosts
+	Executions   float64            `json:"executions,omitempty"`
+	ProcessPairs map[string]float64 `json:"process_pairs,omitempty"` // "parent>child"
+	ExecRate     RateStats          `json:"exec_rate"`
+}
+
+// RateStats tracks executions per active minute as an exponentially
+// weighted mean and variance. Minutes with no executions are not counted,
+// so quiet hosts don't read as a zero baseline that every burst exceeds.
+type RateStats struct {
+	Mean     float64   `json:"mean"`
+	Variance float64   `json:"variance"`
+	Minutes  int       `json:"minutes"`
+	Minute   time.Time `json:"minute"` // the minute being counted
+	Count    int       `json:"count"`
+	Alerted  bool      `json:"alerted"`
+}
+
+func (r RateStats) Stddev() float64 {
+	return math.Sqrt(r.Variance)
+}
+
+// decay ages every weight from DecayedAt to now.
+func (p *Profile) decay(now time.Time, halfLife time.Duration) {
+	if p.DecayedAt.IsZero() {
+		p.DecayedAt = now
+		return
+	}
+	elapsed := now.Sub(p.DecayedAt)
+	if elapsed <= 0 || halfLife <= 0 {
+		return
+	}
+
+	factor := math.Pow(0.5, float64(elapsed)/float64(halfLife))
+	p.Logins *= factor
+	p.Executions *= factor
+	for i := range p.LoginHours {
+		p.LoginHours[i] *= factor
+	}
+	decayMap(p.SourceASNs, factor)
+	decayMap(p.ProcessPairs, factor)
+	p.DecayedAt = now
+}
+
+func decayMap(weights map[string]float64, factor float64) {
+	for key, weight := range weights {
+		if weight *= factor; weight < pruneWeight {
+			delete(weights, key)
+		} else {
+			weights[key] = weight
+		}
+	}
+}
+
+// observe adds one occurrence of key, making room if the map is full.
+func observe(weights map[string]float64, key string) {
+	if _, ok := weights[key]; !ok && len(weights) >= maxTrackedValues {
+		lightest, min := "", math.Inf(1)
+		for k, w := range weights {
+			if w < min {
+				lightest, min = k, w
+			}
+		}
+		delete(weights, lightest)
+	}
+	weights[key]++
+}
+
+// hourShare is the fraction of logins in hour, smoothed with its
+// neighbours so a login at 09:05 isn't unusual for someone who always logs in
+// at 08:55.
+func (p *Profile) hourShare(hour int) float64 {
+	if p.Logins <= 0 {
+		return 0
+	}
+	prev := p.LoginHours[(hour+23)%24]
+	next := p.LoginHours[(hour+1)%24]
+	smoothed := (prev/2 + p.LoginHours[hour] + next/2) / 2
+	return smoothed / p.Logins
+}
+
+func (p Profile) clone() Profile {
+	p.SourceASNs = maps.Clone(p.SourceASNs)
+	p.ProcessPairs = maps.Clone(p.ProcessPairs)
+	return p
+}
+
+// Weighted is a profile value and its decayed weight.
+type Weighted struct {
+	Value  string
+	Weight float64
+}
+
+// Top returns the heaviest entries of weights, heaviest first.
+func Top(weights map[string]float64, n int) []Weighted {
+	entries := make([]Weighted, 0, len(weights))
+	for _, key := range slices.Sorted(maps.Keys(weights)) {
+		entries = append(entries, Weighted{Value: key, Weight: weights[key]})
+	}
+	slices.SortStableFunc(entries, func(a, b Weighted) int {
+		switch {
+		case a.Weight > b.Weight:
+			return -1
+		case a.Weight < b.Weight:
+			return 1
+		}
+		return 0
+	})
+	if n > 0 && len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
@@ -0,0 +1,389 @@
+package baseline
+
+import (
+	"fmt"
+	"math"
+	"nox/internal/model"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AnomalyLoginHour   = "unusual_login_hour"
+	AnomalySourceASN   = "new_source_asn"
+	AnomalyProcessPair = "new_process_pair"
+	AnomalyExecRate    = "exec_rate_spike"
+)
+
+type Config struct {
+	// HalfLife is how long it takes a behaviour to lose half its weight;
+	// it sets the rolling period the baseline reflects.
+	HalfLife time.Duration
+	// LearningPeriod and MinObservations must both be met before an
+	// entity's deviations are scored.
+	LearningPeriod  time.Duration
+	MinObservations int
+	// MinScore is the lowest anomaly score reported, from 0 to 1.
+	MinScore float64
+	// ExecRateZ is how many standard deviations above the mean a minute's
+	// executions must be to count as a spike.
+	ExecRateZ   float64
+	MaxEntities int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		HalfLife:        14 * 24 * time.Hour,
+		LearningPeriod:  7 * 24 * time.Hour,
+		MinObservations: 20,
+		MinScore:        0.7,
+		ExecRateZ:       4,
+		MaxEntities:     100_000,
+	}
+}
+
+// Anomaly is a scored deviation from an entity's baseline.
+type Anomaly struct {
+	Type     string
+	Entity   string
+	Score    float64 // 0 to 1
+	Detail   string
+	Metadata map[string]string
+}
+
+// suspiciousChildren are processes whose first appearance under a parent is
+// worth more attention than an unfamiliar utility.
+var suspiciousChildren = map[string]bool{
+	"sh": true, "bash": true, "dash": true, "zsh": true,
+	"python": true, "python3": true, "perl": true, "ruby": true,
+	"nc": true, "ncat": true, "socat": true, "curl": true, "wget": true,
+}
+
+// maxTrackedPIDs bounds the per-host PID to process name map used to name
+// parents. It is rebuilt from live traffic, so it isn't persisted.
+const maxTrackedPIDs = 4096
+
+// Profiler learns per-user and per-host baselines from events and scores
+// deviations from them.
+type Profiler struct {
+	cfg Config
+
+	mu       sync.Mutex
+	profiles map[string]*Profile
+	pids     map[string]map[string]string // host -> pid -> process name
+}
+
+func NewProfiler(cfg Config) *Profiler {
+	if cfg.MaxEntities <= 0 {
+		cfg.MaxEntities = DefaultConfig().MaxEntities
+	}
+	return &Profiler{
+		cfg:      cfg,
+		profiles: make(map[string]*Profile),
+		pids:     make(map[string]map[string]string),
+	}
+}
+
+// Observe scores event against the baselines of the user and host it
+// belongs to, then folds it into them.
+func (p *Profiler) Observe(event model.Event) []Anomaly {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch event.EventType {
+	case "SSHD_Accepted_Password":
+		if user := event.Metadata["user"]; user != "" {
+			return p.observeLogin(p.profile(UserEntity(user), event.Timestamp), event)
+		}
+	case "Process_Executed":
+		return p.observeExec(p.profile(HostEntity(hostOf(event)), event.Timestamp), event)
+	}
+	return nil
+}
+
+func hostOf(event model.Event) string {
+	if host := event.Metadata["host"]; host != "" {
+		return host
+	}
+	return event.Source
+}
+
+func (p *Profiler) profile(entity string, now time.Time) *Profile {
+	profile, ok := p.profiles[entity]
+	if !ok {
+		if len(p.profiles) >= p.cfg.MaxEntities {
+			p.evictOldest()
+		}
+		profile = &Profile{
+			Entity:       entity,
+			FirstSeen:    now,
+			SourceASNs:   make(map[string]float64),
+			ProcessPairs: make(map[string]float64),
+		}
+		p.profiles[entity] = profile
+	}
+
+	profile.decay(now, p.cfg.HalfLife)
+	if now.After(profile.LastSeen) {
+		profile.LastSeen = now
+	}
+	return profile
+}
+
+func (p *Profiler) evictOldest() {
+	var oldest *Profile
+	for _, profile := range p.profiles {
+		if oldest == nil || profile.LastSeen.Before(oldest.LastSeen) {
+			oldest = profile
+		}
+	}
+	if oldest != nil {
+		delete(p.profiles, oldest.Entity)
+	}
+}
+
+// learning reports whether profile is still too young or too sparse to
+// score.
+func (p *Profiler) learning(profile *Profile, now time.Time) bool {
+	return now.Sub(profile.FirstSeen) < p.cfg.LearningPeriod || profile.Samples < p.cfg.MinObservations
+}
+
+func (p *Profiler) observeLogin(profile *Profile, event model.Event) []Anomaly {
+	var anomalies []Anomaly
+	hour := event.Timestamp.UTC().Hour()
+	asn := event.Metadata["asn"]
+
+	if !p.learning(profile, event.Timestamp) {
+		// a share of zero scores 1, a uniform share of 1/24 scores 0.
+		share := profile.hourShare(hour)
+		score := clamp(1 - share*24)
+		anomalies = p.add(anomalies, Anomaly{
+			Type:   AnomalyLoginHour,
+			Entity: profile.Entity,
+			Score:  score,
+			Detail: fmt.Sprintf("login at %02d:00 UTC, which accounts for %.1f%% of this user's logins", hour, share*100),
+			Metadata: map[string]string{
+				"login_hour": strconv.Itoa(hour),
+				"hour_share": fmt.Sprintf("%.4f", share),
+			},
+		})
+
+		if asn != "" && profile.SourceASNs[asn] == 0 && len(profile.SourceASNs) > 0 {
+			anomalies = p.add(anomalies, Anomaly{
+				Type:   AnomalySourceASN,
+				Entity: profile.Entity,
+				Score:  0.8,
+				Detail: fmt.Sprintf("first login from AS%s (%s)", asn, event.Metadata["as_org"]),
+				Metadata: map[string]string{
+					"asn":    asn,
+					"as_org": event.Metadata["as_org"],
+				},
+			})
+		}
+	}
+
+	profile.Logins++
+	profile.Samples++
+	profile.LoginHours[hour]++
+	if asn != "" {
+		observe(profile.SourceASNs, asn)
+	}
+	return anomalies
+}
+
+func (p *Profiler) observeExec(profile *Profile, event model.Event) []Anomaly {
+	var anomalies []Anomaly
+	host := hostOf(event)
+	child := event.Metadata["process_name"]
+
+	pids, ok := p.pids[host]
+	if !ok {
+		pids = make(map[string]string)
+		p.pids[host] = pids
+	}
+	parent := pids[event.Metadata["ppid"]]
+	if pid := event.Metadata["pid"]; pid != "" && child != "" {
+		if len(pids) >= maxTrackedPIDs {
+			clear(pids)
+		}
+		pids[pid] = child
+	}
+
+	learning := p.learning(profile, event.Timestamp)
+	pair := parent + ">" + child
+
+	if parent != "" && child != "" {
+		if !learning && profile.ProcessPairs[pair] == 0 {
+			score := 0.75
+			if suspiciousChildren[child] {
+				score = 0.9
+			}
+			anomalies = p.add(anomalies, Anomaly{
+				Type:   AnomalyProcessPair,
+				Entity: profile.Entity,
+				Score:  score,
+				Detail: fmt.Sprintf("%s started %s for the first time on this host", parent, child),
+				Metadata: map[string]string{
+					"parent_process": parent,
+					"process_name":   child,
+					"command":        event.Metadata["command"],
+				},
+			})
+		}
+		observe(profile.ProcessPairs, pair)
+	}
+
+	if a, ok := p.observeExecRate(profile, event.Timestamp, learning); ok {
+		anomalies = p.add(anomalies, a)
+	}
+
+	profile.Executions++
+	profile.Samples++
+	return anomalies
+}
+
+// observeExecRate counts the execution in its minute. When a later minute
+// starts, the finished one is folded into the running statistics; while a
+// minute is open, it is compared against them as it fills.
+func (p *Profiler) observeExecRate(profile *Profile, at time.Time, learning bool) (Anomaly, bool) {
+	rate := &profile.ExecRate
+	minute := at.Truncate(time.Minute)
+
+	if minute.After(rate.Minute) {
+		if rate.Count > 0 {
+			rate.fold(float64(rate.Count), p.alpha())
+		}
+		rate.Minute, rate.Count, rate.Alerted = minute, 0, false
+	}
+	rate.Count++
+
+	if learning || rate.Alerted || rate.Minutes < p.cfg.MinObservations {
+		return Anomaly{}, false
+	}
+
+	// a floor of one execution keeps perfectly regular hosts from alerting on
+	// a single extra process.
+	z := (float64(rate.Count) - rate.Mean) / math.Max(rate.Stddev(), 1)
+	if z < p.cfg.ExecRateZ {
+		return Anomaly{}, false
+	}
+
+	rate.Alerted = true
+	return Anomaly{
+		Type:   AnomalyExecRate,
+		Entity: profile.Entity,
+		Score:  clamp(0.7 + 0.1*(z-p.cfg.ExecRateZ)),
+		Detail: fmt.Sprintf("%d executions in a minute against a typical %.1f", rate.Count, rate.Mean),
+		Metadata: map[string]string{
+			"executions_per_minute": strconv.Itoa(rate.Count),
+			"typical_per_minute":    fmt.Sprintf("%.1f", rate.Mean),
+			"z_score":               fmt.Sprintf("%.1f", z),
+		},
+	}, true
+}
+
+// alpha is the per-minute EWMA weight matching the configured half-life,
+// floored so the statistics still move on a short half-life.
+func (p *Profiler) alpha() float64 {
+	minutes := p.cfg.HalfLife.Minutes()
+	if minutes <= 1 {
+		return 0.5
+	}
+	return math.Max(1-math.Pow(0.5, 1/minutes), 0.001)
+}
+
+func (r *RateStats) fold(x, alpha float64) {
+	if r.Minutes == 0 {
+		r.Mean = x
+	} else {
+		diff := x - r.Mean
+		incr := alpha * diff
+		r.Mean += incr
+		r.Variance = (1 - alpha) * (r.Variance + diff*incr)
+	}
+	r.Minutes++
+}
+
+func (p *Profiler) add(anomalies []Anomaly, a Anomaly) []Anomaly {
+	if a.Score < p.cfg.MinScore {
+		return anomalies
+	}
+	return append(anomalies, a)
+}
+
+func clamp(score float64) float64 {
+	return math.Max(0, math.Min(1, score))
+}
+
+// Profile returns a copy of an entity's baseline, and whether it is still
+// learning as of its last event.
+func (p *Profiler) Profile(entity string) (Profile, bool, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	profile, ok := p.profiles[entity]
+	if !ok {
+		return Profile{}, false, false
+	}
+
+	return profile.clone(), p.learning(profile, profile.LastSeen), true
+}
+
+// Name and Sweep let the state janitor retire profiles.
+func (p *Profiler) Name() string { return "baselines" }
+
+// Sweep drops profiles idle for eight half-lives, by which point every
+// weight has decayed below one percent of its peak.
+func (p *Profiler) Sweep(now time.Time) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	idle := 8 * p.cfg.HalfLife
+	evicted := 0
+	for entity, profile := range p.profiles {
+		if now.Sub(profile.LastSeen) > idle {
+			delete(p.profiles, entity)
+			delete(p.pids, strings.TrimPrefix(entity, hostPrefix))
+			evicted++
+		}
+	}
+	return evicted
+}
+
+func (p *Profiler) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.profiles)
+}
+
+// Snapshot returns copies of every profile for persistence.
+func (p *Profiler) Snapshot() []Profile {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	profiles := make([]Profile, 0, len(p.profiles))
+	for _, profile := range p.profiles {
+		profiles = append(profiles, profile.clone())
+	}
+	return profiles
+}
+
+// Restore replaces every profile with the snapshot's.
+func (p *Profiler) Restore(profiles []Profile) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	clear(p.profiles)
+	for _, profile := range profiles {
+		profile = profile.clone()
+		if profile.SourceASNs == nil {
+			profile.SourceASNs = make(map[string]float64)
+		}
+		if profile.ProcessPairs == nil {
+			profile.ProcessPairs = make(map[string]float64)
+		}
+		p.profiles[profile.Entity] = &profile
+	}
+}
@@ -0,0 +1,179 @@
+package baseline
+
+import (
+	"fmt"
+	"nox/internal/model"
+	"testing"
+	"time"
+)
+
+var baseTime = time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+func testConfig() Config {
+	cfg := DefaultConfig()
+	cfg.LearningPeriod = 3 * 24 * time.Hour
+	cfg.MinObservations = 10
+	return cfg
+}
+
+func login(at time.Time, user, asn string) model.Event {
+	return model.Event{
+		Timestamp: at,
+		EventType: "SSHD_Accepted_Password",
+		Source:    "198.51.100.10",
+		Metadata:  map[string]string{"user": user, "host": "web-1", "asn": asn},
+	}
+}
+
+func exec(at time.Time, pid, ppid, name string) model.Event {
+	return model.Event{
+		Timestamp: at,
+		EventType: "Process_Executed",
+		Source:    "127.0.0.1",
+		Metadata:  map[string]string{"pid": pid, "ppid": ppid, "process_name": name, "command": name},
+	}
+}
+
+// learnOfficeHours logs alice in at 09:00 UTC from AS64500 every day for
+// two weeks.
+func learnOfficeHours(p *Profiler) {
+	for day := range 14 {
+		p.Observe(login(baseTime.Add(time.Duration(day)*24*time.Hour+9*time.Hour), "alice", "64500"))
+	}
+}
+
+func anomalyTypes(anomalies []Anomaly) map[string]Anomaly {
+	types := make(map[string]Anomaly)
+	for _, a := range anomalies {
+		types[a.Type] = a
+	}
+	return types
+}
+
+func TestLoginAnomalies(t *testing.T) {
+	next := baseTime.Add(14 * 24 * time.Hour)
+
+	tests := []struct {
+		name  string
+		event model.Event
+		want  []string
+	}{
+		{"usual hour and ASN", login(next.Add(9*time.Hour+10*time.Minute), "alice", "64500"), nil},
+		{"neighbouring hour", login(next.Add(10*time.Hour), "alice", "64500"), nil},
+		{"3am login", login(next.Add(3*time.Hour), "alice", "64500"), []string{AnomalyLoginHour}},
+		{"new ASN", login(next.Add(9*time.Hour), "alice", "64511"), []string{AnomalySourceASN}},
+		{"3am from a new ASN", login(next.Add(3*time.Hour), "alice", "64511"), []string{AnomalyLoginHour, AnomalySourceASN}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProfiler(testConfig())
+			learnOfficeHours(p)
+
+			got := anomalyTypes(p.Observe(tt.event))
+			if len(got) != len(tt.want) {
+				t.Fatalf("got anomalies %v, want %v", got, tt.want)
+			}
+			for _, want := range tt.want {
+				a, ok := got[want]
+				if !ok {
+					t.Fatalf("got anomalies %v, want %s", got, want)
+				}
+				if a.Score < 0.7 || a.Score > 1 || a.Entity != "user:alice" {
+					t.Fatalf("got %+v, want a score in [0.7, 1] for user:alice", a)
+				}
+			}
+		})
+	}
+}
+
+func TestNoAnomaliesWhileLearning(t *testing.T) {
+	p := NewProfiler(testConfig())
+
+	// plenty of logins, but all on the first day.
+	for i := range 30 {
+		p.Observe(login(baseTime.Add(9*time.Hour+time.Duration(i)*time.Minute), "bob", "64500"))
+	}
+	if got := p.Observe(login(baseTime.Add(23*time.Hour), "bob", "64999")); len(got) != 0 {
+		t.Fatalf("got %v during the learning period, want none", got)
+	}
+
+	if _, learning, ok := p.Profile(UserEntity("bob")); !ok || !learning {
+		t.Fatalf("got learning=%v ok=%v, want a profile still learning", learning, ok)
+	}
+}
+
+func TestProcessAnomalies(t *testing.T) {
+	p := NewProfiler(testConfig())
+
+	// two weeks of cron starting backup once a minute for an hour a day.
+	for day := range 14 {
+		for minute := range 60 {
+			at := baseTime.Add(time.Duration(day)*24*time.Hour + time.Duration(minute)*time.Minute)
+			pid := fmt.Sprint(10_000 + day*100 + minute)
+			p.Observe(exec(at, "100", "1", "cron"))
+			p.Observe(exec(at.Add(time.Second), pid, "100", "backup"))
+		}
+	}
+
+	next := baseTime.Add(14*24*time.Hour + 2*time.Hour)
+	p.Observe(exec(next, "200", "1", "nginx"))
+
+	got := anomalyTypes(p.Observe(exec(next.Add(time.Second), "201", "200", "bash")))
+	a, ok := got[AnomalyProcessPair]
+	if !ok {
+		t.Fatalf("got %v, want %s for nginx>bash", got, AnomalyProcessPair)
+	}
+	if a.Score < 0.9 || a.Metadata["parent_process"] != "nginx" {
+		t.Fatalf("got %+v, want a high score naming the nginx parent", a)
+	}
+
+	if got := p.Observe(exec(next.Add(2*time.Second), "202", "200", "bash")); len(got) != 0 {
+		t.Fatalf("got %v for a pair seen a moment ago, want none", got)
+	}
+
+	// a burst of executions well beyond the usual two per minute.
+	burst := next.Add(10 * time.Minute)
+	var spikes int
+	for i := range 30 {
+		for _, a := range p.Observe(exec(burst.Add(time.Duration(i)*time.Second), fmt.Sprint(300+i), "100", "backup")) {
+			if a.Type == AnomalyExecRate {
+				spikes++
+			}
+		}
+	}
+	if spikes != 1 {
+		t.Fatalf("got %d exec rate anomalies, want 1 per minute", spikes)
+	}
+}
+
+func TestDecayForgetsOldHabits(t *testing.T) {
+	cfg := testConfig()
+	cfg.HalfLife = 24 * time.Hour
+	p := NewProfiler(cfg)
+	learnOfficeHours(p)
+
+	// thirty half-lives after the last login.
+	p.Observe(login(baseTime.Add(44*24*time.Hour), "alice", "64500"))
+
+	profile, _, _ := p.Profile(UserEntity("alice"))
+	if profile.Logins > 1.01 {
+		t.Fatalf("got %.2f decayed logins, want about 1", profile.Logins)
+	}
+
+	if evicted := p.Sweep(baseTime.Add(60 * 24 * time.Hour)); evicted != 1 {
+		t.Fatalf("got %d profiles swept, want 1", evicted)
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	p := NewProfiler(testConfig())
+	learnOfficeHours(p)
+
+	restored := NewProfiler(testConfig())
+	restored.Restore(p.Snapshot())
+
+	if got := restored.Observe(login(baseTime.Add(14*24*time.Hour+3*time.Hour), "alice", "64500")); len(got) != 1 {
+		t.Fatalf("got %v after restore, want the learned baseline to flag a 3am login", got)
+	}
+}
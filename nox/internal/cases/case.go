@@ -0,0 +1,184 @@
+// Package cases tracks what analysts do with alerts: their triage status,
+// who owns them, and the incidents related alerts are merged into.
+package cases
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"nox/internal/model"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Triage statuses. An alert nobody has looked at yet has no status and
+// reads as new.
+const (
+	StatusNew           = "new"
+	StatusAcknowledged  = "acknowledged"
+	StatusInProgress    = "in-progress"
+	StatusFalsePositive = "false-positive"
+	StatusResolved      = "resolved"
+)
+
+var Statuses = []string{StatusNew, StatusAcknowledged, StatusInProgress, StatusFalsePositive, StatusResolved}
+
+var ErrUnknownStatus = errors.New("unknown triage status")
+
+type Note struct {
+	Author string
+	Text   string
+	Time   time.Time
+}
+
+// Triage is the case state of an alert or an incident. It is stored on the
+// alert document itself, so false-positive verdicts stay next to the
+// RuleID and RuleVersion they are about.
+type Triage struct {
+	Status   string   `json:",omitempty"`
+	Assignee string   `json:",omitempty"`
+	Tags     []string `json:",omitempty"`
+	Notes    []Note   `json:",omitempty"`
+	// IncidentID is the incident an alert was merged into.
+	IncidentID string    `json:",omitempty"`
+	UpdatedAt  time.Time `json:",omitzero"`
+	UpdatedBy  string    `json:",omitempty"`
+}
+
+// State is the triage status, StatusNew if none was set.
+func (t Triage) State() string {
+	if t.Status == "" {
+		return StatusNew
+	}
+	return t.Status
+}
+
+// Closed reports whether the case needs no more work.
+func (t Triage) Closed() bool {
+	return t.Status == StatusResolved || t.Status == StatusFalsePositive
+}
+
+// Update is a change to a case. Unset fields leave the case as it is; a
+// non-nil empty Assignee unassigns it.
+type Update struct {
+	Status     string
+	Assignee   *string
+	AddTags    []string
+	RemoveTags []string
+	Note       string
+}
+
+// ValidateStatus returns ErrUnknownStatus for anything but an empty or known
+// status.
+func ValidateStatus(status string) error {
+	if status != "" && !slices.Contains(Statuses, status) {
+		return fmt.Errorf("%w %q, expected one of %s", ErrUnknownStatus, status, strings.Join(Statuses, ", "))
+	}
+	return nil
+}
+
+// Apply returns t with u applied by author at now.
+func (u Update) Apply(t Triage, author string, now time.Time) (Triage, error) {
+	if err := ValidateStatus(u.Status); err != nil {
+		return t, err
+	}
+
+	if u.Status != "" {
+		t.Status = u.Status
+	}
+	if u.Assignee != nil {
+		t.Assignee = *u.Assignee
+	}
+
+	tags := slices.Clone(t.Tags)
+	for _, tag := range u.AddTags {
+		if tag = strings.TrimSpace(tag); tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	tags = slices.DeleteFunc(tags, func(tag string) bool { return slices.Contains(u.RemoveTags, tag) })
+	t.Tags = tags
+
+	if note := strings.TrimSpace(u.Note); note != "" {
+		t.Notes = append(slices.Clone(t.Notes), Note{Author: author, Text: note, Time: now})
+	}
+
+	t.UpdatedAt = now
+	t.UpdatedBy = author
+	return t, nil
+}
+
+// Incident groups related alerts so they are worked as one case.
+type Incident struct {
+	ID    string
+	Title string
+	// Severity is the highest severity of the incident's alerts.
+	Severity  string
+	AlertIDs  []string
+	Rules     []string
+	CreatedAt time.Time
+	CreatedBy string
+	Triage    Triage
+}
+
+// NewIncidentID returns a random incident ID like "inc-1a2b3c4d5e6f".
+func NewIncidentID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "inc-" + hex.EncodeToString(b)
+}
+
+// NewIncident merges alerts, keyed by their IDs, into a new incident.
+// Without a title the incident is named after its most severe alert.
+func NewIncident(id, title string, alerts map[string]model.Alert, author string, now time.Time) Incident {
+	inc := Incident{
+		ID:        id,
+		Title:     title,
+		CreatedAt: now,
+		CreatedBy: author,
+		Triage:    Triage{Status: StatusNew, UpdatedAt: now, UpdatedBy: author},
+	}
+
+	var worst model.Alert
+	for _, alertID := range slices.Sorted(maps.Keys(alerts)) {
+		alert := alerts[alertID]
+		inc.AlertIDs = append(inc.AlertIDs, alertID)
+		inc.addRule(alert.RuleID)
+		if inc.Severity == "" || alert.GetSeverityLevel() > worst.GetSeverityLevel() {
+			worst = alert
+			inc.Severity = alert.Severity
+		}
+	}
+
+	if inc.Title == "" {
+		inc.Title = fmt.Sprintf("%s from %s", worst.RuleName, worst.Source)
+		if n := len(alerts) - 1; n > 0 {
+			inc.Title += fmt.Sprintf(" and %d related alerts", n)
+		}
+	}
+	return inc
+}
+
+// AddAlert merges one more alert into the incident.
+func (inc *Incident) AddAlert(id string, alert model.Alert) {
+	if slices.Contains(inc.AlertIDs, id) {
+		return
+	}
+	inc.AlertIDs = append(inc.AlertIDs, id)
+	inc.addRule(alert.RuleID)
+	current := model.Alert{Severity: inc.Severity}
+	if alert.GetSeverityLevel() > current.GetSeverityLevel() {
+		inc.Severity = alert.Severity
+	}
+}
+
+// addRule records a rule involved in the incident. Alerts raised before
+// rules had IDs have none.
+func (inc *Incident) addRule(id string) {
+	if id != "" && !slices.Contains(inc.Rules, id) {
+		inc.Rules = append(inc.Rules, id)
+	}
+}
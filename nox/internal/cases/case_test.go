@@ -0,0 +1,110 @@
+package cases
+
+import (
+	"errors"
+	"nox/internal/model"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestUpdateApply(t *testing.T) {
+	now := time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+	nobody := ""
+	bob := "bob"
+	base := Triage{Status: StatusAcknowledged, Assignee: "alice", Tags: []string{"ssh", "prod"}}
+
+	tests := []struct {
+		name    string
+		update  Update
+		want    Triage
+		wantErr error
+	}{
+		{
+			name:   "empty update only stamps",
+			update: Update{},
+			want:   Triage{Status: StatusAcknowledged, Assignee: "alice", Tags: []string{"ssh", "prod"}},
+		},
+		{
+			name:   "status and reassignment",
+			update: Update{Status: StatusInProgress, Assignee: &bob},
+			want:   Triage{Status: StatusInProgress, Assignee: "bob", Tags: []string{"ssh", "prod"}},
+		},
+		{
+			name:   "unassign",
+			update: Update{Assignee: &nobody},
+			want:   Triage{Status: StatusAcknowledged, Tags: []string{"ssh", "prod"}},
+		},
+		{
+			name:   "tags are deduplicated and removed",
+			update: Update{AddTags: []string{"ssh", " scanner ", ""}, RemoveTags: []string{"prod"}},
+			want:   Triage{Status: StatusAcknowledged, Assignee: "alice", Tags: []string{"ssh", "scanner"}},
+		},
+		{
+			name:   "note",
+			update: Update{Status: StatusFalsePositive, Note: " nessus scan "},
+			want: Triage{Status: StatusFalsePositive, Assignee: "alice", Tags: []string{"ssh", "prod"},
+				Notes: []Note{{Author: "carol", Text: "nessus scan", Time: now}}},
+		},
+		{
+			name:    "unknown status",
+			update:  Update{Status: "closed"},
+			wantErr: ErrUnknownStatus,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.update.Apply(base, "carol", now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			tt.want.UpdatedAt, tt.want.UpdatedBy = now, "carol"
+			if got.Status != tt.want.Status || got.Assignee != tt.want.Assignee ||
+				!slices.Equal(got.Tags, tt.want.Tags) || !slices.Equal(got.Notes, tt.want.Notes) ||
+				got.UpdatedAt != tt.want.UpdatedAt || got.UpdatedBy != tt.want.UpdatedBy {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			if !slices.Equal(base.Tags, []string{"ssh", "prod"}) {
+				t.Fatalf("got base tags %v changed, want them untouched", base.Tags)
+			}
+		})
+	}
+}
+
+func TestNewIncident(t *testing.T) {
+	now := time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+	alerts := map[string]model.Alert{
+		"a1": {RuleName: "SSH Brute Force", RuleID: "ssh-brute-force", Severity: "MEDIUM", Source: "203.0.113.7"},
+		"a2": {RuleName: "Brute Force and Evasion", RuleID: "brute-force-evasion", Severity: "CRITICAL", Source: "203.0.113.7"},
+		"a3": {RuleName: "SSH Brute Force", RuleID: "ssh-brute-force", Severity: "MEDIUM", Source: "203.0.113.7"},
+	}
+
+	inc := NewIncident("inc-1", "", alerts, "alice", now)
+
+	if !slices.Equal(inc.AlertIDs, []string{"a1", "a2", "a3"}) {
+		t.Fatalf("got alert IDs %v, want a1, a2 and a3", inc.AlertIDs)
+	}
+	if !slices.Equal(inc.Rules, []string{"ssh-brute-force", "brute-force-evasion"}) {
+		t.Fatalf("got rules %v, want each rule once", inc.Rules)
+	}
+	if inc.Severity != "CRITICAL" {
+		t.Fatalf("got severity %s, want CRITICAL", inc.Severity)
+	}
+	if want := "Brute Force and Evasion from 203.0.113.7 and 2 related alerts"; inc.Title != want {
+		t.Fatalf("got title %q, want %q", inc.Title, want)
+	}
+	if inc.Triage.State() != StatusNew || inc.CreatedBy != "alice" {
+		t.Fatalf("got %+v, want a new incident created by alice", inc)
+	}
+
+	inc.AddAlert("a4", model.Alert{RuleID: "rapid-process-execution", Severity: "LOW"})
+	inc.AddAlert("a4", model.Alert{RuleID: "rapid-process-execution", Severity: "LOW"})
+	if len(inc.AlertIDs) != 4 || inc.Severity != "CRITICAL" || len(inc.Rules) != 3 {
+		t.Fatalf("got %+v after adding a4 twice, want it merged once", inc)
+	}
+}
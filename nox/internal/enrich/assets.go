@@ -0,0 +1,134 @@
+package enrich
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"nox/internal/model"
+	"strings"
+)
+
+// Asset is one entry in the asset inventory. Match is a host name, an IP or
+// a CIDR range.
+type Asset struct {
+	Match       string `yaml:"match"`
+	Name        string `yaml:"name"`
+	Owner       string `yaml:"owner"`
+	Criticality string `yaml:"criticality"`
+}
+
+// AssetInventory tags events with the owner and criticality of the host that
+// logged them (asset_*) and, when the source IP is one of ours, of the source
+// (source_asset_*).
+type AssetInventory struct {
+	hosts    map[string]Asset
+	ips      map[netip.Addr]Asset
+	prefixes []prefixAsset
+}
+
+type prefixAsset struct {
+	prefix netip.Prefix
+	asset  Asset
+}
+
+// LoadAssetInventory reads a CSV file with the header
+// match,name,owner,criticality, or a YAML list of the same fields.
+func LoadAssetInventory(path string) (*AssetInventory, error) {
+	var assets []Asset
+	err := loadTable(path, &assets, "match", func(row map[string]string) {
+		assets = append(assets, Asset{
+			Match:       row["match"],
+			Name:        row["name"],
+			Owner:       row["owner"],
+			Criticality: row["criticality"],
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load asset inventory: %w", err)
+	}
+
+	return NewAssetInventory(assets)
+}
+
+func NewAssetInventory(assets []Asset) (*AssetInventory, error) {
+	inv := &AssetInventory{
+		hosts: make(map[string]Asset),
+		ips:   make(map[netip.Addr]Asset),
+	}
+
+	for _, asset := range assets {
+		match := strings.TrimSpace(asset.Match)
+		if match == "" {
+			return nil, fmt.Errorf("asset %q has no match", asset.Name)
+		}
+		asset.Criticality = strings.ToUpper(asset.Criticality)
+
+		if strings.Contains(match, "/") {
+			prefix, err := netip.ParsePrefix(match)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q: %w", match, err)
+			}
+			inv.prefixes = append(inv.prefixes, prefixAsset{prefix: prefix.Masked(), asset: asset})
+		} else if addr, err := netip.ParseAddr(match); err == nil {
+			inv.ips[addr] = asset
+		} else {
+			inv.hosts[strings.ToLower(match)] = asset
+		}
+	}
+
+	return inv, nil
+}
+
+func (a *AssetInventory) Name() string { return "asset_inventory" }
+
+// Lookup finds the asset for a host name or IP. Exact matches win over CIDR
+// ranges, and the longest range wins among those.
+func (a *AssetInventory) Lookup(key string) (Asset, bool) {
+	if key == "" {
+		return Asset{}, false
+	}
+
+	addr, err := netip.ParseAddr(key)
+	if err != nil {
+		asset, ok := a.hosts[strings.ToLower(key)]
+		return asset, ok
+	}
+
+	if asset, ok := a.ips[addr]; ok {
+		return asset, true
+	}
+
+	var best prefixAsset
+	found := false
+	for _, p := range a.prefixes {
+		if p.prefix.Contains(addr) && (!found || p.prefix.Bits() > best.prefix.Bits()) {
+			best, found = p, true
+		}
+	}
+	return best.asset, found
+}
+
+func (a *AssetInventory) Enrich(event *model.Event) {
+	if asset, ok := a.Lookup(event.Metadata["host"]); ok {
+		setAsset(event, asset, FieldAssetName, FieldAssetOwner, FieldAssetCriticality)
+	}
+
+	if net.ParseIP(event.Source) == nil {
+		return
+	}
+	if asset, ok := a.Lookup(event.Source); ok {
+		setAsset(event, asset, FieldSourceAssetName, FieldSourceAssetOwner, FieldSourceCritical)
+	}
+}
+
+func setAsset(event *model.Event, asset Asset, nameKey, ownerKey, criticalityKey string) {
+	if asset.Name != "" {
+		event.Metadata[nameKey] = asset.Name
+	}
+	if asset.Owner != "" {
+		event.Metadata[ownerKey] = asset.Owner
+	}
+	if asset.Criticality != "" {
+		event.Metadata[criticalityKey] = asset.Criticality
+	}
+}
@@ -0,0 +1,178 @@
+package enrich
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"nox/internal/model"
+	"time"
+)
+
+// Metadata keys added by the enrichers. Rules reference them as
+// metadata.<key>, and the engine copies them onto alerts.
+const (
+	FieldCountry          = "country"
+	FieldCountryName      = "country_name"
+	FieldCity             = "city"
+	FieldLatitude         = "latitude"
+	FieldLongitude        = "longitude"
+	FieldASN              = "asn"
+	FieldASOrg            = "as_org"
+	FieldSourceHostname   = "source_hostname"
+	FieldAssetName        = "asset_name"
+	FieldAssetOwner       = "asset_owner"
+	FieldAssetCriticality = "asset_criticality"
+	FieldSourceAssetName  = "source_asset_name"
+	FieldSourceAssetOwner = "source_asset_owner"
+	FieldSourceCritical   = "source_asset_criticality"
+	FieldUserDepartment   = "user_department"
+	FieldUserPrivileged   = "user_privileged"
+	FieldParentProcess    = "parent_process_name"
+)
+
+// Fields lists every key an enricher may add, in a stable order.
+var Fields = []string{
+	FieldCountry, FieldCountryName, FieldCity, FieldLatitude, FieldLongitude,
+	FieldASN, FieldASOrg,
+	FieldSourceHostname,
+	FieldAssetName, FieldAssetOwner, FieldAssetCriticality,
+	FieldSourceAssetName, FieldSourceAssetOwner, FieldSourceCritical,
+	FieldUserDepartment, FieldUserPrivileged,
+	FieldParentProcess,
+}
+
+// Enricher adds context to an event before detection. Implementations are
+// called concurrently and must not block for long: a slow enricher stalls
+// its whole shard.
+type Enricher interface {
+	Name() string
+	Enrich(event *model.Event)
+}
+
+type Config struct {
+	GeoIPCityPath      string
+	GeoIPASNPath       string
+	AssetInventoryPath string // .csv, .yaml or .yml
+	UserDirectoryPath  string // .csv, .yaml or .yml
+	ReverseDNS         bool
+	ReverseDNSTTL      time.Duration
+}
+
+// Chain runs enrichers in order, so later ones can build on fields added by
+// earlier ones.
+type Chain struct {
+	enrichers []Enricher
+	closers   []io.Closer
+	processes *ProcessTree
+}
+
+func NewChain(enrichers ...Enricher) *Chain {
+	return &Chain{enrichers: enrichers}
+}
+
+// New builds the chain described by cfg. Every enricher is optional: one
+// whose data can't be loaded is logged and left out rather than stopping
+// the engine.
+func New(cfg Config, logger *slog.Logger) *Chain {
+	chain := &Chain{processes: NewProcessTree(defaultProcessTreePIDs)}
+	chain.add(chain.processes, nil)
+
+	if cfg.GeoIPCityPath != "" {
+		city, err := OpenGeoIPCity(cfg.GeoIPCityPath)
+		if err != nil {
+			logger.Warn("GeoIP city enrichment disabled", "error", err)
+		} else {
+			chain.add(city, city)
+		}
+	}
+
+	if cfg.GeoIPASNPath != "" {
+		asn, err := OpenGeoIPASN(cfg.GeoIPASNPath)
+		if err != nil {
+			logger.Warn("GeoIP ASN enrichment disabled", "error", err)
+		} else {
+			chain.add(asn, asn)
+		}
+	}
+
+	if cfg.ReverseDNS {
+		chain.add(NewReverseDNS(cfg.ReverseDNSTTL, defaultReverseDNSEntries, nil), nil)
+	}
+
+	if cfg.AssetInventoryPath != "" {
+		inventory, err := LoadAssetInventory(cfg.AssetInventoryPath)
+		if err != nil {
+			logger.Warn("asset inventory enrichment disabled", "error", err)
+		} else {
+			chain.add(inventory, nil)
+		}
+	}
+
+	if cfg.UserDirectoryPath != "" {
+		users, err := LoadUserDirectory(cfg.UserDirectoryPath)
+		if err != nil {
+			logger.Warn("user directory enrichment disabled", "error", err)
+		} else {
+			chain.add(users, nil)
+		}
+	}
+
+	logger.Info("Enrichment chain configured", "enrichers", chain.Names())
+	return chain
+}
+
+func (c *Chain) add(e Enricher, closer io.Closer) {
+	c.enrichers = append(c.enrichers, e)
+	if closer != nil {
+		c.closers = append(c.closers, closer)
+	}
+}
+
+// ProcessTree returns the chain's process tree, which the engine asks for
+// the ancestry of alerting processes. It is nil for a chain not built by
+// New.
+func (c *Chain) ProcessTree() *ProcessTree {
+	return c.processes
+}
+
+func (c *Chain) Names() []string {
+	names := make([]string, len(c.enrichers))
+	for i, e := range c.enrichers {
+		names[i] = e.Name()
+	}
+	return names
+}
+
+func (c *Chain) Enrich(event *model.Event) {
+	if event.Metadata == nil {
+		event.Metadata = make(map[string]string)
+	}
+	for _, e := range c.enrichers {
+		e.Enrich(event)
+	}
+}
+
+// Close releases databases held by the enrichers.
+func (c *Chain) Close() error {
+	var firstErr error
+	for _, closer := range c.closers {
+		if err := closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// publicSourceIP returns the event's source as an IP if it is one worth
+// looking up externally.
+func publicSourceIP(event *model.Event) net.IP {
+	if event.Source == "localhost" || event.Source == "" {
+		return nil
+	}
+
+	ip := net.ParseIP(event.Source)
+	if ip == nil || ip.IsPrivate() || ip.IsLoopback() {
+		return nil
+	}
+	return ip
+}
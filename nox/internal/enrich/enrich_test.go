@@ -0,0 +1,295 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"nox/internal/model"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newEvent(source, host, user string) *model.Event {
+	return &model.Event{
+		Source:   source,
+		Metadata: map[string]string{"host": host, "user": user},
+	}
+}
+
+func TestAssetInventoryFormats(t *testing.T) {
+	csvPath := writeFile(t, "assets.csv", `match,name,owner,criticality
+# domain controllers
+dc-1,Domain Controller,identity-team,critical
+10.0.0.0/8,Corp Network,netops,low
+10.1.2.0/24,Payments VLAN,payments,high
+10.1.2.3,Payments DB,payments-dba,critical
+`)
+	yamlPath := writeFile(t, "assets.yaml", `
+- match: dc-1
+  name: Domain Controller
+  owner: identity-team
+  criticality: critical
+- match: 10.0.0.0/8
+  name: Corp Network
+  owner: netops
+  criticality: low
+- match: 10.1.2.0/24
+  name: Payments VLAN
+  owner: payments
+  criticality: high
+- match: 10.1.2.3
+  name: Payments DB
+  owner: payments-dba
+  criticality: critical
+`)
+
+	tests := []struct {
+		key       string
+		wantName  string
+		wantFound bool
+	}{
+		{"DC-1", "Domain Controller", true},
+		{"10.1.2.3", "Payments DB", true},
+		{"10.1.2.4", "Payments VLAN", true},
+		{"10.9.9.9", "Corp Network", true},
+		{"203.0.113.5", "", false},
+		{"web-1", "", false},
+	}
+
+	for _, path := range []string{csvPath, yamlPath} {
+		inv, err := LoadAssetInventory(path)
+		if err != nil {
+			t.Fatalf("%s: got error %v, want nil", filepath.Base(path), err)
+		}
+
+		for _, tt := range tests {
+			asset, ok := inv.Lookup(tt.key)
+			if ok != tt.wantFound || asset.Name != tt.wantName {
+				t.Fatalf("%s: Lookup(%q) got %q, %v, want %q, %v", filepath.Base(path), tt.key, asset.Name, ok, tt.wantName, tt.wantFound)
+			}
+		}
+	}
+}
+
+func TestAssetInventoryEnrichesHostAndSource(t *testing.T) {
+	inv, err := NewAssetInventory([]Asset{
+		{Match: "dc-1", Name: "Domain Controller", Owner: "identity-team", Criticality: "critical"},
+		{Match: "10.1.0.0/16", Name: "Build Farm", Owner: "ci", Criticality: "medium"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := newEvent("10.1.4.4", "dc-1", "")
+	inv.Enrich(event)
+
+	want := map[string]string{
+		FieldAssetName:        "Domain Controller",
+		FieldAssetOwner:       "identity-team",
+		FieldAssetCriticality: "CRITICAL",
+		FieldSourceAssetName:  "Build Farm",
+		FieldSourceAssetOwner: "ci",
+		FieldSourceCritical:   "MEDIUM",
+	}
+	for field, value := range want {
+		if got := event.Metadata[field]; got != value {
+			t.Fatalf("got %s=%q, want %q", field, got, value)
+		}
+	}
+}
+
+func TestUserDirectory(t *testing.T) {
+	path := writeFile(t, "users.csv", `username,department,privileged
+alice,engineering,false
+root,it,true
+`)
+	dir, err := LoadUserDirectory(path)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	event := newEvent("", "web-1", "root")
+	dir.Enrich(event)
+	if event.Metadata[FieldUserDepartment] != "it" || event.Metadata[FieldUserPrivileged] != "true" {
+		t.Fatalf("got %v, want department it and privileged true", event.Metadata)
+	}
+
+	event = newEvent("", "web-1", "mallory")
+	dir.Enrich(event)
+	if _, ok := event.Metadata[FieldUserPrivileged]; ok {
+		t.Fatalf("got %v for unknown user, want no user fields", event.Metadata)
+	}
+}
+
+func TestLoadRejectsBadFiles(t *testing.T) {
+	if _, err := LoadUserDirectory(writeFile(t, "users.csv", "username,privileged\nroot,sometimes\n")); err == nil {
+		t.Fatalf("got nil error for invalid privileged value, want error")
+	}
+	if _, err := LoadAssetInventory(writeFile(t, "assets.csv", "name,owner\nweb,ops\n")); err == nil {
+		t.Fatalf("got nil error for missing match column, want error")
+	}
+	if _, err := LoadAssetInventory(writeFile(t, "assets.json", "[]")); err == nil {
+		t.Fatalf("got nil error for unsupported extension, want error")
+	}
+}
+
+func TestReverseDNSCachesLookups(t *testing.T) {
+	var lookups atomic.Int32
+	r := NewReverseDNS(time.Minute, 0, func(ctx context.Context, addr string) ([]string, error) {
+		lookups.Add(1)
+		if addr == "198.51.100.7" {
+			return nil, errors.New("no PTR record")
+		}
+		return []string{"scanner.example.net."}, nil
+	})
+
+	// the first event misses the cache and is not delayed by the lookup.
+	event := newEvent("203.0.113.9", "web-1", "")
+	r.Enrich(event)
+	if _, ok := event.Metadata[FieldSourceHostname]; ok {
+		t.Fatalf("got hostname on cache miss, want none")
+	}
+	r.pending.Wait()
+
+	event = newEvent("203.0.113.9", "web-1", "")
+	r.Enrich(event)
+	if got := event.Metadata[FieldSourceHostname]; got != "scanner.example.net" {
+		t.Fatalf("got hostname %q, want scanner.example.net", got)
+	}
+
+	// failures are cached as well.
+	r.Enrich(newEvent("198.51.100.7", "web-1", ""))
+	r.pending.Wait()
+	r.Enrich(newEvent("198.51.100.7", "web-1", ""))
+	r.Enrich(newEvent("10.0.0.1", "web-1", ""))
+
+	if n := lookups.Load(); n != 2 {
+		t.Fatalf("got %d lookups, want 2", n)
+	}
+}
+
+func TestChainRunsEnrichersInOrder(t *testing.T) {
+	inv, err := NewAssetInventory([]Asset{{Match: "web-1", Owner: "platform"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := NewChain(inv, NewUserDirectory([]User{{Username: "deploy", Department: "platform"}}))
+
+	event := &model.Event{Metadata: nil}
+	chain.Enrich(event)
+	if event.Metadata == nil {
+		t.Fatalf("got nil metadata, want the chain to allocate it")
+	}
+
+	event = newEvent("", "web-1", "deploy")
+	chain.Enrich(event)
+	if event.Metadata[FieldAssetOwner] != "platform" || event.Metadata[FieldUserPrivileged] != "false" {
+		t.Fatalf("got %v, want asset and user fields", event.Metadata)
+	}
+}
+
+func TestProcessTreeNamesParents(t *testing.T) {
+	tree := NewProcessTree(2)
+	exec := func(host, pid, ppid, name string) *model.Event {
+		event := &model.Event{
+			EventType: "Process_Executed",
+			Source:    "127.0.0.1",
+			Metadata:  map[string]string{"host": host, "pid": pid, "ppid": ppid, "process_name": name},
+		}
+		tree.Enrich(event)
+		return event
+	}
+
+	exec("build-1", "100", "1", "apt")
+	if got := exec("build-1", "101", "100", "curl").Metadata[FieldParentProcess]; got != "apt" {
+		t.Fatalf("got parent %q, want apt", got)
+	}
+	if got, ok := exec("web-1", "102", "100", "curl").Metadata[FieldParentProcess]; ok {
+		t.Fatalf("got parent %q from another host, want none", got)
+	}
+
+	// the third PID on build-1 overflows the bound and forgets apt, the
+	// least recently active.
+	exec("build-1", "103", "1", "make")
+	if _, ok := exec("build-1", "104", "100", "curl").Metadata[FieldParentProcess]; ok {
+		t.Fatalf("got a parent for a forgotten PID, want none")
+	}
+}
+
+func TestProcessTreeAncestry(t *testing.T) {
+	tree := NewProcessTree(100)
+	start := time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+	exec := func(at time.Duration, pid, ppid, name string) model.Event {
+		event := model.Event{
+			Timestamp: start.Add(at),
+			EventType: "Process_Executed",
+			Metadata:  map[string]string{"host": "web-1", "pid": pid, "ppid": ppid, "process_name": name, "command": name},
+		}
+		tree.Enrich(&event)
+		return event
+	}
+
+	exec(0, "812", "1", "sshd")
+	exec(time.Second, "900", "812", "bash")
+	wget := exec(2*time.Second, "901", "900", "wget")
+
+	var names []string
+	for _, proc := range tree.Ancestry(wget) {
+		names = append(names, proc.ProcessName)
+	}
+	if got := strings.Join(names, " < "); got != "wget < bash < sshd" {
+		t.Fatalf("got ancestry %q, want wget < bash < sshd", got)
+	}
+
+	// bash's PID is reused by a later process, which can't be wget's parent.
+	exec(3*time.Second, "900", "1", "cron")
+	if got := tree.Ancestry(wget); len(got) != 1 {
+		t.Fatalf("got %d processes, want wget alone once its parent's PID is reused", len(got))
+	}
+}
+
+func TestProcessTreeAncestrySurvivesCapacity(t *testing.T) {
+	tree := NewProcessTree(4)
+	start := time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+	exec := func(at int, pid, ppid, name string) model.Event {
+		event := model.Event{
+			Timestamp: start.Add(time.Duration(at) * time.Second),
+			EventType: "Process_Executed",
+			Metadata:  map[string]string{"host": "web-1", "pid": pid, "ppid": ppid, "process_name": name},
+		}
+		tree.Enrich(&event)
+		return event
+	}
+
+	exec(0, "812", "1", "sshd")
+	exec(1, "900", "812", "bash")
+	// a busy shell runs far more commands than the tree holds
+	for i := range 50 {
+		exec(2+i, strconv.Itoa(1000+i), "900", "ls")
+	}
+	wget := exec(60, "2000", "900", "wget")
+
+	var names []string
+	for _, proc := range tree.Ancestry(wget) {
+		names = append(names, proc.ProcessName)
+	}
+	if got := strings.Join(names, " < "); got != "wget < bash < sshd" {
+		t.Fatalf("got ancestry %q, want wget < bash < sshd", got)
+	}
+	if got := tree.Ancestry(model.Event{EventType: "Process_Executed", Metadata: map[string]string{"host": "web-1", "pid": "1000"}}); len(got) != 0 {
+		t.Fatalf("got %d processes for the first ls, want it forgotten", len(got))
+	}
+}
@@ -0,0 +1,89 @@
+package enrich
+
+import (
+	"fmt"
+	"nox/internal/model"
+	"strconv"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// GeoIPCity adds country, city and coordinates for public source IPs.
+type GeoIPCity struct {
+	db *geoip2.Reader
+}
+
+func OpenGeoIPCity(path string) (*GeoIPCity, error) {
+	db, err := geoip2.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP city database: %w", err)
+	}
+	return &GeoIPCity{db: db}, nil
+}
+
+func (g *GeoIPCity) Name() string { return "geoip_city" }
+
+func (g *GeoIPCity) Enrich(event *model.Event) {
+	ip := publicSourceIP(event)
+	if ip == nil {
+		return
+	}
+
+	record, err := g.db.City(ip)
+	if err != nil {
+		return
+	}
+
+	// add geographic metadata
+	event.Metadata[FieldCountry] = record.Country.IsoCode
+	event.Metadata[FieldCountryName] = record.Country.Names["en"]
+	if len(record.City.Names) > 0 {
+		event.Metadata[FieldCity] = record.City.Names["en"]
+	}
+
+	// add coordinates for geographic analysis
+	if record.Location.Latitude != 0 && record.Location.Longitude != 0 {
+		event.Metadata[FieldLatitude] = fmt.Sprintf("%.4f", record.Location.Latitude)
+		event.Metadata[FieldLongitude] = fmt.Sprintf("%.4f", record.Location.Longitude)
+	}
+}
+
+func (g *GeoIPCity) Close() error {
+	return g.db.Close()
+}
+
+// GeoIPASN adds the autonomous system number and organisation from a
+// GeoLite2-ASN database, which is how hosting providers and VPN exits are
+// usually recognised.
+type GeoIPASN struct {
+	db *geoip2.Reader
+}
+
+func OpenGeoIPASN(path string) (*GeoIPASN, error) {
+	db, err := geoip2.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP ASN database: %w", err)
+	}
+	return &GeoIPASN{db: db}, nil
+}
+
+func (g *GeoIPASN) Name() string { return "geoip_asn" }
+
+func (g *GeoIPASN) Enrich(event *model.Event) {
+	ip := publicSourceIP(event)
+	if ip == nil {
+		return
+	}
+
+	record, err := g.db.ASN(ip)
+	if err != nil || record.AutonomousSystemNumber == 0 {
+		return
+	}
+
+	event.Metadata[FieldASN] = strconv.FormatUint(uint64(record.AutonomousSystemNumber), 10)
+	event.Metadata[FieldASOrg] = record.AutonomousSystemOrganization
+}
+
+func (g *GeoIPASN) Close() error {
+	return g.db.Close()
+}
@@ -0,0 +1,144 @@
+package enrich
+
+import (
+	"container/list"
+	"nox/internal/model"
+	"sync"
+)
+
+// defaultProcessTreePIDs bounds the PIDs remembered per host.
+const defaultProcessTreePIDs = 16384
+
+// maxAncestryDepth bounds how far Ancestry walks up the tree.
+const maxAncestryDepth = 20
+
+// ProcessTree remembers the last process executed under every PID, per
+// host. It names the parent of every executed process, from the
+// process_name of the last event that reported the parent's PID, and gives
+// alerts the ancestry of their process. A parent that started before nox
+// did, or was forgotten, is left unnamed.
+//
+// Once a host has maxPIDs processes, the least recently active is
+// forgotten. Executing a process makes it and its ancestors active, so a
+// long-lived sshd or shell is kept for as long as it has children running.
+type ProcessTree struct {
+	mu      sync.Mutex
+	maxPIDs int
+	hosts   map[string]*pidTable
+}
+
+// pidTable holds one host's processes, the most recently active first.
+type pidTable struct {
+	order *list.List // of model.Process
+	pids  map[string]*list.Element
+}
+
+func NewProcessTree(maxPIDs int) *ProcessTree {
+	return &ProcessTree{
+		maxPIDs: maxPIDs,
+		hosts:   make(map[string]*pidTable),
+	}
+}
+
+func (t *ProcessTree) Name() string { return "process_tree" }
+
+func (t *ProcessTree) Enrich(event *model.Event) {
+	if event.EventType != "Process_Executed" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	host := processHost(*event)
+	table, ok := t.hosts[host]
+	if !ok {
+		table = &pidTable{order: list.New(), pids: make(map[string]*list.Element)}
+		t.hosts[host] = table
+	}
+
+	if parent, ok := table.pids[event.Metadata["ppid"]]; ok {
+		event.Metadata[FieldParentProcess] = parent.Value.(model.Process).ProcessName
+	}
+
+	pid, name := event.Metadata["pid"], event.Metadata["process_name"]
+	if pid == "" || name == "" {
+		return
+	}
+	proc := model.Process{
+		Timestamp:   event.Timestamp,
+		PID:         pid,
+		PPID:        event.Metadata["ppid"],
+		ProcessName: name,
+		Command:     event.Metadata["command"],
+		UID:         event.Metadata["uid"],
+	}
+	if elem, ok := table.pids[pid]; ok {
+		elem.Value = proc
+	} else {
+		if table.order.Len() >= t.maxPIDs {
+			oldest := table.order.Back()
+			table.order.Remove(oldest)
+			delete(table.pids, oldest.Value.(model.Process).PID)
+		}
+		table.pids[pid] = table.order.PushFront(proc)
+	}
+
+	// Ancestors first, so the new process ends up the most recent.
+	chain := table.ancestry(pid)
+	for i := len(chain) - 1; i >= 0; i-- {
+		table.order.MoveToFront(chain[i])
+	}
+}
+
+// Ancestry returns the process of a Process_Executed event followed by its
+// parents, as far as they are remembered. It stops at a parent that started
+// after its child, since its PID has been reused since.
+func (t *ProcessTree) Ancestry(event model.Event) []model.Process {
+	pid := event.Metadata["pid"]
+	if event.EventType != "Process_Executed" || pid == "" {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	table, ok := t.hosts[processHost(event)]
+	if !ok {
+		return nil
+	}
+	var out []model.Process
+	for _, elem := range table.ancestry(pid) {
+		out = append(out, elem.Value.(model.Process))
+	}
+	return out
+}
+
+// ancestry returns the entries of pid and of its remembered parents.
+func (p *pidTable) ancestry(pid string) []*list.Element {
+	var out []*list.Element
+	var child model.Process
+	for range maxAncestryDepth {
+		elem, ok := p.pids[pid]
+		if !ok {
+			break
+		}
+		proc := elem.Value.(model.Process)
+		if len(out) > 0 && proc.Timestamp.After(child.Timestamp) {
+			break
+		}
+		out = append(out, elem)
+		if proc.PPID == "" || proc.PPID == proc.PID {
+			break
+		}
+		child, pid = proc, proc.PPID
+	}
+	return out
+}
+
+func processHost(event model.Event) string {
+	if host := event.Metadata["host"]; host != "" {
+		return host
+	}
+	return event.Source
+}
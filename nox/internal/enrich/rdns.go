@@ -0,0 +1,137 @@
+package enrich
+
+import (
+	"context"
+	"net"
+	"nox/internal/model"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultReverseDNSTTL     = time.Hour
+	defaultReverseDNSEntries = 10_000
+	reverseDNSTimeout        = 2 * time.Second
+	maxConcurrentLookups     = 16
+)
+
+// LookupAddrFunc resolves an IP to host names, like net.Resolver.LookupAddr.
+type LookupAddrFunc func(ctx context.Context, addr string) ([]string, error)
+
+// ReverseDNS adds the PTR name of public source IPs. Lookups never block the
+// pipeline: a cache miss starts a background lookup and the event goes on
+// without the field, so only later events from the same IP carry it.
+// Failed lookups are cached too, so an unresolvable scanner costs one query
+// per TTL.
+type ReverseDNS struct {
+	lookup     LookupAddrFunc
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+	sem        chan struct{}
+	pending    sync.WaitGroup
+
+	mu       sync.Mutex
+	cache    map[string]dnsEntry
+	inFlight map[string]bool
+}
+
+type dnsEntry struct {
+	hostname string
+	expires  time.Time
+}
+
+// NewReverseDNS creates a reverse DNS enricher. A nil lookup uses the system
+// resolver.
+func NewReverseDNS(ttl time.Duration, maxEntries int, lookup LookupAddrFunc) *ReverseDNS {
+	if ttl <= 0 {
+		ttl = defaultReverseDNSTTL
+	}
+	if maxEntries <= 0 {
+		maxEntries = defaultReverseDNSEntries
+	}
+	if lookup == nil {
+		lookup = net.DefaultResolver.LookupAddr
+	}
+
+	return &ReverseDNS{
+		lookup:     lookup,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		sem:        make(chan struct{}, maxConcurrentLookups),
+		cache:      make(map[string]dnsEntry),
+		inFlight:   make(map[string]bool),
+	}
+}
+
+func (r *ReverseDNS) Name() string { return "reverse_dns" }
+
+func (r *ReverseDNS) Enrich(event *model.Event) {
+	ip := publicSourceIP(event)
+	if ip == nil {
+		return
+	}
+	addr := ip.String()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if entry, ok := r.cache[addr]; ok && r.now().Before(entry.expires) {
+		if entry.hostname != "" {
+			event.Metadata[FieldSourceHostname] = entry.hostname
+		}
+		return
+	}
+
+	if r.inFlight[addr] {
+		return
+	}
+	select {
+	case r.sem <- struct{}{}:
+	default:
+		// resolver saturated; a later event will retry.
+		return
+	}
+
+	r.inFlight[addr] = true
+	r.pending.Add(1)
+	go r.resolve(addr)
+}
+
+func (r *ReverseDNS) resolve(addr string) {
+	defer r.pending.Done()
+	defer func() { <-r.sem }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), reverseDNSTimeout)
+	defer cancel()
+
+	var hostname string
+	if names, err := r.lookup(ctx, addr); err == nil && len(names) > 0 {
+		hostname = strings.TrimSuffix(names[0], ".")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.inFlight, addr)
+	now := r.now()
+	if len(r.cache) >= r.maxEntries {
+		r.evictLocked(now)
+	}
+	r.cache[addr] = dnsEntry{hostname: hostname, expires: now.Add(r.ttl)}
+}
+
+// evictLocked drops expired entries, or every entry if none have expired,
+// which is cheaper than tracking recency for a cache that refills itself.
+func (r *ReverseDNS) evictLocked(now time.Time) {
+	for addr, entry := range r.cache {
+		if !now.Before(entry.expires) {
+			delete(r.cache, addr)
+		}
+	}
+	if len(r.cache) >= r.maxEntries {
+		clear(r.cache)
+	}
+}
@@ -0,0 +1,64 @@
+package enrich
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadTable reads path as YAML into out, or as CSV with a header row, calling
+// addRow with each row keyed by the lower-cased header. keyColumn is the one
+// column a CSV file must have.
+func loadTable(path string, out any, keyColumn string, addRow func(row map[string]string)) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("failed to unmarshal yaml: %w", err)
+		}
+		return nil
+	case ".csv":
+	default:
+		return fmt.Errorf("unsupported file type %q, want .csv, .yaml or .yml", filepath.Ext(path))
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return fmt.Errorf("failed to parse csv: %w", err)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	header := make([]string, len(records[0]))
+	for i, name := range records[0] {
+		header[i] = strings.ToLower(strings.TrimSpace(name))
+	}
+	if !slices.Contains(header, keyColumn) {
+		return fmt.Errorf("csv header is missing the %q column", keyColumn)
+	}
+
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, value := range record {
+			if i < len(header) {
+				row[header[i]] = strings.TrimSpace(value)
+			}
+		}
+		addRow(row)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package enrich
+
+import (
+	"fmt"
+	"nox/internal/model"
+	"strconv"
+)
+
+type User struct {
+	Username   string `yaml:"username"`
+	Department string `yaml:"department"`
+	Privileged bool   `yaml:"privileged"`
+}
+
+// UserDirectory tags events that name a user with their department and
+// whether the account is privileged.
+type UserDirectory struct {
+	users map[string]User
+}
+
+// LoadUserDirectory reads a CSV file with the header
+// username,department,privileged, or a YAML list of the same fields.
+func LoadUserDirectory(path string) (*UserDirectory, error) {
+	var users []User
+	var rowErr error
+	err := loadTable(path, &users, "username", func(row map[string]string) {
+		privileged := false
+		if v := row["privileged"]; v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil && rowErr == nil {
+				rowErr = fmt.Errorf("user %q: invalid privileged value %q", row["username"], v)
+			}
+			privileged = b
+		}
+		users = append(users, User{
+			Username:   row["username"],
+			Department: row["department"],
+			Privileged: privileged,
+		})
+	})
+	if err == nil {
+		err = rowErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user directory: %w", err)
+	}
+
+	return NewUserDirectory(users), nil
+}
+
+func NewUserDirectory(users []User) *UserDirectory {
+	d := &UserDirectory{users: make(map[string]User, len(users))}
+	for _, user := range users {
+		d.users[user.Username] = user
+	}
+	return d
+}
+
+func (d *UserDirectory) Name() string { return "user_directory" }
+
+func (d *UserDirectory) Lookup(username string) (User, bool) {
+	user, ok := d.users[username]
+	return user, ok
+}
+
+func (d *UserDirectory) Enrich(event *model.Event) {
+	user, ok := d.users[event.Metadata["user"]]
+	if !ok {
+		return
+	}
+
+	if user.Department != "" {
+		event.Metadata[FieldUserDepartment] = user.Department
+	}
+	event.Metadata[FieldUserPrivileged] = strconv.FormatBool(user.Privileged)
+}
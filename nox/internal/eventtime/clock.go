@@ -0,0 +1,125 @@
+package eventtime
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	lateEventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "nox_late_events_total",
+		Help: "Events that arrived behind the watermark, by input. They are stored but not evaluated by rules.",
+	}, []string{"input"})
+
+	inputWatermark = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "nox_input_watermark_seconds",
+		Help: "Current watermark of each input as a Unix timestamp.",
+	}, []string{"input"})
+)
+
+func init() {
+	prometheus.MustRegister(lateEventsTotal)
+	prometheus.MustRegister(inputWatermark)
+}
+
+// Clock tracks event time across inputs. Each input's watermark trails the
+// newest event it has produced by the allowed lateness; the clock's
+// watermark is the slowest active input's, so one input running behind
+// never causes another's events to be judged late. Inputs that stay silent
+// for longer than the idle timeout stop holding the watermark back.
+type Clock struct {
+	allowedLateness time.Duration
+	idleTimeout     time.Duration
+	wallNow         func() time.Time
+
+	mu     sync.Mutex
+	inputs map[string]*inputState
+}
+
+type inputState struct {
+	maxEventTime time.Time
+	lastSeen     time.Time // wall clock, for idleness only
+}
+
+func NewClock(allowedLateness, idleTimeout time.Duration) *Clock {
+	return &Clock{
+		allowedLateness: allowedLateness,
+		idleTimeout:     idleTimeout,
+		wallNow:         time.Now,
+		inputs:          make(map[string]*inputState),
+	}
+}
+
+// Observe records an event from input and reports whether it is late, i.e.
+// older than the watermark was before it arrived.
+func (c *Clock) Observe(input string, t time.Time) (late bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	watermark := c.watermarkLocked()
+	late = !watermark.IsZero() && t.Before(watermark)
+
+	state, ok := c.inputs[input]
+	if !ok {
+		state = &inputState{}
+		c.inputs[input] = state
+	}
+	state.lastSeen = c.wallNow()
+	if t.After(state.maxEventTime) {
+		state.maxEventTime = t
+		inputWatermark.WithLabelValues(input).Set(float64(t.Add(-c.allowedLateness).Unix()))
+	}
+
+	if late {
+		lateEventsTotal.WithLabelValues(input).Inc()
+	}
+	return late
+}
+
+// Watermark is the event time up to which all inputs are considered
+// complete. It is zero until the first event arrives.
+func (c *Clock) Watermark() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.watermarkLocked()
+}
+
+func (c *Clock) watermarkLocked() time.Time {
+	var watermark, newest time.Time
+	now := c.wallNow()
+
+	for _, state := range c.inputs {
+		if state.maxEventTime.After(newest) {
+			newest = state.maxEventTime
+		}
+		if c.idleTimeout > 0 && now.Sub(state.lastSeen) > c.idleTimeout {
+			continue
+		}
+		if watermark.IsZero() || state.maxEventTime.Before(watermark) {
+			watermark = state.maxEventTime
+		}
+	}
+
+	// every input is idle: fall back to the newest event seen anywhere.
+	if watermark.IsZero() {
+		watermark = newest
+	}
+	if watermark.IsZero() {
+		return watermark
+	}
+
+	return watermark.Add(-c.allowedLateness)
+}
+
+// Reference is the time used to resolve partial timestamps, such as sshd's
+// missing year: the watermark once events have been seen, the wall clock
+// before that.
+func (c *Clock) Reference() time.Time {
+	if watermark := c.Watermark(); !watermark.IsZero() {
+		return watermark
+	}
+	return c.wallNow().UTC()
+}
@@ -0,0 +1,73 @@
+package eventtime
+
+import (
+	"testing"
+	"time"
+)
+
+var baseTime = time.Date(2026, time.June, 19, 12, 0, 0, 0, time.UTC)
+
+func newTestClock(lateness, idle time.Duration, wall *time.Time) *Clock {
+	c := NewClock(lateness, idle)
+	c.wallNow = func() time.Time { return *wall }
+	return c
+}
+
+func TestWatermarkTrailsSlowestInput(t *testing.T) {
+	wall := baseTime
+	c := newTestClock(30*time.Second, 0, &wall)
+
+	if !c.Watermark().IsZero() {
+		t.Fatalf("got watermark %s before any events, want zero", c.Watermark())
+	}
+
+	c.Observe("auth.log", baseTime.Add(5*time.Minute))
+	c.Observe("execsnoop.log", baseTime.Add(time.Minute))
+
+	want := baseTime.Add(time.Minute - 30*time.Second)
+	if got := c.Watermark(); !got.Equal(want) {
+		t.Fatalf("got watermark %s, want %s", got, want)
+	}
+}
+
+func TestObserveReportsLateEvents(t *testing.T) {
+	wall := baseTime
+	c := newTestClock(30*time.Second, 0, &wall)
+
+	c.Observe("auth.log", baseTime.Add(2*time.Minute))
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want bool
+	}{
+		{"within allowed lateness", baseTime.Add(100 * time.Second), false},
+		{"behind the watermark", baseTime.Add(time.Minute), true},
+		{"newer than every event", baseTime.Add(3 * time.Minute), false},
+	}
+
+	for _, tt := range tests {
+		if got := c.Observe("auth.log", tt.at); got != tt.want {
+			t.Fatalf("%s: got late=%v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIdleInputsDoNotHoldWatermarkBack(t *testing.T) {
+	wall := baseTime
+	c := newTestClock(0, time.Minute, &wall)
+
+	c.Observe("quiet.log", baseTime)
+	wall = wall.Add(2 * time.Minute)
+	c.Observe("auth.log", baseTime.Add(time.Hour))
+
+	if got, want := c.Watermark(), baseTime.Add(time.Hour); !got.Equal(want) {
+		t.Fatalf("got watermark %s, want %s", got, want)
+	}
+
+	// every input idle: the newest event seen still bounds the watermark.
+	wall = wall.Add(time.Hour)
+	if got, want := c.Watermark(), baseTime.Add(time.Hour); !got.Equal(want) {
+		t.Fatalf("got watermark %s with all inputs idle, want %s", got, want)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"nox/internal/model"
+	"time"
 
 	"github.com/hpcloud/tail"
 )
@@ -18,16 +19,20 @@ type Ingester struct {
 	parsers []Parser
 }
 
-func NewIngester(logger *slog.Logger) *Ingester {
+// NewIngester creates an ingester whose parsers resolve partial timestamps
+// against reference, normally the event-time clock's.
+func NewIngester(logger *slog.Logger, reference func() time.Time) *Ingester {
 	return &Ingester{
 		logger: logger,
 		parsers: []Parser{
-			NewSSHDParser(),
+			NewSSHDParser(reference),
 			NewExecsnoopParser(),
 		},
 	}
 }
 
+// ParseLog parses logline with the first parser that recognises it. The
+// event keeps the line as Raw.
 func (i *Ingester) ParseLog(logline string) (model.Event, error) {
 	for _, parser := range i.parsers {
 		event, err := parser.Parse(logline)
@@ -37,13 +42,22 @@ func (i *Ingester) ParseLog(logline string) (model.Event, error) {
 			return model.Event{}, fmt.Errorf("parser failed on recognized line: %w", err)
 		}
 
-		return event, err
+		event.Raw = logline
+		return event, nil
 	}
 
 	return model.Event{}, model.ErrIgnoredLine
 }
 
-func (i *Ingester) TailFile(ctx context.Context, fpath string, ch chan<- model.Event) error {
+// Line is a raw log line and the input it was read from.
+type Line struct {
+	Input string
+	Text  string
+}
+
+// TailFile follows fpath and sends every non-empty line on ch. Parsing is
+// left to the caller so it can run as its own pipeline stage.
+func (i *Ingester) TailFile(ctx context.Context, fpath string, ch chan<- Line) error {
 	t, err := tail.TailFile(fpath, tail.Config{Follow: true, ReOpen: true, Logger: tail.DiscardingLogger})
 	if err != nil {
 		return fmt.Errorf("failed to tail file: %v", err)
@@ -65,18 +79,8 @@ func (i *Ingester) TailFile(ctx context.Context, fpath string, ch chan<- model.E
 				continue
 			}
 
-			event, err := i.ParseLog(line.Text)
-			if err == model.ErrIgnoredLine {
-				i.logger.Debug("Ignoring log line", "line", line.Text)
-				continue
-			} else if err != nil {
-				i.logger.Error("failed to parse line", "error", err, "line", line.Text)
-				break
-			}
-			i.logger.Debug("Parsed event", "type", event.EventType, "source", event.Source)
-
 			select {
-			case ch <- event:
+			case ch <- Line{Input: fpath, Text: line.Text}:
 			case <-ctx.Done():
 				i.logger.Info("Stopping log file tailing due to context cancellation.", "path", fpath)
 				return nil
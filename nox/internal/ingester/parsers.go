@@ -23,15 +23,31 @@ const (
 	execsnoopTimeFormat = time.RFC3339
 )
 
+// EventFields lists the metadata keys each parser sets, by event type. The
+// rule linter uses it to spot conditions no event can satisfy.
+var EventFields = map[string][]string{
+	"SSHD_Failed_Password":   {"user", "host"},
+	"SSHD_Accepted_Password": {"user", "sshd_pid", "host"},
+	"Process_Executed":       {"uid", "process_name", "pid", "ppid", "return_code", "command"},
+}
+
 type sshdParser struct {
 	failedLoginRegex   *regexp.Regexp
 	acceptedLoginRegex *regexp.Regexp
+	// reference returns the event time used to infer the year sshd leaves
+	// out of its timestamps.
+	reference func() time.Time
 }
 
-func NewSSHDParser() Parser {
+func NewSSHDParser(reference func() time.Time) Parser {
+	if reference == nil {
+		reference = time.Now
+	}
+
 	return &sshdParser{
-		failedLoginRegex:   regexp.MustCompile(`^(\w+\s+\d+\s+[\d:]+)\s+my-server sshd\[\d+\]: Failed password for .*?(\S+) from ([\d\.]+)`),
-		acceptedLoginRegex: regexp.MustCompile(`^(\w+\s+\d+\s+[\d:]+)\s+my-server sshd\[(\d+)\]: Accepted password for (\S+) from ([\d\.]+)`),
+		reference:          reference,
+		failedLoginRegex:   regexp.MustCompile(`^(\w+\s+\d+\s+[\d:]+)\s+(\S+)\s+sshd\[\d+\]: Failed password for .*?(\S+) from ([\d\.]+)`),
+		acceptedLoginRegex: regexp.MustCompile(`^(\w+\s+\d+\s+[\d:]+)\s+(\S+)\s+sshd\[(\d+)\]: Accepted password for (\S+) from ([\d\.]+)`),
 	}
 }
 
@@ -45,8 +61,11 @@ func (p *sshdParser) Parse(logLine string) (model.Event, error) {
 		return model.Event{
 			Timestamp: ts,
 			EventType: "SSHD_Failed_Password",
-			Source:    matches[3],
-			Metadata:  map[string]string{"user": matches[2]},
+			Source:    matches[4],
+			Metadata: map[string]string{
+				"user": matches[3],
+				"host": matches[2],
+			},
 		}, nil
 	}
 
@@ -59,10 +78,11 @@ func (p *sshdParser) Parse(logLine string) (model.Event, error) {
 		return model.Event{
 			Timestamp: ts,
 			EventType: "SSHD_Accepted_Password",
-			Source:    matches[4],
+			Source:    matches[5],
 			Metadata: map[string]string{
-				"user":     matches[3],
-				"sshd_pid": matches[2],
+				"user":     matches[4],
+				"sshd_pid": matches[3],
+				"host":     matches[2],
 			},
 		}, nil
 	}
@@ -76,11 +96,29 @@ func (p *sshdParser) parseSSHDTimestamp(timstamp string) (time.Time, error) {
 		return time.Time{}, fmt.Errorf("failed to parse sshd timestamp: %w", err)
 	}
 
-	if ts.Year() == 0 {
-		ts = ts.AddDate(time.Now().UTC().Year(), 0, 0)
+	return inferYear(ts, p.reference().UTC()), nil
+}
+
+// inferYear places a year-less timestamp in whichever of the reference's
+// previous, current or next year puts it closest to the reference, so a
+// "Dec 31 23:59:59" line read just after New Year lands in the old year.
+func inferYear(ts, reference time.Time) time.Time {
+	var best time.Time
+	var bestDistance time.Duration = -1
+
+	for _, year := range []int{reference.Year() - 1, reference.Year(), reference.Year() + 1} {
+		candidate := time.Date(year, ts.Month(), ts.Day(), ts.Hour(), ts.Minute(), ts.Second(), ts.Nanosecond(), time.UTC)
+
+		distance := candidate.Sub(reference)
+		if distance < 0 {
+			distance = -distance
+		}
+		if bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
 	}
 
-	return ts, nil
+	return best
 }
 
 type execsnoopParser struct {
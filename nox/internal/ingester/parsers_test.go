@@ -0,0 +1,84 @@
+package ingester
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestInferYearAcrossNewYear(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		reference time.Time
+		want      time.Time
+	}{
+		{
+			name:      "late December line read after New Year",
+			line:      "Dec 31 23:59:59",
+			reference: time.Date(2027, time.January, 1, 0, 0, 30, 0, time.UTC),
+			want:      time.Date(2026, time.December, 31, 23, 59, 59, 0, time.UTC),
+		},
+		{
+			name:      "early January line read before New Year",
+			line:      "Jan  1 00:00:10",
+			reference: time.Date(2026, time.December, 31, 23, 59, 50, 0, time.UTC),
+			want:      time.Date(2027, time.January, 1, 0, 0, 10, 0, time.UTC),
+		},
+		{
+			name:      "mid-year line",
+			line:      "Jun 19 12:00:00",
+			reference: time.Date(2026, time.June, 19, 12, 5, 0, 0, time.UTC),
+			want:      time.Date(2026, time.June, 19, 12, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewSSHDParser(func() time.Time { return tt.reference }).(*sshdParser)
+
+			got, err := parser.parseSSHDTimestamp(tt.line)
+			if err != nil {
+				t.Fatalf("got error %v, want nil", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventFieldsMatchParsers(t *testing.T) {
+	ingester := NewIngester(slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	lines := []string{
+		"Aug 24 13:30:00 my-server sshd[8888]: Failed password for root from 192.168.1.50 port 12345 ssh2",
+		"Aug 24 13:30:00 my-server sshd[8888]: Accepted password for jsmith from 192.168.1.50 port 12345 ssh2",
+		"2026-06-19T12:00:00Z 0 ls 1234 567 0 /bin/ls -la /tmp",
+	}
+
+	seen := make(map[string]bool)
+	for _, line := range lines {
+		event, err := ingester.ParseLog(line)
+		if err != nil {
+			t.Fatalf("got error %v for %q, want nil", err, line)
+		}
+		seen[event.EventType] = true
+
+		fields := EventFields[event.EventType]
+		if len(fields) != len(event.Metadata) {
+			t.Fatalf("got %d fields for %s, want %d", len(event.Metadata), event.EventType, len(fields))
+		}
+		for _, field := range fields {
+			if _, ok := event.Metadata[field]; !ok {
+				t.Fatalf("got no %s field on %s, want it set", field, event.EventType)
+			}
+		}
+	}
+
+	for eventType := range EventFields {
+		if !seen[eventType] {
+			t.Fatalf("got no sample line for %s", eventType)
+		}
+	}
+}
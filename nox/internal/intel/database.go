@@ -0,0 +1,116 @@
+package intel
+
+import (
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// Database is an immutable, indexed set of indicators. Refreshing builds a
+// new one and swaps it in, so lookups never take a lock.
+type Database struct {
+	ips     *prefixTrie
+	domains map[string][]*Indicator
+	urls    map[string][]*Indicator
+	hashes  map[string][]*Indicator
+	size    int
+}
+
+func NewDatabase(indicators []Indicator) *Database {
+	db := &Database{
+		ips:     newPrefixTrie(),
+		domains: make(map[string][]*Indicator),
+		urls:    make(map[string][]*Indicator),
+		hashes:  make(map[string][]*Indicator),
+	}
+
+	for i := range indicators {
+		indicator := &indicators[i]
+		if indicator.normalize() != nil {
+			continue
+		}
+
+		switch indicator.Type {
+		case TypeIP:
+			prefix, _ := parsePrefix(indicator.Value)
+			db.ips.Insert(prefix, indicator)
+		case TypeDomain:
+			db.domains[indicator.Value] = append(db.domains[indicator.Value], indicator)
+		case TypeURL:
+			db.urls[indicator.Value] = append(db.urls[indicator.Value], indicator)
+		case TypeHash:
+			db.hashes[indicator.Value] = append(db.hashes[indicator.Value], indicator)
+		}
+		db.size++
+	}
+
+	return db
+}
+
+// Len is the number of valid indicators in the database.
+func (db *Database) Len() int {
+	return db.size
+}
+
+// Match is an indicator hit on one observable from an event.
+type Match struct {
+	Observable string
+	Indicator  *Indicator
+}
+
+// MatchIP finds indicators whose range contains ip, preferring the most
+// specific range.
+func (db *Database) MatchIP(ip string, at time.Time) []Match {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil
+	}
+	return matches(ip, db.ips.Lookup(addr, at))
+}
+
+// MatchDomain finds indicators for domain or any parent of it, so an
+// indicator for evil.com also covers cdn.evil.com.
+func (db *Database) MatchDomain(domain string, at time.Time) []Match {
+	domain = normalizeDomain(domain)
+	for candidate := domain; candidate != ""; {
+		if live := liveIndicators(db.domains[candidate], at); len(live) > 0 {
+			return matches(domain, live)
+		}
+		_, parent, found := strings.Cut(candidate, ".")
+		if !found || !strings.Contains(parent, ".") {
+			break
+		}
+		candidate = parent
+	}
+	return nil
+}
+
+// MatchURL finds indicators for the exact URL, then for its host.
+func (db *Database) MatchURL(raw string, at time.Time) []Match {
+	normalized, ok := normalizeURL(raw)
+	if !ok {
+		return nil
+	}
+	if live := liveIndicators(db.urls[normalized], at); len(live) > 0 {
+		return matches(normalized, live)
+	}
+
+	host := hostOf(normalized)
+	if _, err := netip.ParseAddr(host); err == nil {
+		return db.MatchIP(host, at)
+	}
+	return db.MatchDomain(host, at)
+}
+
+func (db *Database) MatchHash(hash string, at time.Time) []Match {
+	hash = strings.ToLower(strings.TrimSpace(hash))
+	return matches(hash, liveIndicators(db.hashes[hash], at))
+}
+
+func matches(observable string, indicators []*Indicator) []Match {
+	var result []Match
+	for _, indicator := range indicators {
+		result = append(result, Match{Observable: observable, Indicator: indicator})
+	}
+	return result
+}
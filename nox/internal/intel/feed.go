@@ -0,0 +1,204 @@
+package intel
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	indicatorsLoaded = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "nox_intel_indicators",
+		Help: "Indicators currently loaded, by feed.",
+	}, []string{"feed"})
+
+	feedRefreshErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "nox_intel_refresh_errors_total",
+		Help: "Failed feed refreshes, by feed. The feed's previous indicators stay in use.",
+	}, []string{"feed"})
+)
+
+func init() {
+	prometheus.MustRegister(indicatorsLoaded)
+	prometheus.MustRegister(feedRefreshErrors)
+}
+
+const (
+	defaultRefreshInterval = 15 * time.Minute
+	maxFeedSize            = 64 << 20
+)
+
+// Feed is a source of indicators: a local file or an http(s) URL.
+type Feed struct {
+	Name     string
+	Location string
+	Format   Format
+}
+
+// ParseFeeds turns locations into feeds named after their file. A location
+// may be prefixed with a format, e.g. "misp:https://misp.local/events.json".
+func ParseFeeds(locations []string) []Feed {
+	var feeds []Feed
+	for _, location := range locations {
+		feed := Feed{Location: location}
+		if prefix, rest, ok := strings.Cut(location, ":"); ok {
+			switch Format(prefix) {
+			case FormatText, FormatCSV, FormatSTIX, FormatMISP:
+				feed.Format, feed.Location = Format(prefix), rest
+			}
+		}
+
+		name, _, _ := strings.Cut(path.Base(feed.Location), "?")
+		feed.Name = strings.TrimSuffix(name, path.Ext(name))
+		feeds = append(feeds, feed)
+	}
+	return feeds
+}
+
+// Manager loads feeds into a Database and keeps it fresh.
+type Manager struct {
+	feeds  []Feed
+	logger *slog.Logger
+	client *http.Client
+	// maxSize is the largest feed fetched; a bigger one fails to load
+	// rather than being cut short.
+	maxSize int64
+
+	db     atomic.Pointer[Database]
+	mu     sync.Mutex
+	loaded map[string][]Indicator // last good load per feed
+}
+
+func NewManager(feeds []Feed, logger *slog.Logger) *Manager {
+	m := &Manager{
+		feeds:   feeds,
+		logger:  logger,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		maxSize: maxFeedSize,
+		loaded:  make(map[string][]Indicator),
+	}
+	m.db.Store(NewDatabase(nil))
+	return m
+}
+
+// Database returns the current indicator set. It is never nil.
+func (m *Manager) Database() *Database {
+	return m.db.Load()
+}
+
+// Refresh reloads every feed. A feed that fails keeps its previous
+// indicators, so a flaky intel server never empties the watchlist.
+// Indicators already expired by the wall clock are dropped.
+func (m *Manager) Refresh(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	now := time.Now()
+	for _, feed := range m.feeds {
+		indicators, err := m.load(ctx, feed)
+		if err != nil {
+			feedRefreshErrors.WithLabelValues(feed.Name).Inc()
+			m.logger.Warn("failed to refresh threat intel feed", "feed", feed.Name, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", feed.Name, err))
+			continue
+		}
+
+		live := indicators[:0]
+		for _, indicator := range indicators {
+			if !indicator.Expired(now) {
+				live = append(live, indicator)
+			}
+		}
+		m.loaded[feed.Name] = live
+	}
+
+	var all []Indicator
+	for _, feed := range m.feeds {
+		all = append(all, m.loaded[feed.Name]...)
+	}
+	db := NewDatabase(all)
+	m.db.Store(db)
+
+	for _, feed := range m.feeds {
+		indicatorsLoaded.WithLabelValues(feed.Name).Set(float64(len(m.loaded[feed.Name])))
+	}
+	m.logger.Info("Threat intel refreshed", "feeds", len(m.feeds), "indicators", db.Len())
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to refresh %d of %d feeds: %v", len(errs), len(m.feeds), errs)
+	}
+	return nil
+}
+
+func (m *Manager) load(ctx context.Context, feed Feed) ([]Indicator, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(feed.Location, "http://") || strings.HasPrefix(feed.Location, "https://") {
+		data, err = m.fetch(ctx, feed.Location)
+	} else {
+		data, err = os.ReadFile(feed.Location)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(feed.Name, feed.Location, data, feed.Format)
+}
+
+func (m *Manager) fetch(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch feed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch feed: unexpected status %s", resp.Status)
+	}
+
+	// read one byte past the limit, a truncated feed would parse as a
+	// smaller valid one and silently drop indicators.
+	data, err := io.ReadAll(io.LimitReader(resp.Body, m.maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed: %w", err)
+	}
+	if int64(len(data)) > m.maxSize {
+		return nil, fmt.Errorf("feed is larger than %d bytes", m.maxSize)
+	}
+	return data, nil
+}
+
+// Run refreshes the feeds every interval until ctx is cancelled.
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultRefreshInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.Refresh(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package intel
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Format string
+
+const (
+	FormatAuto Format = ""
+	FormatText Format = "text" // one IP, CIDR, domain, URL or hash per line
+	FormatCSV  Format = "csv"
+	FormatSTIX Format = "stix" // STIX 2.1 bundle
+	FormatMISP Format = "misp" // MISP event JSON export
+)
+
+// Parse decodes a feed. With FormatAuto the format is picked from the
+// location's extension, and JSON is sniffed for a STIX bundle or MISP event.
+func Parse(feed, location string, data []byte, format Format) ([]Indicator, error) {
+	if format == FormatAuto {
+		format = detectFormat(location, data)
+	}
+
+	var indicators []Indicator
+	var err error
+	switch format {
+	case FormatText:
+		indicators, err = parseText(data)
+	case FormatCSV:
+		indicators, err = parseCSV(data)
+	case FormatSTIX:
+		indicators, err = parseSTIX(data)
+	case FormatMISP:
+		indicators, err = parseMISP(data)
+	default:
+		return nil, fmt.Errorf("unknown feed format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s feed: %w", format, err)
+	}
+
+	for i := range indicators {
+		if indicators[i].Feed == "" {
+			indicators[i].Feed = feed
+		}
+	}
+	return indicators, nil
+}
+
+func detectFormat(location string, data []byte) Format {
+	// drop any query string before looking at a URL's extension.
+	location, _, _ = strings.Cut(location, "?")
+
+	switch strings.ToLower(path.Ext(location)) {
+	case ".csv":
+		return FormatCSV
+	case ".json":
+		var probe struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(data, &probe) == nil && probe.Type == "bundle" {
+			return FormatSTIX
+		}
+		return FormatMISP
+	default:
+		return FormatText
+	}
+}
+
+// inferType guesses an untyped value's indicator type.
+func inferType(value string) Type {
+	switch {
+	case strings.Contains(value, "://"):
+		return TypeURL
+	case isHash(strings.ToLower(value)):
+		return TypeHash
+	}
+	if _, err := parsePrefix(value); err == nil {
+		return TypeIP
+	}
+	return TypeDomain
+}
+
+func parseText(data []byte) ([]Indicator, error) {
+	var indicators []Indicator
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		value := strings.TrimSpace(scanner.Text())
+		if value == "" || strings.HasPrefix(value, "#") {
+			continue
+		}
+		indicators = append(indicators, Indicator{Type: inferType(value), Value: value})
+	}
+	return indicators, scanner.Err()
+}
+
+// parseCSV reads a header row naming at least a value column, plus any of
+// type, confidence, expires (RFC 3339), description and feed.
+func parseCSV(data []byte) ([]Indicator, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["value"]; !ok {
+		return nil, fmt.Errorf("header is missing the value column")
+	}
+	get := func(record []string, column string) string {
+		if i, ok := columns[column]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var indicators []Indicator
+	for line, record := range records[1:] {
+		indicator := Indicator{
+			Type:        Type(strings.ToLower(get(record, "type"))),
+			Value:       get(record, "value"),
+			Description: get(record, "description"),
+			Feed:        get(record, "feed"),
+		}
+		if indicator.Type == "" {
+			indicator.Type = inferType(indicator.Value)
+		}
+		if v := get(record, "confidence"); v != "" {
+			if indicator.Confidence, err = strconv.Atoi(v); err != nil {
+				return nil, fmt.Errorf("row %d: invalid confidence %q", line+2, v)
+			}
+		}
+		if v := get(record, "expires"); v != "" {
+			if indicator.Expires, err = time.Parse(time.RFC3339, v); err != nil {
+				return nil, fmt.Errorf("row %d: invalid expires %q", line+2, v)
+			}
+		}
+		indicators = append(indicators, indicator)
+	}
+	return indicators, nil
+}
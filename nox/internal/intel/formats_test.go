@@ -0,0 +1,180 @@
+package intel
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const stixFeed = `{
+  "type": "bundle",
+  "id": "bundle--1",
+  "objects": [
+    {
+      "type": "indicator",
+      "spec_version": "2.1",
+      "name": "APT C2",
+      "pattern": "[ipv4-addr:value = '198.51.100.1'] OR [ipv4-addr:value = '198.51.100.0/28']",
+      "pattern_type": "stix",
+      "confidence": 85,
+      "valid_until": "2027-01-01T00:00:00Z"
+    },
+    {
+      "type": "indicator",
+      "pattern": "[file:hashes.'SHA-256' = 'E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855']",
+      "pattern_type": "stix"
+    },
+    {
+      "type": "indicator",
+      "pattern": "[domain-name:value = 'revoked.example']",
+      "revoked": true
+    },
+    {"type": "malware", "name": "not an indicator"}
+  ]
+}`
+
+const mispFeed = `{
+  "Event": {
+    "info": "Phishing campaign",
+    "threat_level_id": "1",
+    "Attribute": [
+      {"type": "domain", "value": "login-update.example", "to_ids": true},
+      {"type": "ip-dst|port", "value": "203.0.113.9|443", "to_ids": true, "comment": "C2 over TLS"},
+      {"type": "url", "value": "http://203.0.113.9/a", "to_ids": false},
+      {"type": "email-src", "value": "x@example.com", "to_ids": true}
+    ],
+    "Object": [
+      {"Attribute": [{"type": "filename|md5", "value": "invoice.exe|44d88612fea8a8f36de82e1278abb02f", "to_ids": true}]}
+    ]
+  }
+}`
+
+const csvFeed = `type,value,confidence,expires,description
+ip,192.0.2.0/24,70,,Scanner range
+domain,bad.example,,2027-01-01T00:00:00Z,Phishing
+,https://bad.example/drop,90,,
+`
+
+func TestParseFormats(t *testing.T) {
+	tests := []struct {
+		location string
+		data     string
+		want     []Indicator
+	}{
+		{
+			location: "feeds/apt.json",
+			data:     stixFeed,
+			want: []Indicator{
+				{Type: TypeIP, Value: "198.51.100.1/32", Confidence: 85, Description: "APT C2", Expires: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+				{Type: TypeIP, Value: "198.51.100.0/28", Confidence: 85, Description: "APT C2", Expires: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+				{Type: TypeHash, Value: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", Confidence: DefaultConfidence},
+			},
+		},
+		{
+			location: "feeds/misp.json",
+			data:     mispFeed,
+			want: []Indicator{
+				{Type: TypeDomain, Value: "login-update.example", Confidence: 90, Description: "Phishing campaign"},
+				{Type: TypeIP, Value: "203.0.113.9/32", Confidence: 90, Description: "C2 over TLS"},
+				{Type: TypeHash, Value: "44d88612fea8a8f36de82e1278abb02f", Confidence: 90, Description: "Phishing campaign"},
+			},
+		},
+		{
+			location: "feeds/local.csv",
+			data:     csvFeed,
+			want: []Indicator{
+				{Type: TypeIP, Value: "192.0.2.0/24", Confidence: 70, Description: "Scanner range"},
+				{Type: TypeDomain, Value: "bad.example", Confidence: DefaultConfidence, Description: "Phishing", Expires: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+				{Type: TypeURL, Value: "https://bad.example/drop", Confidence: 90},
+			},
+		},
+		{
+			location: "intel/ip_watchlist.txt",
+			data:     "# comment\n185.193.125.241\n\nevil.com\n",
+			want: []Indicator{
+				{Type: TypeIP, Value: "185.193.125.241/32", Confidence: DefaultConfidence},
+				{Type: TypeDomain, Value: "evil.com", Confidence: DefaultConfidence},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.location, func(t *testing.T) {
+			got, err := Parse("feed", tt.location, []byte(tt.data), FormatAuto)
+			if err != nil {
+				t.Fatalf("got error %v, want nil", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d indicators %+v, want %d", len(got), got, len(tt.want))
+			}
+			for i := range got {
+				if err := got[i].normalize(); err != nil {
+					t.Fatalf("got invalid indicator %+v: %v", got[i], err)
+				}
+				tt.want[i].Feed = "feed"
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %+v, want %+v", got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestManagerKeepsLastGoodFeedOnFailure(t *testing.T) {
+	body := "203.0.113.7\n"
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "local.csv")
+	if err := os.WriteFile(path, []byte("value\n192.0.2.1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManager(ParseFeeds([]string{path, "text:" + srv.URL + "/feed"}), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err := m.Refresh(context.Background()); err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+	if n := m.Database().Len(); n != 2 {
+		t.Fatalf("got %d indicators, want 2", n)
+	}
+
+	status = http.StatusInternalServerError
+	if err := m.Refresh(context.Background()); err == nil {
+		t.Fatalf("got nil error for failing feed, want error")
+	}
+	if got := m.Database().MatchIP("203.0.113.7", baseTime); len(got) != 1 || got[0].Indicator.Feed != "feed" {
+		t.Fatalf("got %v after failed refresh, want the previous indicator kept", got)
+	}
+}
+
+func TestManagerRejectsOversizedFeed(t *testing.T) {
+	body := "203.0.113.7\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	m := NewManager(ParseFeeds([]string{"text:" + srv.URL + "/feed"}), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	m.maxSize = int64(len(body))
+	if err := m.Refresh(context.Background()); err != nil {
+		t.Fatalf("got error %v for a feed at the limit, want nil", err)
+	}
+
+	body = "203.0.113.7\n198.51.100.1\n"
+	if err := m.Refresh(context.Background()); err == nil {
+		t.Fatalf("got nil error for an oversized feed, want error")
+	}
+	if m.Database().Len() != 1 || len(m.Database().MatchIP("203.0.113.7", baseTime)) != 1 {
+		t.Fatalf("got %d indicators after an oversized feed, want the previous one kept", m.Database().Len())
+	}
+}
@@ -0,0 +1,137 @@
+package intel
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type Type string
+
+const (
+	TypeIP     Type = "ip" // a single address or a CIDR range
+	TypeDomain Type = "domain"
+	TypeURL    Type = "url"
+	TypeHash   Type = "hash" // MD5, SHA-1 or SHA-256, told apart by length
+)
+
+// Indicator is one piece of threat intelligence and the context an analyst
+// needs when it matches.
+type Indicator struct {
+	Type        Type
+	Value       string
+	Feed        string
+	Confidence  int // 0-100; feeds that don't say get DefaultConfidence
+	Description string
+	Expires     time.Time // zero means the indicator never expires
+}
+
+const DefaultConfidence = 50
+
+// Expired reports whether the indicator no longer applies at t.
+func (i *Indicator) Expired(t time.Time) bool {
+	return !i.Expires.IsZero() && !t.Before(i.Expires)
+}
+
+// normalize validates the indicator's value and puts it in the form the
+// database matches on.
+func (i *Indicator) normalize() error {
+	value := strings.TrimSpace(i.Value)
+	if value == "" {
+		return fmt.Errorf("empty %s indicator", i.Type)
+	}
+
+	switch i.Type {
+	case TypeIP:
+		prefix, err := parsePrefix(value)
+		if err != nil {
+			return err
+		}
+		value = prefix.String()
+	case TypeDomain:
+		value = normalizeDomain(value)
+	case TypeURL:
+		normalized, ok := normalizeURL(value)
+		if !ok {
+			return fmt.Errorf("invalid URL indicator %q", value)
+		}
+		value = normalized
+	case TypeHash:
+		value = strings.ToLower(value)
+		if !isHash(value) {
+			return fmt.Errorf("invalid hash indicator %q", value)
+		}
+	default:
+		return fmt.Errorf("unknown indicator type %q", i.Type)
+	}
+
+	if i.Confidence <= 0 {
+		i.Confidence = DefaultConfidence
+	}
+	i.Confidence = min(i.Confidence, 100)
+	i.Value = value
+	return nil
+}
+
+// parsePrefix accepts a bare address as a host-length prefix.
+func parsePrefix(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid CIDR indicator %q: %w", value, err)
+		}
+		return netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()).Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid IP indicator %q: %w", value, err)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+// normalizeURL lower-cases the scheme and host and drops the fragment and a
+// bare trailing slash, so trivially different spellings of a URL match.
+func normalizeURL(raw string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	if u.Path == "/" {
+		u.Path = ""
+	}
+	return u.String(), true
+}
+
+func hostOf(normalizedURL string) string {
+	u, err := url.Parse(normalizedURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+func isHash(value string) bool {
+	switch len(value) {
+	case 32, 40, 64:
+	default:
+		return false
+	}
+	for _, c := range value {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
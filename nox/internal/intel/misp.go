@@ -0,0 +1,109 @@
+package intel
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+type mispEvent struct {
+	Info          string          `json:"info"`
+	ThreatLevelID string          `json:"threat_level_id"`
+	Attributes    []mispAttribute `json:"Attribute"`
+	Objects       []struct {
+		Attributes []mispAttribute `json:"Attribute"`
+	} `json:"Object"`
+}
+
+type mispAttribute struct {
+	Type    string `json:"type"`
+	Value   string `json:"value"`
+	Comment string `json:"comment"`
+	ToIDS   *bool  `json:"to_ids"`
+}
+
+// mispTypes maps MISP attribute types to indicator types. Composite types
+// like ip-dst|port and filename|sha256 use the part given by the index.
+var mispTypes = map[string]struct {
+	t    Type
+	part int
+}{
+	"ip-src":          {TypeIP, 0},
+	"ip-dst":          {TypeIP, 0},
+	"ip-src|port":     {TypeIP, 0},
+	"ip-dst|port":     {TypeIP, 0},
+	"domain":          {TypeDomain, 0},
+	"hostname":        {TypeDomain, 0},
+	"domain|ip":       {TypeDomain, 0},
+	"url":             {TypeURL, 0},
+	"md5":             {TypeHash, 0},
+	"sha1":            {TypeHash, 0},
+	"sha256":          {TypeHash, 0},
+	"filename|md5":    {TypeHash, 1},
+	"filename|sha1":   {TypeHash, 1},
+	"filename|sha256": {TypeHash, 1},
+}
+
+// mispConfidence maps an event's threat level (1 high to 4 undefined) to a
+// confidence; undefined falls back to DefaultConfidence.
+var mispConfidence = map[string]int{
+	"1": 90,
+	"2": 70,
+	"3": 40,
+}
+
+// parseMISP reads a MISP JSON export: a single {"Event": ...}, a list of
+// them, or a REST search result {"response": [...]}. Attributes not flagged
+// for IDS use are skipped.
+func parseMISP(data []byte) ([]Indicator, error) {
+	type wrapped struct {
+		Event mispEvent `json:"Event"`
+	}
+
+	var events []wrapped
+	var single wrapped
+	var search struct {
+		Response []wrapped `json:"response"`
+	}
+
+	switch {
+	case json.Unmarshal(data, &events) == nil:
+	case json.Unmarshal(data, &search) == nil && search.Response != nil:
+		events = search.Response
+	default:
+		if err := json.Unmarshal(data, &single); err != nil {
+			return nil, err
+		}
+		events = []wrapped{single}
+	}
+
+	var indicators []Indicator
+	for _, w := range events {
+		attributes := w.Event.Attributes
+		for _, obj := range w.Event.Objects {
+			attributes = append(attributes, obj.Attributes...)
+		}
+
+		for _, attr := range attributes {
+			mapping, ok := mispTypes[attr.Type]
+			if !ok || (attr.ToIDS != nil && !*attr.ToIDS) {
+				continue
+			}
+			parts := strings.Split(attr.Value, "|")
+			if mapping.part >= len(parts) {
+				continue
+			}
+
+			description := attr.Comment
+			if description == "" {
+				description = w.Event.Info
+			}
+			indicators = append(indicators, Indicator{
+				Type:        mapping.t,
+				Value:       parts[mapping.part],
+				Confidence:  mispConfidence[w.Event.ThreatLevelID],
+				Description: description,
+			})
+		}
+	}
+	return indicators, nil
+}
@@ -0,0 +1,76 @@
+package intel
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type stixBundle struct {
+	Type    string       `json:"type"`
+	Objects []stixObject `json:"objects"`
+}
+
+type stixObject struct {
+	Type        string    `json:"type"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Pattern     string    `json:"pattern"`
+	PatternType string    `json:"pattern_type"`
+	Confidence  int       `json:"confidence"`
+	ValidUntil  time.Time `json:"valid_until"`
+	Revoked     bool      `json:"revoked"`
+}
+
+// stixComparison matches one comparison expression in a STIX pattern, such
+// as [ipv4-addr:value = '198.51.100.1'] or
+// [file:hashes.'SHA-256' = '...']. Patterns joined with OR yield an
+// indicator per comparison; other operators and object paths are skipped.
+var stixComparison = regexp.MustCompile(`([a-z0-9-]+):([A-Za-z0-9_.'-]+)\s*=\s*'((?:[^'\\]|\\.)*)'`)
+
+func parseSTIX(data []byte) ([]Indicator, error) {
+	var bundle stixBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, err
+	}
+
+	var indicators []Indicator
+	for _, obj := range bundle.Objects {
+		if obj.Type != "indicator" || obj.Revoked || (obj.PatternType != "" && obj.PatternType != "stix") {
+			continue
+		}
+
+		description := obj.Name
+		if description == "" {
+			description = obj.Description
+		}
+
+		for _, m := range stixComparison.FindAllStringSubmatch(obj.Pattern, -1) {
+			objectType, property, value := m[1], m[2], strings.ReplaceAll(m[3], `\'`, `'`)
+
+			var t Type
+			switch {
+			case (objectType == "ipv4-addr" || objectType == "ipv6-addr") && property == "value":
+				t = TypeIP
+			case objectType == "domain-name" && property == "value":
+				t = TypeDomain
+			case objectType == "url" && property == "value":
+				t = TypeURL
+			case objectType == "file" && strings.HasPrefix(property, "hashes."):
+				t = TypeHash
+			default:
+				continue
+			}
+
+			indicators = append(indicators, Indicator{
+				Type:        t,
+				Value:       value,
+				Confidence:  obj.Confidence,
+				Description: description,
+				Expires:     obj.ValidUntil,
+			})
+		}
+	}
+	return indicators, nil
+}
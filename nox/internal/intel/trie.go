@@ -0,0 +1,72 @@
+package intel
+
+import (
+	"net/netip"
+	"time"
+)
+
+// prefixTrie is a binary trie over address bits. IPv4 and IPv6 live in
+// separate roots, so a v4 prefix never matches a v6 address.
+type prefixTrie struct {
+	v4, v6 *trieNode
+}
+
+type trieNode struct {
+	children   [2]*trieNode
+	indicators []*Indicator
+}
+
+func newPrefixTrie() *prefixTrie {
+	return &prefixTrie{v4: &trieNode{}, v6: &trieNode{}}
+}
+
+func (t *prefixTrie) root(addr netip.Addr) *trieNode {
+	if addr.Is4() {
+		return t.v4
+	}
+	return t.v6
+}
+
+func (t *prefixTrie) Insert(prefix netip.Prefix, indicator *Indicator) {
+	addr := prefix.Addr()
+	bytes := addr.AsSlice()
+
+	node := t.root(addr)
+	for i := range prefix.Bits() {
+		bit := bytes[i/8] >> (7 - i%8) & 1
+		if node.children[bit] == nil {
+			node.children[bit] = &trieNode{}
+		}
+		node = node.children[bit]
+	}
+	node.indicators = append(node.indicators, indicator)
+}
+
+// Lookup returns the live indicators of the longest prefix containing addr.
+func (t *prefixTrie) Lookup(addr netip.Addr, at time.Time) []*Indicator {
+	addr = addr.Unmap()
+	bytes := addr.AsSlice()
+
+	var best []*Indicator
+	node := t.root(addr)
+	for i := 0; node != nil; i++ {
+		if live := liveIndicators(node.indicators, at); len(live) > 0 {
+			best = live
+		}
+		if i == addr.BitLen() {
+			break
+		}
+		node = node.children[bytes[i/8]>>(7-i%8)&1]
+	}
+	return best
+}
+
+func liveIndicators(indicators []*Indicator, at time.Time) []*Indicator {
+	var live []*Indicator
+	for _, indicator := range indicators {
+		if !indicator.Expired(at) {
+			live = append(live, indicator)
+		}
+	}
+	return live
+}
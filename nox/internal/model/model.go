@@ -2,18 +2,38 @@ package model
 
 import (
 	"errors"
+	"fmt"
+	"hash/fnv"
 	"time"
 )
 
 var ErrIgnoredLine = errors.New("log line does not match any known patterns")
 
 type Alert struct {
-	RuleName  string
-	Message   string
-	Severity  string
-	Timestamp time.Time
-	Source    string
-	Metadata  map[string]string
+	RuleName    string
+	RuleID      string
+	RuleVersion int
+	Message     string
+	Severity    string
+	Timestamp   time.Time
+	Source      string
+	Metadata    map[string]string
+	// ExceptionID names the exception that suppressed the alert, if any.
+	// Suppressed alerts are kept for review but not raised.
+	ExceptionID string `json:",omitempty"`
+	// Shadow is set on the would-be alerts of a rule in shadow mode, which
+	// are recorded but never raised.
+	Shadow bool `json:",omitempty"`
+	// RiskScore is what the alert adds to the risk of its user, source IP
+	// and host: its severity scaled by the rule's risk weight.
+	RiskScore float64 `json:",omitempty"`
+	// Events are the events that triggered the alert, oldest first: the
+	// one event for most rules, every event of the chain for a correlation
+	// rule.
+	Events []Event `json:",omitempty"`
+	// Ancestry is the triggering process followed by its parents, as far
+	// as nox has seen them.
+	Ancestry []Process `json:",omitempty"`
 }
 
 type Event struct {
@@ -21,6 +41,39 @@ type Event struct {
 	EventType string
 	Source    string
 	Metadata  map[string]string
+	// Input is where the event was read from, and Raw the log line it was
+	// parsed from.
+	Input string `json:",omitempty"`
+	Raw   string `json:",omitempty"`
+}
+
+// Process is an executed process, as recorded from its Process_Executed
+// event.
+type Process struct {
+	Timestamp   time.Time
+	PID         string
+	PPID        string
+	ProcessName string
+	Command     string
+	UID         string `json:",omitempty"`
+}
+
+// ID identifies the alert in storage. It is derived from the alert and the
+// events that triggered it, so the same detection is stored once however
+// often it is replayed, while two detections of a rule in the same second
+// from the same source, like two local processes, are stored apart.
+func (a *Alert) ID() string {
+	id := fmt.Sprintf("%s-%d-%s", a.RuleName, a.Timestamp.Unix(), a.Source)
+	if len(a.Events) == 0 {
+		return id
+	}
+
+	h := fnv.New64a()
+	for _, e := range a.Events {
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00%d\x00%s\x00%s\n",
+			e.Input, e.Raw, e.EventType, e.Timestamp.UnixNano(), e.Source, e.Metadata["pid"])
+	}
+	return fmt.Sprintf("%s-%016x", id, h.Sum64())
 }
 
 func (a *Alert) GetSeverityLevel() int {
@@ -38,6 +91,10 @@ func (a *Alert) GetSeverityLevel() int {
 	}
 }
 
+func (a *Alert) IsSuppressed() bool {
+	return a.ExceptionID != ""
+}
+
 func (a *Alert) IsHighPriority() bool {
 	return a.GetSeverityLevel() >= 3
 }
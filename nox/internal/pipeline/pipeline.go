@@ -0,0 +1,378 @@
+package pipeline
+
+import (
+	"context"
+	"hash/maphash"
+	"log/slog"
+	"net/netip"
+	"nox/internal/eventtime"
+	"nox/internal/ingester"
+	"nox/internal/model"
+	"nox/internal/rules"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	eventsProcessedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "nox_events_processed_total",
+		Help: "Total number of events processed by the engine.",
+	})
+
+	shardQueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "nox_pipeline_shard_queue_depth",
+		Help: "Events waiting to be enriched, by shard.",
+	}, []string{"shard"})
+
+	persistQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "nox_pipeline_persist_queue_depth",
+		Help: "Events waiting to be written to storage.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(eventsProcessedTotal)
+	prometheus.MustRegister(shardQueueDepth)
+	prometheus.MustRegister(persistQueueDepth)
+}
+
+type Parser interface {
+	ParseLog(line string) (model.Event, error)
+}
+
+// Enricher adds context to an event before detection. Implementations are
+// called concurrently from every shard.
+type Enricher interface {
+	Enrich(event *model.Event)
+}
+
+type Detector interface {
+	EvaluateEvent(event model.Event) []model.Alert
+}
+
+type Sink interface {
+	IndexEvent(ctx context.Context, event model.Event) error
+}
+
+type Config struct {
+	// Shards is the number of detection workers. Events with the same
+	// partition key always land on the same shard, in order.
+	Shards int
+	// ParseWorkers parse lines in parallel; results are re-sequenced so
+	// shards still receive events in input order.
+	ParseWorkers   int
+	PersistWorkers int
+	BufferSize     int
+	// Host is the host events that don't name one were logged on, normally
+	// the one nox runs on, as sshd names it. It is stamped onto them as
+	// their "host" so host-scoped exceptions, risk and profiles see it.
+	Host string
+	// MaxSessions bounds the login sessions and session processes tracked
+	// to route processes to the shard of their login.
+	MaxSessions int
+}
+
+const defaultMaxSessions = 16384
+
+// Pipeline runs events through parse → enrich → detect → persist. Parsing
+// is parallel but keeps input order, enrichment and detection are sharded by
+// partition key so per-key rule state sees events in order while unrelated
+// keys run in parallel, and persistence runs on its own worker pool so a slow store
+// doesn't stall detection until its queue fills.
+type Pipeline struct {
+	cfg      Config
+	logger   *slog.Logger
+	parser   Parser
+	enricher Enricher
+	detector Detector
+	sink     Sink
+	clock    *eventtime.Clock
+	seed     maphash.Seed
+	// sessions maps "host|pid" of accepted logins, and of the processes
+	// started under them, to the login's partition key. Only the dispatch
+	// loop in parse touches it.
+	sessions *rules.Store[string]
+}
+
+// New builds a pipeline. When clock is set, every parsed event advances its
+// input's watermark, and events older than the watermark are stored without
+// being evaluated, since the rule state they belong to may already be gone.
+func New(cfg Config, logger *slog.Logger, parser Parser, enricher Enricher, detector Detector, sink Sink, clock *eventtime.Clock) *Pipeline {
+	if cfg.Shards <= 0 {
+		cfg.Shards = runtime.GOMAXPROCS(0)
+	}
+	if cfg.ParseWorkers <= 0 {
+		cfg.ParseWorkers = runtime.GOMAXPROCS(0)
+	}
+	if cfg.PersistWorkers <= 0 {
+		cfg.PersistWorkers = 1
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 1000
+	}
+	if cfg.MaxSessions <= 0 {
+		cfg.MaxSessions = defaultMaxSessions
+	}
+
+	return &Pipeline{
+		cfg:      cfg,
+		logger:   logger,
+		parser:   parser,
+		enricher: enricher,
+		detector: detector,
+		sink:     sink,
+		clock:    clock,
+		seed:     maphash.MakeSeed(),
+		sessions: rules.NewStore[string]("pipeline_sessions", 0, cfg.MaxSessions),
+	}
+}
+
+// PartitionKey groups events whose rule state must be updated in order.
+// Login events are keyed by the source IP they came from, which the brute
+// force, spray and failed login rules count by; events without a remote
+// source, like the processes execsnoop reports, are keyed by the host they
+// happened on, and those that don't name a host happened on localHost.
+//
+// Per-user state, such as login locations and travel, can see one user's
+// logins from two IPs on two shards; those rules tolerate logins arriving
+// out of order. Processes of a login session are routed to the login's key
+// by the pipeline, see partitionKey.
+func PartitionKey(event model.Event, localHost string) string {
+	if addr, err := netip.ParseAddr(event.Source); err == nil && !addr.IsLoopback() && !addr.IsUnspecified() {
+		return event.Source
+	}
+	if host := event.Metadata["host"]; host != "" {
+		return host
+	}
+	return localHost
+}
+
+// partitionKey is PartitionKey, except that a process started in a login
+// session, directly or through its ancestors, follows the login's key so
+// correlations that track an attacker from their source IP into the
+// session see every step in order.
+func (p *Pipeline) partitionKey(event model.Event) string {
+	host := event.Metadata["host"]
+	if host == "" {
+		host = p.cfg.Host
+	}
+
+	switch event.EventType {
+	case "SSHD_Accepted_Password":
+		key := PartitionKey(event, p.cfg.Host)
+		if pid := event.Metadata["sshd_pid"]; pid != "" {
+			p.sessions.Set(host+"|"+pid, key, event.Timestamp)
+		}
+		return key
+	case "Process_Executed":
+		if key, ok := p.sessions.Get(host + "|" + event.Metadata["ppid"]); ok {
+			if pid := event.Metadata["pid"]; pid != "" {
+				p.sessions.Set(host+"|"+pid, key, event.Timestamp)
+			}
+			return key
+		}
+	}
+
+	return PartitionKey(event, p.cfg.Host)
+}
+
+// Run processes lines until the channel is closed, then drains every stage
+// and closes alerts. Alerts are dropped with a warning when the channel is
+// full, so a slow alert handler never stalls detection.
+func (p *Pipeline) Run(ctx context.Context, lines <-chan ingester.Line, alerts chan<- model.Alert) {
+	defer close(alerts)
+
+	perShard := max(p.cfg.BufferSize/p.cfg.Shards, 1)
+	shards := make([]chan model.Event, p.cfg.Shards)
+	enriched := make([]chan model.Event, p.cfg.Shards)
+	for i := range shards {
+		shards[i] = make(chan model.Event, perShard)
+		enriched[i] = make(chan model.Event, perShard)
+	}
+	persistCh := make(chan model.Event, p.cfg.BufferSize)
+
+	var persistWG sync.WaitGroup
+	var dropped atomic.Int64
+	for range p.cfg.PersistWorkers {
+		persistWG.Add(1)
+		go func() {
+			defer persistWG.Done()
+			p.persist(ctx, persistCh, &dropped)
+		}()
+	}
+
+	// each shard is an enrich goroutine feeding a detect goroutine, so a
+	// GeoIP lookup for the next event overlaps rule evaluation of this one.
+	var detectWG sync.WaitGroup
+	for i := range shards {
+		detectWG.Add(2)
+		go func() {
+			defer detectWG.Done()
+			defer close(enriched[i])
+			p.enrich(shards[i], shardQueueDepth.WithLabelValues(strconv.Itoa(i)), enriched[i])
+		}()
+		go func() {
+			defer detectWG.Done()
+			p.detect(enriched[i], persistCh, alerts)
+		}()
+	}
+
+	p.parse(lines, shards, persistCh)
+
+	for _, ch := range shards {
+		close(ch)
+	}
+	detectWG.Wait()
+
+	close(persistCh)
+	persistWG.Wait()
+
+	if n := dropped.Load(); n > 0 {
+		p.logger.Warn("Dropped unpersisted events on shutdown", "count", n)
+	}
+}
+
+type parseResult struct {
+	input string
+	event model.Event
+	ok    bool
+}
+
+// parse fans lines out round-robin to the parse workers and collects their
+// results in the same round-robin order, which restores input order without
+// sequence numbers: each worker returns exactly one result per line.
+func (p *Pipeline) parse(lines <-chan ingester.Line, shards []chan model.Event, persistCh chan<- model.Event) {
+	workers := p.cfg.ParseWorkers
+	ins := make([]chan ingester.Line, workers)
+	outs := make([]chan parseResult, workers)
+	for i := range workers {
+		ins[i] = make(chan ingester.Line, 64)
+		outs[i] = make(chan parseResult, 64)
+		go func() {
+			defer close(outs[i])
+			for line := range ins[i] {
+				outs[i] <- p.parseLine(line)
+			}
+		}()
+	}
+
+	go func() {
+		next := 0
+		for line := range lines {
+			ins[next] <- line
+			next = (next + 1) % workers
+		}
+		for _, in := range ins {
+			close(in)
+		}
+	}()
+
+	for next := 0; ; next = (next + 1) % workers {
+		result, ok := <-outs[next]
+		if !ok {
+			// the worker after the last dispatched line is always the first
+			// to run dry, so every earlier result has been collected.
+			return
+		}
+		if !result.ok {
+			continue
+		}
+
+		if p.clock != nil && p.clock.Observe(result.input, result.event.Timestamp) {
+			p.logger.Debug("Late event skipped detection",
+				"input", result.input,
+				"type", result.event.EventType,
+				"timestamp", result.event.Timestamp,
+			)
+			persistCh <- result.event
+			continue
+		}
+
+		shards[p.shardFor(result.event)] <- result.event
+	}
+}
+
+func (p *Pipeline) parseLine(line ingester.Line) parseResult {
+	event, err := p.parser.ParseLog(line.Text)
+	if err == model.ErrIgnoredLine {
+		p.logger.Debug("Ignoring log line", "line", line.Text)
+		return parseResult{}
+	} else if err != nil {
+		p.logger.Error("failed to parse line", "error", err, "line", line.Text, "input", line.Input)
+		return parseResult{}
+	}
+
+	event.Input = line.Input
+	if event.Metadata["host"] == "" && p.cfg.Host != "" {
+		if event.Metadata == nil {
+			event.Metadata = make(map[string]string)
+		}
+		event.Metadata["host"] = p.cfg.Host
+	}
+	return parseResult{input: line.Input, event: event, ok: true}
+}
+
+func (p *Pipeline) shardFor(event model.Event) int {
+	return int(maphash.String(p.seed, p.partitionKey(event)) % uint64(p.cfg.Shards))
+}
+
+func (p *Pipeline) enrich(events <-chan model.Event, depth prometheus.Gauge, out chan<- model.Event) {
+	for event := range events {
+		depth.Set(float64(len(events)))
+		if p.enricher != nil {
+			p.enricher.Enrich(&event)
+		}
+		out <- event
+	}
+}
+
+func (p *Pipeline) detect(events <-chan model.Event, persistCh chan<- model.Event, alerts chan<- model.Alert) {
+	for event := range events {
+		eventsProcessedTotal.Inc()
+
+		p.logger.Debug("Processing Event",
+			"type", event.EventType,
+			"source", event.Source,
+			"timestamp", event.Timestamp,
+		)
+
+		for _, alert := range p.detector.EvaluateEvent(event) {
+			select {
+			case alerts <- alert:
+				// alert sent successfully
+			default:
+				p.logger.Warn("Alert channel full, dropping alert",
+					"rule_name", alert.RuleName,
+					"source", alert.Source,
+				)
+			}
+		}
+
+		persistCh <- event
+		persistQueueDepth.Set(float64(len(persistCh)))
+	}
+}
+
+func (p *Pipeline) persist(ctx context.Context, events <-chan model.Event, dropped *atomic.Int64) {
+	for event := range events {
+		// once shutdown starts the store may already be gone; drain without
+		// writing so upstream stages can finish.
+		if ctx.Err() != nil {
+			dropped.Add(1)
+			continue
+		}
+
+		if err := p.sink.IndexEvent(ctx, event); err != nil {
+			p.logger.Error(
+				"failed to persist event",
+				"error", err,
+				"event_type", event.EventType,
+				"source", event.Source,
+			)
+		}
+	}
+}
@@ -0,0 +1,444 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"nox/internal/eventtime"
+	"nox/internal/ingester"
+	"nox/internal/model"
+	"nox/internal/rules"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	baseTime      = time.Date(2026, time.June, 19, 12, 0, 0, 0, time.UTC)
+)
+
+func newTestIngester() *ingester.Ingester {
+	return ingester.NewIngester(discardLogger, func() time.Time { return baseTime })
+}
+
+type countingSink struct {
+	mu      sync.Mutex
+	events  int
+	latency time.Duration
+}
+
+func (s *countingSink) IndexEvent(ctx context.Context, event model.Event) error {
+	if s.latency > 0 {
+		time.Sleep(s.latency)
+	}
+	s.mu.Lock()
+	s.events++
+	s.mu.Unlock()
+	return nil
+}
+
+// orderCheckingDetector fails the test if any partition key sees its events
+// out of timestamp order.
+type orderCheckingDetector struct {
+	t    *testing.T
+	next Detector
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func (d *orderCheckingDetector) EvaluateEvent(event model.Event) []model.Alert {
+	key := PartitionKey(event, "")
+
+	d.mu.Lock()
+	if prev, ok := d.last[key]; ok && event.Timestamp.Before(prev) {
+		d.t.Errorf("got event for %s at %s after %s, want per-key order", key, event.Timestamp, prev)
+	}
+	d.last[key] = event.Timestamp
+	d.mu.Unlock()
+
+	return d.next.EvaluateEvent(event)
+}
+
+type regionEnricher struct{}
+
+func (regionEnricher) Enrich(event *model.Event) {
+	event.Metadata["region"] = "test"
+}
+
+func failedLoginLine(ts time.Time, ip, user string) ingester.Line {
+	return ingester.Line{
+		Input: "auth.log",
+		Text:  fmt.Sprintf("%s web-1 sshd[4242]: Failed password for %s from %s port 22 ssh2", ts.Format("Jan _2 15:04:05"), user, ip),
+	}
+}
+
+func runPipeline(t *testing.T, cfg Config, clock *eventtime.Clock, detector Detector, sink Sink, lines []ingester.Line) []model.Alert {
+	t.Helper()
+
+	p := New(cfg, discardLogger, newTestIngester(), regionEnricher{}, detector, sink, clock)
+
+	lineCh := make(chan ingester.Line)
+	alertCh := make(chan model.Alert, len(lines))
+
+	done := make(chan struct{})
+	go func() {
+		p.Run(context.Background(), lineCh, alertCh)
+		close(done)
+	}()
+
+	for _, line := range lines {
+		lineCh <- line
+	}
+	close(lineCh)
+	<-done
+
+	var alerts []model.Alert
+	for alert := range alertCh {
+		alerts = append(alerts, alert)
+	}
+	return alerts
+}
+
+func TestPipelineShardsKeepPerKeyStateConsistent(t *testing.T) {
+	const (
+		sources         = 64
+		attemptsPerIP   = 6
+		failedThreshold = 5
+	)
+
+	// interleave every source's attempts so all shards are busy at once.
+	var lines []ingester.Line
+	for attempt := range attemptsPerIP {
+		for i := range sources {
+			ts := baseTime.Add(time.Duration(attempt) * time.Second)
+			lines = append(lines, failedLoginLine(ts, fmt.Sprintf("203.0.113.%d", i), "root"))
+		}
+	}
+
+	for _, shards := range []int{1, 4, 16} {
+		t.Run(fmt.Sprintf("shards=%d", shards), func(t *testing.T) {
+			detector := &orderCheckingDetector{
+				t:    t,
+				next: rules.NewEngine(discardLogger, rules.NewStateManager(), nil),
+				last: make(map[string]time.Time),
+			}
+			sink := &countingSink{}
+
+			alerts := runPipeline(t, Config{Shards: shards, ParseWorkers: 3, PersistWorkers: 4, BufferSize: 64}, eventtime.NewClock(time.Minute, 0), detector, sink, lines)
+
+			if sink.events != len(lines) {
+				t.Fatalf("got %d persisted events, want %d", sink.events, len(lines))
+			}
+
+			bruteForce := make(map[string]int)
+			for _, alert := range alerts {
+				if alert.RuleName == "TooManyFailedLogins" {
+					bruteForce[alert.Source]++
+					if alert.Metadata["attempt_count"] != fmt.Sprint(failedThreshold) {
+						t.Fatalf("got attempt_count %q, want %d", alert.Metadata["attempt_count"], failedThreshold)
+					}
+				}
+			}
+			if len(bruteForce) != sources {
+				t.Fatalf("got brute force alerts for %d sources, want %d", len(bruteForce), sources)
+			}
+			for source, count := range bruteForce {
+				if count != 1 {
+					t.Fatalf("got %d alerts for %s, want 1", count, source)
+				}
+			}
+		})
+	}
+}
+
+func TestPipelineKeepsCrossKeyChainsInOrder(t *testing.T) {
+	const sessions = 32
+
+	// each attacker brute forces root, logs in and clears its history from
+	// the login session. The process events carry no IP or host of their
+	// own, so the chain only holds if they reach the shard that saw the
+	// login.
+	var lines []ingester.Line
+	for i := range sessions {
+		ip := fmt.Sprintf("198.51.100.%d", i)
+		ts := baseTime.Add(time.Duration(i) * time.Second)
+		for range 5 {
+			lines = append(lines, failedLoginLine(ts, ip, "root"))
+		}
+		sshdPID := 4000 + i
+		lines = append(lines,
+			ingester.Line{
+				Input: "auth.log",
+				Text:  fmt.Sprintf("%s web-1 sshd[%d]: Accepted password for root from %s port 22 ssh2", ts.Format("Jan _2 15:04:05"), sshdPID, ip),
+			},
+			ingester.Line{
+				Input: "execsnoop.log",
+				Text:  fmt.Sprintf("%s 0 bash %d %d 0 history -c", ts.Format(time.RFC3339), 9000+i, sshdPID),
+			},
+		)
+	}
+
+	for _, shards := range []int{1, 4, 16} {
+		t.Run(fmt.Sprintf("shards=%d", shards), func(t *testing.T) {
+			engine := rules.NewEngine(discardLogger, rules.NewStateManager(), nil)
+			alerts := runPipeline(t, Config{Shards: shards, ParseWorkers: 3, PersistWorkers: 4, BufferSize: 64, Host: "web-1"}, eventtime.NewClock(time.Minute, 0), engine, &countingSink{}, lines)
+
+			chains := make(map[string]bool)
+			for _, alert := range alerts {
+				if alert.RuleName == "CorrelatedBruteForceAndEvasion" {
+					chains[alert.Source] = true
+				}
+			}
+			if len(chains) != sessions {
+				t.Fatalf("got attack chains for %d sources, want %d", len(chains), sessions)
+			}
+		})
+	}
+}
+
+func TestPartitionKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		event model.Event
+		want  string
+	}{
+		{name: "logins are keyed by source ip", event: model.Event{Source: "203.0.113.1", Metadata: map[string]string{"host": "web-1"}}, want: "203.0.113.1"},
+		{name: "process events are keyed by host", event: model.Event{Source: "127.0.0.1", Metadata: map[string]string{"pid": "1", "host": "web-1"}}, want: "web-1"},
+		{name: "process events without a host happen locally", event: model.Event{Source: "127.0.0.1", Metadata: map[string]string{"pid": "1"}}, want: "local"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PartitionKey(tt.event, "local"); got != tt.want {
+				t.Fatalf("got key %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartitionKeyFollowsLoginSessions(t *testing.T) {
+	p := New(Config{Host: "web-1"}, discardLogger, newTestIngester(), regionEnricher{}, &countingDetector{}, &countingSink{}, nil)
+	login := model.Event{EventType: "SSHD_Accepted_Password", Source: "198.51.100.7", Metadata: map[string]string{"host": "web-1", "sshd_pid": "100"}}
+	process := func(pid, ppid string) model.Event {
+		return model.Event{EventType: "Process_Executed", Source: "127.0.0.1", Metadata: map[string]string{"pid": pid, "ppid": ppid}}
+	}
+
+	tests := []struct {
+		name  string
+		event model.Event
+		want  string
+	}{
+		{name: "login", event: login, want: "198.51.100.7"},
+		{name: "session shell", event: process("200", "100"), want: "198.51.100.7"},
+		{name: "its child", event: process("300", "200"), want: "198.51.100.7"},
+		{name: "process outside the session", event: process("400", "1"), want: "web-1"},
+		{name: "same pid on another host", event: model.Event{EventType: "Process_Executed", Source: "127.0.0.1", Metadata: map[string]string{"pid": "500", "ppid": "100", "host": "web-2"}}, want: "web-2"},
+	}
+
+	// in order, like the dispatch loop.
+	for _, tt := range tests {
+		if got := p.partitionKey(tt.event); got != tt.want {
+			t.Fatalf("%s: got key %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPipelineSpreadsOneHostAcrossShards(t *testing.T) {
+	const shards = 4
+	p := New(Config{Shards: shards, Host: "web-1"}, discardLogger, newTestIngester(), regionEnricher{}, &countingDetector{}, &countingSink{}, nil)
+
+	used := make(map[int]bool)
+	for i := range 64 {
+		used[p.shardFor(model.Event{Source: fmt.Sprintf("203.0.113.%d", i), Metadata: map[string]string{"host": "web-1"}})] = true
+	}
+	if len(used) != shards {
+		t.Fatalf("got events of one host on %d shards, want all %d", len(used), shards)
+	}
+}
+
+func TestParseLineStampsHost(t *testing.T) {
+	p := New(Config{Host: "db-1"}, discardLogger, newTestIngester(), regionEnricher{}, &countingDetector{}, &countingSink{}, nil)
+
+	tests := []struct {
+		name string
+		line ingester.Line
+		want string
+	}{
+		{name: "process events get the local host", line: ingester.Line{Input: "execsnoop.log", Text: baseTime.Format(time.RFC3339) + " 0 bash 200 100 0 id"}, want: "db-1"},
+		{name: "sshd keeps the host it logged", line: failedLoginLine(baseTime, "203.0.113.1", "root"), want: "web-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := p.parseLine(tt.line)
+			if !result.ok {
+				t.Fatalf("got line rejected, want it parsed")
+			}
+			if got := result.event.Metadata["host"]; got != tt.want {
+				t.Fatalf("got host %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipelineSkipsUnparsableLines(t *testing.T) {
+	lines := []ingester.Line{
+		{Input: "auth.log", Text: "not a log line"},
+		failedLoginLine(baseTime, "203.0.113.1", "root"),
+	}
+
+	sink := &countingSink{}
+	runPipeline(t, Config{Shards: 2}, nil, rules.NewEngine(discardLogger, rules.NewStateManager(), nil), sink, lines)
+
+	if sink.events != 1 {
+		t.Fatalf("got %d persisted events, want 1", sink.events)
+	}
+}
+
+type countingDetector struct {
+	mu        sync.Mutex
+	evaluated int
+}
+
+func (d *countingDetector) EvaluateEvent(event model.Event) []model.Alert {
+	d.mu.Lock()
+	d.evaluated++
+	d.mu.Unlock()
+	return nil
+}
+
+func TestPipelineLateEventsAreStoredButNotEvaluated(t *testing.T) {
+	lines := []ingester.Line{
+		failedLoginLine(baseTime, "203.0.113.1", "root"),
+		failedLoginLine(baseTime.Add(2*time.Minute), "203.0.113.2", "root"),
+		// within the allowed lateness of the newest event.
+		failedLoginLine(baseTime.Add(110*time.Second), "203.0.113.3", "root"),
+		// a minute behind the watermark.
+		failedLoginLine(baseTime.Add(30*time.Second), "203.0.113.4", "root"),
+	}
+
+	detector := &countingDetector{}
+	sink := &countingSink{}
+	runPipeline(t, Config{Shards: 2}, eventtime.NewClock(30*time.Second, 0), detector, sink, lines)
+
+	if sink.events != len(lines) {
+		t.Fatalf("got %d persisted events, want %d", sink.events, len(lines))
+	}
+	if detector.evaluated != 3 {
+		t.Fatalf("got %d evaluated events, want 3", detector.evaluated)
+	}
+}
+
+// --- benchmarks ---
+
+// sinkLatencies covers an in-memory sink, which isolates parse and detection
+// throughput, and a sink that sleeps like an Elasticsearch round trip.
+var sinkLatencies = []time.Duration{0, 50 * time.Microsecond}
+
+// benchmarkLines mixes what a single internet-facing host logs: failed
+// logins from many sources, some accepted logins each followed by a few
+// commands in the session, and background processes outside any session.
+func benchmarkLines(n int) []ingester.Line {
+	lines := make([]ingester.Line, 0, n)
+	for i := 0; len(lines) < n; i++ {
+		ts := baseTime.Add(time.Duration(i) * time.Millisecond)
+		ip := fmt.Sprintf("198.51.%d.%d", i/256%16, i%256)
+		switch {
+		case i%20 == 0:
+			sshdPID := 10000 + i%50000
+			lines = append(lines, ingester.Line{
+				Input: "auth.log",
+				Text:  fmt.Sprintf("%s web-1 sshd[%d]: Accepted password for user%d from %s port 22 ssh2", ts.Format("Jan _2 15:04:05"), sshdPID, i%50, ip),
+			})
+			for j := range 3 {
+				lines = append(lines, ingester.Line{
+					Input: "execsnoop.log",
+					Text:  fmt.Sprintf("%s 1000 bash %d %d 0 ls -la", ts.Format(time.RFC3339), 60000+i%50000*3+j, sshdPID),
+				})
+			}
+		case i%10 == 5:
+			lines = append(lines, ingester.Line{
+				Input: "execsnoop.log",
+				Text:  fmt.Sprintf("%s 0 logrotate %d 1 0 logrotate /etc/logrotate.conf", ts.Format(time.RFC3339), 200000+i%50000),
+			})
+		default:
+			lines = append(lines, failedLoginLine(ts, ip, fmt.Sprintf("user%d", i%50)))
+		}
+	}
+	return lines[:n]
+}
+
+// BenchmarkSequential is the single-goroutine loop the engine used before
+// the pipeline: parse, enrich, detect and persist one event at a time.
+func BenchmarkSequential(b *testing.B) {
+	for _, latency := range sinkLatencies {
+		b.Run(fmt.Sprintf("sink=%s", latency), func(b *testing.B) {
+			lines := benchmarkLines(b.N)
+			parser := newTestIngester()
+			enricher := regionEnricher{}
+			engine := rules.NewEngine(discardLogger, rules.NewStateManager(), nil)
+			sink := &countingSink{latency: latency}
+			ctx := context.Background()
+
+			b.ResetTimer()
+			for _, line := range lines {
+				event, err := parser.ParseLog(line.Text)
+				if err != nil {
+					b.Fatal(err)
+				}
+				enricher.Enrich(&event)
+				engine.EvaluateEvent(event)
+				sink.IndexEvent(ctx, event)
+			}
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "events/s")
+		})
+	}
+}
+
+func BenchmarkPipeline(b *testing.B) {
+	for _, latency := range sinkLatencies {
+		for _, shards := range []int{1, 4, 8} {
+			b.Run(fmt.Sprintf("sink=%s/shards=%d", latency, shards), func(b *testing.B) {
+				lines := benchmarkLines(b.N)
+				p := New(Config{Shards: shards, ParseWorkers: shards, PersistWorkers: 8, BufferSize: 4096, Host: "web-1"}, discardLogger,
+					newTestIngester(), regionEnricher{},
+					rules.NewEngine(discardLogger, rules.NewStateManager(), nil), &countingSink{latency: latency}, nil)
+
+				lineCh := make(chan ingester.Line, 4096)
+				alertCh := make(chan model.Alert, 4096)
+				go func() {
+					for range alertCh {
+					}
+				}()
+
+				b.ResetTimer()
+				go func() {
+					for _, line := range lines {
+						lineCh <- line
+					}
+					close(lineCh)
+				}()
+				p.Run(context.Background(), lineCh, alertCh)
+				b.StopTimer()
+				b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "events/s")
+				b.ReportMetric(busiestShardShare(lines, shards), "busiest-shard-%")
+			})
+		}
+	}
+}
+
+// busiestShardShare is the percentage of lines the busiest shard gets, which
+// bounds how far detection can scale with more shards.
+func busiestShardShare(lines []ingester.Line, shards int) float64 {
+	p := New(Config{Shards: shards, Host: "web-1"}, discardLogger, newTestIngester(), nil, nil, nil, nil)
+	counts := make([]int, shards)
+	for _, line := range lines {
+		if result := p.parseLine(line); result.ok {
+			counts[p.shardFor(result.event)]++
+		}
+	}
+	return float64(slices.Max(counts)) / float64(len(lines)) * 100
+}
@@ -0,0 +1,230 @@
+// Package response runs automated response actions, like blocking an IP
+// or calling a webhook, when alerts are raised.
+package response
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/netip"
+	"net/url"
+	"nox/internal/model"
+	"nox/internal/rules"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Action types.
+const (
+	TypeCommand   = "command"
+	TypeBlocklist = "blocklist"
+	TypeWebhook   = "webhook"
+)
+
+var Types = []string{TypeCommand, TypeBlocklist, TypeWebhook}
+
+const defaultActionTimeout = 10 * time.Second
+
+// An Action responds to the alerts it is triggered by. It is triggered by
+// an alert of one of its rules, or of at least its minimum severity, or
+// both when both are set.
+type Action struct {
+	ID          string   `yaml:"id"`
+	Type        string   `yaml:"type"`
+	Description string   `yaml:"description"`
+	Rules       []string `yaml:"rules"` // rule IDs
+	MinSeverity string   `yaml:"min_severity"`
+	// DryRun records what the action would do without doing it.
+	DryRun bool `yaml:"dry_run"`
+	// RequireApproval holds the action until an admin approves it.
+	RequireApproval bool `yaml:"require_approval"`
+	// MaxPerHour caps how often the action runs; 0 means no limit.
+	MaxPerHour int           `yaml:"max_per_hour"`
+	Timeout    time.Duration `yaml:"timeout"`
+
+	// Command is run without a shell; each argument is a template. An
+	// argument that renders empty, or to an option its template doesn't
+	// spell out, stops the command from running.
+	Command []string `yaml:"command"`
+	// Blocklist is a file the alert's source IP is appended to, one per
+	// line, for fail2ban or nftables to pick up. Loopback, private,
+	// link-local, multicast and unspecified addresses are never blocked,
+	// nor those in Protected, CIDRs like the office or bastion ranges.
+	Blocklist string   `yaml:"blocklist"`
+	Protected []string `yaml:"protected"`
+	// URL is POSTed the alert as JSON, or Body when set, a template that
+	// must render valid JSON; {{json .Message}} quotes a value.
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	Body    string            `yaml:"body"`
+
+	command   []*template.Template
+	body      *template.Template
+	protected []netip.Prefix
+}
+
+// TemplateData is what command and body templates see, e.g.
+// {{.AlertID}}, {{.Source}} or {{.Metadata.user}}.
+type TemplateData struct {
+	AlertID string
+	model.Alert
+}
+
+// templateFuncs are available to every template. json renders a value as
+// JSON, so alert fields can't break out of the string they are put in.
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+func parseTemplate(id, text string) (*template.Template, error) {
+	return template.New(id).Option("missingkey=zero").Funcs(templateFuncs).Parse(text)
+}
+
+// LoadActions reads and validates response actions. A missing file means
+// none.
+func LoadActions(path string) ([]Action, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response actions: %w", err)
+	}
+
+	var actions []Action
+	if err := yaml.Unmarshal(data, &actions); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response actions: %w", err)
+	}
+
+	ids := make(map[string]bool)
+	for i := range actions {
+		a := &actions[i]
+		if err := a.compile(); err != nil {
+			return nil, fmt.Errorf("invalid response action %d in %s: %w", i+1, path, err)
+		}
+		if ids[a.ID] {
+			return nil, fmt.Errorf("duplicate response action id %q in %s", a.ID, path)
+		}
+		ids[a.ID] = true
+	}
+
+	slog.Info("Loaded response actions", "path", path, "count", len(actions))
+	return actions, nil
+}
+
+// compile validates the action and parses its templates.
+func (a *Action) compile() error {
+	switch {
+	case !rules.ValidRuleID(a.ID):
+		return fmt.Errorf("id %q must be lowercase words separated by dashes", a.ID)
+	case !slices.Contains(Types, a.Type):
+		return fmt.Errorf("%s: unknown type %q, expected one of %s", a.ID, a.Type, strings.Join(Types, ", "))
+	case len(a.Rules) == 0 && a.MinSeverity == "":
+		// without a trigger an action would fire on every alert.
+		return fmt.Errorf("%s: needs rules or min_severity", a.ID)
+	case a.MinSeverity != "" && !slices.Contains(rules.Severities, a.MinSeverity):
+		return fmt.Errorf("%s: unknown min_severity %q", a.ID, a.MinSeverity)
+	case a.MaxPerHour < 0:
+		return fmt.Errorf("%s: max_per_hour must not be negative", a.ID)
+	case a.Timeout < 0:
+		return fmt.Errorf("%s: timeout must not be negative", a.ID)
+	}
+	if a.Timeout == 0 {
+		a.Timeout = defaultActionTimeout
+	}
+
+	a.command, a.body, a.protected = nil, nil, nil
+	switch a.Type {
+	case TypeCommand:
+		if len(a.Command) == 0 {
+			return fmt.Errorf("%s: command is required", a.ID)
+		}
+		for _, arg := range a.Command {
+			tmpl, err := parseTemplate(a.ID, arg)
+			if err != nil {
+				return fmt.Errorf("%s: invalid command template %q: %w", a.ID, arg, err)
+			}
+			a.command = append(a.command, tmpl)
+		}
+	case TypeBlocklist:
+		if a.Blocklist == "" {
+			return fmt.Errorf("%s: blocklist is required", a.ID)
+		}
+		for _, cidr := range a.Protected {
+			prefix, err := netip.ParsePrefix(cidr)
+			if err != nil {
+				return fmt.Errorf("%s: invalid protected CIDR %q", a.ID, cidr)
+			}
+			a.protected = append(a.protected, prefix.Masked())
+		}
+	case TypeWebhook:
+		if u, err := url.Parse(a.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%s: url must be an http(s) URL", a.ID)
+		}
+		if a.Body != "" {
+			tmpl, err := parseTemplate(a.ID, a.Body)
+			if err != nil {
+				return fmt.Errorf("%s: invalid body template: %w", a.ID, err)
+			}
+			a.body = tmpl
+		}
+	}
+	return nil
+}
+
+// Triggered reports whether alert triggers the action.
+func (a Action) Triggered(alert model.Alert) bool {
+	if len(a.Rules) > 0 && !slices.Contains(a.Rules, alert.RuleID) {
+		return false
+	}
+	if a.MinSeverity != "" {
+		min := model.Alert{Severity: a.MinSeverity}
+		if alert.GetSeverityLevel() < min.GetSeverityLevel() {
+			return false
+		}
+	}
+	return true
+}
+
+// blockable returns why addr must not be blocked, or nil if it may be.
+// Blocking an address of the host itself or of its own network would lock
+// out more than the attacker.
+func (a Action) blockable(addr netip.Addr) error {
+	switch {
+	case addr.IsLoopback():
+		return fmt.Errorf("refusing to block loopback address %s", addr)
+	case addr.IsUnspecified():
+		return fmt.Errorf("refusing to block unspecified address %s", addr)
+	case addr.IsPrivate():
+		return fmt.Errorf("refusing to block private address %s", addr)
+	case addr.IsLinkLocalUnicast(), addr.IsLinkLocalMulticast():
+		return fmt.Errorf("refusing to block link-local address %s", addr)
+	case addr.IsMulticast():
+		return fmt.Errorf("refusing to block multicast address %s", addr)
+	}
+	for _, prefix := range a.protected {
+		if prefix.Contains(addr) {
+			return fmt.Errorf("refusing to block %s, protected by %s", addr, prefix)
+		}
+	}
+	return nil
+}
+
+func render(tmpl *template.Template, data TemplateData) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,502 @@
+package response
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"nox/internal/model"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var actionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "nox_response_actions_total",
+	Help: "Response actions triggered, by action and outcome status.",
+}, []string{"action", "status"})
+
+func init() {
+	prometheus.MustRegister(actionsTotal)
+}
+
+// Execution statuses.
+const (
+	StatusPending     = "pending-approval"
+	StatusRejected    = "rejected"
+	StatusDryRun      = "dry-run"
+	StatusRateLimited = "rate-limited"
+	StatusSucceeded   = "succeeded"
+	StatusFailed      = "failed"
+)
+
+var Statuses = []string{StatusPending, StatusRejected, StatusDryRun, StatusRateLimited, StatusSucceeded, StatusFailed}
+
+var (
+	ErrNotPending    = errors.New("response action is not pending approval")
+	ErrUnknownAction = errors.New("response action is no longer configured")
+)
+
+// maxOutput caps the command output and webhook response kept per
+// execution.
+const maxOutput = 4 << 10
+
+// queueSize is how many triggered actions can wait for the worker before
+// new ones are dropped.
+const queueSize = 256
+
+// An Execution is one time an action was triggered by an alert, and what
+// came of it. Every execution is recorded, whatever its outcome.
+type Execution struct {
+	ID       string
+	ActionID string
+	Type     string
+	AlertID  string
+	// Alert is kept so an action waiting for approval can still run.
+	Alert  model.Alert
+	Status string
+	DryRun bool `json:",omitempty"`
+	// Target is what the action acts on: the command line, the IP or the
+	// URL.
+	Target      string
+	Output      string `json:",omitempty"`
+	Error       string `json:",omitempty"`
+	CreatedAt   time.Time
+	CompletedAt time.Time `json:",omitzero"`
+	// DecidedBy and Note record who approved or rejected the action.
+	DecidedBy string `json:",omitempty"`
+	Note      string `json:",omitempty"`
+}
+
+// NewExecutionID returns a random execution ID like "act-1a2b3c4d5e6f".
+func NewExecutionID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "act-" + hex.EncodeToString(b)
+}
+
+// A Recorder stores executions; storage.ESClient implements it.
+type Recorder interface {
+	SaveResponseAction(ctx context.Context, x Execution) error
+	GetResponseAction(ctx context.Context, id string) (Execution, error)
+}
+
+type Config struct {
+	// DryRun turns every action into a dry run, whatever it says itself.
+	DryRun bool
+}
+
+// Responder runs the actions raised alerts trigger. Actions run one at a
+// time on a worker, so a slow webhook never holds up alert handling.
+type Responder struct {
+	actions  []Action
+	cfg      Config
+	recorder Recorder
+	logger   *slog.Logger
+	client   *http.Client
+	now      func() time.Time
+
+	queue chan job
+
+	mu   sync.Mutex
+	runs map[string][]time.Time // by action ID, within the last hour
+	// deciding holds the executions being approved or rejected, so two
+	// admins deciding at once don't both run the action.
+	deciding map[string]bool
+	// blocklistMu serializes writes to blocklist files.
+	blocklistMu sync.Mutex
+}
+
+type job struct {
+	action Action
+	x      Execution
+}
+
+func NewResponder(actions []Action, cfg Config, recorder Recorder, logger *slog.Logger) *Responder {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Responder{
+		actions:  actions,
+		cfg:      cfg,
+		recorder: recorder,
+		logger:   logger,
+		client:   &http.Client{},
+		now:      time.Now,
+		queue:    make(chan job, queueSize),
+		runs:     make(map[string][]time.Time),
+		deciding: make(map[string]bool),
+	}
+}
+
+// Actions returns the configured actions.
+func (r *Responder) Actions() []Action {
+	return r.actions
+}
+
+// Handle queues the actions alert triggers. Actions that need approval are
+// recorded as pending straight away.
+func (r *Responder) Handle(ctx context.Context, alertID string, alert model.Alert) {
+	for _, action := range r.actions {
+		if !action.Triggered(alert) {
+			continue
+		}
+
+		x := Execution{
+			ID:        NewExecutionID(),
+			ActionID:  action.ID,
+			Type:      action.Type,
+			AlertID:   alertID,
+			Alert:     alert,
+			DryRun:    r.cfg.DryRun || action.DryRun,
+			CreatedAt: r.now().UTC(),
+		}
+		// an action that can't run for this alert fails now rather than
+		// wait for an approval that couldn't make it run.
+		target, err := action.target(x)
+		x.Target = target
+		if err != nil {
+			x.Status, x.Error, x.CompletedAt = StatusFailed, err.Error(), x.CreatedAt
+			r.record(ctx, x)
+			r.logger.Error("Response action can't run for alert", "action", action.ID, "alert_id", alertID, "error", err)
+			continue
+		}
+
+		if action.RequireApproval {
+			x.Status = StatusPending
+			r.record(ctx, x)
+			r.logger.Warn("Response action waiting for approval", "action", action.ID, "execution", x.ID, "alert_id", alertID)
+			continue
+		}
+
+		select {
+		case r.queue <- job{action, x}:
+		default:
+			x.Status, x.Error = StatusFailed, "response queue is full"
+			r.record(ctx, x)
+		}
+	}
+}
+
+// Run executes queued actions until ctx is cancelled.
+func (r *Responder) Run(ctx context.Context) {
+	for {
+		select {
+		case j := <-r.queue:
+			r.record(ctx, r.execute(ctx, j.action, j.x))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Approve runs a pending action and returns its outcome. The action's
+// rate limit doesn't apply, since someone asked for it.
+func (r *Responder) Approve(ctx context.Context, id, by, note string) (Execution, error) {
+	if err := r.claim(id); err != nil {
+		return Execution{}, err
+	}
+	defer r.release(id)
+
+	x, action, err := r.pending(ctx, id)
+	if err != nil {
+		return x, err
+	}
+
+	x.DecidedBy, x.Note = by, note
+	x = r.run(ctx, action, x)
+	r.logger.Info("Response action approved", "action", x.ActionID, "execution", x.ID, "by", by, "status", x.Status)
+	return x, r.save(ctx, x)
+}
+
+// Reject cancels a pending action.
+func (r *Responder) Reject(ctx context.Context, id, by, note string) (Execution, error) {
+	if err := r.claim(id); err != nil {
+		return Execution{}, err
+	}
+	defer r.release(id)
+
+	x, _, err := r.pending(ctx, id)
+	if errors.Is(err, ErrUnknownAction) {
+		err = nil
+	}
+	if err != nil {
+		return x, err
+	}
+
+	x.Status = StatusRejected
+	x.DecidedBy, x.Note = by, note
+	x.CompletedAt = r.now().UTC()
+	r.logger.Info("Response action rejected", "action", x.ActionID, "execution", x.ID, "by", by)
+	return x, r.save(ctx, x)
+}
+
+// claim marks execution id as being decided, until release. The decision
+// is only saved once the action has run, so until then the execution still
+// reads as pending.
+func (r *Responder) claim(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.deciding[id] {
+		return fmt.Errorf("%w: %s is already being decided", ErrNotPending, id)
+	}
+	r.deciding[id] = true
+	return nil
+}
+
+func (r *Responder) release(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.deciding, id)
+}
+
+func (r *Responder) pending(ctx context.Context, id string) (Execution, Action, error) {
+	if r.recorder == nil {
+		return Execution{}, Action{}, errors.New("response actions are not recorded")
+	}
+	x, err := r.recorder.GetResponseAction(ctx, id)
+	if err != nil {
+		return x, Action{}, err
+	}
+	if x.Status != StatusPending {
+		return x, Action{}, fmt.Errorf("%w: %s is %s", ErrNotPending, id, x.Status)
+	}
+	for _, action := range r.actions {
+		if action.ID == x.ActionID {
+			return x, action, nil
+		}
+	}
+	return x, Action{}, fmt.Errorf("%w: %s", ErrUnknownAction, x.ActionID)
+}
+
+// execute runs an action within its rate limit.
+func (r *Responder) execute(ctx context.Context, action Action, x Execution) Execution {
+	if !r.allow(action) {
+		x.Status = StatusRateLimited
+		x.Error = fmt.Sprintf("ran %d times in the last hour", action.MaxPerHour)
+		x.CompletedAt = r.now().UTC()
+		r.logger.Warn("Response action rate limited", "action", action.ID, "alert_id", x.AlertID)
+		return x
+	}
+	return r.run(ctx, action, x)
+}
+
+func (r *Responder) run(ctx context.Context, action Action, x Execution) (done Execution) {
+	defer func() { done.CompletedAt = r.now().UTC() }()
+
+	target, err := action.target(x)
+	x.Target = target
+	if err != nil {
+		x.Status, x.Error = StatusFailed, err.Error()
+		return x
+	}
+	if x.DryRun {
+		x.Status = StatusDryRun
+		r.logger.Info("Response action dry run", "action", action.ID, "alert_id", x.AlertID, "target", target)
+		return x
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, action.Timeout)
+	defer cancel()
+
+	var output string
+	switch action.Type {
+	case TypeCommand:
+		output, err = r.runCommand(ctx, action, x)
+	case TypeBlocklist:
+		output, err = r.block(action.Blocklist, target)
+	case TypeWebhook:
+		output, err = r.callWebhook(ctx, action, x)
+	}
+
+	x.Output = truncate(output)
+	if err != nil {
+		x.Status, x.Error = StatusFailed, err.Error()
+		r.logger.Error("Response action failed", "action", action.ID, "alert_id", x.AlertID, "error", err)
+		return x
+	}
+	x.Status = StatusSucceeded
+	r.logger.Info("Response action ran", "action", action.ID, "alert_id", x.AlertID, "target", target)
+	return x
+}
+
+// allow counts a run against the action's hourly limit, unless it is used
+// up.
+func (r *Responder) allow(action Action) bool {
+	if action.MaxPerHour == 0 {
+		return true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	var recent []time.Time
+	for _, t := range r.runs[action.ID] {
+		if now.Sub(t) < time.Hour {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= action.MaxPerHour {
+		r.runs[action.ID] = recent
+		return false
+	}
+	r.runs[action.ID] = append(recent, now)
+	return true
+}
+
+// target describes what the action acts on for x.
+func (a Action) target(x Execution) (string, error) {
+	switch a.Type {
+	case TypeCommand:
+		args, err := a.args(x)
+		return strings.Join(args, " "), err
+	case TypeBlocklist:
+		addr, err := netip.ParseAddr(x.Alert.Source)
+		if err != nil {
+			return "", fmt.Errorf("alert source %q is not an IP address", x.Alert.Source)
+		}
+		addr = addr.Unmap()
+		return addr.String(), a.blockable(addr)
+	case TypeWebhook:
+		return a.URL, nil
+	}
+	return "", nil
+}
+
+// args renders the command. Alert fields come from logs an attacker
+// writes, so an argument that renders empty, or to something the program
+// would read as an option when its template isn't one, is refused.
+func (a Action) args(x Execution) ([]string, error) {
+	data := TemplateData{AlertID: x.AlertID, Alert: x.Alert}
+	args := make([]string, len(a.command))
+	for i, tmpl := range a.command {
+		arg, err := render(tmpl, data)
+		if err != nil {
+			return nil, err
+		}
+		switch text := a.Command[i]; {
+		case arg == "" && text != "":
+			return nil, fmt.Errorf("argument %q rendered empty", text)
+		case strings.HasPrefix(arg, "-") && !strings.HasPrefix(text, "-"):
+			return nil, fmt.Errorf("argument %q rendered %q, which would be read as an option", text, arg)
+		}
+		args[i] = arg
+	}
+	return args, nil
+}
+
+func (r *Responder) runCommand(ctx context.Context, action Action, x Execution) (string, error) {
+	args, err := action.args(x)
+	if err != nil {
+		return "", err
+	}
+	out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("command failed: %w", err)
+	}
+	return string(out), nil
+}
+
+// block appends ip to the blocklist file unless it is already listed.
+func (r *Responder) block(path, ip string) (string, error) {
+	r.blocklistMu.Lock()
+	defer r.blocklistMu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("failed to open blocklist: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == ip {
+			return "already listed", nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read blocklist: %w", err)
+	}
+
+	if _, err := f.WriteString(ip + "\n"); err != nil {
+		return "", fmt.Errorf("failed to write blocklist: %w", err)
+	}
+	return "added to " + path, nil
+}
+
+func (r *Responder) callWebhook(ctx context.Context, action Action, x Execution) (string, error) {
+	var body []byte
+	if action.body != nil {
+		rendered, err := render(action.body, TemplateData{AlertID: x.AlertID, Alert: x.Alert})
+		if err != nil {
+			return "", err
+		}
+		if !json.Valid([]byte(rendered)) {
+			return "", fmt.Errorf("body did not render valid JSON, quote alert fields with {{json .Field}}")
+		}
+		body = []byte(rendered)
+	} else {
+		var err error
+		body, err = json.Marshal(map[string]any{"alert_id": x.AlertID, "action": x.ActionID, "alert": x.Alert})
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal alert: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, action.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range action.Headers {
+		req.Header.Set(key, os.ExpandEnv(value))
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	out, _ := io.ReadAll(io.LimitReader(resp.Body, maxOutput))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return string(out), fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return resp.Status, nil
+}
+
+func (r *Responder) record(ctx context.Context, x Execution) {
+	if err := r.save(ctx, x); err != nil {
+		r.logger.Error("Failed to record response action", "action", x.ActionID, "execution", x.ID, "status", x.Status, "error", err)
+	}
+}
+
+// save records x and counts its outcome.
+func (r *Responder) save(ctx context.Context, x Execution) error {
+	actionsTotal.WithLabelValues(x.ActionID, x.Status).Inc()
+	if r.recorder == nil {
+		return nil
+	}
+	return r.recorder.SaveResponseAction(ctx, x)
+}
+
+func truncate(s string) string {
+	if len(s) > maxOutput {
+		return s[:maxOutput] + "..."
+	}
+	return s
+}
@@ -0,0 +1,494 @@
+package response
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"nox/internal/model"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type memRecorder struct {
+	mu         sync.Mutex
+	executions map[string]Execution
+}
+
+func (m *memRecorder) SaveResponseAction(_ context.Context, x Execution) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.executions == nil {
+		m.executions = make(map[string]Execution)
+	}
+	m.executions[x.ID] = x
+	return nil
+}
+
+func (m *memRecorder) GetResponseAction(_ context.Context, id string) (Execution, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	x, ok := m.executions[id]
+	if !ok {
+		return x, errors.New("not found")
+	}
+	return x, nil
+}
+
+func (m *memRecorder) only(t *testing.T) Execution {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.executions) != 1 {
+		t.Fatalf("got %d executions, want 1", len(m.executions))
+	}
+	for _, x := range m.executions {
+		return x
+	}
+	return Execution{}
+}
+
+var criticalAlert = model.Alert{
+	RuleName:  "Brute Force and Evasion",
+	RuleID:    "brute-force-evasion",
+	Severity:  "CRITICAL",
+	Source:    "203.0.113.7",
+	Timestamp: time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC),
+	Metadata:  map[string]string{"user": "root"},
+}
+
+func compiled(t *testing.T, a Action) Action {
+	t.Helper()
+	if err := a.compile(); err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	return a
+}
+
+// handle runs the actions alert triggers and waits for them to be recorded.
+func handle(t *testing.T, r *Responder, alert model.Alert) {
+	t.Helper()
+	r.Handle(context.Background(), "alert-1", alert)
+	for len(r.queue) > 0 {
+		j := <-r.queue
+		r.record(context.Background(), r.execute(context.Background(), j.action, j.x))
+	}
+}
+
+func TestActionTriggered(t *testing.T) {
+	tests := []struct {
+		name   string
+		action Action
+		alert  model.Alert
+		want   bool
+	}{
+		{name: "severity", action: Action{MinSeverity: "HIGH"}, alert: criticalAlert, want: true},
+		{name: "below severity", action: Action{MinSeverity: "CRITICAL"}, alert: model.Alert{Severity: "HIGH"}},
+		{name: "rule", action: Action{Rules: []string{"brute-force-evasion"}}, alert: criticalAlert, want: true},
+		{name: "other rule", action: Action{Rules: []string{"nmap"}}, alert: criticalAlert},
+		{name: "rule and severity", action: Action{Rules: []string{"nmap"}, MinSeverity: "LOW"}, alert: model.Alert{RuleID: "nmap", Severity: "LOW"}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.action.Triggered(tt.alert); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadActionsValidates(t *testing.T) {
+	tests := []struct {
+		name    string
+		actions string
+		wantErr string
+	}{
+		{name: "valid", actions: "- id: notify\n  type: webhook\n  min_severity: HIGH\n  url: https://hooks.example.com/nox\n"},
+		{name: "no trigger", actions: "- id: notify\n  type: webhook\n  url: https://hooks.example.com/nox\n", wantErr: "needs rules or min_severity"},
+		{name: "unknown type", actions: "- id: notify\n  type: email\n  min_severity: HIGH\n", wantErr: "unknown type"},
+		{name: "no command", actions: "- id: run\n  type: command\n  min_severity: HIGH\n", wantErr: "command is required"},
+		{name: "bad template", actions: "- id: run\n  type: command\n  min_severity: HIGH\n  command: [echo, '{{.Source']\n", wantErr: "invalid command template"},
+		{name: "bad url", actions: "- id: notify\n  type: webhook\n  min_severity: HIGH\n  url: ftp://example.com\n", wantErr: "http(s) URL"},
+		{name: "bad protected cidr", actions: "- id: b\n  type: blocklist\n  min_severity: HIGH\n  blocklist: x\n  protected: [10.0.0.1]\n", wantErr: "invalid protected CIDR"},
+		{name: "duplicate", actions: "- id: b\n  type: blocklist\n  min_severity: HIGH\n  blocklist: x\n- id: b\n  type: blocklist\n  min_severity: HIGH\n  blocklist: x\n", wantErr: "duplicate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "actions.yaml")
+			if err := os.WriteFile(path, []byte(tt.actions), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadActions(path)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("got error %v, want nil", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResponderCommandDryRun(t *testing.T) {
+	recorder := &memRecorder{}
+	action := compiled(t, Action{
+		ID:          "isolate",
+		Type:        TypeCommand,
+		MinSeverity: "CRITICAL",
+		DryRun:      true,
+		Command:     []string{"/usr/local/bin/isolate", "--ip", "{{.Source}}", "--user", "{{.Metadata.user}}", "--ticket", "{{.AlertID}}"},
+	})
+	r := NewResponder([]Action{action}, Config{}, recorder, nil)
+
+	handle(t, r, criticalAlert)
+
+	x := recorder.only(t)
+	if x.Status != StatusDryRun || !x.DryRun {
+		t.Fatalf("got status %s, want a dry run", x.Status)
+	}
+	if want := "/usr/local/bin/isolate --ip 203.0.113.7 --user root --ticket alert-1"; x.Target != want {
+		t.Fatalf("got target %q, want %q", x.Target, want)
+	}
+}
+
+func TestActionArgsRefuseUnsafeRenders(t *testing.T) {
+	tests := []struct {
+		name    string
+		command []string
+		meta    map[string]string
+		want    string
+		wantErr string
+	}{
+		{name: "literal options", command: []string{"isolate", "--reason", "{{.RuleName}}"}, want: "isolate --reason brute-force-evasion"},
+		{name: "option template", command: []string{"isolate", "--user={{.Metadata.user}}"}, meta: map[string]string{"user": "root"}, want: "isolate --user=root"},
+		{name: "field rendering an option", command: []string{"isolate", "{{.Metadata.user}}"}, meta: map[string]string{"user": "--force"}, wantErr: "read as an option"},
+		{name: "missing field", command: []string{"isolate", "{{.Metadata.host}}"}, meta: map[string]string{}, wantErr: "rendered empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action := compiled(t, Action{ID: "isolate", Type: TypeCommand, MinSeverity: "LOW", Command: tt.command})
+			alert := model.Alert{RuleName: "brute-force-evasion", Metadata: tt.meta}
+			args, err := action.args(Execution{Alert: alert})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %q, %v, want an error containing %q", args, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("args: %v", err)
+			}
+			if got := strings.Join(args, " "); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponderFailsActionsThatCantRunInsteadOfHoldingThem(t *testing.T) {
+	recorder := &memRecorder{}
+	action := compiled(t, Action{
+		ID:              "isolate-host",
+		Type:            TypeCommand,
+		MinSeverity:     "CRITICAL",
+		RequireApproval: true,
+		Command:         []string{"/usr/local/sbin/isolate-host", "{{.Metadata.host}}"},
+	})
+	r := NewResponder([]Action{action}, Config{}, recorder, nil)
+
+	// criticalAlert names no host
+	handle(t, r, criticalAlert)
+
+	if x := recorder.only(t); x.Status != StatusFailed || !strings.Contains(x.Error, "rendered empty") {
+		t.Fatalf("got %+v, want a failed action, not one pending approval", x)
+	}
+}
+
+func TestResponderWebhookBodyIsJSON(t *testing.T) {
+	actions, err := LoadActions("../../detections/actions.example.yaml")
+	if err != nil {
+		t.Fatalf("LoadActions: %v", err)
+	}
+	i := slices.IndexFunc(actions, func(a Action) bool { return a.ID == "page-on-call" })
+	if i < 0 {
+		t.Fatalf("got no page-on-call action in the example")
+	}
+
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Errorf("got body %s, want JSON: %v", body, err)
+		}
+	}))
+	defer srv.Close()
+
+	hostile := criticalAlert
+	hostile.Message = `login as "root", "admin": "yes"` + "\n"
+
+	pager := actions[i]
+	pager.URL, pager.DryRun = srv.URL, false
+	recorder := &memRecorder{}
+	handle(t, NewResponder([]Action{pager}, Config{}, recorder, nil), hostile)
+
+	if x := recorder.only(t); x.Status != StatusSucceeded {
+		t.Fatalf("got %+v, want the page sent", x)
+	}
+	if !strings.HasSuffix(got["text"], hostile.Message) || len(got) != 1 {
+		t.Fatalf("got body %v, want the message kept inside text", got)
+	}
+
+	// an unquoted field that breaks the JSON is not sent
+	pager.Body = `{"text": "{{.Message}}"}`
+	pager = compiled(t, pager)
+	recorder = &memRecorder{}
+	handle(t, NewResponder([]Action{pager}, Config{}, recorder, nil), hostile)
+	if x := recorder.only(t); x.Status != StatusFailed || !strings.Contains(x.Error, "valid JSON") {
+		t.Fatalf("got %+v, want the page refused", x)
+	}
+}
+
+func TestResponderBlocklist(t *testing.T) {
+	recorder := &memRecorder{}
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	action := compiled(t, Action{ID: "block-ip", Type: TypeBlocklist, MinSeverity: "CRITICAL", Blocklist: path})
+	r := NewResponder([]Action{action}, Config{}, recorder, nil)
+
+	handle(t, r, criticalAlert)
+	handle(t, r, criticalAlert)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "203.0.113.7\n" {
+		t.Fatalf("got blocklist %q, want the IP listed once", data)
+	}
+	for _, x := range recorder.executions {
+		if x.Status != StatusSucceeded || x.Target != "203.0.113.7" {
+			t.Fatalf("got %+v, want a succeeded block of 203.0.113.7", x)
+		}
+	}
+
+	notAnIP := criticalAlert
+	notAnIP.Source = "localhost"
+	recorder.executions = nil
+	handle(t, r, notAnIP)
+	if x := recorder.only(t); x.Status != StatusFailed {
+		t.Fatalf("got status %s for a non-IP source, want failed", x.Status)
+	}
+}
+
+func TestResponderBlocklistRefusesOwnAddresses(t *testing.T) {
+	action := compiled(t, Action{
+		ID:          "block-ip",
+		Type:        TypeBlocklist,
+		MinSeverity: "CRITICAL",
+		Blocklist:   filepath.Join(t.TempDir(), "blocklist.txt"),
+		Protected:   []string{"198.51.100.0/24", "2001:db8::/32"},
+	})
+
+	tests := []struct {
+		source  string
+		wantErr string
+	}{
+		{source: "203.0.113.7"},
+		{source: "::ffff:203.0.113.7"},
+		{source: "127.0.0.1", wantErr: "loopback"},
+		{source: "::1", wantErr: "loopback"},
+		{source: "0.0.0.0", wantErr: "unspecified"},
+		{source: "10.1.2.3", wantErr: "private"},
+		{source: "192.168.0.10", wantErr: "private"},
+		{source: "::ffff:172.16.0.1", wantErr: "private"},
+		{source: "fd00::1", wantErr: "private"},
+		{source: "169.254.169.254", wantErr: "link-local"},
+		{source: "fe80::1", wantErr: "link-local"},
+		{source: "224.0.0.1", wantErr: "link-local"},
+		{source: "239.1.1.1", wantErr: "multicast"},
+		{source: "198.51.100.20", wantErr: "protected by 198.51.100.0/24"},
+		{source: "2001:db8::7", wantErr: "protected by 2001:db8::/32"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			alert := criticalAlert
+			alert.Source = tt.source
+			_, err := action.target(Execution{Alert: alert})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("got error %v, want %s blocked", err, tt.source)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// process alerts come from the host itself
+	recorder := &memRecorder{}
+	r := NewResponder([]Action{action}, Config{}, recorder, nil)
+	local := criticalAlert
+	local.Source = "127.0.0.1"
+	handle(t, r, local)
+
+	if x := recorder.only(t); x.Status != StatusFailed || !strings.Contains(x.Error, "loopback") {
+		t.Fatalf("got %+v, want the block of 127.0.0.1 refused", x)
+	}
+	if _, err := os.Stat(action.Blocklist); err == nil {
+		t.Fatalf("got a blocklist, want nothing written")
+	}
+}
+
+func TestResponderWebhookRateLimit(t *testing.T) {
+	var calls int
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &got)
+	}))
+	defer srv.Close()
+
+	recorder := &memRecorder{}
+	action := compiled(t, Action{ID: "notify", Type: TypeWebhook, MinSeverity: "HIGH", URL: srv.URL, MaxPerHour: 2})
+	r := NewResponder([]Action{action}, Config{}, recorder, nil)
+
+	for range 3 {
+		handle(t, r, criticalAlert)
+	}
+
+	if calls != 2 {
+		t.Fatalf("got %d webhook calls, want 2", calls)
+	}
+	if got["alert_id"] != "alert-1" {
+		t.Fatalf("got body %v, want the alert ID", got)
+	}
+	statuses := map[string]int{}
+	for _, x := range recorder.executions {
+		statuses[x.Status]++
+	}
+	if statuses[StatusSucceeded] != 2 || statuses[StatusRateLimited] != 1 {
+		t.Fatalf("got statuses %v, want 2 succeeded and 1 rate limited", statuses)
+	}
+}
+
+func TestResponderApproval(t *testing.T) {
+	recorder := &memRecorder{}
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	action := compiled(t, Action{ID: "block-ip", Type: TypeBlocklist, MinSeverity: "CRITICAL", Blocklist: path, RequireApproval: true})
+	r := NewResponder([]Action{action}, Config{}, recorder, nil)
+	ctx := context.Background()
+
+	handle(t, r, criticalAlert)
+	pending := recorder.only(t)
+	if pending.Status != StatusPending {
+		t.Fatalf("got status %s, want %s", pending.Status, StatusPending)
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Fatalf("got a blocklist before approval, want none")
+	}
+
+	x, err := r.Approve(ctx, pending.ID, "alice", "confirmed with the owner")
+	if err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+	if x.Status != StatusSucceeded || x.DecidedBy != "alice" || x.CompletedAt.IsZero() {
+		t.Fatalf("got %+v, want a succeeded action approved by alice", x)
+	}
+	if _, err := r.Approve(ctx, pending.ID, "alice", ""); !errors.Is(err, ErrNotPending) {
+		t.Fatalf("got error %v approving twice, want ErrNotPending", err)
+	}
+	if _, err := r.Reject(ctx, pending.ID, "bob", ""); !errors.Is(err, ErrNotPending) {
+		t.Fatalf("got error %v rejecting a done action, want ErrNotPending", err)
+	}
+}
+
+func TestResponderConcurrentApprovalRunsOnce(t *testing.T) {
+	var calls atomic.Int32
+	started, unblock := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		<-unblock
+	}))
+	defer srv.Close()
+
+	recorder := &memRecorder{}
+	action := compiled(t, Action{ID: "notify", Type: TypeWebhook, MinSeverity: "HIGH", URL: srv.URL, RequireApproval: true})
+	r := NewResponder([]Action{action}, Config{}, recorder, nil)
+	ctx := context.Background()
+
+	handle(t, r, criticalAlert)
+	id := recorder.only(t).ID
+
+	first := make(chan error)
+	go func() {
+		_, err := r.Approve(ctx, id, "alice", "")
+		first <- err
+	}()
+	<-started
+
+	// the record still reads as pending while the first approval runs
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := range cap(errs) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			if i%2 == 0 {
+				_, err = r.Approve(ctx, id, "bob", "")
+			} else {
+				_, err = r.Reject(ctx, id, "bob", "")
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	close(unblock)
+
+	for err := range errs {
+		if !errors.Is(err, ErrNotPending) {
+			t.Fatalf("got error %v deciding concurrently, want ErrNotPending", err)
+		}
+	}
+	if err := <-first; err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("got %d webhook calls, want 1", got)
+	}
+	if x := recorder.only(t); x.Status != StatusSucceeded || x.DecidedBy != "alice" {
+		t.Fatalf("got %+v, want the action run once, approved by alice", x)
+	}
+}
+
+func TestResponderGlobalDryRun(t *testing.T) {
+	recorder := &memRecorder{}
+	action := compiled(t, Action{ID: "block-ip", Type: TypeBlocklist, MinSeverity: "CRITICAL", Blocklist: "/nonexistent/blocklist", RequireApproval: true})
+	r := NewResponder([]Action{action}, Config{DryRun: true}, recorder, nil)
+
+	handle(t, r, criticalAlert)
+	x, err := r.Approve(context.Background(), recorder.only(t).ID, "alice", "")
+	if err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+	if x.Status != StatusDryRun {
+		t.Fatalf("got status %s, want an approved action to stay a dry run", x.Status)
+	}
+}
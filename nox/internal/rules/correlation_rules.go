@@ -21,6 +21,21 @@ func (r *DownloadAndExecuteRule) Name() string {
 	return "CorrelatedDownloadAndExecute"
 }
 
+func (r *DownloadAndExecuteRule) Metadata() RuleMetadata {
+	return builtin(RuleMetadata{
+		ID:          "correlated-download-and-execute",
+		Name:        r.Name(),
+		Kind:        RuleKindCorrelation,
+		Description: "A file downloaded to /tmp or /dev/shm was executed shortly after.",
+		Severity:    "CRITICAL",
+		Techniques:  []string{"T1105", "T1059.004"},
+		Tags:        []string{"process", "chain"},
+		FalsePositives: []string{
+			"Installers that download and run a bootstrap script",
+		},
+	})
+}
+
 func (r *DownloadAndExecuteRule) extractFilePath(command string) string {
 	parts := strings.Fields(command)
 
@@ -48,9 +63,8 @@ func (r *DownloadAndExecuteRule) Evaluate(event model.Event, existingAlerts []mo
 	if processName == "wget" || processName == "curl" {
 		filepath := r.extractFilePath(command)
 		if filepath != "" {
-			s.mu.Lock()
-			s.Payloads[filepath] = event.Timestamp
-			s.mu.Unlock()
+			s.Payloads.Set(filepath, event.Timestamp, event.Timestamp)
+			state.Chains.remember(r.Name(), filepath, event)
 		}
 		return nil
 	}
@@ -58,22 +72,32 @@ func (r *DownloadAndExecuteRule) Evaluate(event model.Event, existingAlerts []mo
 	// Stage 2: Detect execution
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	for stagedPath, downloadTime := range s.Payloads {
-		if strings.Contains(command, stagedPath) && event.Timestamp.Sub(downloadTime) <= r.Window {
-			delete(s.Payloads, stagedPath)
-			return &model.Alert{
-				RuleName:  r.Name(),
-				Message:   fmt.Sprintf("Attack Chain Detected: A file was download to %s and then executed.", stagedPath),
-				Severity:  "CRITICAL",
-				Timestamp: event.Timestamp,
-				Source:    event.Source,
-				Metadata: map[string]string{
-					"mitre_technique":   "T1105",
-					"time_to_execution": event.Timestamp.Sub(downloadTime).String(),
-					"executed_command":  command,
-					"staged_filepath":   stagedPath,
-				},
-			}
+
+	var stagedPath string
+	var downloadTime time.Time
+	s.Payloads.Range(func(path string, downloadedAt time.Time, _ time.Time) bool {
+		if strings.Contains(command, path) && event.Timestamp.Sub(downloadedAt) <= r.Window {
+			stagedPath, downloadTime = path, downloadedAt
+			return false
+		}
+		return true
+	})
+
+	if stagedPath != "" {
+		s.Payloads.Delete(stagedPath)
+		return &model.Alert{
+			RuleName:  r.Name(),
+			Message:   fmt.Sprintf("Attack Chain Detected: A file was download to %s and then executed.", stagedPath),
+			Severity:  "CRITICAL",
+			Timestamp: event.Timestamp,
+			Source:    event.Source,
+			Metadata: map[string]string{
+				"mitre_technique":   "T1105",
+				"time_to_execution": event.Timestamp.Sub(downloadTime).String(),
+				"executed_command":  command,
+				"staged_filepath":   stagedPath,
+			},
+			Events: append(state.Chains.take(r.Name(), stagedPath), event),
 		}
 	}
 
@@ -102,20 +126,29 @@ func (r *LoginAndEscalationRule) Name() string {
 	return "CorrelatedLoginAndEscalation"
 }
 
+func (r *LoginAndEscalationRule) Metadata() RuleMetadata {
+	return builtin(RuleMetadata{
+		ID:          "correlated-login-and-escalation",
+		Name:        r.Name(),
+		Kind:        RuleKindCorrelation,
+		Description: "A login from a new country was followed by a privilege escalation attempt.",
+		Severity:    "CRITICAL",
+		Techniques:  []string{"T1078", "T1548.003"},
+		Tags:        []string{"sshd", "process", "chain"},
+		FalsePositives: []string{
+			"An administrator travelling and using sudo",
+		},
+	})
+}
+
 func (r *LoginAndEscalationRule) Evaluate(event model.Event, existingAlerts []model.Alert, state *StateManager) *model.Alert {
 	s := state.SuspiciousLoginTracker
 	// Stage 1: check for the start of the chain (a NewCountryLogin)
 	for _, alert := range existingAlerts {
 		if alert.RuleName == "NewCountryLogin" {
-			s.mu.Lock()
-			for ip, t := range s.Logins {
-				if time.Since(t) > r.Window {
-					delete(s.Logins, ip)
-				}
-			}
-
-			s.Logins[alert.Source] = alert.Timestamp
-			s.mu.Unlock()
+			// stale logins are expired by the state janitor.
+			s.Logins.Set(alert.Source, alert.Timestamp, alert.Timestamp)
+			state.Chains.remember(r.Name(), alert.Source, alert.Events...)
 			return nil
 		}
 	}
@@ -136,9 +169,9 @@ func (r *LoginAndEscalationRule) Evaluate(event model.Event, existingAlerts []mo
 			s.mu.Lock()
 			defer s.mu.Unlock()
 
-			if loginTime, ok := s.Logins[event.Source]; ok {
+			if loginTime, ok := s.Logins.Get(event.Source); ok {
 				if event.Timestamp.Sub(loginTime) <= r.Window {
-					delete(s.Logins, event.Source)
+					s.Logins.Delete(event.Source)
 					return &model.Alert{
 						RuleName:  r.Name(),
 						Message:   fmt.Sprintf("Attack Chain Detected: A Login from a new country (%s) was followed by a privile escalation attempt", event.Source),
@@ -150,6 +183,7 @@ func (r *LoginAndEscalationRule) Evaluate(event model.Event, existingAlerts []mo
 							"correlated_events":  "NewCountryLogin, PrivilegeEscalationAttempt",
 							"time_to_escalation": event.Timestamp.Sub(loginTime).String(),
 						},
+						Events: append(state.Chains.take(r.Name(), event.Source), event),
 					}
 				}
 			}
@@ -180,13 +214,33 @@ func (r *BruteForceAndEvasionRule) Name() string {
 	return "CorrelatedBruteForceAndEvasion"
 }
 
+func (r *BruteForceAndEvasionRule) Metadata() RuleMetadata {
+	return builtin(RuleMetadata{
+		ID:          "correlated-brute-force-and-evasion",
+		Name:        r.Name(),
+		Kind:        RuleKindCorrelation,
+		Description: "A successful login after a brute force was followed by shell history tampering.",
+		Severity:    "CRITICAL",
+		Techniques:  []string{"T1110", "T1070.003"},
+		Tags:        []string{"sshd", "process", "chain"},
+	})
+}
+
 func (r *BruteForceAndEvasionRule) Evaluate(event model.Event, existingAlerts []model.Alert, state *StateManager) *model.Alert {
+	for _, alert := range existingAlerts {
+		if alert.RuleName == "TooManyFailedLogins" {
+			state.Chains.remember(r.Name(), alert.Source, alert.Events...)
+		}
+	}
+
 	switch event.EventType {
 	case "SSHD_Accepted_Password":
+		// TooManyFailedLogins marks the account it saw brute forced
+		key := event.Source + "|" + event.Metadata["user"]
 		state.FailedLogins.mu.Lock()
-		wasAlerted := state.FailedLogins.AlertedIPs[event.Source]
+		wasAlerted, _ := state.FailedLogins.AlertedIPs.Get(key)
 		if wasAlerted {
-			delete(state.FailedLogins.AlertedIPs, event.Source)
+			state.FailedLogins.AlertedIPs.Delete(key)
 		}
 		state.FailedLogins.mu.Unlock()
 
@@ -198,9 +252,10 @@ func (r *BruteForceAndEvasionRule) Evaluate(event model.Event, existingAlerts []
 					LoginTime: event.Timestamp,
 					SourceIP:  event.Source,
 				}
-				state.PostBruteForceLogins.mu.Lock()
-				state.PostBruteForceLogins.SuccessfulLogins[sshdPID] = loginInfo
-				state.PostBruteForceLogins.mu.Unlock()
+				state.PostBruteForceLogins.SuccessfulLogins.Set(sshdPID, loginInfo, event.Timestamp)
+				// the chain continues in the login session
+				chain := append(state.Chains.take(r.Name(), event.Source), event)
+				state.Chains.remember(r.Name(), "sshd:"+sshdPID, chain...)
 			}
 		}
 	case "Process_Executed":
@@ -220,9 +275,9 @@ func (r *BruteForceAndEvasionRule) Evaluate(event model.Event, existingAlerts []
 			s.mu.Lock()
 			defer s.mu.Unlock()
 
-			if loginInfo, ok := s.SuccessfulLogins[ppid]; ok {
+			if loginInfo, ok := s.SuccessfulLogins.Get(ppid); ok {
 				if event.Timestamp.Sub(loginInfo.LoginTime) <= r.Window {
-					delete(s.SuccessfulLogins, ppid)
+					s.SuccessfulLogins.Delete(ppid)
 					return &model.Alert{
 						RuleName:  r.Name(),
 						Message:   fmt.Sprintf("Attach Chain Detected: A successful login from %s after a brute-force was followed by the defenseive evasion command: '%s'", loginInfo.SourceIP, command),
@@ -236,6 +291,7 @@ func (r *BruteForceAndEvasionRule) Evaluate(event model.Event, existingAlerts []
 							"evasion_command":   command,
 							"linked_sshd_pid":   ppid,
 						},
+						Events: append(state.Chains.take(r.Name(), "sshd:"+ppid), event),
 					}
 				}
 			}
@@ -260,6 +316,21 @@ func (r *LocalAccountImmediateUseRule) Name() string {
 	return "CorrelatedNewAccountUsage"
 }
 
+func (r *LocalAccountImmediateUseRule) Metadata() RuleMetadata {
+	return builtin(RuleMetadata{
+		ID:          "correlated-new-account-usage",
+		Name:        r.Name(),
+		Kind:        RuleKindCorrelation,
+		Description: "A new local account was created and used to log in shortly after.",
+		Severity:    "HIGH",
+		Techniques:  []string{"T1136.001", "T1078.003"},
+		Tags:        []string{"sshd", "process", "chain"},
+		FalsePositives: []string{
+			"Provisioning that creates and immediately tests an account",
+		},
+	})
+}
+
 func (r *LocalAccountImmediateUseRule) Evaluate(event model.Event, existingAlerts []model.Alert, state *StateManager) *model.Alert {
 	switch event.EventType {
 	case "Process_Executed":
@@ -271,9 +342,8 @@ func (r *LocalAccountImmediateUseRule) Evaluate(event model.Event, existingAlert
 			parts := strings.Fields(command)
 			if len(parts) > 1 {
 				newUser := parts[len(parts)-1]
-				s.mu.Lock()
-				s.CreationTimes[newUser] = event.Timestamp
-				s.mu.Unlock()
+				s.CreationTimes.Set(newUser, event.Timestamp, event.Timestamp)
+				state.Chains.remember(r.Name(), newUser, event)
 			}
 		}
 	case "SSHD_Accepted_Password":
@@ -286,9 +356,9 @@ func (r *LocalAccountImmediateUseRule) Evaluate(event model.Event, existingAlert
 		s.mu.Lock()
 		defer s.mu.Unlock()
 
-		if creationTime, ok := s.CreationTimes[loginUser]; ok {
+		if creationTime, ok := s.CreationTimes.Get(loginUser); ok {
 			if event.Timestamp.Sub(creationTime) <= r.Window {
-				delete(s.CreationTimes, loginUser)
+				s.CreationTimes.Delete(loginUser)
 				return &model.Alert{
 					RuleName:  r.Name(),
 					Message:   fmt.Sprintf("Attack Chain Detected: A new local account for user '%s' was created and used to log in shortly after.", loginUser),
@@ -300,6 +370,7 @@ func (r *LocalAccountImmediateUseRule) Evaluate(event model.Event, existingAlert
 						"username":      loginUser,
 						"time_to_login": event.Timestamp.Sub(creationTime).String(),
 					},
+					Events: append(state.Chains.take(r.Name(), loginUser), event),
 				}
 			}
 		}
@@ -0,0 +1,185 @@
+package rules
+
+import (
+	"maps"
+	"nox/internal/attack"
+	"slices"
+	"strings"
+)
+
+const (
+	RuleKindYAML        = "yaml"
+	RuleKindStateful    = "stateful"
+	RuleKindCorrelation = "correlation"
+)
+
+// RuleTechniques is the ATT&CK mapping of one loaded rule.
+type RuleTechniques struct {
+	Rule       string
+	Kind       string
+	Techniques []string
+}
+
+// RuleTechniques lists every active rule with the techniques it detects.
+// Shadow rules don't raise alerts, so they don't count as coverage yet.
+func (e *Engine) RuleTechniques() []RuleTechniques {
+	var out []RuleTechniques
+	for _, meta := range e.Rules() {
+		if meta.Mode == ModeActive {
+			out = append(out, RuleTechniques{Rule: meta.Name, Kind: meta.Kind, Techniques: meta.Techniques})
+		}
+	}
+	return out
+}
+
+// Coverage is a tactic by technique matrix of what the loaded rules detect.
+type Coverage struct {
+	Tactics []TacticCoverage
+	// Unmapped lists rules without a technique.
+	Unmapped []string
+	// Unknown lists technique IDs that aren't in the bundled ATT&CK list.
+	Unknown []string
+}
+
+type TacticCoverage struct {
+	Tactic attack.Tactic
+	// Total is the number of bundled techniques in the tactic, not counting
+	// sub-techniques, so Covered/Total is the share of the tactic covered.
+	Total      int
+	Covered    int
+	Techniques []TechniqueCoverage
+}
+
+type TechniqueCoverage struct {
+	Technique attack.Technique
+	Rules     []string
+	Alerts    int64
+}
+
+// BuildCoverage maps rules onto the ATT&CK matrix. alerts holds alert
+// counts by rule name; each alert counts towards every technique its rule
+// maps to. A technique counts as covered when it or one of its
+// sub-techniques has a rule.
+func BuildCoverage(rules []RuleTechniques, alerts map[string]int64) Coverage {
+	var cov Coverage
+
+	byTechnique := make(map[string][]string)
+	unknown := make(map[string]bool)
+	for _, rule := range rules {
+		if len(rule.Techniques) == 0 {
+			cov.Unmapped = append(cov.Unmapped, rule.Rule)
+			continue
+		}
+		for _, id := range rule.Techniques {
+			if _, ok := attack.Lookup(id); !ok {
+				unknown[id] = true
+				continue
+			}
+			if !slices.Contains(byTechnique[id], rule.Rule) {
+				byTechnique[id] = append(byTechnique[id], rule.Rule)
+			}
+		}
+	}
+	cov.Unknown = slices.Sorted(maps.Keys(unknown))
+
+	for _, tactic := range attack.Tactics {
+		tc := TacticCoverage{Tactic: tactic}
+		covered := make(map[string]bool)
+
+		for _, technique := range attack.Techniques() {
+			if !slices.Contains(technique.Tactics, tactic.ShortName) {
+				continue
+			}
+			if !technique.IsSubTechnique() {
+				tc.Total++
+			}
+
+			rules := byTechnique[technique.ID]
+			if len(rules) == 0 {
+				continue
+			}
+			var count int64
+			for _, rule := range rules {
+				count += alerts[rule]
+			}
+			tc.Techniques = append(tc.Techniques, TechniqueCoverage{Technique: technique, Rules: rules, Alerts: count})
+			covered[technique.Parent()] = true
+		}
+
+		tc.Covered = len(covered)
+		cov.Tactics = append(cov.Tactics, tc)
+	}
+
+	return cov
+}
+
+// NavigatorLayer is an ATT&CK Navigator layer file. Only the fields nox
+// sets are modelled.
+type NavigatorLayer struct {
+	Name        string               `json:"name"`
+	Versions    NavigatorVersions    `json:"versions"`
+	Domain      string               `json:"domain"`
+	Description string               `json:"description"`
+	Techniques  []NavigatorTechnique `json:"techniques"`
+	Gradient    NavigatorGradient    `json:"gradient"`
+	Legend      []NavigatorLegend    `json:"legendItems"`
+}
+
+type NavigatorVersions struct {
+	Attack    string `json:"attack"`
+	Navigator string `json:"navigator"`
+	Layer     string `json:"layer"`
+}
+
+type NavigatorTechnique struct {
+	TechniqueID string `json:"techniqueID"`
+	Tactic      string `json:"tactic,omitempty"`
+	Score       int64  `json:"score"`
+	Color       string `json:"color,omitempty"`
+	Comment     string `json:"comment,omitempty"`
+	Enabled     bool   `json:"enabled"`
+}
+
+type NavigatorGradient struct {
+	Colors   []string `json:"colors"`
+	MinValue int64    `json:"minValue"`
+	MaxValue int64    `json:"maxValue"`
+}
+
+type NavigatorLegend struct {
+	Label string `json:"label"`
+	Color string `json:"color"`
+}
+
+// NavigatorLayer exports the coverage as a layer: covered techniques are
+// scored by alert count, so gaps stay blank and hot spots stand out.
+func (c Coverage) NavigatorLayer(name string) NavigatorLayer {
+	layer := NavigatorLayer{
+		Name:        name,
+		Versions:    NavigatorVersions{Attack: attack.Version, Navigator: "4.9.1", Layer: "4.5"},
+		Domain:      "enterprise-attack",
+		Description: "Techniques covered by nox detection rules, scored by alert count.",
+		Gradient:    NavigatorGradient{Colors: []string{"#c6e5ff", "#ff6666"}},
+		Legend: []NavigatorLegend{
+			{Label: "Covered, no alerts", Color: "#c6e5ff"},
+			{Label: "Most alerts", Color: "#ff6666"},
+		},
+	}
+
+	for _, tactic := range c.Tactics {
+		for _, tc := range tactic.Techniques {
+			layer.Techniques = append(layer.Techniques, NavigatorTechnique{
+				TechniqueID: tc.Technique.ID,
+				Tactic:      tactic.Tactic.ShortName,
+				Score:       tc.Alerts,
+				Comment:     "Rules: " + strings.Join(tc.Rules, ", "),
+				Enabled:     true,
+			})
+			layer.Gradient.MaxValue = max(layer.Gradient.MaxValue, tc.Alerts)
+		}
+	}
+	// the navigator needs a non-empty range to draw the gradient.
+	layer.Gradient.MaxValue = max(layer.Gradient.MaxValue, 1)
+
+	return layer
+}
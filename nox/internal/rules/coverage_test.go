@@ -0,0 +1,90 @@
+package rules
+
+import (
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+)
+
+func TestBuildCoverage(t *testing.T) {
+	mapped := []RuleTechniques{
+		{Rule: "Nmap", Kind: RuleKindYAML, Techniques: []string{"T1046"}},
+		{Rule: "Spray", Kind: RuleKindStateful, Techniques: []string{"T1110.003"}},
+		{Rule: "Guess", Kind: RuleKindStateful, Techniques: []string{"T1110.001"}},
+		{Rule: "Chain", Kind: RuleKindCorrelation, Techniques: []string{"T1110", "T1046"}},
+		{Rule: "Anomaly", Kind: RuleKindStateful},
+		{Rule: "Typo", Kind: RuleKindYAML, Techniques: []string{"T9999"}},
+	}
+	alerts := map[string]int64{"Nmap": 3, "Chain": 2, "Spray": 1}
+
+	coverage := BuildCoverage(mapped, alerts)
+
+	if !slices.Equal(coverage.Unmapped, []string{"Anomaly"}) {
+		t.Fatalf("got unmapped %v, want [Anomaly]", coverage.Unmapped)
+	}
+	if !slices.Equal(coverage.Unknown, []string{"T9999"}) {
+		t.Fatalf("got unknown %v, want [T9999]", coverage.Unknown)
+	}
+
+	tactics := make(map[string]TacticCoverage)
+	for _, tactic := range coverage.Tactics {
+		tactics[tactic.Tactic.ShortName] = tactic
+	}
+	if len(tactics) != 14 {
+		t.Fatalf("got %d tactics, want all 14", len(tactics))
+	}
+
+	// T1110 and its two sub-techniques count as one covered technique.
+	credentialAccess := tactics["credential-access"]
+	if credentialAccess.Covered != 1 || len(credentialAccess.Techniques) != 3 {
+		t.Fatalf("got covered=%d techniques=%d, want 1 and 3", credentialAccess.Covered, len(credentialAccess.Techniques))
+	}
+	if credentialAccess.Total <= credentialAccess.Covered {
+		t.Fatalf("got total %d, want more than covered", credentialAccess.Total)
+	}
+
+	discovery := tactics["discovery"]
+	if len(discovery.Techniques) != 1 {
+		t.Fatalf("got %d discovery techniques, want 1", len(discovery.Techniques))
+	}
+	nmap := discovery.Techniques[0]
+	if nmap.Alerts != 5 || !slices.Equal(nmap.Rules, []string{"Nmap", "Chain"}) {
+		t.Fatalf("got %+v, want 5 alerts from Nmap and Chain", nmap)
+	}
+
+	if got := tactics["impact"]; got.Covered != 0 || len(got.Techniques) != 0 {
+		t.Fatalf("got impact coverage %+v, want a gap", got)
+	}
+}
+
+func TestNavigatorLayer(t *testing.T) {
+	coverage := BuildCoverage([]RuleTechniques{
+		{Rule: "Nmap", Techniques: []string{"T1046"}},
+		{Rule: "Guess", Techniques: []string{"T1110.001"}},
+	}, map[string]int64{"Nmap": 7})
+
+	layer := coverage.NavigatorLayer("test")
+	if layer.Domain != "enterprise-attack" || len(layer.Techniques) != 2 {
+		t.Fatalf("got domain %q with %d techniques, want enterprise-attack with 2", layer.Domain, len(layer.Techniques))
+	}
+	if layer.Gradient.MaxValue != 7 {
+		t.Fatalf("got gradient max %d, want 7", layer.Gradient.MaxValue)
+	}
+}
+
+func TestEngineRuleTechniquesCoversBuiltins(t *testing.T) {
+	engine := NewEngine(slog.New(slog.NewTextHandler(io.Discard, nil)), NewStateManager(), []RuleDefinition{
+		{Name: "Nmap", TechniqueID: "T1046"},
+	})
+
+	for _, rule := range engine.RuleTechniques() {
+		// neither rule looks for a technique of its own
+		if rule.Rule == "Anomaly" || rule.Rule == "EntityRiskThreshold" {
+			continue
+		}
+		if len(rule.Techniques) == 0 {
+			t.Fatalf("got no techniques for %s, want a mapping", rule.Rule)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package rules
 
 import (
-	"bufio"
 	"fmt"
 	"log/slog"
 	"nox/internal/model"
@@ -13,17 +12,34 @@ import (
 )
 
 type ProcessExecution struct {
-	Timestamp   time.Time
-	ProcessName string
-	Command     string
-	PID         string
-	PPID        string
-	UID         string
+	Timestamp   time.Time `json:"timestamp"`
+	ProcessName string    `json:"process_name"`
+	Command     string    `json:"command"`
+	PID         string    `json:"pid"`
+	PPID        string    `json:"ppid"`
+	UID         string    `json:"uid"`
 }
 
 type PostBruteForceInfo struct {
-	LoginTime time.Time
-	SourceIP  string
+	LoginTime time.Time `json:"login_time"`
+	SourceIP  string    `json:"source_ip"`
+}
+
+// LoginFix is where and when a user logged in.
+type LoginFix struct {
+	Time      time.Time `json:"time"`
+	SourceIP  string    `json:"source_ip"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Country   string    `json:"country,omitempty"`
+	City      string    `json:"city,omitempty"`
+}
+
+// TravelProfile is a user's login history as far as impossible travel needs
+// it: when nox first saw them log in, and their last located login.
+type TravelProfile struct {
+	FirstSeen time.Time `json:"first_seen"`
+	Last      LoginFix  `json:"last"`
 }
 
 type Condition struct {
@@ -33,12 +49,24 @@ type Condition struct {
 }
 
 type RuleDefinition struct {
+	ID          string      `yaml:"id"`
 	Name        string      `yaml:"name"`
+	Version     int         `yaml:"version"`
+	Author      string      `yaml:"author"`
 	Description string      `yaml:"description"`
 	TechniqueID string      `yaml:"technique_id"`
 	Severity    string      `yaml:"severity"`
 	EventType   string      `yaml:"event_type"`
 	Conditions  []Condition `yaml:"conditions"`
+	Tags        []string    `yaml:"tags"`
+	References  []string    `yaml:"references"`
+	// FalsePositives notes known benign causes, for whoever triages alerts.
+	FalsePositives []string `yaml:"false_positives"`
+	Enabled        *bool    `yaml:"enabled"`  // unset means enabled
+	Mode           string   `yaml:"mode"`     // active, shadow or disabled; overrides enabled
+	Replaces       string   `yaml:"replaces"` // the rule a shadow rule would take over from
+	// RiskWeight scales the risk the rule's alerts add; unset means 1.
+	RiskWeight *float64 `yaml:"risk_weight"`
 }
 
 func LoadRulesFromFile(path string) ([]RuleDefinition, error) {
@@ -59,41 +87,19 @@ func LoadRulesFromFile(path string) ([]RuleDefinition, error) {
 	return rules, nil
 }
 
-func LoadIPWatchlistFromFile(path string) (map[string]bool, error) {
-	slog.Info("Loading IP watchlist from file...", "path", path)
-
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read IP watchlist file: %w", err)
-	}
-	defer file.Close()
-
-	ipWatchlist := make(map[string]bool)
-	scanner := bufio.NewScanner(file)
-
-	for scanner.Scan() {
-		ip := strings.TrimSpace(scanner.Text())
-		if ip != "" && !strings.HasPrefix(ip, "#") {
-			ipWatchlist[ip] = true
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, err
-	}
-
-	slog.Info("Successfully loaded IP watchlist", "count", len(ipWatchlist))
-	return ipWatchlist, nil
+func EvaluateYAMLRule(event model.Event, rule RuleDefinition) bool {
+	return matchConditions(event.Metadata, rule.Conditions)
 }
 
-func EvaluateYAMLRule(event model.Event, rule RuleDefinition) bool {
-	for _, cond := range rule.Conditions {
+// matchConditions reports whether metadata satisfies every condition.
+func matchConditions(metadata map[string]string, conditions []Condition) bool {
+	for _, cond := range conditions {
 		fieldParts := strings.Split(cond.Field, ".")
 		if len(fieldParts) != 2 || fieldParts[0] != "metadata" {
 			continue
 		}
 
-		eventValue, ok := event.Metadata[fieldParts[1]]
+		eventValue, ok := metadata[fieldParts[1]]
 		if !ok {
 			return false
 		}
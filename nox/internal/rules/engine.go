@@ -2,54 +2,331 @@ package rules
 
 import (
 	"log/slog"
+	"maps"
+	"nox/internal/enrich"
 	"nox/internal/model"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 )
 
 type Rule interface {
 	Name() string
+	Metadata() RuleMetadata
 	Evaluate(event model.Event, state *StateManager) *model.Alert
 }
 
 // A CorrelationRule looks for chains of events and alerts over time.
 type CorrelationRule interface {
 	Name() string
+	Metadata() RuleMetadata
 	Evaluate(event model.Event, existingAlerts []model.Alert, state *StateManager) *model.Alert
 }
 
 type Engine struct {
-	logger           *slog.Logger
-	state            *StateManager
-	statelessRules   []RuleDefinition
+	logger *slog.Logger
+	state  *StateManager
+
+	// mu lets a RuleManager swap rules and overrides while events are
+	// being evaluated.
+	mu             sync.RWMutex
+	statelessRules []RuleDefinition
+	// byEventType indexes statelessRules, so an event is only checked
+	// against the rules for its type.
+	byEventType      map[string][]RuleDefinition
 	statefulRules    []Rule
 	correlationRules []CorrelationRule
+	overrides        map[string]RuleOverride // by rule ID
+	exceptions       []Exception
+	// metadata holds every rule's metadata with overrides applied, keyed by
+	// rule name since that is what alerts carry.
+	metadata map[string]RuleMetadata
+	stats    map[string]*ruleStats // by rule name
+	slowRule time.Duration
+	risk     RiskConfig
+	// processes supplies the ancestry of alerting processes, if set.
+	processes ProcessHistory
+}
+
+// ProcessHistory remembers recently executed processes.
+type ProcessHistory interface {
+	// Ancestry returns the process of a Process_Executed event followed by
+	// its parents.
+	Ancestry(event model.Event) []model.Process
+}
+
+// EngineOption tunes a built-in rule.
+type EngineOption func(*engineOptions)
+
+type engineOptions struct {
+	impossibleTravel ImpossibleTravelConfig
+	overrides        []RuleOverride
+	exceptions       []Exception
+	slowRule         time.Duration
+	risk             RiskConfig
+	processes        ProcessHistory
+}
+
+// WithProcessHistory adds the ancestry of the triggering process to
+// alerts, from the process history kept by the enrichers.
+func WithProcessHistory(history ProcessHistory) EngineOption {
+	return func(o *engineOptions) {
+		o.processes = history
+	}
+}
+
+// WithRisk tunes entity risk scoring.
+func WithRisk(cfg RiskConfig) EngineOption {
+	return func(o *engineOptions) {
+		o.risk = cfg
+	}
+}
+
+// WithSlowRuleThreshold logs a warning when a rule takes longer than d to
+// evaluate one event. Zero turns the warning off.
+func WithSlowRuleThreshold(d time.Duration) EngineOption {
+	return func(o *engineOptions) {
+		o.slowRule = d
+	}
+}
+
+// WithExceptions suppresses the alerts that match an exception.
+func WithExceptions(exceptions []Exception) EngineOption {
+	return func(o *engineOptions) {
+		o.exceptions = exceptions
+	}
+}
+
+// WithRuleOverrides enables, disables or re-grades rules by ID.
+func WithRuleOverrides(overrides []RuleOverride) EngineOption {
+	return func(o *engineOptions) {
+		o.overrides = overrides
+	}
+}
+
+func WithImpossibleTravel(cfg ImpossibleTravelConfig) EngineOption {
+	return func(o *engineOptions) {
+		o.impossibleTravel = cfg
+	}
+}
+
+func NewEngine(logger *slog.Logger, state *StateManager, yamlRules []RuleDefinition, opts ...EngineOption) *Engine {
+	options := engineOptions{
+		impossibleTravel: DefaultImpossibleTravelConfig(),
+		risk:             DefaultRiskConfig(),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	e := &Engine{
+		logger:           logger,
+		state:            state,
+		statelessRules:   yamlRules,
+		overrides:        make(map[string]RuleOverride),
+		exceptions:       options.exceptions,
+		stats:            make(map[string]*ruleStats),
+		slowRule:         options.slowRule,
+		risk:             options.risk,
+		processes:        options.processes,
+		statefulRules:    builtinRules(options),
+		correlationRules: builtinCorrelationRules(options),
+	}
+	for _, o := range options.overrides {
+		e.overrides[o.ID] = o
+	}
+	e.indexRules()
+	e.buildMetadata()
+	return e
+}
+
+func builtinRules(options engineOptions) []Rule {
+	return []Rule{
+		NewFailedLoginsRule(),
+		NewLoginLocationRule(),
+		NewImpossibleTravelRule(options.impossibleTravel),
+		NewRapidProcessExecutionRuile(),
+		NewThreatIntelRule(),
+		NewPasswordSprayRule(),
+		NewAnomalyRule(),
+	}
+}
+
+func builtinCorrelationRules(options engineOptions) []CorrelationRule {
+	return []CorrelationRule{
+		NewBruteForceAndEvasionRule(),
+		NewDownloadAndExecuteRule(),
+		NewLocalAccountImmediateUseRule(),
+		NewLoginAndEscalationRule(),
+		// last, so it scores the alerts of the other correlation rules too
+		NewEntityRiskRule(options.risk),
+	}
+}
+
+// BuiltinRules describes the rules built into nox, before overrides.
+func BuiltinRules() []RuleMetadata {
+	options := engineOptions{impossibleTravel: DefaultImpossibleTravelConfig(), risk: DefaultRiskConfig()}
+	var out []RuleMetadata
+	for _, rule := range builtinRules(options) {
+		out = append(out, rule.Metadata())
+	}
+	for _, rule := range builtinCorrelationRules(options) {
+		out = append(out, rule.Metadata())
+	}
+	return out
+}
+
+func (e *Engine) buildMetadata() {
+	e.metadata = make(map[string]RuleMetadata)
+	ids := make(map[string]bool)
+	for _, meta := range e.rules() {
+		ids[meta.ID] = true
+		if _, dup := e.metadata[meta.Name]; dup {
+			e.logger.Warn("Duplicate detection rule name, alerts can't be told apart", "rule", meta.Name)
+			continue
+		}
+		e.metadata[meta.Name] = meta
+		if s, ok := e.stats[meta.Name]; !ok || s.id != meta.ID {
+			e.stats[meta.Name] = newRuleStats(meta.ID)
+		}
+	}
+	for id := range e.overrides {
+		if !ids[id] {
+			e.logger.Warn("Rule override matches no rule", "rule_id", id)
+		}
+	}
+	for _, x := range e.exceptions {
+		for _, id := range x.Rules {
+			if !ids[id] {
+				e.logger.Warn("Exception names an unknown rule", "exception", x.ID, "rule_id", id)
+			}
+		}
+	}
+}
+
+// Rules lists every loaded rule, enabled or not, with overrides applied.
+func (e *Engine) Rules() []RuleMetadata {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.rules()
+}
+
+// Rule looks a loaded rule up by ID.
+func (e *Engine) Rule(id string) (RuleMetadata, bool) {
+	for _, meta := range e.Rules() {
+		if meta.ID == id {
+			return meta, true
+		}
+	}
+	return RuleMetadata{}, false
+}
+
+// YAMLRules returns a copy of the loaded YAML rules.
+func (e *Engine) YAMLRules() []RuleDefinition {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return slices.Clone(e.statelessRules)
+}
+
+// RuleOverrides returns the active overrides, ordered by rule ID.
+func (e *Engine) RuleOverrides() []RuleOverride {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return slices.SortedFunc(maps.Values(e.overrides), func(a, b RuleOverride) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+}
+
+func (e *Engine) setYAMLRules(defs []RuleDefinition) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.statelessRules = defs
+	e.indexRules()
+	e.buildMetadata()
+}
+
+func (e *Engine) setOverrides(overrides []RuleOverride) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.overrides = make(map[string]RuleOverride)
+	for _, o := range overrides {
+		e.overrides[o.ID] = o
+	}
+	e.buildMetadata()
+}
+
+func (e *Engine) indexRules() {
+	e.byEventType = make(map[string][]RuleDefinition)
+	for _, rule := range e.statelessRules {
+		e.byEventType[rule.EventType] = append(e.byEventType[rule.EventType], rule)
+	}
 }
 
-func NewEngine(logger *slog.Logger, state *StateManager, yamlRules []RuleDefinition) *Engine {
-	return &Engine{
-		logger:         logger,
-		state:          state,
-		statelessRules: yamlRules,
-		statefulRules: []Rule{
-			NewFailedLoginsRule(),
-			NewLoginLocationRule(),
-			NewRapidProcessExecutionRuile(),
-			NewIPWatchlistRule(),
-			NewPasswordSprayRule(),
-		},
-		correlationRules: []CorrelationRule{
-			NewBruteForceAndEvasionRule(),
-			NewDownloadAndExecuteRule(),
-			NewLocalAccountImmediateUseRule(),
-			NewLoginAndEscalationRule(),
-		},
+func (e *Engine) rules() []RuleMetadata {
+	var all []RuleMetadata
+	for _, rule := range e.statelessRules {
+		all = append(all, rule.Metadata())
+	}
+	for _, rule := range e.statefulRules {
+		all = append(all, rule.Metadata())
+	}
+	for _, rule := range e.correlationRules {
+		all = append(all, rule.Metadata())
+	}
+
+	for i, meta := range all {
+		if o, ok := e.overrides[meta.ID]; ok {
+			all[i] = o.apply(meta)
+		}
 	}
+	return all
+}
+
+func (e *Engine) enabled(name string) bool {
+	meta, ok := e.metadata[name]
+	return !ok || meta.Enabled
+}
+
+func (e *Engine) shadow(name string) bool {
+	return e.metadata[name].Mode == ModeShadow
+}
+
+// describe stamps an alert with the rule that raised it, applies any
+// severity override and scores its risk.
+func (e *Engine) describe(alert *model.Alert) {
+	meta, ok := e.metadata[alert.RuleName]
+	if !ok {
+		return
+	}
+	alert.RuleID = meta.ID
+	alert.RuleVersion = meta.Version
+	if o, ok := e.overrides[meta.ID]; ok && o.Severity != "" {
+		alert.Severity = o.Severity
+	}
+	alert.RiskScore = RiskScore(alert.Severity, meta.RiskWeight)
 }
 
 func (e *Engine) EvaluateEvent(event model.Event) []model.Alert {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
 	var triggeredAlerts []model.Alert
+	e.state.Observe(event.Timestamp)
 
-	for _, rule := range e.statelessRules {
-		if event.EventType == rule.EventType && EvaluateYAMLRule(event, rule) {
+	for _, rule := range e.byEventType[event.EventType] {
+		if !e.enabled(rule.Name) {
+			continue
+		}
+		var matched bool
+		e.observe(rule.Name, func() bool {
+			matched = EvaluateYAMLRule(event, rule)
+			return matched
+		})
+		if matched {
 			alert := model.Alert{
 				RuleName:  rule.Name,
 				Message:   rule.Description,
@@ -75,16 +352,88 @@ func (e *Engine) EvaluateEvent(event model.Event) []model.Alert {
 	}
 
 	for _, rule := range e.statefulRules {
-		if alert := rule.Evaluate(event, e.state); alert != nil {
+		if !e.enabled(rule.Name()) {
+			continue
+		}
+		var alert *model.Alert
+		e.observe(rule.Name(), func() bool {
+			alert = rule.Evaluate(event, e.state)
+			return alert != nil
+		})
+		if alert != nil {
 			triggeredAlerts = append(triggeredAlerts, *alert)
 		}
 	}
 
+	triggeredAlerts, held := e.finish(triggeredAlerts, event)
+
 	for _, rule := range e.correlationRules {
-		if alert := rule.Evaluate(event, triggeredAlerts, e.state); alert != nil {
-			triggeredAlerts = append(triggeredAlerts, *alert)
+		if !e.enabled(rule.Name()) {
+			continue
+		}
+		var alert *model.Alert
+		e.observe(rule.Name(), func() bool {
+			alert = rule.Evaluate(event, triggeredAlerts, e.state)
+			return alert != nil
+		})
+		if alert != nil {
+			raised, kept := e.finish([]model.Alert{*alert}, event)
+			triggeredAlerts = append(triggeredAlerts, raised...)
+			held = append(held, kept...)
 		}
 	}
 
-	return triggeredAlerts
+	return append(triggeredAlerts, held...)
+}
+
+// finish describes and enriches new alerts, attaches the events and
+// process ancestry behind them, and holds back the ones an exception
+// suppresses or a shadow rule raised, so correlation rules don't build on
+// them.
+func (e *Engine) finish(alerts []model.Alert, event model.Event) (raised, held []model.Alert) {
+	for _, alert := range alerts {
+		e.describe(&alert)
+		addEnrichment(&alert, event)
+		// correlation rules attach the whole chain themselves
+		if len(alert.Events) == 0 {
+			alert.Events = []model.Event{event}
+		}
+		if e.processes != nil && alert.Ancestry == nil {
+			alert.Ancestry = e.processes.Ancestry(event)
+		}
+
+		if i := slices.IndexFunc(e.exceptions, func(x Exception) bool { return x.matches(alert, event) }); i >= 0 {
+			alert.ExceptionID = e.exceptions[i].ID
+		}
+		alert.Shadow = e.shadow(alert.RuleName)
+
+		if alert.IsSuppressed() || alert.Shadow {
+			held = append(held, alert)
+			continue
+		}
+		raised = append(raised, alert)
+	}
+	return raised, held
+}
+
+// alertFields are the event fields addEnrichment copies onto alerts.
+var alertFields = append([]string{"host"}, enrich.Fields...)
+
+// addEnrichment copies the host and the enrichment fields of the triggering
+// event onto the alert, so responders see where it happened, the asset
+// owner, user department and so on without looking the event up. Fields a
+// rule set itself are kept.
+func addEnrichment(alert *model.Alert, event model.Event) {
+	for _, field := range alertFields {
+		value, ok := event.Metadata[field]
+		if !ok {
+			continue
+		}
+		if alert.Metadata == nil {
+			alert.Metadata = make(map[string]string)
+		}
+		if _, set := alert.Metadata[field]; !set {
+			alert.Metadata[field] = value
+		}
+	}
 }
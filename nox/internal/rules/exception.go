@@ -0,0 +1,159 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/netip"
+	"nox/internal/model"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// An Exception suppresses the alerts of known-benign activity, like curl
+// run by apt. Every criterion that is set must match: all conditions, one
+// of the hosts and one of the CIDRs. Conditions are checked against the
+// triggering event's metadata, then the alert's.
+type Exception struct {
+	ID string `yaml:"id"`
+	// Rules are rule IDs; an exception without rules applies to all of them.
+	Rules      []string    `yaml:"rules"`
+	Reason     string      `yaml:"reason"`
+	Author     string      `yaml:"author"`
+	Expires    time.Time   `yaml:"expires"`
+	Conditions []Condition `yaml:"conditions"`
+	// Hosts match the event's host metadata or its source.
+	Hosts []string `yaml:"hosts"`
+	// CIDRs match the alert's or event's source address; a bare IP is a
+	// single address.
+	CIDRs []string `yaml:"cidrs"`
+
+	prefixes []netip.Prefix
+}
+
+// LoadExceptions reads and validates exceptions. A missing file means none.
+func LoadExceptions(path string) ([]Exception, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exceptions: %w", err)
+	}
+
+	var exceptions []Exception
+	if err := yaml.Unmarshal(data, &exceptions); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal exceptions: %w", err)
+	}
+
+	ids := make(map[string]bool)
+	for i := range exceptions {
+		x := &exceptions[i]
+		if err := x.compile(); err != nil {
+			return nil, fmt.Errorf("invalid exception %d in %s: %w", i+1, path, err)
+		}
+		if ids[x.ID] {
+			return nil, fmt.Errorf("duplicate exception id %q in %s", x.ID, path)
+		}
+		ids[x.ID] = true
+	}
+
+	slog.Info("Loaded detection exceptions", "path", path, "count", len(exceptions))
+	return exceptions, nil
+}
+
+// compile validates the exception and parses its CIDRs.
+func (x *Exception) compile() error {
+	switch {
+	case !ValidRuleID(x.ID):
+		return fmt.Errorf("id %q must be lowercase words separated by dashes", x.ID)
+	case strings.TrimSpace(x.Reason) == "":
+		return fmt.Errorf("%s: reason is required", x.ID)
+	case x.Expires.IsZero():
+		return fmt.Errorf("%s: expires is required", x.ID)
+	case len(x.Conditions) == 0 && len(x.Hosts) == 0 && len(x.CIDRs) == 0:
+		// without criteria an exception silently disables its rules.
+		return fmt.Errorf("%s: needs conditions, hosts or cidrs", x.ID)
+	}
+
+	for _, cond := range x.Conditions {
+		if !strings.HasPrefix(cond.Field, "metadata.") || strings.Count(cond.Field, ".") != 1 {
+			return fmt.Errorf("%s: field %q must look like metadata.<key>", x.ID, cond.Field)
+		}
+		if !slices.Contains(Operators, cond.Operator) {
+			return fmt.Errorf("%s: unknown operator %q", x.ID, cond.Operator)
+		}
+	}
+
+	x.prefixes = nil
+	for _, cidr := range x.CIDRs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(cidr)
+			if addrErr != nil {
+				return fmt.Errorf("%s: invalid cidr %q", x.ID, cidr)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		x.prefixes = append(x.prefixes, prefix.Masked())
+	}
+	return nil
+}
+
+// Expired reports whether the exception no longer applies at t.
+func (x Exception) Expired(t time.Time) bool {
+	return !t.Before(x.Expires)
+}
+
+// matches reports whether the exception suppresses alert, raised for event.
+// Expiry is judged by event time, like everything else in the engine.
+func (x Exception) matches(alert model.Alert, event model.Event) bool {
+	if x.Expired(event.Timestamp) {
+		return false
+	}
+	if len(x.Rules) > 0 && !slices.Contains(x.Rules, alert.RuleID) {
+		return false
+	}
+
+	if len(x.Conditions) > 0 {
+		metadata := make(map[string]string, len(alert.Metadata)+len(event.Metadata))
+		for k, v := range alert.Metadata {
+			metadata[k] = v
+		}
+		for k, v := range event.Metadata {
+			metadata[k] = v
+		}
+		if !matchConditions(metadata, x.Conditions) {
+			return false
+		}
+	}
+
+	if len(x.Hosts) > 0 && !slices.Contains(x.Hosts, event.Metadata["host"]) && !slices.Contains(x.Hosts, event.Source) {
+		return false
+	}
+
+	if len(x.prefixes) > 0 && !x.containsAny(alert.Source, event.Source) {
+		return false
+	}
+	return true
+}
+
+func (x Exception) containsAny(sources ...string) bool {
+	for _, source := range sources {
+		addr, err := netip.ParseAddr(source)
+		if err != nil {
+			continue
+		}
+		for _, prefix := range x.prefixes {
+			if prefix.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+	}
+	return false
+}
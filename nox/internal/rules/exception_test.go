@@ -0,0 +1,182 @@
+package rules
+
+import (
+	"nox/internal/model"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadExceptionsValidates(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{
+			name: "valid",
+			yaml: "- id: apt\n  reason: package installs\n  expires: 2027-01-01T00:00:00Z\n  cidrs: [10.0.0.0/8, 192.168.1.5]\n",
+		},
+		{
+			name: "no reason",
+			yaml: "- id: apt\n  expires: 2027-01-01T00:00:00Z\n  hosts: [build-1]\n",
+			want: "reason is required",
+		},
+		{
+			name: "no expiry",
+			yaml: "- id: apt\n  reason: package installs\n  hosts: [build-1]\n",
+			want: "expires is required",
+		},
+		{
+			name: "no criteria",
+			yaml: "- id: apt\n  reason: package installs\n  expires: 2027-01-01T00:00:00Z\n  rules: [curl-download]\n",
+			want: "needs conditions, hosts or cidrs",
+		},
+		{
+			name: "bad cidr",
+			yaml: "- id: apt\n  reason: package installs\n  expires: 2027-01-01T00:00:00Z\n  cidrs: [10.0.0.0/33]\n",
+			want: "invalid cidr",
+		},
+		{
+			name: "bad operator",
+			yaml: "- id: apt\n  reason: r\n  expires: 2027-01-01T00:00:00Z\n  conditions:\n    - {field: metadata.process_name, operator: like, value: curl}\n",
+			want: "unknown operator",
+		},
+		{
+			name: "duplicate id",
+			yaml: "- id: apt\n  reason: r\n  expires: 2027-01-01T00:00:00Z\n  hosts: [a]\n- id: apt\n  reason: r\n  expires: 2027-01-01T00:00:00Z\n  hosts: [b]\n",
+			want: "duplicate exception id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "exceptions.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := LoadExceptions(path)
+			if tt.want == "" && err != nil {
+				t.Fatalf("got %v, want nil", err)
+			}
+			if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+				t.Fatalf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+
+	if exceptions, err := LoadExceptions(filepath.Join(t.TempDir(), "missing.yaml")); err != nil || exceptions != nil {
+		t.Fatalf("got %v, %v for a missing file, want no exceptions", exceptions, err)
+	}
+}
+
+func TestExampleExceptionSuppressesBuildBursts(t *testing.T) {
+	exceptions, err := LoadExceptions("../../detections/exceptions.example.yaml")
+	if err != nil {
+		t.Fatalf("LoadExceptions: %v", err)
+	}
+	start := time.Date(2026, time.January, 15, 12, 0, 0, 0, time.UTC)
+
+	for _, tt := range []struct{ host, want string }{{"build-1", "build-farm-bursts"}, {"web-1", ""}} {
+		t.Run(tt.host, func(t *testing.T) {
+			engine := NewEngine(nil, NewStateManager(), nil, WithExceptions(exceptions))
+
+			var burst *model.Alert
+			for i := range 10 {
+				// as the pipeline stamps it on execsnoop events
+				event := processEvent("cc1")
+				event.Timestamp = start.Add(time.Duration(i) * time.Second)
+				event.Source = "127.0.0.1"
+				event.Metadata["host"] = tt.host
+				for _, alert := range engine.EvaluateEvent(event) {
+					if alert.RuleID == "rapid-process-execution" {
+						burst = &alert
+					}
+				}
+			}
+
+			if burst == nil {
+				t.Fatalf("got no rapid-process-execution alert, want one")
+			}
+			if burst.ExceptionID != tt.want {
+				t.Fatalf("got exception %q, want %q", burst.ExceptionID, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngineSuppressesMatchingAlerts(t *testing.T) {
+	curl := RuleDefinition{
+		ID:        "curl-download",
+		Name:      "File Download with Curl",
+		Severity:  "LOW",
+		EventType: "Process_Executed",
+		Conditions: []Condition{
+			{Field: "metadata.process_name", Operator: "equals", Value: "curl"},
+		},
+	}
+	expires := time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC)
+	exceptions := []Exception{
+		{
+			ID:      "apt-fetches",
+			Rules:   []string{"curl-download"},
+			Reason:  "apt downloads packages with curl",
+			Expires: expires,
+			Conditions: []Condition{
+				{Field: "metadata.parent_process_name", Operator: "equals", Value: "apt"},
+			},
+		},
+		{
+			ID:      "build-farm",
+			Reason:  "CI runners",
+			Expires: expires,
+			Hosts:   []string{"build-1"},
+			CIDRs:   []string{"10.20.0.0/16"},
+		},
+	}
+	for i := range exceptions {
+		if err := exceptions[i].compile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		at     time.Time
+		source string
+		meta   map[string]string
+		want   string
+	}{
+		{name: "parent matches", meta: map[string]string{"parent_process_name": "apt"}, want: "apt-fetches"},
+		{name: "other parent", meta: map[string]string{"parent_process_name": "bash"}},
+		{name: "host and cidr", source: "10.20.3.4", meta: map[string]string{"host": "build-1"}, want: "build-farm"},
+		{name: "host outside cidr", source: "10.30.3.4", meta: map[string]string{"host": "build-1"}},
+		{name: "expired", at: expires, meta: map[string]string{"parent_process_name": "apt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := NewEngine(nil, NewStateManager(), []RuleDefinition{curl}, WithExceptions(exceptions))
+
+			event := processEvent("curl")
+			if !tt.at.IsZero() {
+				event.Timestamp = tt.at
+			}
+			event.Source = tt.source
+			for k, v := range tt.meta {
+				event.Metadata[k] = v
+			}
+
+			alerts := engine.EvaluateEvent(event)
+			if len(alerts) != 1 {
+				t.Fatalf("got %d alerts, want 1", len(alerts))
+			}
+			if got := alerts[0].ExceptionID; got != tt.want {
+				t.Fatalf("got exception %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,449 @@
+package rules
+
+import (
+	"fmt"
+	"maps"
+	"nox/internal/attack"
+	"nox/internal/enrich"
+	"nox/internal/ingester"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type LintSeverity string
+
+const (
+	LintError   LintSeverity = "error"
+	LintWarning LintSeverity = "warning"
+)
+
+// Finding is one problem the linter found in a rules file.
+type Finding struct {
+	Line     int          `json:"line"`
+	Column   int          `json:"column"`
+	Severity LintSeverity `json:"severity"`
+	Check    string       `json:"check"`
+	Rule     string       `json:"rule,omitempty"`
+	Message  string       `json:"message"`
+}
+
+func (f Finding) String() string {
+	rule := ""
+	if f.Rule != "" {
+		rule = f.Rule + ": "
+	}
+	return fmt.Sprintf("%d:%d: %s: %s%s (%s)", f.Line, f.Column, f.Severity, rule, f.Message, f.Check)
+}
+
+var (
+	Severities = []string{"LOW", "MEDIUM", "HIGH", "CRITICAL"}
+	Operators  = []string{"equals", "contains"}
+
+	ruleFields = []string{
+		"id", "name", "version", "author", "description", "technique_id", "severity", "event_type", "conditions",
+		"tags", "references", "false_positives", "enabled", "mode", "replaces",
+		"risk_weight",
+	}
+	conditionFields = []string{"field", "operator", "value"}
+)
+
+// LintFile lints a rules file. The error is only for an unreadable file;
+// malformed YAML is reported as a finding.
+func LintFile(path string) ([]Finding, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return Lint(data), nil
+}
+
+// Lint checks rules YAML for mistakes the loader accepts silently: unknown
+// or misspelled fields, values the engine doesn't understand, unknown ATT&CK
+// techniques, duplicate names and rules that can never fire. Findings are
+// sorted by position.
+func Lint(data []byte) []Finding {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return []Finding{syntaxFinding(err)}
+	}
+	if len(root.Content) == 0 {
+		return nil
+	}
+
+	l := &linter{}
+	doc := root.Content[0]
+	if doc.Kind != yaml.SequenceNode {
+		l.errorf(doc, "schema", "", "rules file must be a list of rules")
+		return l.findings
+	}
+
+	l.names = make(map[string]*yaml.Node)
+	l.ids = make(map[string]*yaml.Node)
+	for _, meta := range BuiltinRules() {
+		l.builtinIDs = append(l.builtinIDs, meta.ID)
+	}
+	for _, node := range doc.Content {
+		l.rule(node)
+	}
+	for _, ref := range l.replaces {
+		if _, ok := l.ids[ref.node.Value]; !ok && !slices.Contains(l.builtinIDs, ref.node.Value) {
+			l.warnf(ref.node, "replaces", ref.rule, "replaces %q, which is not a rule in this file or built in", ref.node.Value)
+		}
+	}
+
+	slices.SortStableFunc(l.findings, func(a, b Finding) int {
+		if a.Line != b.Line {
+			return a.Line - b.Line
+		}
+		return a.Column - b.Column
+	})
+	return l.findings
+}
+
+type linter struct {
+	findings   []Finding
+	names      map[string]*yaml.Node
+	ids        map[string]*yaml.Node
+	builtinIDs []string
+	// replaces are checked once every rule's id is known.
+	replaces []ruleRef
+}
+
+type ruleRef struct {
+	node *yaml.Node
+	rule string
+}
+
+func (l *linter) add(node *yaml.Node, severity LintSeverity, check, rule, format string, args ...any) {
+	l.findings = append(l.findings, Finding{
+		Line:     node.Line,
+		Column:   node.Column,
+		Severity: severity,
+		Check:    check,
+		Rule:     rule,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (l *linter) errorf(node *yaml.Node, check, rule, format string, args ...any) {
+	l.add(node, LintError, check, rule, format, args...)
+}
+
+func (l *linter) warnf(node *yaml.Node, check, rule, format string, args ...any) {
+	l.add(node, LintWarning, check, rule, format, args...)
+}
+
+// fields checks a mapping's keys against the known ones and returns the
+// value node of each known key.
+func (l *linter) fields(node *yaml.Node, known []string, rule string) map[string]*yaml.Node {
+	values := make(map[string]*yaml.Node)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		switch {
+		case slices.Contains(known, key.Value):
+			if _, dup := values[key.Value]; dup {
+				l.errorf(key, "schema", rule, "field %q is set more than once", key.Value)
+			}
+			values[key.Value] = value
+		default:
+			l.errorf(key, "schema", rule, "unknown field %q is ignored%s", key.Value, suggest(key.Value, known))
+		}
+	}
+	return values
+}
+
+func (l *linter) rule(node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		l.errorf(node, "schema", "", "rule must be a mapping")
+		return
+	}
+
+	name := ""
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "name" {
+			name = node.Content[i+1].Value
+		}
+	}
+
+	fields := l.fields(node, ruleFields, name)
+	for _, key := range []string{"name", "severity", "event_type"} {
+		if value := fields[key]; value != nil && value.Kind != yaml.ScalarNode {
+			l.errorf(value, "schema", name, "field %q must be a string", key)
+			delete(fields, key)
+		}
+	}
+
+	nameNode := fields["name"]
+	if nameNode == nil || nameNode.Value == "" {
+		l.errorf(node, "required", "", "rule has no name")
+	} else if first, dup := l.names[name]; dup {
+		l.errorf(nameNode, "duplicate", name, "duplicate rule name, first defined on line %d", first.Line)
+		name = "" // already reported; don't report its derived id too
+	} else {
+		l.names[name] = nameNode
+	}
+
+	l.metadata(node, fields, name)
+
+	if value := fields["description"]; value == nil || value.Value == "" {
+		l.warnf(node, "required", name, "rule has no description, which is used as the alert message")
+	}
+
+	l.technique(node, fields["technique_id"], name)
+
+	if value := fields["severity"]; value == nil {
+		l.errorf(node, "required", name, "rule has no severity")
+	} else if !slices.Contains(Severities, value.Value) {
+		l.errorf(value, "severity", name, "unknown severity %q, expected one of %s", value.Value, strings.Join(Severities, ", "))
+	}
+
+	eventType, eventOK := "", false
+	if value := fields["event_type"]; value == nil {
+		l.errorf(node, "required", name, "rule has no event_type and can never fire")
+	} else if _, eventOK = ingester.EventFields[value.Value]; !eventOK {
+		known := slices.Sorted(maps.Keys(ingester.EventFields))
+		l.errorf(value, "never-fires", name, "no parser produces event type %q%s", value.Value, suggest(value.Value, known))
+	} else {
+		eventType = value.Value
+	}
+
+	conditions := fields["conditions"]
+	switch {
+	case conditions == nil || len(conditions.Content) == 0:
+		l.warnf(node, "conditions", name, "rule has no conditions and alerts on every %s event", orAny(eventType))
+	case conditions.Kind != yaml.SequenceNode:
+		l.errorf(conditions, "schema", name, "conditions must be a list")
+	default:
+		l.conditions(conditions, name, eventType)
+	}
+}
+
+func (l *linter) metadata(rule *yaml.Node, fields map[string]*yaml.Node, name string) {
+	var def RuleDefinition
+	if err := rule.Decode(&def); err != nil {
+		l.errorf(rule, "schema", name, "invalid rule: %s", strings.TrimPrefix(err.Error(), "yaml: "))
+		return
+	}
+
+	id, at := def.ID, fields["id"]
+	switch {
+	case at == nil && name == "":
+		return
+	case at == nil:
+		id, at = RuleID(name), rule
+		l.warnf(rule, "id", name, "rule has no id, so its derived id %q changes if the rule is renamed", id)
+	case at != nil && !ValidRuleID(id):
+		l.errorf(at, "id", name, "id %q must be lowercase letters, digits and dashes", id)
+		return
+	}
+	if slices.Contains(l.builtinIDs, id) {
+		l.errorf(at, "duplicate", name, "id %q is taken by a built-in rule", id)
+	} else if first, dup := l.ids[id]; dup {
+		l.errorf(at, "duplicate", name, "duplicate rule id %q, first used on line %d", id, first.Line)
+	} else {
+		l.ids[id] = at
+	}
+
+	if value := fields["version"]; value != nil && def.Version < 1 {
+		l.errorf(value, "schema", name, "version must be a positive integer")
+	}
+
+	mode := fields["mode"]
+	if mode != nil && !slices.Contains(Modes, def.Mode) {
+		l.errorf(mode, "mode", name, "unknown mode %q, expected one of %s%s", def.Mode, strings.Join(Modes, ", "), suggest(def.Mode, Modes))
+	}
+	if mode != nil && fields["enabled"] != nil {
+		l.warnf(fields["enabled"], "mode", name, "enabled is ignored when mode is set")
+	}
+	if value := fields["replaces"]; value != nil {
+		if def.Mode != ModeShadow {
+			l.warnf(value, "mode", name, "replaces only matters for a rule in shadow mode")
+		}
+		l.replaces = append(l.replaces, ruleRef{value, name})
+	}
+
+	if value := fields["risk_weight"]; value != nil && def.RiskWeight != nil && *def.RiskWeight < 0 {
+		l.errorf(value, "risk_weight", name, "risk_weight must not be negative")
+	}
+}
+
+func (l *linter) technique(rule, value *yaml.Node, name string) {
+	if value == nil || value.Value == "" {
+		l.warnf(rule, "technique", name, "rule has no technique_id, so it doesn't count towards ATT&CK coverage")
+		return
+	}
+
+	id := value.Value
+	if !attack.ValidID(id) {
+		l.errorf(value, "technique", name, "technique_id %q is not an ATT&CK technique ID like T1059 or T1059.004", id)
+		return
+	}
+	if _, ok := attack.Lookup(id); ok {
+		return
+	}
+
+	// only sub-techniques relevant to nox are bundled, so an unknown one
+	// under a known parent is most likely fine.
+	parentID, _, isSub := strings.Cut(id, ".")
+	if parent, ok := attack.Lookup(parentID); ok && isSub {
+		l.warnf(value, "technique", name, "technique_id %q is not in the bundled ATT&CK v%s list, but its parent %s %s is", id, attack.Version, parent.ID, parent.Name)
+		return
+	}
+	l.errorf(value, "technique", name, "technique_id %q is not in the bundled ATT&CK v%s list", id, attack.Version)
+}
+
+func (l *linter) conditions(node *yaml.Node, rule, eventType string) {
+	type parsed struct {
+		node *yaml.Node
+		cond Condition
+	}
+	byField := make(map[string][]parsed)
+
+	for _, item := range node.Content {
+		if item.Kind != yaml.MappingNode {
+			l.errorf(item, "schema", rule, "condition must be a mapping")
+			continue
+		}
+		fields := l.fields(item, conditionFields, rule)
+
+		var cond Condition
+		if err := item.Decode(&cond); err != nil {
+			l.errorf(item, "schema", rule, "invalid condition: %v", err)
+			continue
+		}
+
+		valid := true
+		if value := fields["field"]; value == nil {
+			l.errorf(item, "required", rule, "condition has no field")
+			valid = false
+		} else if key, ok := strings.CutPrefix(cond.Field, "metadata."); !ok || key == "" || strings.Contains(key, ".") {
+			l.errorf(value, "field", rule, "field %q is skipped by the engine, only metadata.<key> fields are evaluated", cond.Field)
+			valid = false
+		} else if eventType != "" && !producesField(eventType, key) {
+			l.errorf(value, "never-fires", rule, "no %s event has a %q field%s", eventType, key, suggest(key, eventFields(eventType)))
+		}
+
+		if value := fields["operator"]; value == nil {
+			l.errorf(item, "required", rule, "condition has no operator and can never match")
+			valid = false
+		} else if !slices.Contains(Operators, cond.Operator) {
+			l.errorf(value, "never-fires", rule, "unknown operator %q never matches, expected one of %s%s", cond.Operator, strings.Join(Operators, ", "), suggest(cond.Operator, Operators))
+			valid = false
+		}
+
+		if value := fields["value"]; value == nil || cond.Value == "" {
+			at := item
+			if value != nil {
+				at = value
+			}
+			if cond.Operator == "contains" {
+				l.warnf(at, "value", rule, "empty contains value matches any %s", cond.Field)
+			} else {
+				l.warnf(at, "value", rule, "condition has an empty value")
+			}
+		}
+
+		if valid {
+			byField[cond.Field] = append(byField[cond.Field], parsed{item, cond})
+		}
+	}
+
+	// conditions are ANDed, so conflicting ones on a field can never all hold.
+	for _, field := range slices.Sorted(maps.Keys(byField)) {
+		conds := byField[field]
+		for i, a := range conds {
+			for _, b := range conds[:i] {
+				if conflicts(a.cond, b.cond) {
+					l.errorf(a.node, "never-fires", rule, "%s %s %q conflicts with %s %q on line %d", field, a.cond.Operator, a.cond.Value, b.cond.Operator, b.cond.Value, b.node.Line)
+				} else if a.cond == b.cond {
+					l.warnf(a.node, "conditions", rule, "duplicate condition, same as line %d", b.node.Line)
+				}
+			}
+		}
+	}
+}
+
+func conflicts(a, b Condition) bool {
+	switch {
+	case a.Operator == "equals" && b.Operator == "equals":
+		return a.Value != b.Value
+	case a.Operator == "equals" && b.Operator == "contains":
+		return !strings.Contains(a.Value, b.Value)
+	case a.Operator == "contains" && b.Operator == "equals":
+		return !strings.Contains(b.Value, a.Value)
+	}
+	return false
+}
+
+func producesField(eventType, key string) bool {
+	return slices.Contains(ingester.EventFields[eventType], key) || slices.Contains(enrich.Fields, key)
+}
+
+func eventFields(eventType string) []string {
+	return append(slices.Clone(ingester.EventFields[eventType]), enrich.Fields...)
+}
+
+func orAny(eventType string) string {
+	if eventType == "" {
+		return "matching"
+	}
+	return eventType
+}
+
+// suggest returns a "did you mean" hint for a likely misspelling.
+func suggest(got string, known []string) string {
+	best, bestDist := "", len(got)/2+1
+	for _, k := range known {
+		if strings.HasPrefix(k, got) || strings.HasPrefix(got, k) {
+			return fmt.Sprintf(", did you mean %q?", k)
+		}
+		if d := editDistance(strings.ToLower(got), strings.ToLower(k)); d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %q?", best)
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+var yamlLinePattern = regexp.MustCompile(`line (\d+)`)
+
+func syntaxFinding(err error) Finding {
+	line := 1
+	if m := yamlLinePattern.FindStringSubmatch(err.Error()); m != nil {
+		line, _ = strconv.Atoi(m[1])
+	}
+	return Finding{
+		Line:     line,
+		Column:   1,
+		Severity: LintError,
+		Check:    "syntax",
+		Message:  strings.TrimPrefix(err.Error(), "yaml: "),
+	}
+}
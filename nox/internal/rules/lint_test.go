@@ -0,0 +1,132 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShippedRulesLintClean(t *testing.T) {
+	findings, err := LintFile("../../detections/rules.yaml")
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+	for _, f := range findings {
+		t.Errorf("got finding %s, want none", f)
+	}
+}
+
+func TestLint(t *testing.T) {
+	const valid = `
+- id: nmap
+  name: Nmap
+  description: nmap ran
+  technique_id: T1046
+  severity: MEDIUM
+  event_type: Process_Executed
+  conditions:
+    - field: metadata.process_name
+      operator: equals
+      value: nmap
+`
+
+	tests := []struct {
+		name     string
+		rules    string
+		check    string // empty for no findings
+		line     int
+		want     string
+		severity LintSeverity // defaults to LintError
+	}{
+		{name: "valid", rules: valid},
+		{name: "enrichment field", rules: strings.Replace(valid, "process_name", "asset_criticality", 1)},
+		{name: "syntax", rules: "- name: [", check: "syntax", line: 1},
+		{name: "not a list", rules: "name: x", check: "schema", line: 1},
+		{name: "misspelled field", rules: strings.Replace(valid, "technique_id:", "technique:", 1), check: "schema", line: 5, want: `did you mean "technique_id"`},
+		{name: "malformed technique", rules: strings.Replace(valid, "T1046", "1046", 1), check: "technique", line: 5},
+		{name: "unknown technique", rules: strings.Replace(valid, "T1046", "T1999", 1), check: "technique", line: 5},
+		{name: "severity", rules: strings.Replace(valid, "MEDIUM", "medium", 1), check: "severity", line: 6},
+		{name: "event type", rules: strings.Replace(valid, "Process_Executed", "Process_Exec", 1), check: "never-fires", line: 7, want: `did you mean "Process_Executed"`},
+		{name: "non-metadata field", rules: strings.Replace(valid, "metadata.process_name", "process_name", 1), check: "field", line: 9},
+		{name: "field the event never has", rules: strings.Replace(valid, "process_name", "user", 1), check: "never-fires", line: 9},
+		{name: "operator", rules: strings.Replace(valid, "equals", "matches", 1), check: "never-fires", line: 10},
+		{
+			name:  "duplicate name",
+			rules: valid + strings.Replace(strings.TrimPrefix(valid, "\n"), "id: nmap", "id: nmap-again", 1),
+			check: "duplicate",
+			line:  13,
+			want:  "first defined on line 3",
+		},
+		{name: "missing id", rules: strings.Replace(valid, "- id: nmap\n  name", "- name", 1), check: "id", line: 2, severity: LintWarning},
+		{name: "malformed id", rules: strings.Replace(valid, "id: nmap", "id: Nmap Scan", 1), check: "id", line: 2},
+		{name: "builtin id", rules: strings.Replace(valid, "id: nmap", "id: password-spray", 1), check: "duplicate", line: 2},
+		{name: "version", rules: strings.Replace(valid, "name: Nmap", "name: Nmap\n  version: 0", 1), check: "schema", line: 4},
+		{name: "mode", rules: strings.Replace(valid, "name: Nmap", "name: Nmap\n  mode: shaddow", 1), check: "mode", line: 4, want: `did you mean "shadow"`},
+		{name: "negative risk weight", rules: strings.Replace(valid, "name: Nmap", "name: Nmap\n  risk_weight: -1", 1), check: "risk_weight", line: 4},
+		{name: "risk weight", rules: strings.Replace(valid, "name: Nmap", "name: Nmap\n  risk_weight: 0.5", 1)},
+		{
+			name:     "replaces unknown rule",
+			rules:    strings.Replace(valid, "name: Nmap", "name: Nmap\n  mode: shadow\n  replaces: nmap-v1", 1),
+			check:    "replaces",
+			line:     5,
+			severity: LintWarning,
+		},
+		{
+			name:     "replaces on an active rule",
+			rules:    strings.Replace(valid, "name: Nmap", "name: Nmap\n  replaces: password-spray", 1),
+			check:    "mode",
+			line:     4,
+			severity: LintWarning,
+		},
+		{
+			name:  "duplicate id",
+			rules: valid + strings.Replace(strings.TrimPrefix(valid, "\n"), "name: Nmap", "name: Nmap again", 1),
+			check: "duplicate",
+			line:  12,
+			want:  "first used on line 2",
+		},
+		{
+			name:  "conflicting conditions",
+			rules: valid + "    - field: metadata.process_name\n      operator: equals\n      value: nc\n",
+			check: "never-fires",
+			line:  12,
+			want:  "conflicts with equals",
+		},
+		{
+			name:  "contains compatible with equals",
+			rules: valid + "    - field: metadata.process_name\n      operator: contains\n      value: map\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := Lint([]byte(tt.rules))
+			if tt.check == "" {
+				if len(findings) != 0 {
+					t.Fatalf("got findings %v, want none", findings)
+				}
+				return
+			}
+
+			severity := tt.severity
+			if severity == "" {
+				severity = LintError
+			}
+			var matching []Finding
+			for _, f := range findings {
+				if f.Severity == severity {
+					matching = append(matching, f)
+				}
+			}
+			if len(matching) != 1 {
+				t.Fatalf("got findings %v, want one %s %s", findings, tt.check, severity)
+			}
+			f := matching[0]
+			if f.Check != tt.check || f.Line != tt.line {
+				t.Fatalf("got %s, want %s error on line %d", f, tt.check, tt.line)
+			}
+			if !strings.Contains(f.Message, tt.want) {
+				t.Fatalf("got message %q, want it to contain %q", f.Message, tt.want)
+			}
+		})
+	}
+}
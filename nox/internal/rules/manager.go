@@ -0,0 +1,249 @@
+package rules
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrRuleNotFound = errors.New("rule not found")
+	// ErrBuiltinRule is returned when deleting a rule that is compiled into
+	// nox. Built-in rules can only be disabled or re-graded.
+	ErrBuiltinRule  = errors.New("built-in rules can't be deleted")
+	ErrRuleConflict = errors.New("rule name is used by another rule")
+	ErrUnknownMode  = errors.New("unknown rule mode")
+)
+
+// InvalidRuleError rejects a rule the linter found errors in.
+type InvalidRuleError struct {
+	Findings []Finding
+}
+
+func (e *InvalidRuleError) Error() string {
+	msgs := make([]string, len(e.Findings))
+	for i, f := range e.Findings {
+		msgs[i] = f.String()
+	}
+	return "invalid rule: " + strings.Join(msgs, "; ")
+}
+
+// RuleManager changes the rules of a running engine. Every change is
+// written to disk before it takes effect, so it survives a restart: YAML
+// rules go back into the rules file, enabling and disabling into the
+// overrides file.
+type RuleManager struct {
+	// mu serializes changes, so the files and the engine never disagree.
+	mu            sync.Mutex
+	engine        *Engine
+	rulesPath     string
+	overridesPath string
+}
+
+func NewRuleManager(engine *Engine, rulesPath, overridesPath string) *RuleManager {
+	return &RuleManager{
+		engine:        engine,
+		rulesPath:     rulesPath,
+		overridesPath: overridesPath,
+	}
+}
+
+// SetMode activates, shadows or disables any rule, built-in ones included.
+func (m *RuleManager) SetMode(id, mode string) (RuleMetadata, error) {
+	if !slices.Contains(Modes, mode) {
+		return RuleMetadata{}, fmt.Errorf("%w: %q, expected one of %s", ErrUnknownMode, mode, strings.Join(Modes, ", "))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.engine.Rule(id); !ok {
+		return RuleMetadata{}, fmt.Errorf("%w: %s", ErrRuleNotFound, id)
+	}
+
+	overrides := m.engine.RuleOverrides()
+	i := slices.IndexFunc(overrides, func(o RuleOverride) bool { return o.ID == id })
+	if i < 0 {
+		overrides = append(overrides, RuleOverride{ID: id})
+		i = len(overrides) - 1
+	}
+	overrides[i].Mode, overrides[i].Enabled = mode, nil
+
+	if err := SaveRuleOverrides(m.overridesPath, overrides); err != nil {
+		return RuleMetadata{}, err
+	}
+	m.engine.setOverrides(overrides)
+
+	meta, _ := m.engine.Rule(id)
+	slog.Info("Detection rule mode changed", "rule_id", id, "mode", mode)
+	return meta, nil
+}
+
+// Upsert lints a YAML rules document and adds its rules, replacing loaded
+// YAML rules with the same ID. Nothing changes unless every rule is valid.
+// The linter's warnings are returned alongside.
+func (m *RuleManager) Upsert(data []byte) ([]RuleMetadata, []Finding, error) {
+	var problems, warnings []Finding
+	for _, f := range Lint(data) {
+		if f.Severity == LintError {
+			problems = append(problems, f)
+		} else {
+			warnings = append(warnings, f)
+		}
+	}
+	if len(problems) > 0 {
+		return nil, warnings, &InvalidRuleError{Findings: problems}
+	}
+
+	// the linter has already checked the document is a list of rules.
+	var doc yaml.Node
+	var defs []RuleDefinition
+	if yaml.Unmarshal(data, &doc) != nil || len(doc.Content) == 0 || doc.Decode(&defs) != nil || len(defs) == 0 {
+		return nil, warnings, &InvalidRuleError{Findings: []Finding{{Severity: LintError, Check: "schema", Message: "no rules to apply"}}}
+	}
+	nodes := doc.Content[0].Content
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	loaded := m.engine.Rules()
+	yamlRules := m.engine.YAMLRules()
+	for _, def := range defs {
+		id := def.Metadata().ID
+		for _, meta := range loaded {
+			if meta.Name == def.Name && meta.ID != id {
+				return nil, warnings, fmt.Errorf("%w: %q belongs to %s", ErrRuleConflict, def.Name, meta.ID)
+			}
+		}
+
+		i := slices.IndexFunc(yamlRules, func(r RuleDefinition) bool { return r.Metadata().ID == id })
+		if i < 0 {
+			yamlRules = append(yamlRules, def)
+		} else {
+			yamlRules[i] = def
+		}
+	}
+
+	err := m.editRulesFile(func(seq *yaml.Node) {
+		for i, def := range defs {
+			id := def.Metadata().ID
+			j := slices.IndexFunc(seq.Content, func(n *yaml.Node) bool { return nodeRuleID(n) == id })
+			if j < 0 {
+				seq.Content = append(seq.Content, nodes[i])
+			} else {
+				// a comment above the old rule, often the file's header,
+				// stays unless the new rule brings its own.
+				if nodes[i].HeadComment == "" {
+					nodes[i].HeadComment = seq.Content[j].HeadComment
+				}
+				seq.Content[j] = nodes[i]
+			}
+		}
+	})
+	if err != nil {
+		return nil, warnings, err
+	}
+	m.engine.setYAMLRules(yamlRules)
+
+	var applied []RuleMetadata
+	for _, def := range defs {
+		meta, _ := m.engine.Rule(def.Metadata().ID)
+		applied = append(applied, meta)
+		slog.Info("Detection rule applied", "rule_id", meta.ID, "version", meta.Version)
+	}
+	return applied, warnings, nil
+}
+
+// Delete removes a YAML rule and any override for it.
+func (m *RuleManager) Delete(id string) (RuleMetadata, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	meta, ok := m.engine.Rule(id)
+	if !ok {
+		return RuleMetadata{}, fmt.Errorf("%w: %s", ErrRuleNotFound, id)
+	}
+	if meta.Kind != RuleKindYAML {
+		return RuleMetadata{}, fmt.Errorf("%w: %s, disable it instead", ErrBuiltinRule, id)
+	}
+
+	yamlRules := slices.DeleteFunc(m.engine.YAMLRules(), func(r RuleDefinition) bool { return r.Metadata().ID == id })
+	err := m.editRulesFile(func(seq *yaml.Node) {
+		seq.Content = slices.DeleteFunc(seq.Content, func(n *yaml.Node) bool { return nodeRuleID(n) == id })
+	})
+	if err != nil {
+		return RuleMetadata{}, err
+	}
+	m.engine.setYAMLRules(yamlRules)
+
+	overrides := m.engine.RuleOverrides()
+	if kept := slices.DeleteFunc(slices.Clone(overrides), func(o RuleOverride) bool { return o.ID == id }); len(kept) < len(overrides) {
+		if err := SaveRuleOverrides(m.overridesPath, kept); err != nil {
+			return RuleMetadata{}, err
+		}
+		m.engine.setOverrides(kept)
+	}
+
+	slog.Info("Detection rule deleted", "rule_id", id)
+	return meta, nil
+}
+
+// editRulesFile rewrites the rules file through its YAML node tree, so the
+// comments and layout of untouched rules survive.
+func (m *RuleManager) editRulesFile(edit func(seq *yaml.Node)) error {
+	perm := os.FileMode(0o644)
+	data, err := os.ReadFile(m.rulesPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("failed to read rules file: %w", err)
+	default:
+		if info, err := os.Stat(m.rulesPath); err == nil {
+			perm = info.Mode().Perm()
+		}
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("failed to unmarshal rules yaml: %w", err)
+	}
+	if len(root.Content) == 0 {
+		root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.SequenceNode, Tag: "!!seq"}}}
+	}
+	seq := root.Content[0]
+	if seq.Kind != yaml.SequenceNode {
+		return fmt.Errorf("rules file %s is not a list of rules", m.rulesPath)
+	}
+	edit(seq)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&root); err != nil {
+		return fmt.Errorf("failed to marshal rules yaml: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("failed to marshal rules yaml: %w", err)
+	}
+
+	if err := writeFileAtomic(m.rulesPath, buf.Bytes(), perm); err != nil {
+		return fmt.Errorf("failed to write rules file: %w", err)
+	}
+	return nil
+}
+
+func nodeRuleID(node *yaml.Node) string {
+	var def RuleDefinition
+	if err := node.Decode(&def); err != nil {
+		return ""
+	}
+	return def.Metadata().ID
+}
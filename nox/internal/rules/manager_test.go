@@ -0,0 +1,196 @@
+package rules
+
+import (
+	"errors"
+	"nox/internal/model"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const managedRules = `# hand-written rules
+- id: nmap
+  name: Nmap
+  severity: MEDIUM
+  event_type: Process_Executed
+  technique_id: T1046
+  conditions:
+    # keep this comment
+    - field: metadata.process_name
+      operator: equals
+      value: nmap
+`
+
+func newTestManager(t *testing.T) (*RuleManager, *Engine, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	rulesPath := filepath.Join(dir, "rules.yaml")
+	overridesPath := filepath.Join(dir, "overrides.yaml")
+	if err := os.WriteFile(rulesPath, []byte(managedRules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	defs, err := LoadRulesFromFile(rulesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := NewEngine(nil, NewStateManager(), defs)
+	return NewRuleManager(engine, rulesPath, overridesPath), engine, rulesPath, overridesPath
+}
+
+func processEvent(name string) model.Event {
+	return model.Event{
+		Timestamp: time.Date(2026, time.January, 15, 12, 0, 0, 0, time.UTC),
+		EventType: "Process_Executed",
+		Metadata:  map[string]string{"process_name": name},
+	}
+}
+
+func TestRuleManagerSetModePersistsOverride(t *testing.T) {
+	manager, engine, _, overridesPath := newTestManager(t)
+
+	meta, err := manager.SetMode("nmap", ModeDisabled)
+	if err != nil {
+		t.Fatalf("SetMode: %v", err)
+	}
+	if meta.Enabled {
+		t.Fatalf("got enabled rule, want disabled")
+	}
+	if alerts := engine.EvaluateEvent(processEvent("nmap")); len(alerts) != 0 {
+		t.Fatalf("got %d alerts from a disabled rule, want 0", len(alerts))
+	}
+
+	overrides, err := LoadRuleOverrides(overridesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(overrides) != 1 || overrides[0].ID != "nmap" || overrides[0].Mode != ModeDisabled {
+		t.Fatalf("got overrides %+v, want nmap disabled", overrides)
+	}
+
+	if _, err := manager.SetMode("password-spray", ModeShadow); err != nil {
+		t.Fatalf("got %v shadowing a built-in rule, want nil", err)
+	}
+	if _, err := manager.SetMode("missing", ModeActive); !errors.Is(err, ErrRuleNotFound) {
+		t.Fatalf("got %v, want ErrRuleNotFound", err)
+	}
+	if _, err := manager.SetMode("nmap", "paused"); !errors.Is(err, ErrUnknownMode) {
+		t.Fatalf("got %v, want ErrUnknownMode", err)
+	}
+}
+
+func TestRuleManagerUpsertAndDelete(t *testing.T) {
+	manager, engine, rulesPath, _ := newTestManager(t)
+
+	_, _, err := manager.Upsert([]byte(`
+- id: netcat
+  name: Netcat
+  severity: HIGH
+  event_type: Process_Executed
+  conditions:
+    - field: metadata.process_name
+      operator: equals
+      value: nc
+`))
+	if err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	if alerts := engine.EvaluateEvent(processEvent("nc")); len(alerts) != 1 || alerts[0].RuleID != "netcat" {
+		t.Fatalf("got %+v, want one netcat alert", alerts)
+	}
+
+	// replacing a rule keeps the rest of the file as it was.
+	applied, _, err := manager.Upsert([]byte(`
+- id: nmap
+  name: Nmap
+  version: 2
+  severity: LOW
+  event_type: Process_Executed
+  conditions:
+    - field: metadata.process_name
+      operator: equals
+      value: nmap
+`))
+	if err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	if applied[0].Version != 2 || applied[0].Severity != "LOW" {
+		t.Fatalf("got %+v, want nmap v2 at LOW", applied[0])
+	}
+
+	data, err := os.ReadFile(rulesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "# hand-written rules") {
+		t.Fatalf("rules file lost its header comment:\n%s", data)
+	}
+	defs, err := LoadRulesFromFile(rulesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defs) != 2 || defs[0].Version != 2 || defs[1].ID != "netcat" {
+		t.Fatalf("got %+v, want nmap v2 and netcat", defs)
+	}
+
+	if _, err := manager.Delete("netcat"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if alerts := engine.EvaluateEvent(processEvent("nc")); len(alerts) != 0 {
+		t.Fatalf("got %d alerts from a deleted rule, want 0", len(alerts))
+	}
+	if defs, _ := LoadRulesFromFile(rulesPath); len(defs) != 1 {
+		t.Fatalf("got %d rules on disk, want 1", len(defs))
+	}
+
+	if _, err := manager.Delete("password-spray"); !errors.Is(err, ErrBuiltinRule) {
+		t.Fatalf("got %v, want ErrBuiltinRule", err)
+	}
+}
+
+func TestRuleManagerUpsertRejectsBadRules(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want func(error) bool
+	}{
+		{
+			name: "lint error",
+			yaml: "- id: bad\n  name: Bad\n  severity: SEVERE\n  event_type: Process_Executed\n  conditions: []\n",
+			want: func(err error) bool {
+				var invalid *InvalidRuleError
+				return errors.As(err, &invalid)
+			},
+		},
+		{
+			name: "name of another rule",
+			yaml: "- id: nmap-2\n  name: Nmap\n  severity: LOW\n  event_type: Process_Executed\n  conditions:\n    - field: metadata.process_name\n      operator: equals\n      value: nmap\n",
+			want: func(err error) bool { return errors.Is(err, ErrRuleConflict) },
+		},
+		{
+			name: "empty",
+			yaml: "",
+			want: func(err error) bool {
+				var invalid *InvalidRuleError
+				return errors.As(err, &invalid)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager, engine, rulesPath, _ := newTestManager(t)
+			if _, _, err := manager.Upsert([]byte(tt.yaml)); !tt.want(err) {
+				t.Fatalf("got %v, want a rejection", err)
+			}
+			if got := len(engine.YAMLRules()); got != 1 {
+				t.Fatalf("got %d loaded rules, want 1", got)
+			}
+			if data, _ := os.ReadFile(rulesPath); string(data) != managedRules {
+				t.Fatalf("rules file changed:\n%s", data)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"nox/internal/attack"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RuleMetadata describes a rule the same way whether it is a YAML rule or
+// built into nox, so alerts can be traced back to the exact rule version
+// that raised them.
+type RuleMetadata struct {
+	// ID is stable across renames; alerts carry it as RuleID.
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+	Version int    `json:"version"`
+	Author  string `json:"author,omitempty"`
+
+	Description    string   `json:"description,omitempty"`
+	Techniques     []string `json:"techniques,omitempty"`
+	Tags           []string `json:"tags,omitempty"`
+	References     []string `json:"references,omitempty"`
+	FalsePositives []string `json:"false_positives,omitempty"`
+
+	// Severity is what the rule's alerts are raised with: DefaultSeverity
+	// unless an override replaces it. Built-in rules that grade their own
+	// alerts, like ThreatIntelMatch, report their highest severity.
+	Severity        string `json:"severity"`
+	DefaultSeverity string `json:"default_severity"`
+	Mode            string `json:"mode"`
+	// Enabled is true unless Mode is disabled.
+	Enabled bool `json:"enabled"`
+	// Replaces is the ID of the rule a shadow rule would take over from,
+	// which the shadow report compares it with.
+	Replaces string `json:"replaces,omitempty"`
+	// RiskWeight scales the risk the rule's alerts add to their entities;
+	// 1 unless set, 0 keeps the rule out of risk scoring.
+	RiskWeight float64 `json:"risk_weight"`
+}
+
+func (m *RuleMetadata) setMode(mode string) {
+	m.Mode = mode
+	m.Enabled = mode != ModeDisabled
+}
+
+// Rule modes. A shadow rule is evaluated like an active one, but its alerts
+// are only recorded, so a new or changed rule can prove itself on live
+// traffic before it pages anyone.
+const (
+	ModeActive   = "active"
+	ModeShadow   = "shadow"
+	ModeDisabled = "disabled"
+)
+
+var Modes = []string{ModeActive, ModeShadow, ModeDisabled}
+
+// A MetadataProvider describes a built-in rule.
+type MetadataProvider interface {
+	Metadata() RuleMetadata
+}
+
+const builtinAuthor = "nox"
+
+var ruleIDPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// ValidRuleID reports whether id is a lowercase, dash-separated slug.
+func ValidRuleID(id string) bool {
+	return ruleIDPattern.MatchString(id)
+}
+
+// RuleID derives a rule ID from its name, for YAML rules that don't set one.
+func RuleID(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		default:
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// Metadata describes a YAML rule, filling in what the file leaves out.
+func (r RuleDefinition) Metadata() RuleMetadata {
+	meta := RuleMetadata{
+		ID:              r.ID,
+		Name:            r.Name,
+		Kind:            RuleKindYAML,
+		Version:         max(r.Version, 1),
+		Author:          r.Author,
+		Description:     r.Description,
+		Tags:            r.Tags,
+		References:      r.References,
+		FalsePositives:  r.FalsePositives,
+		Severity:        r.Severity,
+		DefaultSeverity: r.Severity,
+		Replaces:        r.Replaces,
+		RiskWeight:      defaultRiskWeight,
+	}
+	if r.RiskWeight != nil {
+		meta.RiskWeight = *r.RiskWeight
+	}
+	switch {
+	case r.Mode != "":
+		meta.setMode(r.Mode)
+	case r.Enabled != nil && !*r.Enabled:
+		meta.setMode(ModeDisabled)
+	default:
+		meta.setMode(ModeActive)
+	}
+	if meta.ID == "" {
+		meta.ID = RuleID(r.Name)
+	}
+	if r.TechniqueID != "" {
+		meta.Techniques = []string{r.TechniqueID}
+	}
+	return meta
+}
+
+// builtin fills in the fields every built-in rule shares.
+func builtin(meta RuleMetadata) RuleMetadata {
+	meta.Author = builtinAuthor
+	meta.Version = max(meta.Version, 1)
+	meta.DefaultSeverity = meta.Severity
+	meta.setMode(ModeActive)
+	if meta.RiskWeight == 0 {
+		meta.RiskWeight = defaultRiskWeight
+	}
+	for _, id := range meta.Techniques {
+		meta.References = append(meta.References, attack.URL(id))
+	}
+	return meta
+}
+
+// RuleOverride changes a rule without editing it, which is the only way to
+// tune a built-in rule. Unset fields leave the rule as it is.
+type RuleOverride struct {
+	ID   string `yaml:"id" json:"id"`
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+	// Enabled is the older form of Mode: true is active, false disabled.
+	Enabled    *bool    `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	Severity   string   `yaml:"severity,omitempty" json:"severity,omitempty"`
+	RiskWeight *float64 `yaml:"risk_weight,omitempty" json:"risk_weight,omitempty"`
+}
+
+func (o RuleOverride) apply(meta RuleMetadata) RuleMetadata {
+	switch {
+	case o.Mode != "":
+		meta.setMode(o.Mode)
+	case o.Enabled != nil && *o.Enabled:
+		meta.setMode(ModeActive)
+	case o.Enabled != nil:
+		meta.setMode(ModeDisabled)
+	}
+	if o.Severity != "" {
+		meta.Severity = o.Severity
+	}
+	if o.RiskWeight != nil {
+		meta.RiskWeight = *o.RiskWeight
+	}
+	return meta
+}
+
+// LoadRuleOverrides reads rule overrides. A missing file means no overrides.
+func LoadRuleOverrides(path string) ([]RuleOverride, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rule overrides: %w", err)
+	}
+
+	var overrides []RuleOverride
+	if err := yaml.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal rule overrides: %w", err)
+	}
+
+	for _, o := range overrides {
+		if o.ID == "" {
+			return nil, fmt.Errorf("rule override without an id in %s", path)
+		}
+		if o.Mode != "" && !slices.Contains(Modes, o.Mode) {
+			return nil, fmt.Errorf("rule override for %s has unknown mode %q", o.ID, o.Mode)
+		}
+		if o.Severity != "" && !slices.Contains(Severities, o.Severity) {
+			return nil, fmt.Errorf("rule override for %s has unknown severity %q", o.ID, o.Severity)
+		}
+		if o.RiskWeight != nil && *o.RiskWeight < 0 {
+			return nil, fmt.Errorf("rule override for %s has a negative risk_weight", o.ID)
+		}
+	}
+
+	slog.Info("Loaded rule overrides", "path", path, "count", len(overrides))
+	return overrides, nil
+}
+
+// SaveRuleOverrides writes overrides to path, ordered by rule ID.
+func SaveRuleOverrides(path string, overrides []RuleOverride) error {
+	overrides = slices.Clone(overrides)
+	slices.SortFunc(overrides, func(a, b RuleOverride) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	data, err := yaml.Marshal(overrides)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rule overrides: %w", err)
+	}
+	if err := writeFileAtomic(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write rule overrides: %w", err)
+	}
+	return nil
+}
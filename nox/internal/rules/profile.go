@@ -0,0 +1,152 @@
+package rules
+
+import (
+	"cmp"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	ruleEvaluationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "nox_rule_evaluations_total",
+		Help: "Total number of events each rule evaluated.",
+	}, []string{"rule_id"})
+
+	ruleMatchesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "nox_rule_matches_total",
+		Help: "Total number of times each rule matched, suppressed matches included.",
+	}, []string{"rule_id"})
+
+	ruleEvaluationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "nox_rule_evaluation_seconds",
+		Help:    "Time each rule took to evaluate one event.",
+		Buckets: prometheus.ExponentialBuckets(0.000001, 4, 10), // 1µs to ~262ms
+	}, []string{"rule_id"})
+)
+
+func init() {
+	prometheus.MustRegister(ruleEvaluationsTotal)
+	prometheus.MustRegister(ruleMatchesTotal)
+	prometheus.MustRegister(ruleEvaluationSeconds)
+}
+
+// slowRuleWarnInterval limits slow-rule warnings to one per rule per
+// interval.
+const slowRuleWarnInterval = time.Minute
+
+// ruleStats accumulates one rule's evaluations. It outlives rule changes,
+// so a rule that is replaced keeps its history.
+type ruleStats struct {
+	id          string
+	evaluations atomic.Int64
+	matches     atomic.Int64
+	total       atomic.Int64 // nanoseconds
+	max         atomic.Int64 // nanoseconds
+	lastWarned  atomic.Int64 // unix nanoseconds
+
+	evaluationsMetric prometheus.Counter
+	matchesMetric     prometheus.Counter
+	latencyMetric     prometheus.Observer
+}
+
+func newRuleStats(id string) *ruleStats {
+	return &ruleStats{
+		id:                id,
+		evaluationsMetric: ruleEvaluationsTotal.WithLabelValues(id),
+		matchesMetric:     ruleMatchesTotal.WithLabelValues(id),
+		latencyMetric:     ruleEvaluationSeconds.WithLabelValues(id),
+	}
+}
+
+func (s *ruleStats) record(took time.Duration, matched bool) {
+	s.evaluations.Add(1)
+	s.evaluationsMetric.Inc()
+	if matched {
+		s.matches.Add(1)
+		s.matchesMetric.Inc()
+	}
+
+	s.total.Add(int64(took))
+	for {
+		current := s.max.Load()
+		if int64(took) <= current || s.max.CompareAndSwap(current, int64(took)) {
+			break
+		}
+	}
+	s.latencyMetric.Observe(took.Seconds())
+}
+
+// shouldWarn reports whether a slow evaluation at now should be logged.
+func (s *ruleStats) shouldWarn(now time.Time) bool {
+	last := s.lastWarned.Load()
+	if now.UnixNano()-last < int64(slowRuleWarnInterval) {
+		return false
+	}
+	return s.lastWarned.CompareAndSwap(last, now.UnixNano())
+}
+
+// RuleProfile is how much work one rule has done.
+type RuleProfile struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Kind        string        `json:"kind"`
+	Evaluations int64         `json:"evaluations"`
+	Matches     int64         `json:"matches"`
+	Total       time.Duration `json:"total_ns"`
+	Max         time.Duration `json:"max_ns"`
+}
+
+func (p RuleProfile) Mean() time.Duration {
+	if p.Evaluations == 0 {
+		return 0
+	}
+	return p.Total / time.Duration(p.Evaluations)
+}
+
+// Profile reports every loaded rule's evaluations since the engine started,
+// the most expensive first.
+func (e *Engine) Profile() []RuleProfile {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var profiles []RuleProfile
+	for name, meta := range e.metadata {
+		p := RuleProfile{ID: meta.ID, Name: name, Kind: meta.Kind}
+		if s, ok := e.stats[name]; ok {
+			p.Evaluations = s.evaluations.Load()
+			p.Matches = s.matches.Load()
+			p.Total = time.Duration(s.total.Load())
+			p.Max = time.Duration(s.max.Load())
+		}
+		profiles = append(profiles, p)
+	}
+
+	slices.SortFunc(profiles, func(a, b RuleProfile) int {
+		if c := cmp.Compare(b.Total, a.Total); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return profiles
+}
+
+// observe times one evaluation of the named rule.
+func (e *Engine) observe(name string, eval func() bool) {
+	s := e.stats[name]
+	if s == nil {
+		eval()
+		return
+	}
+
+	start := time.Now()
+	matched := eval()
+	took := time.Since(start)
+	s.record(took, matched)
+
+	if e.slowRule > 0 && took >= e.slowRule && s.shouldWarn(start) {
+		e.logger.Warn("Slow detection rule", "rule_id", s.id, "took", took, "threshold", e.slowRule)
+	}
+}
@@ -0,0 +1,51 @@
+package rules
+
+import (
+	"testing"
+)
+
+func TestEngineProfileCountsEvaluationsAndMatches(t *testing.T) {
+	engine := NewEngine(nil, NewStateManager(), []RuleDefinition{
+		{
+			ID:        "nc",
+			Name:      "Netcat",
+			Severity:  "HIGH",
+			EventType: "Process_Executed",
+			Conditions: []Condition{
+				{Field: "metadata.process_name", Operator: "equals", Value: "nc"},
+			},
+		},
+		{
+			ID:        "root-login",
+			Name:      "Root Login",
+			Severity:  "HIGH",
+			EventType: "SSHD_Accepted_Password",
+			Conditions: []Condition{
+				{Field: "metadata.user", Operator: "equals", Value: "root"},
+			},
+		},
+	})
+
+	for _, name := range []string{"nc", "ls", "nc"} {
+		engine.EvaluateEvent(processEvent(name))
+	}
+
+	profiles := make(map[string]RuleProfile)
+	for _, p := range engine.Profile() {
+		profiles[p.ID] = p
+	}
+
+	if p := profiles["nc"]; p.Evaluations != 3 || p.Matches != 2 {
+		t.Fatalf("got %d evaluations and %d matches, want 3 and 2", p.Evaluations, p.Matches)
+	}
+	// the event type index keeps the sshd rule away from process events.
+	if p := profiles["root-login"]; p.Evaluations != 0 {
+		t.Fatalf("got %d evaluations of an sshd rule, want 0", p.Evaluations)
+	}
+	if p := profiles["rapid-process-execution"]; p.Evaluations != 3 || p.Total <= 0 || p.Max < p.Mean() {
+		t.Fatalf("got %+v, want 3 timed evaluations", p)
+	}
+	if _, ok := profiles["password-spray"]; !ok {
+		t.Fatalf("got no profile for a rule that hasn't run, want a zero one")
+	}
+}
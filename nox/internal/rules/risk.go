@@ -0,0 +1,279 @@
+package rules
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"net/netip"
+	"nox/internal/model"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultRiskWeight is the risk weight of a rule that doesn't set one.
+const defaultRiskWeight = 1.0
+
+// riskTTL retires entities nobody has raised an alert on for two weeks; by
+// then their risk has decayed to nothing at any sensible half-life.
+const riskTTL = 14 * 24 * time.Hour
+
+// Entity types risk is tracked for.
+const (
+	EntityUser = "user"
+	EntityIP   = "ip"
+	EntityHost = "host"
+)
+
+var EntityTypes = []string{EntityUser, EntityIP, EntityHost}
+
+// severityRisk is what an alert of each severity adds to its entities at
+// risk weight 1. Two HIGH alerts, or three MEDIUM ones, cross the default
+// threshold.
+var severityRisk = map[string]float64{
+	"LOW":      15,
+	"MEDIUM":   40,
+	"HIGH":     65,
+	"CRITICAL": 90,
+}
+
+// RiskScore is the risk an alert of the given severity adds, scaled by its
+// rule's risk weight.
+func RiskScore(severity string, weight float64) float64 {
+	return severityRisk[severity] * weight
+}
+
+// RiskConfig tunes entity risk scoring.
+type RiskConfig struct {
+	// Threshold is the risk at which an entity raises an alert.
+	Threshold float64
+	// HalfLife is how long it takes an entity's risk to halve.
+	HalfLife time.Duration
+	// MaxContributions caps the alerts kept per entity, newest first.
+	MaxContributions int
+}
+
+func DefaultRiskConfig() RiskConfig {
+	return RiskConfig{
+		Threshold:        100,
+		HalfLife:         24 * time.Hour,
+		MaxContributions: 20,
+	}
+}
+
+// RiskContribution is one alert's share of an entity's risk.
+type RiskContribution struct {
+	AlertID  string    `json:"alert_id"`
+	RuleID   string    `json:"rule_id"`
+	RuleName string    `json:"rule_name"`
+	Severity string    `json:"severity"`
+	Score    float64   `json:"score"`
+	Time     time.Time `json:"time"`
+}
+
+// EntityRisk is the accumulated risk of a user, source IP or host. Score is
+// as of UpdatedAt and decays from there.
+type EntityRisk struct {
+	Type      string    `json:"type"`
+	Entity    string    `json:"entity"`
+	Score     float64   `json:"score"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// AlertedAt is when the entity last crossed the threshold; it is
+	// cleared once the risk falls back below half of it.
+	AlertedAt time.Time          `json:"alerted_at,omitzero"`
+	Alerts    []RiskContribution `json:"alerts"`
+}
+
+// decayed returns the risk as of now.
+func (r EntityRisk) decayed(now time.Time, halfLife time.Duration) EntityRisk {
+	if halfLife > 0 && now.After(r.UpdatedAt) {
+		r.Score *= math.Pow(0.5, float64(now.Sub(r.UpdatedAt))/float64(halfLife))
+		r.UpdatedAt = now
+	}
+	return r
+}
+
+type RiskState struct {
+	mu       sync.Mutex
+	Entities *Store[EntityRisk] // Key: entity type:entity
+}
+
+// --- Entity Risk Rule ---
+
+// EntityRiskRule adds up the risk of the alerts raised on each user, source
+// IP and host, and alerts when one of them crosses the threshold. Alerts
+// that are individually ignorable, like an nmap scan followed by a chmod
+// 777 and a new crontab on the same host, add up to one that isn't.
+type EntityRiskRule struct {
+	cfg RiskConfig
+}
+
+func NewEntityRiskRule(cfg RiskConfig) CorrelationRule {
+	return &EntityRiskRule{cfg: cfg}
+}
+
+func (r *EntityRiskRule) Name() string {
+	return "EntityRiskThreshold"
+}
+
+func (r *EntityRiskRule) Metadata() RuleMetadata {
+	return builtin(RuleMetadata{
+		ID:          "entity-risk-threshold",
+		Name:        r.Name(),
+		Kind:        RuleKindCorrelation,
+		Description: "The alerts raised on one user, source IP or host add up to more risk than the threshold.",
+		Severity:    "HIGH",
+		Tags:        []string{"risk", "chain"},
+		FalsePositives: []string{
+			"A noisy admin host whose routine activity trips several low-value rules",
+		},
+	})
+}
+
+func (r *EntityRiskRule) Evaluate(event model.Event, existingAlerts []model.Alert, state *StateManager) *model.Alert {
+	s := state.Risk
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var crossed []EntityRisk
+	for _, alert := range existingAlerts {
+		if alert.RuleName == r.Name() || alert.RiskScore <= 0 {
+			continue
+		}
+		contribution := RiskContribution{
+			AlertID:  alert.ID(),
+			RuleID:   alert.RuleID,
+			RuleName: alert.RuleName,
+			Severity: alert.Severity,
+			Score:    alert.RiskScore,
+			Time:     alert.Timestamp,
+		}
+
+		for _, entity := range riskEntities(alert, event) {
+			key := entity.Type + ":" + entity.Entity
+			risk, ok := s.Entities.Get(key)
+			if !ok {
+				risk = entity
+			}
+			risk = risk.decayed(alert.Timestamp, r.cfg.HalfLife)
+			if risk.UpdatedAt.IsZero() {
+				risk.UpdatedAt = alert.Timestamp
+			}
+
+			// hysteresis: an entity hovering at the threshold alerts once,
+			// and again only after its risk has clearly come down
+			if !risk.AlertedAt.IsZero() && risk.Score < r.cfg.Threshold/2 {
+				risk.AlertedAt = time.Time{}
+			}
+
+			risk.Score += contribution.Score
+			risk.Alerts = append([]RiskContribution{contribution}, risk.Alerts...)
+			if n := r.cfg.MaxContributions; n > 0 && len(risk.Alerts) > n {
+				risk.Alerts = risk.Alerts[:n]
+			}
+
+			if risk.Score >= r.cfg.Threshold && risk.AlertedAt.IsZero() {
+				risk.AlertedAt = alert.Timestamp
+				crossed = append(crossed, risk)
+			}
+			s.Entities.Set(key, risk, risk.UpdatedAt)
+		}
+	}
+
+	if len(crossed) == 0 {
+		return nil
+	}
+
+	worst := slices.MaxFunc(crossed, func(a, b EntityRisk) int { return cmp.Compare(a.Score, b.Score) })
+	ids := make([]string, len(worst.Alerts))
+	for i, c := range worst.Alerts {
+		ids[i] = c.AlertID
+	}
+	names := make([]string, len(crossed))
+	for i, risk := range crossed {
+		names[i] = risk.Type + ":" + risk.Entity
+	}
+
+	alert := &model.Alert{
+		RuleName:  r.Name(),
+		Message:   fmt.Sprintf("Risk of %s %s reached %.0f from %d alerts (threshold %.0f).", worst.Type, worst.Entity, worst.Score, len(worst.Alerts), r.cfg.Threshold),
+		Severity:  "HIGH",
+		Timestamp: event.Timestamp,
+		Source:    event.Source,
+		Metadata: map[string]string{
+			"entity_type":             worst.Type,
+			"entity":                  worst.Entity,
+			"risk_score":              strconv.FormatFloat(worst.Score, 'f', 1, 64),
+			"risk_threshold":          strconv.FormatFloat(r.cfg.Threshold, 'f', 1, 64),
+			"contributing_alerts":     strings.Join(ids, ","),
+			"entities_over_threshold": strings.Join(names, ","),
+		},
+	}
+	switch worst.Type {
+	case EntityUser:
+		alert.Metadata["user"] = worst.Entity
+	case EntityHost:
+		alert.Metadata["host"] = worst.Entity
+	case EntityIP:
+		alert.Source = worst.Entity
+	}
+	return alert
+}
+
+// riskEntities returns the user, source IP and host an alert is about, as
+// far as the alert or its event name them.
+func riskEntities(alert model.Alert, event model.Event) []EntityRisk {
+	var out []EntityRisk
+	add := func(typ string, values ...string) {
+		for _, value := range values {
+			if value != "" {
+				out = append(out, EntityRisk{Type: typ, Entity: value})
+				return
+			}
+		}
+	}
+
+	add(EntityUser, alert.Metadata["user"], alert.Metadata["username"], event.Metadata["user"])
+	add(EntityIP, remoteIP(alert.Source), remoteIP(event.Source))
+	add(EntityHost, alert.Metadata["host"], event.Metadata["host"])
+	return out
+}
+
+// remoteIP is s if it is an IP address other than loopback. Local process
+// events come from 127.0.0.1, and their risk belongs to their host.
+func remoteIP(s string) string {
+	addr, err := netip.ParseAddr(s)
+	if err != nil || addr.IsLoopback() || addr.IsUnspecified() {
+		return ""
+	}
+	return addr.String()
+}
+
+// RiskThreshold is the risk at which an entity raises an alert.
+func (e *Engine) RiskThreshold() float64 {
+	return e.risk.Threshold
+}
+
+// RiskyEntities returns the entities with at least minScore risk as of the
+// latest event, riskiest first.
+func (e *Engine) RiskyEntities(minScore float64) []EntityRisk {
+	s := e.state.Risk
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := e.state.Now()
+	var out []EntityRisk
+	s.Entities.Range(func(_ string, risk EntityRisk, _ time.Time) bool {
+		if risk = risk.decayed(now, e.risk.HalfLife); risk.Score >= minScore && risk.Score > 0 {
+			risk.Alerts = slices.Clone(risk.Alerts)
+			out = append(out, risk)
+		}
+		return true
+	})
+	slices.SortFunc(out, func(a, b EntityRisk) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), strings.Compare(a.Type+a.Entity, b.Type+b.Entity))
+	})
+	return out
+}
@@ -0,0 +1,155 @@
+package rules
+
+import (
+	"math"
+	"nox/internal/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func riskEngine(t *testing.T, weight float64) *Engine {
+	t.Helper()
+	var defs []RuleDefinition
+	for _, name := range []string{"nmap", "chmod", "crontab"} {
+		defs = append(defs, RuleDefinition{
+			ID:         name,
+			Name:       "Suspicious " + name,
+			Severity:   "MEDIUM",
+			EventType:  "Process_Executed",
+			Conditions: []Condition{{Field: "metadata.process_name", Operator: "equals", Value: name}},
+			RiskWeight: &weight,
+		})
+	}
+	return NewEngine(nil, NewStateManager(), defs)
+}
+
+func riskEvent(name string, at time.Time) model.Event {
+	event := processEvent(name)
+	event.Timestamp = at
+	event.Source = "10.0.0.5"
+	event.Metadata["host"] = "web-1"
+	return event
+}
+
+func riskAlert(alerts []model.Alert) *model.Alert {
+	for _, alert := range alerts {
+		if alert.RuleName == "EntityRiskThreshold" {
+			return &alert
+		}
+	}
+	return nil
+}
+
+func TestEntityRiskRuleAddsUpMediumAlerts(t *testing.T) {
+	engine := riskEngine(t, 1)
+	start := time.Date(2026, time.January, 15, 12, 0, 0, 0, time.UTC)
+
+	for i, name := range []string{"nmap", "chmod"} {
+		alerts := engine.EvaluateEvent(riskEvent(name, start.Add(time.Duration(i)*time.Minute)))
+		if alert := riskAlert(alerts); alert != nil {
+			t.Fatalf("got a risk alert after %s, want none below the threshold", name)
+		}
+	}
+
+	alerts := engine.EvaluateEvent(riskEvent("crontab", start.Add(2*time.Minute)))
+	alert := riskAlert(alerts)
+	if alert == nil {
+		t.Fatalf("got no risk alert after three MEDIUM alerts, want one")
+	}
+	if got := strings.Split(alert.Metadata["contributing_alerts"], ","); len(got) != 3 {
+		t.Fatalf("got contributing alerts %v, want 3", got)
+	}
+	if alert.Metadata["entity_type"] != EntityIP || alert.Source != "10.0.0.5" {
+		t.Fatalf("got %s %s, want the source IP", alert.Metadata["entity_type"], alert.Metadata["entity"])
+	}
+	if !strings.Contains(alert.Metadata["entities_over_threshold"], "host:web-1") {
+		t.Fatalf("got entities %q, want the host too", alert.Metadata["entities_over_threshold"])
+	}
+
+	entities := engine.RiskyEntities(engine.RiskThreshold())
+	if len(entities) != 2 {
+		t.Fatalf("got %d risky entities, want the IP and the host", len(entities))
+	}
+	if entities[0].Score < 119 || len(entities[0].Alerts) != 3 || entities[0].Alerts[0].RuleID != "crontab" {
+		t.Fatalf("got %+v, want 3 contributions newest first", entities[0])
+	}
+}
+
+func TestEntityRiskRuleScoresLocalProcessesOnTheirHost(t *testing.T) {
+	engine := riskEngine(t, 1)
+	start := time.Date(2026, time.January, 15, 12, 0, 0, 0, time.UTC)
+
+	var alert *model.Alert
+	for i, name := range []string{"nmap", "chmod", "crontab"} {
+		// execsnoop events come from loopback; the pipeline stamps the host.
+		event := riskEvent(name, start.Add(time.Duration(i)*time.Minute))
+		event.Source = "127.0.0.1"
+		alert = riskAlert(engine.EvaluateEvent(event))
+	}
+
+	if alert == nil {
+		t.Fatalf("got no risk alert after three MEDIUM process alerts, want one on the host")
+	}
+	if alert.Metadata["entity_type"] != EntityHost || alert.Metadata["host"] != "web-1" {
+		t.Fatalf("got %s %s, want host web-1", alert.Metadata["entity_type"], alert.Metadata["entity"])
+	}
+	for _, risk := range engine.RiskyEntities(0) {
+		if risk.Type == EntityIP {
+			t.Fatalf("got risk on %s:%s, want none on loopback", risk.Type, risk.Entity)
+		}
+	}
+}
+
+func TestEntityRiskRuleHysteresis(t *testing.T) {
+	engine := riskEngine(t, 1.5) // 60 per alert
+	start := time.Date(2026, time.January, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		after time.Duration
+		want  bool
+	}{
+		{name: "below threshold", after: 0},
+		{name: "crosses", after: time.Minute, want: true},
+		{name: "still over, already alerted", after: 2 * time.Minute},
+		// three days later ~22 remains, under half the threshold
+		{name: "decayed and rearmed", after: 72 * time.Hour},
+		{name: "crosses again", after: 72*time.Hour + time.Minute, want: true},
+	}
+
+	for _, tt := range tests {
+		alerts := engine.EvaluateEvent(riskEvent("nmap", start.Add(tt.after)))
+		if got := riskAlert(alerts) != nil; got != tt.want {
+			t.Fatalf("%s: got risk alert %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEntityRiskDecay(t *testing.T) {
+	start := time.Date(2026, time.January, 15, 12, 0, 0, 0, time.UTC)
+	risk := EntityRisk{Score: 80, UpdatedAt: start}
+
+	got := risk.decayed(start.Add(48*time.Hour), 24*time.Hour)
+	if math.Abs(got.Score-20) > 0.001 {
+		t.Fatalf("got %.3f after two half-lives, want 20", got.Score)
+	}
+	if got := risk.decayed(start.Add(-time.Hour), 24*time.Hour); got.Score != 80 {
+		t.Fatalf("got %.3f for an earlier time, want it unchanged", got.Score)
+	}
+}
+
+func TestRiskWeightZeroOptsOut(t *testing.T) {
+	engine := riskEngine(t, 0)
+	start := time.Date(2026, time.January, 15, 12, 0, 0, 0, time.UTC)
+
+	for i := range 5 {
+		alerts := engine.EvaluateEvent(riskEvent("nmap", start.Add(time.Duration(i)*time.Minute)))
+		if riskAlert(alerts) != nil {
+			t.Fatalf("got a risk alert from a rule with weight 0, want none")
+		}
+	}
+	if entities := engine.RiskyEntities(0); len(entities) != 0 {
+		t.Fatalf("got %d risky entities, want none", len(entities))
+	}
+}
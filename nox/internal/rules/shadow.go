@@ -0,0 +1,61 @@
+package rules
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// ShadowComparison is how often a shadow rule would have fired, next to the
+// active rule it replaces, if it names one.
+type ShadowComparison struct {
+	Rule         RuleMetadata
+	ShadowAlerts int64
+	// Baseline is the rule named by Rule.Replaces; it has a zero ID when
+	// the shadow rule is new or the replaced rule isn't loaded.
+	Baseline       RuleMetadata
+	BaselineAlerts int64
+}
+
+// ShadowReport compares shadow rules with what they replace over Period.
+type ShadowReport struct {
+	Period time.Duration
+	Rules  []ShadowComparison
+}
+
+// PerDay is n alerts over the report's period as a daily rate.
+func (r ShadowReport) PerDay(n int64) float64 {
+	days := r.Period.Hours() / 24
+	if days <= 0 {
+		return 0
+	}
+	return float64(n) / days
+}
+
+// BuildShadowReport compares every shadow rule in rules with the rule it
+// replaces. shadow and active hold alert counts by rule name from the
+// shadow and regular alert stores.
+func BuildShadowReport(rules []RuleMetadata, shadow, active map[string]int64, period time.Duration) ShadowReport {
+	byID := make(map[string]RuleMetadata, len(rules))
+	for _, meta := range rules {
+		byID[meta.ID] = meta
+	}
+
+	report := ShadowReport{Period: period}
+	for _, meta := range rules {
+		if meta.Mode != ModeShadow {
+			continue
+		}
+		cmp := ShadowComparison{Rule: meta, ShadowAlerts: shadow[meta.Name]}
+		if baseline, ok := byID[meta.Replaces]; ok && meta.Replaces != "" {
+			cmp.Baseline = baseline
+			cmp.BaselineAlerts = active[baseline.Name]
+		}
+		report.Rules = append(report.Rules, cmp)
+	}
+
+	slices.SortFunc(report.Rules, func(a, b ShadowComparison) int {
+		return strings.Compare(a.Rule.ID, b.Rule.ID)
+	})
+	return report
+}
@@ -0,0 +1,68 @@
+package rules
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEngineMarksShadowAlerts(t *testing.T) {
+	curl := RuleDefinition{
+		ID:        "curl-download",
+		Name:      "File Download with Curl",
+		Severity:  "LOW",
+		EventType: "Process_Executed",
+		Conditions: []Condition{
+			{Field: "metadata.process_name", Operator: "equals", Value: "curl"},
+		},
+	}
+	candidate := curl
+	candidate.ID = "curl-download-v2"
+	candidate.Name = "File Download with Curl v2"
+	candidate.Mode = ModeShadow
+	candidate.Replaces = "curl-download"
+
+	engine := NewEngine(nil, NewStateManager(), []RuleDefinition{curl, candidate})
+
+	alerts := engine.EvaluateEvent(processEvent("curl"))
+	if len(alerts) != 2 {
+		t.Fatalf("got %d alerts, want 2", len(alerts))
+	}
+	for _, alert := range alerts {
+		if want := alert.RuleID == candidate.ID; alert.Shadow != want {
+			t.Fatalf("got shadow=%v for %s, want %v", alert.Shadow, alert.RuleID, want)
+		}
+	}
+
+	meta, ok := engine.Rule(candidate.ID)
+	if !ok || meta.Mode != ModeShadow || !meta.Enabled || meta.Replaces != curl.ID {
+		t.Fatalf("got %+v, want an enabled shadow rule replacing %s", meta, curl.ID)
+	}
+}
+
+func TestBuildShadowReport(t *testing.T) {
+	rules := []RuleMetadata{
+		{ID: "ssh-brute", Name: "SSH Brute", Mode: ModeActive},
+		{ID: "ssh-brute-v2", Name: "SSH Brute v2", Mode: ModeShadow, Replaces: "ssh-brute"},
+		{ID: "new-rule", Name: "New Rule", Mode: ModeShadow},
+		{ID: "gone", Name: "Gone", Mode: ModeShadow, Replaces: "missing"},
+		{ID: "off", Name: "Off", Mode: ModeDisabled},
+	}
+	shadow := map[string]int64{"SSH Brute v2": 14, "New Rule": 7}
+	active := map[string]int64{"SSH Brute": 70, "Off": 3}
+
+	report := BuildShadowReport(rules, shadow, active, 7*24*time.Hour)
+
+	if len(report.Rules) != 3 {
+		t.Fatalf("got %d rules, want the 3 shadow rules", len(report.Rules))
+	}
+	gone, newRule, v2 := report.Rules[0], report.Rules[1], report.Rules[2]
+	if gone.Baseline.ID != "" || newRule.Baseline.ID != "" {
+		t.Fatalf("got baselines %q and %q, want none", gone.Baseline.ID, newRule.Baseline.ID)
+	}
+	if v2.Baseline.ID != "ssh-brute" || v2.ShadowAlerts != 14 || v2.BaselineAlerts != 70 {
+		t.Fatalf("got %+v, want 14 shadow alerts against 70 from ssh-brute", v2)
+	}
+	if got := report.PerDay(v2.ShadowAlerts); got != 2 {
+		t.Fatalf("got %v per day, want 2", got)
+	}
+}
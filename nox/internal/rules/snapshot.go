@@ -0,0 +1,355 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"nox/internal/baseline"
+	"nox/internal/model"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// SnapshotVersion is bumped whenever the on-disk layout of Snapshot changes
+// in a way older binaries can't read.
+const SnapshotVersion = 1
+
+// Snapshot is a point-in-time copy of all detection state, written to disk so
+// sliding windows and learned baselines survive a restart. The IP watchlist
+// is not included because it is reloaded from the intel file on startup.
+type Snapshot struct {
+	Version                 int                           `json:"version"`
+	TakenAt                 time.Time                     `json:"taken_at"`
+	EventTime               time.Time                     `json:"event_time"` // newest event time the state had seen
+	FailedLogins            FailedLoginSnapshot           `json:"failed_logins"`
+	LoginLocations          map[string][]string           `json:"login_locations"`
+	LoginTravel             map[string]TravelProfile      `json:"login_travel"`
+	NewAccounts             map[string]time.Time          `json:"new_accounts"`
+	PostBruteForceLogins    map[string]PostBruteForceInfo `json:"post_brute_force_logins"`
+	ProcessExecutionHistory ProcessHistorySnapshot        `json:"process_execution_history"`
+	StagedPayloads          map[string]time.Time          `json:"staged_payloads"`
+	SuspiciousLogins        map[string]time.Time          `json:"suspicious_logins"`
+	PasswordSpray           PasswordSpraySnapshot         `json:"password_spray"`
+	Risk                    map[string]EntityRisk         `json:"risk"`
+	Chains                  map[string][]model.Event      `json:"chains"`
+	Baselines               []baseline.Profile            `json:"baselines"`
+}
+
+type FailedLoginSnapshot struct {
+	Attempts   map[string][]time.Time `json:"attempts"`
+	AlertedIPs map[string]bool        `json:"alerted_ips"`
+}
+
+type ProcessHistorySnapshot struct {
+	History      map[string][]ProcessExecution `json:"history"`
+	AlertedHosts map[string]time.Time          `json:"alerted_hosts"`
+}
+
+type PasswordSpraySnapshot struct {
+	Attempts   map[string][]SprayAttempt `json:"attempts"`
+	AlertedIPs map[string]bool           `json:"alerted_ips"`
+}
+
+// Snapshot copies the current state. Each state is locked only while it is
+// being copied, so rules keep running while a snapshot is taken.
+func (s *StateManager) Snapshot() Snapshot {
+	snap := Snapshot{
+		Version:   SnapshotVersion,
+		TakenAt:   time.Now().UTC(),
+		EventTime: s.Now(),
+	}
+
+	s.FailedLogins.mu.Lock()
+	snap.FailedLogins = FailedLoginSnapshot{
+		Attempts:   storeToMap(s.FailedLogins.Attempts, slices.Clone),
+		AlertedIPs: storeToMap(s.FailedLogins.AlertedIPs, identity),
+	}
+	s.FailedLogins.mu.Unlock()
+
+	s.LoginLocations.mu.Lock()
+	snap.LoginLocations = storeToMap(s.LoginLocations.Locations, func(countries map[string]bool) []string {
+		return slices.Sorted(maps.Keys(countries))
+	})
+	s.LoginLocations.mu.Unlock()
+
+	s.LoginTravel.mu.Lock()
+	snap.LoginTravel = storeToMap(s.LoginTravel.Profiles, identity)
+	s.LoginTravel.mu.Unlock()
+
+	s.NewAccountTracker.mu.Lock()
+	snap.NewAccounts = storeToMap(s.NewAccountTracker.CreationTimes, identity)
+	s.NewAccountTracker.mu.Unlock()
+
+	s.PostBruteForceLogins.mu.Lock()
+	snap.PostBruteForceLogins = storeToMap(s.PostBruteForceLogins.SuccessfulLogins, identity)
+	s.PostBruteForceLogins.mu.Unlock()
+
+	s.ProcessExecutionHistory.mu.Lock()
+	snap.ProcessExecutionHistory = ProcessHistorySnapshot{
+		History:      storeToMap(s.ProcessExecutionHistory.History, slices.Clone),
+		AlertedHosts: storeToMap(s.ProcessExecutionHistory.AlertedHosts, identity),
+	}
+	s.ProcessExecutionHistory.mu.Unlock()
+
+	s.StagedPayloads.mu.Lock()
+	snap.StagedPayloads = storeToMap(s.StagedPayloads.Payloads, identity)
+	s.StagedPayloads.mu.Unlock()
+
+	s.SuspiciousLoginTracker.mu.Lock()
+	snap.SuspiciousLogins = storeToMap(s.SuspiciousLoginTracker.Logins, identity)
+	s.SuspiciousLoginTracker.mu.Unlock()
+
+	s.PasswordSpray.mu.Lock()
+	snap.PasswordSpray = PasswordSpraySnapshot{
+		Attempts:   storeToMap(s.PasswordSpray.Attempts, slices.Clone),
+		AlertedIPs: storeToMap(s.PasswordSpray.AlertedIPs, identity),
+	}
+	s.PasswordSpray.mu.Unlock()
+
+	s.Risk.mu.Lock()
+	snap.Risk = storeToMap(s.Risk.Entities, func(risk EntityRisk) EntityRisk {
+		risk.Alerts = slices.Clone(risk.Alerts)
+		return risk
+	})
+	s.Risk.mu.Unlock()
+
+	s.Chains.mu.Lock()
+	snap.Chains = storeToMap(s.Chains.Events, slices.Clone)
+	s.Chains.mu.Unlock()
+
+	snap.Baselines = s.Baselines.Snapshot()
+
+	return snap
+}
+
+// Restore replaces the current state with the snapshot, dropping every
+// timestamped entry older than cutoff. Alert markers are only kept while
+// their key still has attempts inside the window, and learned login
+// countries are kept as if last seen when the snapshot was taken. It returns
+// the number of entries dropped.
+func (s *StateManager) Restore(snap Snapshot, cutoff time.Time) int {
+	if !snap.EventTime.IsZero() {
+		s.Observe(snap.EventTime)
+	}
+
+	expired := 0
+	fresh := func(t time.Time) bool {
+		if t.Before(cutoff) {
+			expired++
+			return false
+		}
+		return true
+	}
+
+	s.FailedLogins.mu.Lock()
+	s.FailedLogins.Attempts.Clear()
+	s.FailedLogins.AlertedIPs.Clear()
+	for key, attempts := range snap.FailedLogins.Attempts {
+		kept := filterSlice(attempts, fresh)
+		if len(kept) == 0 {
+			continue
+		}
+		last := kept[len(kept)-1]
+		s.FailedLogins.Attempts.Set(key, kept, last)
+		if snap.FailedLogins.AlertedIPs[key] {
+			s.FailedLogins.AlertedIPs.Set(key, true, last)
+		}
+	}
+	s.FailedLogins.mu.Unlock()
+
+	s.LoginLocations.mu.Lock()
+	s.LoginLocations.Locations.Clear()
+	for user, countries := range snap.LoginLocations {
+		set := make(map[string]bool, len(countries))
+		for _, country := range countries {
+			set[country] = true
+		}
+		s.LoginLocations.Locations.Set(user, set, snap.TakenAt)
+	}
+	s.LoginLocations.mu.Unlock()
+
+	// travel profiles are baselines like login countries, so they outlive
+	// the cutoff; the store's TTL still retires users who stop logging in.
+	s.LoginTravel.mu.Lock()
+	s.LoginTravel.Profiles.Clear()
+	for user, profile := range snap.LoginTravel {
+		s.LoginTravel.Profiles.Set(user, profile, profile.Last.Time)
+	}
+	s.LoginTravel.mu.Unlock()
+
+	s.NewAccountTracker.mu.Lock()
+	restoreTimes(s.NewAccountTracker.CreationTimes, snap.NewAccounts, fresh)
+	s.NewAccountTracker.mu.Unlock()
+
+	s.PostBruteForceLogins.mu.Lock()
+	s.PostBruteForceLogins.SuccessfulLogins.Clear()
+	for pid, info := range snap.PostBruteForceLogins {
+		if fresh(info.LoginTime) {
+			s.PostBruteForceLogins.SuccessfulLogins.Set(pid, info, info.LoginTime)
+		}
+	}
+	s.PostBruteForceLogins.mu.Unlock()
+
+	s.ProcessExecutionHistory.mu.Lock()
+	s.ProcessExecutionHistory.History.Clear()
+	for source, history := range snap.ProcessExecutionHistory.History {
+		kept := filterSlice(history, func(p ProcessExecution) bool { return fresh(p.Timestamp) })
+		if len(kept) > 0 {
+			s.ProcessExecutionHistory.History.Set(source, kept, kept[len(kept)-1].Timestamp)
+		}
+	}
+	restoreTimes(s.ProcessExecutionHistory.AlertedHosts, snap.ProcessExecutionHistory.AlertedHosts, fresh)
+	s.ProcessExecutionHistory.mu.Unlock()
+
+	s.StagedPayloads.mu.Lock()
+	restoreTimes(s.StagedPayloads.Payloads, snap.StagedPayloads, fresh)
+	s.StagedPayloads.mu.Unlock()
+
+	s.SuspiciousLoginTracker.mu.Lock()
+	restoreTimes(s.SuspiciousLoginTracker.Logins, snap.SuspiciousLogins, fresh)
+	s.SuspiciousLoginTracker.mu.Unlock()
+
+	s.PasswordSpray.mu.Lock()
+	s.PasswordSpray.Attempts.Clear()
+	s.PasswordSpray.AlertedIPs.Clear()
+	for ip, attempts := range snap.PasswordSpray.Attempts {
+		kept := filterSlice(attempts, func(a SprayAttempt) bool { return fresh(a.Timestamp) })
+		if len(kept) == 0 {
+			continue
+		}
+		last := kept[len(kept)-1].Timestamp
+		s.PasswordSpray.Attempts.Set(ip, kept, last)
+		if snap.PasswordSpray.AlertedIPs[ip] {
+			s.PasswordSpray.AlertedIPs.Set(ip, true, last)
+		}
+	}
+	s.PasswordSpray.mu.Unlock()
+
+	// risk decays on its own like baselines, so the cutoff doesn't apply
+	s.Risk.mu.Lock()
+	s.Risk.Entities.Clear()
+	for key, risk := range snap.Risk {
+		s.Risk.Entities.Set(key, risk, risk.UpdatedAt)
+	}
+	s.Risk.mu.Unlock()
+
+	s.Chains.mu.Lock()
+	s.Chains.Events.Clear()
+	for key, events := range snap.Chains {
+		kept := filterSlice(events, func(e model.Event) bool { return fresh(e.Timestamp) })
+		if len(kept) > 0 {
+			s.Chains.Events.Set(key, kept, kept[len(kept)-1].Timestamp)
+		}
+	}
+	s.Chains.mu.Unlock()
+
+	// baselines decay on their own, so the cutoff doesn't apply to them.
+	s.Baselines.Restore(snap.Baselines)
+
+	return expired
+}
+
+// SaveSnapshot writes the snapshot to path atomically, so a crash mid-write
+// never leaves a truncated file behind.
+func SaveSnapshot(path string, snap Snapshot) error {
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state snapshot: %w", err)
+	}
+
+	if err := writeFileAtomic(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write state snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// writeFileAtomic replaces path through a temporary file in the same
+// directory, so readers see either the old or the new content.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+
+	return nil
+}
+
+func LoadSnapshot(path string) (Snapshot, error) {
+	var snap Snapshot
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return snap, fmt.Errorf("failed to read state snapshot: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return snap, fmt.Errorf("failed to unmarshal state snapshot: %w", err)
+	}
+
+	if snap.Version != SnapshotVersion {
+		return snap, fmt.Errorf("unsupported state snapshot version %d (expected %d)", snap.Version, SnapshotVersion)
+	}
+
+	return snap, nil
+}
+
+func storeToMap[V, O any](store *Store[V], convert func(V) O) map[string]O {
+	out := make(map[string]O)
+	store.Range(func(key string, value V, _ time.Time) bool {
+		out[key] = convert(value)
+		return true
+	})
+	return out
+}
+
+func identity[V any](v V) V {
+	return v
+}
+
+func restoreTimes(store *Store[time.Time], in map[string]time.Time, keep func(time.Time) bool) {
+	store.Clear()
+	for key, t := range in {
+		if keep(t) {
+			store.Set(key, t, t)
+		}
+	}
+}
+
+func filterSlice[V any](in []V, keep func(V) bool) []V {
+	var out []V
+	for _, v := range in {
+		if keep(v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
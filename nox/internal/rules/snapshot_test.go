@@ -0,0 +1,128 @@
+package rules
+
+import (
+	"nox/internal/model"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRoundTripKeepsAttackChain(t *testing.T) {
+	baseTime := time.Date(2026, time.June, 19, 12, 0, 0, 0, time.UTC)
+	sourceIP := "203.0.113.10"
+	failedLogins := NewFailedLoginsRule()
+	newCountry := NewLoginLocationRule()
+
+	state := NewStateManager()
+	for i := range 3 {
+		failedLogins.Evaluate(failedLoginEvent(baseTime.Add(time.Duration(i)*time.Second), sourceIP, "root"), state)
+	}
+	newCountry.Evaluate(acceptedLoginEvent(baseTime, "198.51.100.7", "alice", "US"), state)
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := SaveSnapshot(path, state.Snapshot()); err != nil {
+		t.Fatalf("got save error %v, want nil", err)
+	}
+
+	snap, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatalf("got load error %v, want nil", err)
+	}
+
+	restored := NewStateManager()
+	if expired := restored.Restore(snap, baseTime.Add(-time.Hour)); expired != 0 {
+		t.Fatalf("got %d expired entries, want 0", expired)
+	}
+
+	// two more attempts after the restart complete the brute force.
+	var alerted bool
+	for i := 3; i < 5; i++ {
+		if failedLogins.Evaluate(failedLoginEvent(baseTime.Add(time.Duration(i)*time.Second), sourceIP, "root"), restored) != nil {
+			alerted = true
+		}
+	}
+	if !alerted {
+		t.Fatalf("got no alert after restore, want the brute force to complete")
+	}
+
+	if alert := newCountry.Evaluate(acceptedLoginEvent(baseTime.Add(time.Hour), "198.51.100.8", "alice", "US"), restored); alert != nil {
+		t.Fatalf("got %s alert for a learned country, want none", alert.RuleName)
+	}
+}
+
+func TestSnapshotRestoreExpiresStaleEntries(t *testing.T) {
+	baseTime := time.Date(2026, time.June, 19, 12, 0, 0, 0, time.UTC)
+
+	snap := NewStateManager().Snapshot()
+	snap.FailedLogins.Attempts = map[string][]time.Time{
+		"203.0.113.10|root":  {baseTime.Add(-2 * time.Hour), baseTime},
+		"203.0.113.11|admin": {baseTime.Add(-2 * time.Hour)},
+	}
+	snap.FailedLogins.AlertedIPs = map[string]bool{
+		"203.0.113.10|root":  true,
+		"203.0.113.11|admin": true,
+	}
+	snap.StagedPayloads = map[string]time.Time{
+		"/tmp/old.sh": baseTime.Add(-2 * time.Hour),
+		"/tmp/new.sh": baseTime,
+	}
+	snap.LoginLocations = map[string][]string{"alice": {"US"}}
+	snap.LoginTravel = map[string]TravelProfile{
+		"alice": {FirstSeen: baseTime.Add(-30 * 24 * time.Hour), Last: LoginFix{Time: baseTime.Add(-2 * time.Hour), Country: "US"}},
+	}
+	snap.Risk = map[string]EntityRisk{
+		"host:web-1": {Type: EntityHost, Entity: "web-1", Score: 80, UpdatedAt: baseTime.Add(-2 * time.Hour)},
+	}
+	snap.Chains = map[string][]model.Event{
+		"CorrelatedDownloadAndExecute|/tmp/new.sh": {
+			{Timestamp: baseTime.Add(-2 * time.Hour), EventType: "Process_Executed"},
+			{Timestamp: baseTime, EventType: "Process_Executed"},
+		},
+	}
+
+	state := NewStateManager()
+	expired := state.Restore(snap, baseTime.Add(-time.Hour))
+
+	if expired != 4 {
+		t.Fatalf("got %d expired entries, want 4", expired)
+	}
+	if attempts, _ := state.FailedLogins.Attempts.Get("203.0.113.10|root"); len(attempts) != 1 {
+		t.Fatalf("got %d attempts kept, want 1", len(attempts))
+	}
+	if _, ok := state.FailedLogins.Attempts.Get("203.0.113.11|admin"); ok {
+		t.Fatalf("got attempts for a fully expired key, want none")
+	}
+	if alerted, _ := state.FailedLogins.AlertedIPs.Get("203.0.113.11|admin"); alerted {
+		t.Fatalf("got alert marker for a fully expired key, want none")
+	}
+	if alerted, _ := state.FailedLogins.AlertedIPs.Get("203.0.113.10|root"); !alerted {
+		t.Fatalf("got no alert marker for a live key, want it kept")
+	}
+	if _, ok := state.StagedPayloads.Payloads.Get("/tmp/old.sh"); ok {
+		t.Fatalf("got stale staged payload, want it expired")
+	}
+	if countries, _ := state.LoginLocations.Locations.Get("alice"); !countries["US"] {
+		t.Fatalf("got learned country dropped, want it kept")
+	}
+	if profile, _ := state.LoginTravel.Profiles.Get("alice"); profile.Last.Country != "US" {
+		t.Fatalf("got travel profile dropped, want it kept")
+	}
+	if risk, _ := state.Risk.Entities.Get("host:web-1"); risk.Score != 80 {
+		t.Fatalf("got entity risk dropped, want it kept to decay on its own")
+	}
+	if chain, _ := state.Chains.Events.Get("CorrelatedDownloadAndExecute|/tmp/new.sh"); len(chain) != 1 {
+		t.Fatalf("got %d chain events kept, want 1", len(chain))
+	}
+}
+
+func TestLoadSnapshotRejectsUnknownVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte(`{"version": 99}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadSnapshot(path); err == nil {
+		t.Fatalf("got nil error, want unsupported version error")
+	}
+}
@@ -2,7 +2,12 @@ package rules
 
 import (
 	"fmt"
+	"math"
+	"net/netip"
+	"nox/internal/intel"
 	"nox/internal/model"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -24,6 +29,22 @@ func (r *FailedLoginsRule) Name() string {
 	return "TooManyFailedLogins"
 }
 
+func (r *FailedLoginsRule) Metadata() RuleMetadata {
+	return builtin(RuleMetadata{
+		ID:          "too-many-failed-logins",
+		Name:        r.Name(),
+		Kind:        RuleKindStateful,
+		Description: "Many failed SSH logins for one user from one source within a minute.",
+		Severity:    "HIGH",
+		Techniques:  []string{"T1110.001"},
+		Tags:        []string{"sshd", "brute-force"},
+		FalsePositives: []string{
+			"A user repeatedly mistyping their password",
+			"Automation retrying with stale credentials",
+		},
+	})
+}
+
 func (r *FailedLoginsRule) Evaluate(event model.Event, state *StateManager) *model.Alert {
 	if event.EventType != "SSHD_Failed_Password" {
 		return nil
@@ -42,23 +63,24 @@ func (r *FailedLoginsRule) Evaluate(event model.Event, state *StateManager) *mod
 
 	key := ip + "|" + user
 
-	if stateMgr.AlertedIPs[key] {
+	if alerted, _ := stateMgr.AlertedIPs.Get(key); alerted {
 		return nil
 	}
 
 	var recentAttempts []time.Time
 	now := event.Timestamp
-	for _, t := range stateMgr.Attempts[key] {
+	attempts, _ := stateMgr.Attempts.Get(key)
+	for _, t := range attempts {
 		if now.Sub(t) <= r.Window {
 			recentAttempts = append(recentAttempts, t)
 		}
 	}
 
 	recentAttempts = append(recentAttempts, event.Timestamp)
-	stateMgr.Attempts[key] = recentAttempts
+	stateMgr.Attempts.Set(key, recentAttempts, now)
 
 	if len(recentAttempts) >= r.Threshold {
-		stateMgr.AlertedIPs[key] = true
+		stateMgr.AlertedIPs.Set(key, true, now)
 
 		return &model.Alert{
 			RuleName:  r.Name(),
@@ -89,6 +111,22 @@ func (r *LoginLocationRule) Name() string {
 	return "NewCountryLogin"
 }
 
+func (r *LoginLocationRule) Metadata() RuleMetadata {
+	return builtin(RuleMetadata{
+		ID:          "new-country-login",
+		Name:        r.Name(),
+		Kind:        RuleKindStateful,
+		Description: "A user logged in from a country they have not logged in from before.",
+		Severity:    "MEDIUM",
+		Techniques:  []string{"T1078"},
+		Tags:        []string{"sshd", "geoip"},
+		FalsePositives: []string{
+			"Travel",
+			"A new VPN egress point",
+		},
+	})
+}
+
 func (r *LoginLocationRule) Evaluate(event model.Event, state *StateManager) *model.Alert {
 	if event.EventType != "SSHD_Accepted_Password" {
 		return nil
@@ -104,12 +142,17 @@ func (r *LoginLocationRule) Evaluate(event model.Event, state *StateManager) *mo
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, ok := s.Locations[user]; !ok {
-		s.Locations[user] = make(map[string]bool)
+	countries, ok := s.Locations.Get(user)
+	if !ok {
+		countries = make(map[string]bool)
 	}
 
-	if !s.Locations[user][country] {
-		s.Locations[user][country] = true
+	// every login refreshes the user's baseline so active users never age out.
+	known := countries[country]
+	countries[country] = true
+	s.Locations.Set(user, countries, event.Timestamp)
+
+	if !known {
 		return &model.Alert{
 			RuleName:  r.Name(),
 			Message:   fmt.Sprintf("User '%s' logged in from a new country: %s (IP: %s)", user, country, event.Source),
@@ -145,6 +188,21 @@ func (r *RapidProcessExecutionRule) Name() string {
 	return "RapidProcessExecution"
 }
 
+func (r *RapidProcessExecutionRule) Metadata() RuleMetadata {
+	return builtin(RuleMetadata{
+		ID:          "rapid-process-execution",
+		Name:        r.Name(),
+		Kind:        RuleKindStateful,
+		Description: "A burst of process executions from one host, typical of scripted discovery or exploitation.",
+		Severity:    "MEDIUM",
+		Techniques:  []string{"T1059.004"},
+		Tags:        []string{"process"},
+		FalsePositives: []string{
+			"Build jobs, package installs and configuration management runs",
+		},
+	})
+}
+
 func (r *RapidProcessExecutionRule) Evaluate(event model.Event, state *StateManager) *model.Alert {
 	if event.EventType != "Process_Executed" {
 		return nil
@@ -167,7 +225,8 @@ func (r *RapidProcessExecutionRule) Evaluate(event model.Event, state *StateMana
 		UID:         event.Metadata["uid"],
 	}
 
-	history := append(s.History[source], currentProc)
+	previous, _ := s.History.Get(source)
+	history := append(previous, currentProc)
 
 	var recentHistory []ProcessExecution
 	for _, proc := range history {
@@ -176,17 +235,17 @@ func (r *RapidProcessExecutionRule) Evaluate(event model.Event, state *StateMana
 		}
 	}
 
-	s.History[source] = recentHistory
+	s.History.Set(source, recentHistory, now)
 	recentCount := len(recentHistory)
 
 	if recentCount >= r.Threshold {
-		if lastAlertedTime, ok := s.AlertedHosts[source]; ok {
+		if lastAlertedTime, ok := s.AlertedHosts.Get(source); ok {
 			if now.Sub(lastAlertedTime) < r.Cooldown {
 				return nil
 			}
 		}
 
-		s.AlertedHosts[source] = now
+		s.AlertedHosts.Set(source, now, now)
 
 		return &model.Alert{
 			RuleName:  r.Name(),
@@ -206,41 +265,84 @@ func (r *RapidProcessExecutionRule) Evaluate(event model.Event, state *StateMana
 
 // -- IP Watchlist Rules ---
 
-type IPWatchlistRule struct{}
+// ThreatIntelRule matches the event's source IP, the URLs, domains and IPs in
+// its command line, and any file hashes against loaded threat intel.
+type ThreatIntelRule struct{}
+
+func NewThreatIntelRule() Rule {
+	return &ThreatIntelRule{}
+}
 
-func NewIPWatchlistRule() Rule {
-	return &IPWatchlistRule{}
+func (r *ThreatIntelRule) Name() string {
+	return "ThreatIntelMatch"
 }
 
-func (r *IPWatchlistRule) Name() string {
-	return "IPWatchlistMatch"
+func (r *ThreatIntelRule) Metadata() RuleMetadata {
+	return builtin(RuleMetadata{
+		ID:          "threat-intel-match",
+		Name:        r.Name(),
+		Kind:        RuleKindStateful,
+		Description: "An event referenced an IP, domain, URL or file hash from a threat intel feed.",
+		Severity:    "HIGH",
+		Techniques:  []string{"T1071", "T1105"},
+		Tags:        []string{"intel"},
+		FalsePositives: []string{
+			"Stale or low-confidence indicators",
+			"Shared hosting and CDN addresses",
+		},
+	})
 }
 
-func (r *IPWatchlistRule) Evaluate(event model.Event, state *StateManager) *model.Alert {
-	if event.Source == "" {
-		return nil // cant check events without a source IP
+func (r *ThreatIntelRule) Evaluate(event model.Event, state *StateManager) *model.Alert {
+	db := state.ThreatIntel.Database()
+	if db == nil {
+		return nil
 	}
 
-	s := state.IPWatchlist
-	s.mu.Lock()
-	isMatch, found := s.Watchlist[event.Source]
-	s.mu.Unlock()
+	matches := db.MatchEvent(event)
+	if len(matches) == 0 {
+		return nil
+	}
 
-	if found && isMatch {
-		return &model.Alert{
-			RuleName:  r.Name(),
-			Message:   fmt.Sprintf("Traffic detected from a known bad IP address: %s", event.Source),
-			Severity:  "HIGH",
-			Timestamp: event.Timestamp,
-			Source:    event.Source,
-			Metadata: map[string]string{
-				"mitre_tactic": "TA0011",
-				"event_type":   event.EventType,
-			},
+	// report the most trusted indicator; the rest are counted.
+	best := matches[0]
+	for _, m := range matches[1:] {
+		if m.Indicator.Confidence > best.Indicator.Confidence {
+			best = m
 		}
 	}
+	indicator := best.Indicator
 
-	return nil
+	severity := "HIGH"
+	if indicator.Confidence < intel.DefaultConfidence {
+		severity = "MEDIUM"
+	}
+
+	alert := &model.Alert{
+		RuleName:  r.Name(),
+		Message:   fmt.Sprintf("Event matched a known bad %s from threat intel feed %s: %s", indicator.Type, indicator.Feed, best.Observable),
+		Severity:  severity,
+		Timestamp: event.Timestamp,
+		Source:    event.Source,
+		Metadata: map[string]string{
+			"mitre_tactic":       "TA0011",
+			"event_type":         event.EventType,
+			"matched_observable": best.Observable,
+			"indicator_type":     string(indicator.Type),
+			"indicator_value":    indicator.Value,
+			"intel_feed":         indicator.Feed,
+			"intel_confidence":   strconv.Itoa(indicator.Confidence),
+			"intel_match_count":  strconv.Itoa(len(matches)),
+		},
+	}
+	if indicator.Description != "" {
+		alert.Metadata["intel_description"] = indicator.Description
+	}
+	if !indicator.Expires.IsZero() {
+		alert.Metadata["intel_expires"] = indicator.Expires.Format(time.RFC3339)
+	}
+
+	return alert
 }
 
 // --- PasswordSpray Rule ---
@@ -251,14 +353,29 @@ type PasswordSprayRule struct {
 }
 
 type SprayAttempt struct {
-	Timestamp time.Time
-	User      string
+	Timestamp time.Time `json:"timestamp"`
+	User      string    `json:"user"`
 }
 
 func (r *PasswordSprayRule) Name() string {
 	return "PasswordSpray"
 }
 
+func (r *PasswordSprayRule) Metadata() RuleMetadata {
+	return builtin(RuleMetadata{
+		ID:          "password-spray",
+		Name:        r.Name(),
+		Kind:        RuleKindStateful,
+		Description: "Failed SSH logins from one source against many distinct users within a minute.",
+		Severity:    "HIGH",
+		Techniques:  []string{"T1110.003"},
+		Tags:        []string{"sshd", "brute-force"},
+		FalsePositives: []string{
+			"A misconfigured jump host trying several service accounts",
+		},
+	})
+}
+
 func NewPasswordSprayRule() Rule {
 	return &PasswordSprayRule{
 		Window:    60 * time.Second,
@@ -282,13 +399,14 @@ func (r *PasswordSprayRule) Evaluate(event model.Event, state *StateManager) *mo
 	stateMgr.mu.Lock()
 	defer stateMgr.mu.Unlock()
 
-	if stateMgr.AlertedIPs[ip] {
+	if alerted, _ := stateMgr.AlertedIPs.Get(ip); alerted {
 		return nil
 	}
 
 	var recentAttempts []SprayAttempt
 	now := event.Timestamp
-	for _, t := range stateMgr.Attempts[ip] {
+	attempts, _ := stateMgr.Attempts.Get(ip)
+	for _, t := range attempts {
 		if now.Sub(t.Timestamp) <= r.Window {
 			recentAttempts = append(recentAttempts, SprayAttempt{
 				Timestamp: t.Timestamp,
@@ -302,7 +420,7 @@ func (r *PasswordSprayRule) Evaluate(event model.Event, state *StateManager) *mo
 		User:      user,
 	})
 
-	stateMgr.Attempts[ip] = recentAttempts
+	stateMgr.Attempts.Set(ip, recentAttempts, now)
 
 	users := make(map[string]bool)
 	for _, attempt := range recentAttempts {
@@ -310,7 +428,7 @@ func (r *PasswordSprayRule) Evaluate(event model.Event, state *StateManager) *mo
 	}
 
 	if len(users) >= r.Threshold {
-		stateMgr.AlertedIPs[ip] = true
+		stateMgr.AlertedIPs.Set(ip, true, now)
 
 		return &model.Alert{
 			RuleName:  r.Name(),
@@ -327,3 +445,275 @@ func (r *PasswordSprayRule) Evaluate(event model.Event, state *StateManager) *mo
 
 	return nil
 }
+
+// --- Anomaly Rule ---
+
+// AnomalyRule scores events against the learned baselines of the user and
+// host involved and alerts on the strongest deviation.
+type AnomalyRule struct{}
+
+func NewAnomalyRule() Rule {
+	return &AnomalyRule{}
+}
+
+func (r *AnomalyRule) Name() string {
+	return "Anomaly"
+}
+
+func (r *AnomalyRule) Metadata() RuleMetadata {
+	return builtin(RuleMetadata{
+		ID:          "behavioral-anomaly",
+		Name:        r.Name(),
+		Kind:        RuleKindStateful,
+		Description: "An event deviated from the learned baseline of its user or host.",
+		Severity:    "HIGH",
+		Techniques:  nil,
+		Tags:        []string{"baseline"},
+		FalsePositives: []string{
+			"Legitimate changes in routine, such as a new shift or a new deployment",
+		},
+	})
+}
+
+func (r *AnomalyRule) Evaluate(event model.Event, state *StateManager) *model.Alert {
+	anomalies := state.Baselines.Observe(event)
+	if len(anomalies) == 0 {
+		return nil
+	}
+
+	top := anomalies[0]
+	for _, a := range anomalies[1:] {
+		if a.Score > top.Score {
+			top = a
+		}
+	}
+
+	severity := "LOW"
+	switch {
+	case top.Score >= 0.9:
+		severity = "HIGH"
+	case top.Score >= 0.8:
+		severity = "MEDIUM"
+	}
+
+	metadata := map[string]string{
+		"anomaly_type":  top.Type,
+		"anomaly_score": fmt.Sprintf("%.2f", top.Score),
+		"entity":        top.Entity,
+		"anomaly_count": strconv.Itoa(len(anomalies)),
+	}
+	for k, v := range top.Metadata {
+		metadata[k] = v
+	}
+
+	return &model.Alert{
+		RuleName:  r.Name(),
+		Message:   fmt.Sprintf("Behavioral anomaly for %s: %s", top.Entity, top.Detail),
+		Severity:  severity,
+		Timestamp: event.Timestamp,
+		Source:    event.Source,
+		Metadata:  metadata,
+	}
+}
+
+// --- Impossible Travel Rule ---
+
+const earthRadiusKm = 6371.0
+
+type ImpossibleTravelConfig struct {
+	// MaxSpeedKmh is the fastest plausible travel between two logins;
+	// commercial flights cruise at about 900 km/h.
+	MaxSpeedKmh float64
+	// MinDistanceKm ignores short hops, which are usually GeoIP imprecision.
+	MinDistanceKm float64
+	// LearningPeriod suppresses alerts until nox has known a user this long,
+	// so a fresh deployment doesn't alert on everyone's first trip.
+	LearningPeriod time.Duration
+	// AllowedASNs and AllowedNetworks are VPN and proxy egress points whose
+	// location says nothing about where the user is.
+	AllowedASNs     []string
+	AllowedNetworks []netip.Prefix
+}
+
+func DefaultImpossibleTravelConfig() ImpossibleTravelConfig {
+	return ImpossibleTravelConfig{
+		MaxSpeedKmh:    1000,
+		MinDistanceKm:  500,
+		LearningPeriod: 7 * 24 * time.Hour,
+	}
+}
+
+type ImpossibleTravelRule struct {
+	cfg         ImpossibleTravelConfig
+	allowedASNs map[string]bool
+}
+
+func NewImpossibleTravelRule(cfg ImpossibleTravelConfig) Rule {
+	allowed := make(map[string]bool, len(cfg.AllowedASNs))
+	for _, asn := range cfg.AllowedASNs {
+		allowed[strings.TrimPrefix(strings.ToUpper(asn), "AS")] = true
+	}
+	return &ImpossibleTravelRule{cfg: cfg, allowedASNs: allowed}
+}
+
+func (r *ImpossibleTravelRule) Name() string {
+	return "ImpossibleTravel"
+}
+
+func (r *ImpossibleTravelRule) Metadata() RuleMetadata {
+	return builtin(RuleMetadata{
+		ID:          "impossible-travel",
+		Name:        r.Name(),
+		Kind:        RuleKindStateful,
+		Description: "Two logins by one user from places too far apart to travel between in the time between them.",
+		Severity:    "HIGH",
+		Techniques:  []string{"T1078"},
+		Tags:        []string{"sshd", "geoip"},
+		FalsePositives: []string{
+			"VPNs and proxies that are not allowlisted",
+			"GeoIP inaccuracies for mobile and satellite networks",
+		},
+	})
+}
+
+func (r *ImpossibleTravelRule) Evaluate(event model.Event, state *StateManager) *model.Alert {
+	if event.EventType != "SSHD_Accepted_Password" {
+		return nil
+	}
+
+	user := event.Metadata["user"]
+	fix, ok := loginFix(event)
+	if user == "" || !ok || r.allowlisted(event) {
+		return nil // cant place the user, or the location is a VPN exit
+	}
+
+	s := state.LoginTravel
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	profile, known := s.Profiles.Get(user)
+	if !known {
+		profile.FirstSeen = fix.Time
+	}
+	prev := profile.Last
+
+	// out-of-order logins never move the baseline backwards.
+	if !known || !fix.Time.Before(prev.Time) {
+		profile.Last = fix
+	}
+	s.Profiles.Set(user, profile, event.Timestamp)
+
+	if !known || fix.Time.Sub(profile.FirstSeen) < r.cfg.LearningPeriod {
+		return nil
+	}
+
+	distance := haversineKm(prev.Latitude, prev.Longitude, fix.Latitude, fix.Longitude)
+	if distance < r.cfg.MinDistanceKm {
+		return nil
+	}
+
+	elapsed := fix.Time.Sub(prev.Time)
+	if elapsed < 0 {
+		elapsed = -elapsed
+	}
+	speed := math.Inf(1)
+	if elapsed > 0 {
+		speed = distance / elapsed.Hours()
+	}
+	if speed <= r.cfg.MaxSpeedKmh {
+		return nil
+	}
+
+	return &model.Alert{
+		RuleName: r.Name(),
+		Message: fmt.Sprintf("User '%s' logged in from %s %s after logging in from %s %s: %.0f km apart",
+			user, describeFix(fix), fix.SourceIP, describeFix(prev), humanizeDuration(elapsed), distance),
+		Severity:  "HIGH",
+		Timestamp: event.Timestamp,
+		Source:    event.Source,
+		Metadata: map[string]string{
+			"mitre_technique_id": "T1078",
+			"user":               user,
+			"previous_source":    prev.SourceIP,
+			"previous_country":   prev.Country,
+			"previous_login":     prev.Time.Format(time.RFC3339),
+			"distance_km":        fmt.Sprintf("%.0f", distance),
+			"speed_kmh":          formatSpeed(speed),
+		},
+	}
+}
+
+func (r *ImpossibleTravelRule) allowlisted(event model.Event) bool {
+	if r.allowedASNs[event.Metadata["asn"]] {
+		return true
+	}
+
+	addr, err := netip.ParseAddr(event.Source)
+	if err != nil {
+		return false
+	}
+	for _, prefix := range r.cfg.AllowedNetworks {
+		if prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// loginFix reads the coordinates GeoIP enrichment added to the event.
+func loginFix(event model.Event) (LoginFix, bool) {
+	lat, err := strconv.ParseFloat(event.Metadata["latitude"], 64)
+	if err != nil {
+		return LoginFix{}, false
+	}
+	lon, err := strconv.ParseFloat(event.Metadata["longitude"], 64)
+	if err != nil {
+		return LoginFix{}, false
+	}
+
+	return LoginFix{
+		Time:      event.Timestamp,
+		SourceIP:  event.Source,
+		Latitude:  lat,
+		Longitude: lon,
+		Country:   event.Metadata["country"],
+		City:      event.Metadata["city"],
+	}, true
+}
+
+// haversineKm is the great-circle distance between two points.
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+func describeFix(fix LoginFix) string {
+	switch {
+	case fix.City != "" && fix.Country != "":
+		return fix.City + ", " + fix.Country
+	case fix.Country != "":
+		return fix.Country
+	default:
+		return fmt.Sprintf("(%.2f, %.2f)", fix.Latitude, fix.Longitude)
+	}
+}
+
+func humanizeDuration(d time.Duration) string {
+	if d == 0 {
+		return "at the same time"
+	}
+	return d.Round(time.Second).String() + " earlier"
+}
+
+func formatSpeed(speed float64) string {
+	if math.IsInf(speed, 1) {
+		return "inf"
+	}
+	return fmt.Sprintf("%.0f", speed)
+}
@@ -1,6 +1,8 @@
 package rules
 
 import (
+	"net/netip"
+	"nox/internal/intel"
 	"nox/internal/model"
 	"testing"
 	"time"
@@ -233,3 +235,154 @@ func acceptedLoginEvent(timestamp time.Time, source, user, country string) model
 		},
 	}
 }
+
+// -- threat intel -- //
+
+type staticIntel struct{ db *intel.Database }
+
+func (s staticIntel) Database() *intel.Database { return s.db }
+
+func TestThreatIntelRuleEvaluate_AlertCarriesIndicatorContext(t *testing.T) {
+	now := time.Now()
+	rule := NewThreatIntelRule()
+	state := NewStateManager()
+	state.ThreatIntel.Set(staticIntel{intel.NewDatabase([]intel.Indicator{
+		{Type: intel.TypeIP, Value: "185.193.125.0/24", Feed: "apt-feed", Confidence: 90, Description: "APT C2 range", Expires: now.Add(time.Hour)},
+		{Type: intel.TypeIP, Value: "185.193.125.241", Feed: "tor-exits", Confidence: 40},
+	})})
+
+	alert := rule.Evaluate(failedLoginEvent(now, "185.193.125.241", "root"), state)
+	if alert == nil {
+		t.Fatalf("got nil alert, want ThreatIntelMatch")
+	}
+
+	want := map[string]string{
+		"matched_observable": "185.193.125.241",
+		"indicator_value":    "185.193.125.241/32",
+		"intel_feed":         "tor-exits",
+		"intel_confidence":   "40",
+	}
+	for field, value := range want {
+		if got := alert.Metadata[field]; got != value {
+			t.Fatalf("got %s=%q, want %q", field, got, value)
+		}
+	}
+	if alert.Severity != "MEDIUM" {
+		t.Fatalf("got severity %s for a low confidence indicator, want MEDIUM", alert.Severity)
+	}
+
+	alert = rule.Evaluate(failedLoginEvent(now, "185.193.125.9", "root"), state)
+	if alert == nil || alert.Metadata["intel_description"] != "APT C2 range" || alert.Severity != "HIGH" {
+		t.Fatalf("got %+v, want a HIGH alert from the CIDR indicator", alert)
+	}
+
+	if alert := rule.Evaluate(failedLoginEvent(now.Add(2*time.Hour), "185.193.125.9", "root"), state); alert != nil {
+		t.Fatalf("got %+v after the indicator expired, want nil", alert)
+	}
+}
+
+func TestThreatIntelRuleEvaluate_NoIntelLoaded(t *testing.T) {
+	if alert := NewThreatIntelRule().Evaluate(failedLoginEvent(time.Now(), "185.193.125.241", "root"), NewStateManager()); alert != nil {
+		t.Fatalf("got %+v, want nil", alert)
+	}
+}
+
+// -- impossible travel -- //
+
+func locatedLoginEvent(timestamp time.Time, source, user, lat, lon, country string) model.Event {
+	event := acceptedLoginEvent(timestamp, source, user, country)
+	event.Metadata["latitude"] = lat
+	event.Metadata["longitude"] = lon
+	return event
+}
+
+func TestHaversineKm(t *testing.T) {
+	// London to New York is about 5570 km.
+	if got := haversineKm(51.5074, -0.1278, 40.7128, -74.0060); got < 5550 || got > 5590 {
+		t.Fatalf("got %.0f km, want about 5570", got)
+	}
+	if got := haversineKm(10, 10, 10, 10); got != 0 {
+		t.Fatalf("got %.0f km for the same point, want 0", got)
+	}
+}
+
+func TestImpossibleTravelRuleEvaluate(t *testing.T) {
+	baseTime := time.Date(2026, time.June, 19, 12, 0, 0, 0, time.UTC)
+	cfg := DefaultImpossibleTravelConfig()
+	cfg.LearningPeriod = 24 * time.Hour
+	cfg.AllowedASNs = []string{"AS9009"}
+	cfg.AllowedNetworks = []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}
+
+	london := func(ts time.Time) model.Event {
+		return locatedLoginEvent(ts, "81.2.69.142", "alice", "51.5074", "-0.1278", "GB")
+	}
+	newYork := func(ts time.Time) model.Event {
+		return locatedLoginEvent(ts, "216.160.83.56", "alice", "40.7128", "-74.0060", "US")
+	}
+	vpn := func(e model.Event) model.Event {
+		e.Metadata["asn"] = "9009"
+		return e
+	}
+
+	tests := []struct {
+		name      string
+		events    []model.Event
+		wantAlert bool
+	}{
+		{
+			name:      "London then New York an hour later",
+			events:    []model.Event{london(baseTime.Add(-48 * time.Hour)), london(baseTime), newYork(baseTime.Add(time.Hour))},
+			wantAlert: true,
+		},
+		{
+			name:   "London then New York a day later",
+			events: []model.Event{london(baseTime.Add(-48 * time.Hour)), london(baseTime), newYork(baseTime.Add(24 * time.Hour))},
+		},
+		{
+			name:   "user still in learning period",
+			events: []model.Event{london(baseTime), newYork(baseTime.Add(time.Hour))},
+		},
+		{
+			name:   "second login through an allowlisted VPN ASN",
+			events: []model.Event{london(baseTime.Add(-48 * time.Hour)), london(baseTime), vpn(newYork(baseTime.Add(time.Hour)))},
+		},
+		{
+			name: "second login from an allowlisted network",
+			events: []model.Event{
+				london(baseTime.Add(-48 * time.Hour)),
+				london(baseTime),
+				locatedLoginEvent(baseTime.Add(time.Hour), "192.0.2.10", "alice", "40.7128", "-74.0060", "US"),
+			},
+		},
+		{
+			name: "short hop inside GeoIP error",
+			events: []model.Event{
+				london(baseTime.Add(-48 * time.Hour)),
+				london(baseTime),
+				locatedLoginEvent(baseTime.Add(time.Minute), "81.2.69.160", "alice", "52.4862", "-1.8904", "GB"), // Birmingham
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := NewImpossibleTravelRule(cfg)
+			state := NewStateManager()
+
+			var alert *model.Alert
+			for _, event := range tt.events {
+				alert = rule.Evaluate(event, state)
+			}
+
+			if tt.wantAlert && alert == nil {
+				t.Fatalf("got nil alert, want ImpossibleTravel")
+			}
+			if !tt.wantAlert && alert != nil {
+				t.Fatalf("got alert %q, want nil", alert.Message)
+			}
+			if alert != nil && (alert.Metadata["previous_source"] != "81.2.69.142" || alert.Metadata["distance_km"] == "") {
+				t.Fatalf("got metadata %v, want previous login context", alert.Metadata)
+			}
+		})
+	}
+}
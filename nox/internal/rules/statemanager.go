@@ -1,105 +1,295 @@
 package rules
 
 import (
+	"context"
+	"nox/internal/baseline"
+	"nox/internal/intel"
+	"nox/internal/model"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// DefaultMaxStateEntries bounds every state store so a flood of unique
+// source IPs or users can't grow memory without limit.
+const DefaultMaxStateEntries = 100_000
+
+// State TTLs are at least as long as the longest rule window reading the
+// store, so the janitor never drops a key a rule could still match.
+const (
+	failedLoginTTL      = 10 * time.Minute
+	alertedMarkerTTL    = time.Hour
+	loginLocationTTL    = 90 * 24 * time.Hour
+	newAccountTTL       = time.Hour
+	postBruteForceTTL   = 10 * time.Minute
+	processHistoryTTL   = 5 * time.Minute
+	stagedPayloadTTL    = 10 * time.Minute
+	suspiciousLoginTTL  = 10 * time.Minute
+	passwordSprayTTL    = 10 * time.Minute
+	chainTTL            = time.Hour
+	defaultJanitorEvery = 30 * time.Second
+)
+
 type FailedLoginState struct {
 	mu         sync.Mutex
-	Attempts   map[string][]time.Time // Key: IP Address, Value: List of recent attempt timestamps.
-	AlertedIPs map[string]bool        // Key: IP Address, Value: True if an alert has been fired.
+	Attempts   *Store[[]time.Time] // Key: IP Address|User, Value: List of recent attempt timestamps.
+	AlertedIPs *Store[bool]        // Key: IP Address|User, Value: True if an alert has been fired.
 }
 
-type IPWatchlistState struct {
-	mu        sync.Mutex
-	Watchlist map[string]bool // Key: IP Address
+// IntelSource supplies the current threat intel. intel.Manager swaps in a
+// new database on every refresh, so rules ask for it per event.
+type IntelSource interface {
+	Database() *intel.Database
 }
 
-func (s *IPWatchlistState) Set(watchlist map[string]bool) {
+type ThreatIntelState struct {
+	mu     sync.Mutex
+	source IntelSource
+}
+
+func (s *ThreatIntelState) Set(source IntelSource) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.Watchlist = watchlist
+	s.source = source
+}
+
+// Database returns the current indicators, or nil when no intel is loaded.
+func (s *ThreatIntelState) Database() *intel.Database {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.source == nil {
+		return nil
+	}
+	return s.source.Database()
 }
 
 type LoginLocationState struct {
 	mu        sync.Mutex
-	Locations map[string]map[string]bool // Key: Username, Value: Set of country codes.
+	Locations *Store[map[string]bool] // Key: Username, Value: Set of country codes.
+}
+
+type LoginTravelState struct {
+	mu       sync.Mutex
+	Profiles *Store[TravelProfile] // Key: Username
 }
 
 type NewAccountState struct {
 	mu            sync.Mutex
-	CreationTimes map[string]time.Time // Key: Username, Value: Timestamp of creation.
+	CreationTimes *Store[time.Time] // Key: Username, Value: Timestamp of creation.
 }
 
 type PostBruteForceLoginState struct {
 	mu               sync.Mutex
-	SuccessfulLogins map[string]PostBruteForceInfo // Key: SSHD PID of the successful login session.
+	SuccessfulLogins *Store[PostBruteForceInfo] // Key: SSHD PID of the successful login session.
 }
 
 type ProcessExecutionHistoryState struct {
 	mu           sync.Mutex
-	History      map[string][]ProcessExecution // Key: Source Host (e.g., "localhost")
-	AlertedHosts map[string]time.Time
+	History      *Store[[]ProcessExecution] // Key: Source Host (e.g., "localhost")
+	AlertedHosts *Store[time.Time]
 }
 
 type StagedPayloadState struct {
 	mu       sync.Mutex
-	Payloads map[string]time.Time // Key: Filepath, Value: Timestamp of download.
+	Payloads *Store[time.Time] // Key: Filepath, Value: Timestamp of download.
 }
 
 type SuspiciousLoginState struct {
 	mu     sync.Mutex
-	Logins map[string]time.Time // Key: Source IP, Value: Timestamp of the suspicious login.
+	Logins *Store[time.Time] // Key: Source IP, Value: Timestamp of the suspicious login.
 }
 
 type PasswordSprayState struct {
 	mu         sync.Mutex
-	Attempts   map[string][]SprayAttempt
-	AlertedIPs map[string]bool
+	Attempts   *Store[[]SprayAttempt]
+	AlertedIPs *Store[bool]
+}
+
+// maxChainEvents bounds the events kept per chain in progress, newest kept.
+const maxChainEvents = 10
+
+// ChainState keeps the earlier events of the correlation chains in
+// progress, so the alert that completes a chain carries all of them.
+type ChainState struct {
+	mu     sync.Mutex
+	Events *Store[[]model.Event] // Key: rule name|chain key, e.g. the staged file path.
+}
+
+// remember adds events to the chain at key.
+func (s *ChainState) remember(rule, key string, events ...model.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chain, _ := s.Events.Get(rule + "|" + key)
+	chain = append(slices.Clone(chain), events...)
+	if len(chain) > maxChainEvents {
+		chain = chain[len(chain)-maxChainEvents:]
+	}
+	if len(chain) > 0 {
+		s.Events.Set(rule+"|"+key, chain, chain[len(chain)-1].Timestamp)
+	}
+}
+
+// take returns the events of the chain at key, oldest first, and forgets
+// the chain.
+func (s *ChainState) take(rule, key string) []model.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chain, _ := s.Events.Get(rule + "|" + key)
+	s.Events.Delete(rule + "|" + key)
+	return chain
 }
 
 type StateManager struct {
 	FailedLogins            *FailedLoginState
-	IPWatchlist             *IPWatchlistState
+	ThreatIntel             *ThreatIntelState
 	LoginLocations          *LoginLocationState
+	LoginTravel             *LoginTravelState
 	NewAccountTracker       *NewAccountState
 	ProcessExecutionHistory *ProcessExecutionHistoryState
 	PostBruteForceLogins    *PostBruteForceLoginState
 	StagedPayloads          *StagedPayloadState
 	SuspiciousLoginTracker  *SuspiciousLoginState
 	PasswordSpray           *PasswordSprayState
+	Risk                    *RiskState
+	Chains                  *ChainState
+	Baselines               *baseline.Profiler
+
+	clock atomic.Int64 // latest event time seen, in Unix nanoseconds
+}
+
+// sweeper is the part of Store the janitor needs, independent of value type.
+type sweeper interface {
+	Name() string
+	Sweep(now time.Time) int
 }
 
 func NewStateManager() *StateManager {
+	return NewBoundedStateManager(DefaultMaxStateEntries)
+}
+
+// NewBoundedStateManager creates a StateManager whose stores each hold at
+// most maxEntries keys.
+func NewBoundedStateManager(maxEntries int) *StateManager {
 	return &StateManager{
 		FailedLogins: &FailedLoginState{
-			Attempts:   make(map[string][]time.Time),
-			AlertedIPs: make(map[string]bool),
+			Attempts:   NewStore[[]time.Time]("failed_logins_attempts", failedLoginTTL, maxEntries),
+			AlertedIPs: NewStore[bool]("failed_logins_alerted", alertedMarkerTTL, maxEntries),
 		},
-		IPWatchlist: &IPWatchlistState{},
+		ThreatIntel: &ThreatIntelState{},
 		LoginLocations: &LoginLocationState{
-			Locations: make(map[string]map[string]bool),
+			Locations: NewStore[map[string]bool]("login_locations", loginLocationTTL, maxEntries),
+		},
+		LoginTravel: &LoginTravelState{
+			Profiles: NewStore[TravelProfile]("login_travel", loginLocationTTL, maxEntries),
 		},
 		NewAccountTracker: &NewAccountState{
-			CreationTimes: make(map[string]time.Time),
+			CreationTimes: NewStore[time.Time]("new_accounts", newAccountTTL, maxEntries),
 		},
 		PostBruteForceLogins: &PostBruteForceLoginState{
-			SuccessfulLogins: make(map[string]PostBruteForceInfo),
+			SuccessfulLogins: NewStore[PostBruteForceInfo]("post_brute_force_logins", postBruteForceTTL, maxEntries),
 		},
 		ProcessExecutionHistory: &ProcessExecutionHistoryState{
-			History:      make(map[string][]ProcessExecution),
-			AlertedHosts: make(map[string]time.Time),
+			History:      NewStore[[]ProcessExecution]("process_history", processHistoryTTL, maxEntries),
+			AlertedHosts: NewStore[time.Time]("process_alerted_hosts", processHistoryTTL, maxEntries),
 		},
 		StagedPayloads: &StagedPayloadState{
-			Payloads: make(map[string]time.Time),
+			Payloads: NewStore[time.Time]("staged_payloads", stagedPayloadTTL, maxEntries),
 		},
 		SuspiciousLoginTracker: &SuspiciousLoginState{
-			Logins: make(map[string]time.Time),
+			Logins: NewStore[time.Time]("suspicious_logins", suspiciousLoginTTL, maxEntries),
 		},
 		PasswordSpray: &PasswordSprayState{
-			Attempts:   make(map[string][]SprayAttempt),
-			AlertedIPs: make(map[string]bool),
+			Attempts:   NewStore[[]SprayAttempt]("password_spray_attempts", passwordSprayTTL, maxEntries),
+			AlertedIPs: NewStore[bool]("password_spray_alerted", alertedMarkerTTL, maxEntries),
 		},
+		Risk: &RiskState{
+			Entities: NewStore[EntityRisk]("entity_risk", riskTTL, maxEntries),
+		},
+		Chains: &ChainState{
+			Events: NewStore[[]model.Event]("chain_events", chainTTL, maxEntries),
+		},
+		Baselines: baseline.NewProfiler(baseline.DefaultConfig()),
+	}
+}
+
+func (s *StateManager) stores() []sweeper {
+	return []sweeper{
+		s.FailedLogins.Attempts,
+		s.FailedLogins.AlertedIPs,
+		s.LoginLocations.Locations,
+		s.LoginTravel.Profiles,
+		s.NewAccountTracker.CreationTimes,
+		s.PostBruteForceLogins.SuccessfulLogins,
+		s.ProcessExecutionHistory.History,
+		s.ProcessExecutionHistory.AlertedHosts,
+		s.StagedPayloads.Payloads,
+		s.SuspiciousLoginTracker.Logins,
+		s.PasswordSpray.Attempts,
+		s.PasswordSpray.AlertedIPs,
+		s.Risk.Entities,
+		s.Chains.Events,
+		s.Baselines,
+	}
+}
+
+// Observe advances the state clock to the event's timestamp. The clock never
+// moves backwards, so late events don't resurrect expired keys.
+func (s *StateManager) Observe(t time.Time) {
+	ts := t.UnixNano()
+	for {
+		current := s.clock.Load()
+		if ts <= current || s.clock.CompareAndSwap(current, ts) {
+			return
+		}
+	}
+}
+
+// Now returns the latest event time observed, or the zero time before the
+// first event.
+func (s *StateManager) Now() time.Time {
+	ns := s.clock.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns).UTC()
+}
+
+// Sweep expires keys in every store relative to now and returns the number
+// evicted.
+func (s *StateManager) Sweep(now time.Time) int {
+	evicted := 0
+	for _, store := range s.stores() {
+		evicted += store.Sweep(now)
+	}
+	return evicted
+}
+
+// RunJanitor sweeps all stores every interval until ctx is cancelled. now
+// supplies the event time to expire against, normally the watermark so
+// events still allowed to arrive late find their state; nil uses the newest
+// event time seen.
+func (s *StateManager) RunJanitor(ctx context.Context, interval time.Duration, now func() time.Time) {
+	if interval <= 0 {
+		interval = defaultJanitorEvery
+	}
+	if now == nil {
+		now = s.Now
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if t := now(); !t.IsZero() {
+				s.Sweep(t)
+			}
+		case <-ctx.Done():
+			return
+		}
 	}
 }
@@ -0,0 +1,176 @@
+package rules
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	stateEntries = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "nox_state_entries",
+		Help: "Number of keys currently held in each rule state store.",
+	}, []string{"store"})
+
+	stateEvictionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "nox_state_evictions_total",
+		Help: "Total number of keys evicted from rule state stores, by reason (ttl or capacity).",
+	}, []string{"store", "reason"})
+)
+
+func init() {
+	prometheus.MustRegister(stateEntries)
+	prometheus.MustRegister(stateEvictionsTotal)
+}
+
+// Store is a keyed state map bounded both in time and in size. Every write
+// records the event time it happened at; Sweep drops keys whose last write is
+// older than the TTL, and writing a new key into a full store evicts the
+// least recently used one. Time only moves with the events passed in, never
+// with the wall clock, so replaying old logs behaves like live traffic.
+type Store[V any] struct {
+	name       string
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // front is the most recently used key
+
+	size          prometheus.Gauge
+	ttlEvicted    prometheus.Counter
+	capacityEvict prometheus.Counter
+}
+
+type storeEntry[V any] struct {
+	key     string
+	value   V
+	touched time.Time
+}
+
+// NewStore creates a store. A zero ttl never expires keys and a zero
+// maxEntries never evicts for capacity.
+func NewStore[V any](name string, ttl time.Duration, maxEntries int) *Store[V] {
+	return &Store[V]{
+		name:          name,
+		ttl:           ttl,
+		maxEntries:    maxEntries,
+		entries:       make(map[string]*list.Element),
+		lru:           list.New(),
+		size:          stateEntries.WithLabelValues(name),
+		ttlEvicted:    stateEvictionsTotal.WithLabelValues(name, "ttl"),
+		capacityEvict: stateEvictionsTotal.WithLabelValues(name, "capacity"),
+	}
+}
+
+func (s *Store[V]) Name() string {
+	return s.name
+}
+
+func (s *Store[V]) Get(key string) (V, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+
+	s.lru.MoveToFront(elem)
+	return elem.Value.(*storeEntry[V]).value, true
+}
+
+// Set stores value under key and marks it as written at the given event time.
+func (s *Store[V]) Set(key string, value V, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		entry := elem.Value.(*storeEntry[V])
+		entry.value = value
+		if at.After(entry.touched) {
+			entry.touched = at
+		}
+		s.lru.MoveToFront(elem)
+		return
+	}
+
+	if s.maxEntries > 0 && len(s.entries) >= s.maxEntries {
+		s.removeElement(s.lru.Back())
+		s.capacityEvict.Inc()
+	}
+
+	s.entries[key] = s.lru.PushFront(&storeEntry[V]{key: key, value: value, touched: at})
+	s.size.Set(float64(len(s.entries)))
+}
+
+func (s *Store[V]) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		s.removeElement(elem)
+	}
+}
+
+func (s *Store[V]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.entries)
+}
+
+// Range calls fn for every key until fn returns false. The store is locked
+// for the duration, so fn must not call back into it.
+func (s *Store[V]) Range(fn func(key string, value V, touched time.Time) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for elem := s.lru.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*storeEntry[V])
+		if !fn(entry.key, entry.value, entry.touched) {
+			return
+		}
+	}
+}
+
+func (s *Store[V]) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = make(map[string]*list.Element)
+	s.lru.Init()
+	s.size.Set(0)
+}
+
+// Sweep evicts every key last written more than the TTL before now and
+// returns how many were removed.
+func (s *Store[V]) Sweep(now time.Time) int {
+	if s.ttl <= 0 {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := now.Add(-s.ttl)
+	evicted := 0
+	for _, elem := range s.entries {
+		if elem.Value.(*storeEntry[V]).touched.Before(cutoff) {
+			s.removeElement(elem)
+			evicted++
+		}
+	}
+
+	s.ttlEvicted.Add(float64(evicted))
+	return evicted
+}
+
+func (s *Store[V]) removeElement(elem *list.Element) {
+	entry := s.lru.Remove(elem).(*storeEntry[V])
+	delete(s.entries, entry.key)
+	s.size.Set(float64(len(s.entries)))
+}
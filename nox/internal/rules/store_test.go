@@ -0,0 +1,114 @@
+package rules
+
+import (
+	"fmt"
+	"nox/internal/model"
+	"testing"
+	"time"
+)
+
+func TestStoreSweepExpiresByEventTime(t *testing.T) {
+	baseTime := time.Date(2026, time.June, 19, 12, 0, 0, 0, time.UTC)
+	store := NewStore[int]("test_sweep", time.Minute, 0)
+
+	store.Set("old", 1, baseTime)
+	store.Set("fresh", 2, baseTime.Add(50*time.Second))
+
+	if evicted := store.Sweep(baseTime.Add(90 * time.Second)); evicted != 1 {
+		t.Fatalf("got %d evicted, want 1", evicted)
+	}
+	if _, ok := store.Get("old"); ok {
+		t.Fatalf("got expired key still present, want it evicted")
+	}
+	if v, ok := store.Get("fresh"); !ok || v != 2 {
+		t.Fatalf("got %d, %v for live key, want 2, true", v, ok)
+	}
+}
+
+func TestStoreSetRefreshesTTL(t *testing.T) {
+	baseTime := time.Date(2026, time.June, 19, 12, 0, 0, 0, time.UTC)
+	store := NewStore[int]("test_refresh", time.Minute, 0)
+
+	store.Set("key", 1, baseTime)
+	store.Set("key", 2, baseTime.Add(45*time.Second))
+
+	if evicted := store.Sweep(baseTime.Add(90 * time.Second)); evicted != 0 {
+		t.Fatalf("got %d evicted, want 0", evicted)
+	}
+}
+
+func TestStoreEvictsLeastRecentlyUsedAtCapacity(t *testing.T) {
+	baseTime := time.Date(2026, time.June, 19, 12, 0, 0, 0, time.UTC)
+	store := NewStore[int]("test_capacity", 0, 2)
+
+	store.Set("a", 1, baseTime)
+	store.Set("b", 2, baseTime)
+	store.Get("a") // b is now the least recently used key
+	store.Set("c", 3, baseTime)
+
+	if store.Len() != 2 {
+		t.Fatalf("got %d keys, want 2", store.Len())
+	}
+	if _, ok := store.Get("b"); ok {
+		t.Fatalf("got least recently used key still present, want it evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := store.Get(key); !ok {
+			t.Fatalf("got key %q evicted, want it kept", key)
+		}
+	}
+}
+
+func TestStateStaysBoundedUnderFlood(t *testing.T) {
+	const (
+		maxEntries = 1000
+		floodSize  = 50_000
+	)
+	baseTime := time.Date(2026, time.June, 19, 12, 0, 0, 0, time.UTC)
+
+	state := NewBoundedStateManager(maxEntries)
+	engine := NewEngine(nil, state, nil)
+
+	// every event comes from a new IP and user, one second of event time apart,
+	// the shape of a distributed scan.
+	for i := range floodSize {
+		ts := baseTime.Add(time.Duration(i) * time.Second)
+		source := fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)
+		user := fmt.Sprintf("user%d", i)
+
+		engine.EvaluateEvent(failedLoginEvent(ts, source, user))
+		engine.EvaluateEvent(model.Event{
+			Timestamp: ts,
+			EventType: "Process_Executed",
+			Source:    source,
+			Metadata: map[string]string{
+				"process_name": "useradd",
+				"command":      "useradd " + user,
+			},
+		})
+
+		if i%10_000 == 0 {
+			state.Sweep(state.Now())
+		}
+	}
+
+	for _, store := range []interface{ Len() int }{
+		state.FailedLogins.Attempts,
+		state.PasswordSpray.Attempts,
+		state.NewAccountTracker.CreationTimes,
+		state.ProcessExecutionHistory.History,
+	} {
+		if n := store.Len(); n > maxEntries {
+			t.Fatalf("got %d keys, want at most %d", n, maxEntries)
+		}
+	}
+
+	// a sweep at the final event time leaves only keys inside each TTL.
+	state.Sweep(state.Now())
+	if n := state.ProcessExecutionHistory.History.Len(); n > int(processHistoryTTL/time.Second)+1 {
+		t.Fatalf("got %d process history keys after sweep, want at most %d", n, int(processHistoryTTL/time.Second)+1)
+	}
+	if n := state.FailedLogins.Attempts.Len(); n > int(failedLoginTTL/time.Second)+1 {
+		t.Fatalf("got %d failed login keys after sweep, want at most %d", n, int(failedLoginTTL/time.Second)+1)
+	}
+}
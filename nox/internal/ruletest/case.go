@@ -0,0 +1,131 @@
+package ruletest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultStart anchors test timestamps when a case doesn't set its own, so
+// runs are reproducible regardless of the wall clock.
+var DefaultStart = time.Date(2026, time.January, 15, 12, 0, 0, 0, time.UTC)
+
+// Suite is one test file.
+type Suite struct {
+	Name  string
+	Path  string
+	Cases []Case
+}
+
+// Case feeds inputs through the engine and checks the alerts it raises.
+type Case struct {
+	Name        string    `yaml:"name"`
+	Description string    `yaml:"description"`
+	Start       time.Time `yaml:"start"`
+	Input       []Input   `yaml:"input"`
+	// Expect lists alerts that must be raised. With Exact, no other alerts
+	// may be raised either.
+	Expect []Expectation `yaml:"expect"`
+	Exact  bool          `yaml:"exact"`
+	// Absent lists rules that must not fire.
+	Absent []string `yaml:"absent"`
+}
+
+// Input is a raw log line or a ready-made event, At after the case's start.
+//
+// In lines, {{syslog}} is replaced by the timestamp in sshd's format and
+// {{rfc3339}} by the timestamp in execsnoop's. Metadata is merged into the
+// parsed event, standing in for enrichment such as GeoIP.
+type Input struct {
+	At       time.Duration     `yaml:"at"`
+	Line     string            `yaml:"line"`
+	Event    *EventSpec        `yaml:"event"`
+	Metadata map[string]string `yaml:"metadata"`
+	Repeat   int               `yaml:"repeat"` // send the input this many times
+	Every    time.Duration     `yaml:"every"`  // spacing between repeats
+}
+
+type EventSpec struct {
+	Type     string            `yaml:"type"`
+	Source   string            `yaml:"source"`
+	Metadata map[string]string `yaml:"metadata"`
+}
+
+// Expectation matches an alert by rule name and, when set, severity and
+// metadata values. Count, when set, is the exact number of matching alerts.
+type Expectation struct {
+	Rule     string            `yaml:"rule"`
+	Severity string            `yaml:"severity"`
+	Metadata map[string]string `yaml:"metadata"`
+	Count    int               `yaml:"count"`
+}
+
+// Load reads a test file, or every .yaml and .yml file in a directory.
+func Load(path string) ([]Suite, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rule tests: %w", err)
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rule tests: %w", err)
+		}
+		files = nil
+		for _, entry := range entries {
+			ext := filepath.Ext(entry.Name())
+			if !entry.IsDir() && (ext == ".yaml" || ext == ".yml") {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+		slices.Sort(files)
+	}
+
+	var suites []Suite
+	for _, file := range files {
+		suite, err := loadSuite(file)
+		if err != nil {
+			return nil, err
+		}
+		suites = append(suites, suite)
+	}
+	return suites, nil
+}
+
+func loadSuite(path string) (Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Suite{}, fmt.Errorf("failed to read rule tests: %w", err)
+	}
+
+	var cases []Case
+	if err := yaml.Unmarshal(data, &cases); err != nil {
+		return Suite{}, fmt.Errorf("failed to unmarshal rule tests %s: %w", path, err)
+	}
+
+	for i, c := range cases {
+		if c.Name == "" {
+			return Suite{}, fmt.Errorf("%s: test %d has no name", path, i+1)
+		}
+		for j, in := range c.Input {
+			if (in.Line == "") == (in.Event == nil) {
+				return Suite{}, fmt.Errorf("%s: test %q input %d must set exactly one of line or event", path, c.Name, j+1)
+			}
+		}
+		for _, e := range c.Expect {
+			if e.Rule == "" {
+				return Suite{}, fmt.Errorf("%s: test %q has an expectation without a rule", path, c.Name)
+			}
+		}
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return Suite{Name: name, Path: path, Cases: cases}, nil
+}
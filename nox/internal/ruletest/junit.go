@@ -0,0 +1,96 @@
+package ruletest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	File     string          `xml:"file,attr,omitempty"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnit writes results in the JUnit XML format CI systems understand.
+func WriteJUnit(w io.Writer, results []SuiteResult) error {
+	report := junitTestSuites{}
+	var total float64
+
+	for _, sr := range results {
+		suite := junitTestSuite{Name: sr.Suite.Name, File: sr.Suite.Path}
+		var elapsed float64
+
+		for _, r := range sr.Results {
+			tc := junitTestCase{
+				Name:      r.Case.Name,
+				ClassName: "rules." + sr.Suite.Name,
+				Time:      seconds(r.Duration.Seconds()),
+			}
+			switch {
+			case r.Error != nil:
+				tc.Error = &junitMessage{Message: r.Error.Error(), Body: r.Error.Error()}
+				suite.Errors++
+			case len(r.Failures) > 0:
+				tc.Failure = &junitMessage{
+					Message: fmt.Sprintf("%d expectation(s) failed", len(r.Failures)),
+					Body:    strings.Join(r.Failures, "\n"),
+				}
+				suite.Failures++
+			}
+			suite.Cases = append(suite.Cases, tc)
+			suite.Tests++
+			elapsed += r.Duration.Seconds()
+		}
+
+		suite.Time = seconds(elapsed)
+		report.Suites = append(report.Suites, suite)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		total += elapsed
+	}
+	report.Time = seconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return fmt.Errorf("failed to write junit report: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(s float64) string {
+	return fmt.Sprintf("%.3f", s)
+}
@@ -0,0 +1,139 @@
+package ruletest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"nox/internal/model"
+	"nox/internal/rules"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestShippedRuleTests keeps the tests next to rules.yaml passing.
+func TestShippedRuleTests(t *testing.T) {
+	yamlRules, err := rules.LoadRulesFromFile("../../detections/rules.yaml")
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	suites, err := Load("../../detections/tests")
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+	if len(suites) == 0 {
+		t.Fatalf("got no test suites, want at least one")
+	}
+
+	runner := &Runner{Rules: yamlRules}
+	for _, suite := range suites {
+		for _, r := range runner.RunSuite(suite).Results {
+			if !r.Passed() {
+				t.Errorf("%s/%s failed: error=%v failures=%v", suite.Name, r.Case.Name, r.Error, r.Failures)
+			}
+		}
+	}
+}
+
+func TestRunIsDeterministic(t *testing.T) {
+	c := Case{
+		Name: "year inference",
+		// sshd leaves the year out, so the clock must come from the case.
+		Start:  DefaultStart.AddDate(-3, 0, 0),
+		Input:  []Input{{Line: "{{syslog}} web-01 sshd[1]: Failed password for root from 203.0.113.10 port 22 ssh2", Repeat: 5}},
+		Expect: []Expectation{{Rule: "TooManyFailedLogins"}},
+	}
+
+	r := (&Runner{}).Run(c)
+	if !r.Passed() {
+		t.Fatalf("got error=%v failures=%v, want pass", r.Error, r.Failures)
+	}
+	if got := r.Alerts[0].Timestamp; !got.Equal(c.Start) {
+		t.Fatalf("got alert time %v, want %v", got, c.Start)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	alerts := []model.Alert{
+		{RuleName: "A", Severity: "HIGH", Metadata: map[string]string{"user": "root"}},
+		{RuleName: "A", Severity: "HIGH", Metadata: map[string]string{"user": "alice"}},
+		{RuleName: "B", Severity: "LOW"},
+	}
+
+	tests := []struct {
+		name     string
+		c        Case
+		failures int
+	}{
+		{"rule only", Case{Expect: []Expectation{{Rule: "A"}}}, 0},
+		{"severity is case insensitive", Case{Expect: []Expectation{{Rule: "B", Severity: "low"}}}, 0},
+		{"wrong severity", Case{Expect: []Expectation{{Rule: "B", Severity: "HIGH"}}}, 1},
+		{"metadata", Case{Expect: []Expectation{{Rule: "A", Metadata: map[string]string{"user": "alice"}}}}, 0},
+		{"metadata mismatch", Case{Expect: []Expectation{{Rule: "A", Metadata: map[string]string{"user": "bob"}}}}, 1},
+		{"count", Case{Expect: []Expectation{{Rule: "A", Count: 2}}}, 0},
+		{"wrong count", Case{Expect: []Expectation{{Rule: "A", Count: 1}}}, 1},
+		{"absent", Case{Absent: []string{"C"}}, 0},
+		{"not absent", Case{Absent: []string{"B"}}, 1},
+		{"exact", Case{Expect: []Expectation{{Rule: "A"}, {Rule: "B"}}, Exact: true}, 0},
+		{"exact with extra alert", Case{Expect: []Expectation{{Rule: "A"}}, Exact: true}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := check(tt.c, alerts); len(got) != tt.failures {
+				t.Fatalf("got failures %v, want %d", got, tt.failures)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsInvalidCases(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"no name", "- input: [{line: x}]"},
+		{"line and event", "- name: t\n  input: [{line: x, event: {type: y}}]"},
+		{"neither line nor event", "- name: t\n  input: [{at: 1s}]"},
+		{"expectation without rule", "- name: t\n  expect: [{severity: HIGH}]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "bad.yaml")
+			if err := os.WriteFile(path, []byte(tt.data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Load(path); err == nil {
+				t.Fatalf("got nil error, want validation error")
+			}
+		})
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	results := []SuiteResult{{
+		Suite: Suite{Name: "sshd"},
+		Results: []Result{
+			{Case: Case{Name: "passes"}},
+			{Case: Case{Name: "fails"}, Failures: []string{"expected X alert, got none"}},
+		},
+	}}
+
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, results); err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	var report junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("got invalid xml %v:\n%s", err, buf.String())
+	}
+	if report.Tests != 2 || report.Failures != 1 {
+		t.Fatalf("got tests=%d failures=%d, want 2 and 1", report.Tests, report.Failures)
+	}
+	if failure := report.Suites[0].Cases[1].Failure; failure == nil || !strings.Contains(failure.Body, "expected X alert") {
+		t.Fatalf("got failure %+v, want the expectation message", failure)
+	}
+}
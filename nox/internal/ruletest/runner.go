@@ -0,0 +1,205 @@
+package ruletest
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"nox/internal/ingester"
+	"nox/internal/model"
+	"nox/internal/rules"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Result is the outcome of one case.
+type Result struct {
+	Case     Case
+	Alerts   []model.Alert
+	Failures []string
+	Error    error // the case couldn't run, e.g. an unparsable line
+	Duration time.Duration
+}
+
+func (r Result) Passed() bool {
+	return r.Error == nil && len(r.Failures) == 0
+}
+
+type SuiteResult struct {
+	Suite   Suite
+	Results []Result
+}
+
+// Runner runs cases through the real ingester parsers and rule engine. Each
+// case gets fresh state and a clock pinned to its start, so results never
+// depend on when or in what order tests run.
+type Runner struct {
+	Rules   []rules.RuleDefinition
+	Options []rules.EngineOption
+}
+
+func (r *Runner) RunSuite(suite Suite) SuiteResult {
+	result := SuiteResult{Suite: suite}
+	for _, c := range suite.Cases {
+		result.Results = append(result.Results, r.Run(c))
+	}
+	return result
+}
+
+func (r *Runner) Run(c Case) Result {
+	began := time.Now()
+	result := Result{Case: c}
+
+	start := c.Start
+	if start.IsZero() {
+		start = DefaultStart
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	parser := ingester.NewIngester(logger, func() time.Time { return start })
+	engine := rules.NewEngine(logger, rules.NewStateManager(), r.Rules, r.Options...)
+
+	for i, in := range c.Input {
+		for n := range max(in.Repeat, 1) {
+			at := start.Add(in.At + time.Duration(n)*in.Every)
+
+			event, err := buildEvent(parser, in, at)
+			if err != nil {
+				result.Error = fmt.Errorf("input %d: %w", i+1, err)
+				result.Duration = time.Since(began)
+				return result
+			}
+			for _, alert := range engine.EvaluateEvent(event) {
+				// a suppressed alert is never raised, so it can't satisfy
+				// an expectation.
+				if !alert.IsSuppressed() {
+					result.Alerts = append(result.Alerts, alert)
+				}
+			}
+		}
+	}
+
+	result.Failures = check(c, result.Alerts)
+	result.Duration = time.Since(began)
+	return result
+}
+
+func buildEvent(parser *ingester.Ingester, in Input, at time.Time) (model.Event, error) {
+	var event model.Event
+	if in.Event != nil {
+		event = model.Event{
+			Timestamp: at,
+			EventType: in.Event.Type,
+			Source:    in.Event.Source,
+			Metadata:  maps.Clone(in.Event.Metadata),
+		}
+	} else {
+		line := strings.NewReplacer(
+			"{{syslog}}", at.Format(time.Stamp),
+			"{{rfc3339}}", at.Format(time.RFC3339),
+		).Replace(in.Line)
+
+		var err error
+		if event, err = parser.ParseLog(line); err != nil {
+			return model.Event{}, fmt.Errorf("failed to parse line %q: %w", line, err)
+		}
+	}
+
+	if event.Metadata == nil {
+		event.Metadata = make(map[string]string)
+	}
+	maps.Copy(event.Metadata, in.Metadata)
+	return event, nil
+}
+
+func check(c Case, alerts []model.Alert) []string {
+	var failures []string
+	matched := make([]bool, len(alerts))
+
+	for _, want := range c.Expect {
+		count := 0
+		for i, alert := range alerts {
+			if want.matches(alert) {
+				matched[i] = true
+				count++
+			}
+		}
+
+		switch {
+		case want.Count > 0 && count != want.Count:
+			failures = append(failures, fmt.Sprintf("expected %d %s, got %d", want.Count, want.describe(), count))
+		case count == 0:
+			failures = append(failures, fmt.Sprintf("expected %s, got none%s", want.describe(), nearMisses(want.Rule, alerts)))
+		}
+	}
+
+	for _, rule := range c.Absent {
+		for _, alert := range alerts {
+			if alert.RuleName == rule {
+				failures = append(failures, fmt.Sprintf("expected no %s alert, got %q", rule, alert.Message))
+				break
+			}
+		}
+	}
+
+	if c.Exact {
+		for i, alert := range alerts {
+			if !matched[i] {
+				failures = append(failures, fmt.Sprintf("unexpected %s alert (%s): %q", alert.RuleName, alert.Severity, alert.Message))
+			}
+		}
+	}
+
+	return failures
+}
+
+func (e Expectation) matches(alert model.Alert) bool {
+	if alert.RuleName != e.Rule {
+		return false
+	}
+	if e.Severity != "" && !strings.EqualFold(alert.Severity, e.Severity) {
+		return false
+	}
+	for k, v := range e.Metadata {
+		if alert.Metadata[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (e Expectation) describe() string {
+	desc := e.Rule + " alert"
+	var details []string
+	if e.Severity != "" {
+		details = append(details, "severity="+e.Severity)
+	}
+	for _, k := range slices.Sorted(maps.Keys(e.Metadata)) {
+		details = append(details, k+"="+e.Metadata[k])
+	}
+	if len(details) > 0 {
+		desc += " with " + strings.Join(details, ", ")
+	}
+	return desc
+}
+
+// nearMisses describes alerts from the expected rule that didn't match,
+// which is usually the quickest way to see why a test failed.
+func nearMisses(rule string, alerts []model.Alert) string {
+	var misses []string
+	for _, alert := range alerts {
+		if alert.RuleName != rule {
+			continue
+		}
+		var meta []string
+		for _, k := range slices.Sorted(maps.Keys(alert.Metadata)) {
+			meta = append(meta, k+"="+alert.Metadata[k])
+		}
+		misses = append(misses, fmt.Sprintf("severity=%s %s", alert.Severity, strings.Join(meta, " ")))
+	}
+	if len(misses) == 0 {
+		return ""
+	}
+	return " (got " + rule + " with " + strings.Join(misses, "; ") + ")"
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// AuditLogger writes one record per RPC describing who asked what, how many
+// results were returned and how long it took.
+type AuditLogger struct {
+	logger *slog.Logger
+}
+
+func NewAuditLogger(logger *slog.Logger) *AuditLogger {
+	return &AuditLogger{logger: logger}
+}
+
+func (a *AuditLogger) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		a.record(ctx, info.FullMethod, req, resp, err, time.Since(start))
+		return resp, err
+	}
+}
+
+func (a *AuditLogger) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		a.record(ss.Context(), info.FullMethod, nil, nil, err, time.Since(start))
+		return err
+	}
+}
+
+func (a *AuditLogger) record(ctx context.Context, method string, req, resp any, err error, latency time.Duration) {
+	if isHealthMethod(method) {
+		return
+	}
+
+	client, authMethod := callerName(ctx), "none"
+	if id, ok := IdentityFromContext(ctx); ok {
+		authMethod = id.Method
+	}
+
+	attrs := []any{
+		"client", client,
+		"auth_method", authMethod,
+		"method", method,
+		"code", status.Code(err).String(),
+		"latency_ms", latency.Milliseconds(),
+	}
+
+	if msg, ok := req.(proto.Message); ok {
+		if b, mErr := protojson.Marshal(msg); mErr == nil {
+			attrs = append(attrs, "request", string(b))
+		}
+	}
+
+	if msg, ok := resp.(proto.Message); ok && err == nil {
+		attrs = append(attrs, "result_count", resultCount(msg))
+	}
+
+	if err != nil {
+		attrs = append(attrs, "error", status.Convert(err).Message())
+	}
+
+	a.logger.Info("audit", attrs...)
+}
+
+// callerName identifies the client for auditing and rate limiting, falling
+// back to the peer address when the request isn't authenticated.
+func callerName(ctx context.Context) string {
+	if id, ok := IdentityFromContext(ctx); ok {
+		return id.Name
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return host
+		}
+		return p.Addr.String()
+	}
+
+	return "unknown"
+}
+
+// resultCount sums the lengths of the repeated fields of a response, which
+// for every NoxService response is the number of returned results.
+func resultCount(msg proto.Message) int {
+	count := 0
+	msg.ProtoReflect().Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.IsList() {
+			count += v.List().Len()
+		}
+		return true
+	})
+
+	return count
+}
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+)
+
+type Role string
+
+const (
+	// RoleHunter may run read-only hunting queries.
+	RoleHunter Role = "hunter"
+	// RoleAdmin may additionally change rules and silences.
+	RoleAdmin Role = "admin"
+)
+
+// adminMethods lists the RPCs that change engine behaviour. Every other
+// method only reads telemetry and is open to hunters.
+var adminMethods = map[string]bool{
+	noxMethod("EnableRule"):     true,
+	noxMethod("DisableRule"):    true,
+	noxMethod("UpsertYAMLRule"): true,
+	noxMethod("DeleteRule"):     true,
+	noxMethod("SetRuleMode"):    true,
+	// approving runs a command, blocks an IP or calls out, so it is an
+	// engine change like any other.
+	noxMethod("ApproveResponseAction"): true,
+	noxMethod("RejectResponseAction"):  true,
+}
+
+// ClientIdentity is a known API client. A client authenticates either with
+// its bearer token or with a client certificate whose common name equals Name.
+type ClientIdentity struct {
+	Name  string `yaml:"name"`
+	Token string `yaml:"token"`
+	Role  Role   `yaml:"role"`
+}
+
+// Identity is the authenticated caller attached to a request context.
+type Identity struct {
+	Name   string
+	Role   Role
+	Method string // "token" or "mtls"
+}
+
+type identityKey struct{}
+
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+func LoadClientIdentitiesFromFile(path string) ([]ClientIdentity, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client identities file: %w", err)
+	}
+
+	var clients []ClientIdentity
+	if err := yaml.Unmarshal(file, &clients); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal client identities yaml: %w", err)
+	}
+
+	for i, c := range clients {
+		if c.Name == "" {
+			return nil, fmt.Errorf("client identity %d has no name", i)
+		}
+		switch c.Role {
+		case RoleHunter, RoleAdmin:
+		case "":
+			clients[i].Role = RoleHunter
+		default:
+			return nil, fmt.Errorf("client identity %q has unknown role %q", c.Name, c.Role)
+		}
+	}
+
+	return clients, nil
+}
+
+// LoadServerTLSConfig builds the gRPC server TLS config. When caPath is set,
+// client certificates signed by that CA are verified and used as identities;
+// requireClientCert rejects connections that don't present one.
+func LoadServerTLSConfig(certPath, keyPath, caPath string, requireClientCert bool) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if caPath != "" {
+		pool, err := loadCertPool(caPath)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		if requireClientCert {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	return cfg, nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+
+	return pool, nil
+}
+
+// Authenticator resolves the caller of each RPC from its bearer token or
+// verified client certificate and enforces role-based access.
+type Authenticator struct {
+	clients []ClientIdentity
+	logger  *slog.Logger
+}
+
+func NewAuthenticator(clients []ClientIdentity, logger *slog.Logger) *Authenticator {
+	return &Authenticator{clients: clients, logger: logger}
+}
+
+func (a *Authenticator) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := a.authorize(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (a *Authenticator) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authorize(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func (a *Authenticator) authorize(ctx context.Context, method string) (context.Context, error) {
+	if isHealthMethod(method) {
+		return ctx, nil
+	}
+
+	id, err := a.authenticate(ctx)
+	if err != nil {
+		a.logger.Warn("Rejected unauthenticated request", "method", method, "error", err)
+		return nil, err
+	}
+
+	if adminMethods[method] && id.Role != RoleAdmin {
+		a.logger.Warn("Rejected unauthorized request", "method", method, "client", id.Name, "role", id.Role)
+		return nil, status.Errorf(codes.PermissionDenied, "%s requires the %s role", method, RoleAdmin)
+	}
+
+	return context.WithValue(ctx, identityKey{}, id), nil
+}
+
+// ReadOnlyGuard stands in for the Authenticator when no clients are
+// configured. Nobody can prove they are an admin, so every admin method is
+// refused and the API only serves read-only hunting queries.
+type ReadOnlyGuard struct {
+	logger *slog.Logger
+}
+
+func NewReadOnlyGuard(logger *slog.Logger) *ReadOnlyGuard {
+	return &ReadOnlyGuard{logger: logger}
+}
+
+func (g *ReadOnlyGuard) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := g.check(info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (g *ReadOnlyGuard) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := g.check(info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func (g *ReadOnlyGuard) check(method string) error {
+	if !adminMethods[method] {
+		return nil
+	}
+	g.logger.Warn("Rejected admin request, authentication is not configured", "method", method)
+	return status.Errorf(codes.PermissionDenied, "%s requires the %s role, which needs authentication to be configured", method, RoleAdmin)
+}
+
+func (a *Authenticator) authenticate(ctx context.Context) (Identity, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			token, found := strings.CutPrefix(values[0], "Bearer ")
+			if !found {
+				return Identity{}, status.Error(codes.Unauthenticated, "authorization header must use the Bearer scheme")
+			}
+			for _, c := range a.clients {
+				if c.Token != "" && subtle.ConstantTimeCompare([]byte(c.Token), []byte(token)) == 1 {
+					return Identity{Name: c.Name, Role: c.Role, Method: "token"}, nil
+				}
+			}
+			return Identity{}, status.Error(codes.Unauthenticated, "invalid bearer token")
+		}
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.VerifiedChains) > 0 {
+			cn := tlsInfo.State.VerifiedChains[0][0].Subject.CommonName
+			id := Identity{Name: cn, Role: RoleHunter, Method: "mtls"}
+			for _, c := range a.clients {
+				if c.Name == cn {
+					id.Role = c.Role
+					break
+				}
+			}
+			return id, nil
+		}
+	}
+
+	return Identity{}, status.Error(codes.Unauthenticated, "missing bearer token or client certificate")
+}
+
+// contextServerStream overrides the context of a server stream so values
+// added by interceptors reach the handler.
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
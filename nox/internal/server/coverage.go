@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"nox/internal/attack"
+	"nox/internal/rules"
+	pb "nox/proto"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RuleSource lists the loaded detection rules; rules.Engine implements it.
+type RuleSource interface {
+	Rules() []rules.RuleMetadata
+	RuleTechniques() []rules.RuleTechniques
+}
+
+func WithRules(rules RuleSource) Option {
+	return func(s *NoxAPIServer) {
+		s.rules = rules
+	}
+}
+
+func (s *NoxAPIServer) GetCoverage(ctx context.Context, req *pb.CoverageRequest) (*pb.CoverageResponse, error) {
+	slog.Info("Handling GetCoverage request", "start_time", req.StartTime.AsTime(), "end_time", req.EndTime.AsTime())
+
+	if s.rules == nil {
+		return nil, status.Error(codes.Unavailable, "rule engine is not available")
+	}
+
+	var start, end time.Time
+	if req.StartTime.GetSeconds() > 0 {
+		start = req.StartTime.AsTime()
+	}
+	if req.EndTime.GetSeconds() > 0 {
+		end = req.EndTime.AsTime()
+	}
+	if !start.IsZero() && !end.IsZero() && end.Before(start) {
+		return nil, status.Error(codes.InvalidArgument, "end_time is before start_time")
+	}
+
+	// coverage is still useful without alert counts, so a storage outage
+	// only blanks them.
+	var counts map[string]int64
+	alertsUnavailable := s.esClient == nil
+	if s.esClient != nil {
+		var err error
+		if counts, err = s.esClient.CountAlertsByRule(ctx, start, end); err != nil {
+			slog.Warn("Failed to count alerts for coverage", "error", err)
+			alertsUnavailable = true
+		}
+	}
+
+	coverage := rules.BuildCoverage(s.rules.RuleTechniques(), counts)
+	resp := coverageResponse(coverage)
+	resp.AlertsUnavailable = alertsUnavailable
+
+	if req.NavigatorLayer {
+		layer, err := json.Marshal(coverage.NavigatorLayer("nox detection coverage"))
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to encode navigator layer: %v", err)
+		}
+		resp.NavigatorLayer = string(layer)
+	}
+
+	return resp, nil
+}
+
+func coverageResponse(coverage rules.Coverage) *pb.CoverageResponse {
+	resp := &pb.CoverageResponse{
+		AttackVersion:     attack.Version,
+		UnmappedRules:     coverage.Unmapped,
+		UnknownTechniques: coverage.Unknown,
+	}
+
+	for _, tactic := range coverage.Tactics {
+		tc := &pb.TacticCoverage{
+			Id:        tactic.Tactic.ID,
+			ShortName: tactic.Tactic.ShortName,
+			Name:      tactic.Tactic.Name,
+			Total:     int32(tactic.Total),
+			Covered:   int32(tactic.Covered),
+		}
+		for _, technique := range tactic.Techniques {
+			tc.Techniques = append(tc.Techniques, &pb.TechniqueCoverage{
+				Id:     technique.Technique.ID,
+				Name:   technique.Technique.Name,
+				Rules:  technique.Rules,
+				Alerts: technique.Alerts,
+			})
+		}
+		resp.Tactics = append(resp.Tactics, tc)
+	}
+
+	return resp
+}
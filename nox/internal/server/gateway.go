@@ -0,0 +1,483 @@
+package server
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	pb "nox/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const maxGatewayBodyBytes = 1 << 20
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// Gateway exposes NoxService over HTTP/JSON. Every request is run through
+// the same unary interceptors as the gRPC server, so authentication,
+// auditing, limits and metrics behave identically on both paths.
+type Gateway struct {
+	api          pb.NoxServiceServer
+	interceptors []grpc.UnaryServerInterceptor
+}
+
+func NewGateway(api pb.NoxServiceServer, interceptors []grpc.UnaryServerInterceptor) *Gateway {
+	return &Gateway{api: api, interceptors: interceptors}
+}
+
+type gatewayRoute struct {
+	pattern string
+	method  string
+	// decode builds the request message from the path and body.
+	decode func(r *http.Request) (proto.Message, error)
+	call   grpc.UnaryHandler
+}
+
+func (g *Gateway) routes() []gatewayRoute {
+	return []gatewayRoute{
+		{
+			pattern: "POST /v1/events/search",
+			method:  noxMethod("SearchEvents"),
+			decode:  bodyDecoder(func() proto.Message { return &pb.SearchRequest{} }),
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.SearchEvents(ctx, req.(*pb.SearchRequest))
+			},
+		},
+		{
+			pattern: "POST /v1/events/top",
+			method:  noxMethod("GetTopEvents"),
+			decode:  bodyDecoder(func() proto.Message { return &pb.TopNRequest{} }),
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.GetTopEvents(ctx, req.(*pb.TopNRequest))
+			},
+		},
+		{
+			pattern: "GET /v1/processes/{pid}/ancestry",
+			method:  noxMethod("GetProcessAncestry"),
+			decode: func(r *http.Request) (proto.Message, error) {
+				return &pb.PIDRequest{Pid: r.PathValue("pid")}, nil
+			},
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.GetProcessAncestry(ctx, req.(*pb.PIDRequest))
+			},
+		},
+		{
+			pattern: "POST /v1/processes/history",
+			method:  noxMethod("QueryProcessHistory"),
+			decode:  bodyDecoder(func() proto.Message { return &pb.QueryRequest{} }),
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.QueryProcessHistory(ctx, req.(*pb.QueryRequest))
+			},
+		},
+		{
+			pattern: "GET /v1/ips/{ip}/failed-logins",
+			method:  noxMethod("FailedLogins"),
+			decode: func(r *http.Request) (proto.Message, error) {
+				return &pb.IPRequest{IpAdress: r.PathValue("ip")}, nil
+			},
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.FailedLogins(ctx, req.(*pb.IPRequest))
+			},
+		},
+		{
+			pattern: "POST /v1/timeline",
+			method:  noxMethod("GetEntityTimeline"),
+			decode:  bodyDecoder(func() proto.Message { return &pb.TimelineRequest{} }),
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.GetEntityTimeline(ctx, req.(*pb.TimelineRequest))
+			},
+		},
+		{
+			pattern: "GET /v1/profiles/users/{user}",
+			method:  noxMethod("GetProfile"),
+			decode: func(r *http.Request) (proto.Message, error) {
+				return &pb.ProfileRequest{User: r.PathValue("user")}, nil
+			},
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.GetProfile(ctx, req.(*pb.ProfileRequest))
+			},
+		},
+		{
+			pattern: "GET /v1/profiles/hosts/{host}",
+			method:  noxMethod("GetProfile"),
+			decode: func(r *http.Request) (proto.Message, error) {
+				return &pb.ProfileRequest{Host: r.PathValue("host")}, nil
+			},
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.GetProfile(ctx, req.(*pb.ProfileRequest))
+			},
+		},
+		{
+			pattern: "POST /v1/coverage",
+			method:  noxMethod("GetCoverage"),
+			decode:  bodyDecoder(func() proto.Message { return &pb.CoverageRequest{} }),
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.GetCoverage(ctx, req.(*pb.CoverageRequest))
+			},
+		},
+		{
+			pattern: "GET /v1/rules",
+			method:  noxMethod("ListRules"),
+			decode: func(r *http.Request) (proto.Message, error) {
+				q := r.URL.Query()
+				return &pb.ListRulesRequest{
+					Kind:        q.Get("kind"),
+					Tag:         q.Get("tag"),
+					TechniqueId: q.Get("technique_id"),
+					EnabledOnly: q.Get("enabled_only") == "true",
+				}, nil
+			},
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.ListRules(ctx, req.(*pb.ListRulesRequest))
+			},
+		},
+		{
+			pattern: "PUT /v1/rules",
+			method:  noxMethod("UpsertYAMLRule"),
+			decode:  bodyDecoder(func() proto.Message { return &pb.UpsertRuleRequest{} }),
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.UpsertYAMLRule(ctx, req.(*pb.UpsertRuleRequest))
+			},
+		},
+		{
+			pattern: "POST /v1/rules/{id}/enable",
+			method:  noxMethod("EnableRule"),
+			decode:  ruleIDDecoder,
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.EnableRule(ctx, req.(*pb.RuleRequest))
+			},
+		},
+		{
+			pattern: "POST /v1/rules/{id}/disable",
+			method:  noxMethod("DisableRule"),
+			decode:  ruleIDDecoder,
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.DisableRule(ctx, req.(*pb.RuleRequest))
+			},
+		},
+		{
+			pattern: "POST /v1/rules/{id}/mode",
+			method:  noxMethod("SetRuleMode"),
+			decode:  bodyWithIDDecoder(func() proto.Message { return &pb.RuleModeRequest{} }),
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.SetRuleMode(ctx, req.(*pb.RuleModeRequest))
+			},
+		},
+		{
+			pattern: "POST /v1/rules/shadow-report",
+			method:  noxMethod("GetShadowReport"),
+			decode:  bodyDecoder(func() proto.Message { return &pb.ShadowReportRequest{} }),
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.GetShadowReport(ctx, req.(*pb.ShadowReportRequest))
+			},
+		},
+		{
+			pattern: "DELETE /v1/rules/{id}",
+			method:  noxMethod("DeleteRule"),
+			decode:  ruleIDDecoder,
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.DeleteRule(ctx, req.(*pb.RuleRequest))
+			},
+		},
+		{
+			pattern: "POST /v1/alerts/search",
+			method:  noxMethod("ListAlerts"),
+			decode:  bodyDecoder(func() proto.Message { return &pb.ListAlertsRequest{} }),
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.ListAlerts(ctx, req.(*pb.ListAlertsRequest))
+			},
+		},
+		{
+			pattern: "PATCH /v1/alerts/{id}",
+			method:  noxMethod("UpdateAlert"),
+			decode:  bodyWithIDDecoder(func() proto.Message { return &pb.UpdateAlertRequest{} }),
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.UpdateAlert(ctx, req.(*pb.UpdateAlertRequest))
+			},
+		},
+		{
+			pattern: "GET /v1/incidents",
+			method:  noxMethod("ListIncidents"),
+			decode: func(r *http.Request) (proto.Message, error) {
+				q := r.URL.Query()
+				req := &pb.ListIncidentsRequest{Status: q.Get("status"), Assignee: q.Get("assignee")}
+				if limit := q.Get("limit"); limit != "" {
+					n, err := strconv.ParseInt(limit, 10, 32)
+					if err != nil {
+						return nil, fmt.Errorf("invalid limit %q", limit)
+					}
+					req.Limit = int32(n)
+				}
+				return req, nil
+			},
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.ListIncidents(ctx, req.(*pb.ListIncidentsRequest))
+			},
+		},
+		{
+			pattern: "POST /v1/incidents",
+			method:  noxMethod("CreateIncident"),
+			decode:  bodyDecoder(func() proto.Message { return &pb.CreateIncidentRequest{} }),
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.CreateIncident(ctx, req.(*pb.CreateIncidentRequest))
+			},
+		},
+		{
+			pattern: "PATCH /v1/incidents/{id}",
+			method:  noxMethod("UpdateIncident"),
+			decode:  bodyWithIDDecoder(func() proto.Message { return &pb.UpdateIncidentRequest{} }),
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.UpdateIncident(ctx, req.(*pb.UpdateIncidentRequest))
+			},
+		},
+		{
+			pattern: "GET /v1/risk/entities",
+			method:  noxMethod("GetRiskyEntities"),
+			decode: func(r *http.Request) (proto.Message, error) {
+				q := r.URL.Query()
+				req := &pb.RiskyEntitiesRequest{EntityType: q.Get("type")}
+				if minScore := q.Get("min_score"); minScore != "" {
+					f, err := strconv.ParseFloat(minScore, 64)
+					if err != nil {
+						return nil, fmt.Errorf("invalid min_score %q", minScore)
+					}
+					req.MinScore = f
+				}
+				if limit := q.Get("limit"); limit != "" {
+					n, err := strconv.ParseInt(limit, 10, 32)
+					if err != nil {
+						return nil, fmt.Errorf("invalid limit %q", limit)
+					}
+					req.Limit = int32(n)
+				}
+				return req, nil
+			},
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.GetRiskyEntities(ctx, req.(*pb.RiskyEntitiesRequest))
+			},
+		},
+		{
+			pattern: "GET /v1/response-actions",
+			method:  noxMethod("ListResponseActions"),
+			decode: func(r *http.Request) (proto.Message, error) {
+				q := r.URL.Query()
+				req := &pb.ListResponseActionsRequest{AlertId: q.Get("alert_id"), ActionId: q.Get("action_id"), Status: q.Get("status")}
+				if limit := q.Get("limit"); limit != "" {
+					n, err := strconv.ParseInt(limit, 10, 32)
+					if err != nil {
+						return nil, fmt.Errorf("invalid limit %q", limit)
+					}
+					req.Limit = int32(n)
+				}
+				return req, nil
+			},
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.ListResponseActions(ctx, req.(*pb.ListResponseActionsRequest))
+			},
+		},
+		{
+			pattern: "POST /v1/response-actions/{id}/approve",
+			method:  noxMethod("ApproveResponseAction"),
+			decode:  bodyWithIDDecoder(func() proto.Message { return &pb.ResponseActionDecision{} }),
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.ApproveResponseAction(ctx, req.(*pb.ResponseActionDecision))
+			},
+		},
+		{
+			pattern: "POST /v1/response-actions/{id}/reject",
+			method:  noxMethod("RejectResponseAction"),
+			decode:  bodyWithIDDecoder(func() proto.Message { return &pb.ResponseActionDecision{} }),
+			call: func(ctx context.Context, req any) (any, error) {
+				return g.api.RejectResponseAction(ctx, req.(*pb.ResponseActionDecision))
+			},
+		},
+	}
+}
+
+// Register adds the REST routes and the OpenAPI document to mux.
+func (g *Gateway) Register(mux *http.ServeMux) {
+	for _, route := range g.routes() {
+		mux.Handle(route.pattern, g.handler(route))
+	}
+
+	mux.HandleFunc("GET /v1/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPISpec)
+	})
+}
+
+func (g *Gateway) handler(route gatewayRoute) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := route.decode(r)
+		if err != nil {
+			writeGatewayError(w, status.Errorf(codes.InvalidArgument, "invalid request body: %v", err))
+			return
+		}
+
+		resp, err := g.invoke(gatewayContext(r), route, req)
+		if err != nil {
+			writeGatewayError(w, err)
+			return
+		}
+
+		body, err := protojson.Marshal(resp.(proto.Message))
+		if err != nil {
+			writeGatewayError(w, status.Errorf(codes.Internal, "failed to encode response: %v", err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+}
+
+// invoke runs the handler behind the interceptors in the same order
+// grpc.ChainUnaryInterceptor would.
+func (g *Gateway) invoke(ctx context.Context, route gatewayRoute, req proto.Message) (any, error) {
+	info := &grpc.UnaryServerInfo{Server: g.api, FullMethod: route.method}
+
+	handler := route.call
+	for i := len(g.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := g.interceptors[i], handler
+		handler = func(ctx context.Context, req any) (any, error) {
+			return interceptor(ctx, req, info, next)
+		}
+	}
+
+	return handler(ctx, req)
+}
+
+func noxMethod(name string) string {
+	return "/" + pb.NoxService_ServiceDesc.ServiceName + "/" + name
+}
+
+func ruleIDDecoder(r *http.Request) (proto.Message, error) {
+	return &pb.RuleRequest{Id: r.PathValue("id")}, nil
+}
+
+func bodyDecoder(newReq func() proto.Message) func(r *http.Request) (proto.Message, error) {
+	return func(r *http.Request) (proto.Message, error) {
+		msg := newReq()
+
+		body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxGatewayBodyBytes))
+		if err != nil {
+			return nil, err
+		}
+
+		if len(strings.TrimSpace(string(body))) == 0 {
+			return msg, nil
+		}
+
+		if err := protojson.Unmarshal(body, msg); err != nil {
+			return nil, err
+		}
+
+		return msg, nil
+	}
+}
+
+// bodyWithIDDecoder is bodyDecoder for requests that take their id field
+// from the {id} path segment.
+func bodyWithIDDecoder(newReq func() proto.Message) func(r *http.Request) (proto.Message, error) {
+	decode := bodyDecoder(newReq)
+	return func(r *http.Request) (proto.Message, error) {
+		msg, err := decode(r)
+		if err != nil {
+			return nil, err
+		}
+
+		m := msg.ProtoReflect()
+		m.Set(m.Descriptor().Fields().ByName("id"), protoreflect.ValueOfString(r.PathValue("id")))
+		return msg, nil
+	}
+}
+
+// gatewayContext carries the HTTP caller's credentials into the places the
+// gRPC interceptors look for them: the authorization header as incoming
+// metadata and the client address and TLS state as the peer.
+func gatewayContext(r *http.Request) context.Context {
+	ctx := r.Context()
+
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", auth))
+	}
+
+	p := &peer.Peer{}
+	if addrPort, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+		p.Addr = net.TCPAddrFromAddrPort(addrPort)
+	}
+	if r.TLS != nil {
+		p.AuthInfo = credentials.TLSInfo{
+			State:          *r.TLS,
+			CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.PrivacyAndIntegrity},
+		}
+	}
+
+	return peer.NewContext(ctx, p)
+}
+
+type gatewayError struct {
+	Code    int    `json:"code"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+func writeGatewayError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	httpStatus := httpStatusFromCode(st.Code())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	json.NewEncoder(w).Encode(gatewayError{
+		Code:    httpStatus,
+		Status:  st.Code().String(),
+		Message: st.Message(),
+	})
+}
+
+// httpStatusFromCode follows the mapping from google/rpc/code.proto.
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
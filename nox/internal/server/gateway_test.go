@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pb "nox/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+type fakeNoxServer struct {
+	pb.UnimplementedNoxServiceServer
+}
+
+func (f *fakeNoxServer) GetTopEvents(ctx context.Context, req *pb.TopNRequest) (*pb.TopNResponse, error) {
+	return &pb.TopNResponse{Results: []*pb.TopNResponse_Count{{Item: req.Field, Count: int64(req.N)}}}, nil
+}
+
+func TestGatewayRoutesThroughInterceptors(t *testing.T) {
+	auth := NewAuthenticator([]ClientIdentity{{Name: "alice", Token: "hunter-token", Role: RoleHunter}},
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	mux := http.NewServeMux()
+	NewGateway(&fakeNoxServer{}, []grpc.UnaryServerInterceptor{auth.UnaryInterceptor()}).Register(mux)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		token      string
+		wantStatus int
+	}{
+		{
+			name:       "authenticated request succeeds",
+			method:     http.MethodPost,
+			path:       "/v1/events/top",
+			body:       `{"field": "process_name", "n": 3}`,
+			token:      "hunter-token",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "missing token maps to 401",
+			method:     http.MethodPost,
+			path:       "/v1/events/top",
+			body:       `{"field": "process_name", "n": 3}`,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "malformed body maps to 400",
+			method:     http.MethodPost,
+			path:       "/v1/events/top",
+			body:       `{"field": 7}`,
+			token:      "hunter-token",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unimplemented method maps to 501",
+			method:     http.MethodGet,
+			path:       "/v1/ips/198.51.100.99/failed-logins",
+			token:      "hunter-token",
+			wantStatus: http.StatusNotImplemented,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d (body: %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				var gwErr gatewayError
+				if err := json.Unmarshal(rec.Body.Bytes(), &gwErr); err != nil {
+					t.Fatalf("got undecodable error body: %v", err)
+				}
+				if gwErr.Code != tt.wantStatus {
+					t.Fatalf("got error code %d, want %d", gwErr.Code, tt.wantStatus)
+				}
+				return
+			}
+
+			var resp pb.TopNResponse
+			if err := protojson.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("got undecodable response: %v", err)
+			}
+			if len(resp.Results) != 1 || resp.Results[0].Item != "process_name" || resp.Results[0].Count != 3 {
+				t.Fatalf("got results %v, want process_name=3", resp.Results)
+			}
+		})
+	}
+}
+
+func TestOpenAPISpecIsValidJSON(t *testing.T) {
+	var spec map[string]any
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("got invalid OpenAPI JSON: %v", err)
+	}
+
+	paths, _ := spec["paths"].(map[string]any)
+	for _, route := range NewGateway(&fakeNoxServer{}, nil).routes() {
+		_, path, _ := strings.Cut(route.pattern, " ")
+		if _, ok := paths[path]; !ok {
+			t.Fatalf("got route %q missing from the OpenAPI document", route.pattern)
+		}
+	}
+}
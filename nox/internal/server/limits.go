@@ -0,0 +1,279 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	pb "nox/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// --- Rate Limiting ---
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter applies a token bucket per client so a single analyst or
+// script can't monopolise Elasticsearch. Unauthenticated clients are keyed
+// by peer address, so buckets that have refilled, and so are no different
+// from a new one, are swept out as the limiter goes.
+type RateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens added per second
+	burst   float64
+	buckets map[string]*tokenBucket
+	swept   time.Time
+	now     func() time.Time
+}
+
+func NewRateLimiter(requestsPerSecond float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:    requestsPerSecond,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+func (r *RateLimiter) Allow(client string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	r.sweep(now)
+
+	b, ok := r.buckets[client]
+	if !ok {
+		b = &tokenBucket{tokens: r.burst, last: now}
+		r.buckets[client] = b
+	}
+
+	b.tokens = min(r.burst, b.tokens+now.Sub(b.last).Seconds()*r.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+// sweep drops the buckets idle long enough to have refilled, at most once
+// per refill period.
+func (r *RateLimiter) sweep(now time.Time) {
+	refill := time.Duration(r.burst / r.rate * float64(time.Second))
+	if now.Sub(r.swept) < refill {
+		return
+	}
+	r.swept = now
+
+	for client, b := range r.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(r.buckets, client)
+		}
+	}
+}
+
+func (r *RateLimiter) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !isHealthMethod(info.FullMethod) && !r.Allow(callerName(ctx)) {
+			return nil, status.Errorf(codes.ResourceExhausted, "rate limit exceeded for %s", callerName(ctx))
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (r *RateLimiter) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !isHealthMethod(info.FullMethod) && !r.Allow(callerName(ss.Context())) {
+			return status.Errorf(codes.ResourceExhausted, "rate limit exceeded for %s", callerName(ss.Context()))
+		}
+		return handler(srv, ss)
+	}
+}
+
+// --- Query Cost Limits ---
+
+// QueryLimits bounds how expensive a single hunting query may be. Zero
+// values disable the corresponding limit.
+type QueryLimits struct {
+	MaxTimeRange time.Duration
+	MaxResults   int32
+	Timeout      time.Duration
+}
+
+type timeRangeRequest interface {
+	GetStartTime() *timestamppb.Timestamp
+	GetEndTime() *timestamppb.Timestamp
+}
+
+// sizedRequest and limitedRequest cover the requests that ask for a number
+// of results, TopNRequest.N and TimelineRequest.Limit.
+type sizedRequest interface {
+	GetN() int32
+}
+
+type limitedRequest interface {
+	GetLimit() int32
+}
+
+// Check validates req against the limits.
+func (l QueryLimits) Check(req any) error {
+	if r, ok := req.(sizedRequest); ok && l.MaxResults > 0 && r.GetN() > l.MaxResults {
+		return status.Errorf(codes.InvalidArgument, "n=%d exceeds the maximum of %d", r.GetN(), l.MaxResults)
+	}
+
+	if r, ok := req.(limitedRequest); ok {
+		if max := l.maxLimit(req); max > 0 && r.GetLimit() > max {
+			return status.Errorf(codes.InvalidArgument, "limit=%d exceeds the maximum of %d", r.GetLimit(), max)
+		}
+	}
+
+	r, ok := req.(timeRangeRequest)
+	if !ok || l.MaxTimeRange <= 0 {
+		return nil
+	}
+
+	start, end := r.GetStartTime().GetSeconds(), r.GetEndTime().GetSeconds()
+	if start > 0 && end > 0 {
+		span := r.GetEndTime().AsTime().Sub(r.GetStartTime().AsTime())
+		if span < 0 {
+			return status.Error(codes.InvalidArgument, "end_time must be after start_time")
+		}
+		if span > l.MaxTimeRange {
+			return status.Errorf(codes.InvalidArgument, "time range %s exceeds the maximum of %s", span, l.MaxTimeRange)
+		}
+	}
+
+	return nil
+}
+
+// maxLimit is the largest limit req may ask for. A timeline covers a single
+// entity and GetEntityTimeline caps it at maxTimelineLimit, so that cap
+// applies instead of MaxResults; every other list is held to MaxResults.
+func (l QueryLimits) maxLimit(req any) int32 {
+	if _, ok := req.(*pb.TimelineRequest); ok {
+		return maxTimelineLimit
+	}
+	return l.MaxResults
+}
+
+// bound completes open-ended time ranges so a query without a start or end
+// time covers at most MaxTimeRange instead of scanning every index.
+func (l QueryLimits) bound(req any) {
+	r, ok := req.(timeRangeRequest)
+	if !ok || l.MaxTimeRange <= 0 {
+		return
+	}
+
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return
+	}
+
+	m := msg.ProtoReflect()
+	fields := m.Descriptor().Fields()
+
+	end := time.Now()
+	if r.GetEndTime().GetSeconds() > 0 {
+		end = r.GetEndTime().AsTime()
+	} else {
+		m.Set(fields.ByName("end_time"), protoreflect.ValueOfMessage(timestamppb.New(end).ProtoReflect()))
+	}
+
+	if r.GetStartTime().GetSeconds() == 0 {
+		m.Set(fields.ByName("start_time"), protoreflect.ValueOfMessage(timestamppb.New(end.Add(-l.MaxTimeRange)).ProtoReflect()))
+	}
+}
+
+func (l QueryLimits) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		l.bound(req)
+		if err := l.Check(req); err != nil {
+			return nil, err
+		}
+
+		if l.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, l.Timeout)
+			defer cancel()
+		}
+
+		resp, err := handler(ctx, req)
+		return resp, toStatusError(ctx, err)
+	}
+}
+
+// reflectionService streams serve a whole grpcurl or IDE session rather
+// than a query.
+const reflectionService = "/grpc.reflection."
+
+func (l QueryLimits) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		// health Watch streams last as long as the prober wants them to
+		if isHealthMethod(info.FullMethod) || strings.HasPrefix(info.FullMethod, reflectionService) {
+			return handler(srv, ss)
+		}
+
+		ctx := ss.Context()
+		if l.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, l.Timeout)
+			defer cancel()
+		}
+
+		err := handler(srv, &limitedServerStream{ServerStream: ss, ctx: ctx, limits: l})
+		return toStatusError(ctx, err)
+	}
+}
+
+type limitedServerStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	limits QueryLimits
+}
+
+func (s *limitedServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *limitedServerStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	s.limits.bound(m)
+	return s.limits.Check(m)
+}
+
+// toStatusError maps plain handler errors onto gRPC status codes so clients
+// can tell a timed out query from a server fault.
+func toStatusError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "nox/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	now := time.Date(2026, time.June, 19, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(1, 2)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if !limiter.Allow("alice") {
+			t.Fatalf("got request %d rejected, want allowed within burst", i+1)
+		}
+	}
+
+	if limiter.Allow("alice") {
+		t.Fatalf("got request allowed after burst, want rejected")
+	}
+	if !limiter.Allow("bob") {
+		t.Fatalf("got other client rejected, want allowed")
+	}
+
+	now = now.Add(time.Second)
+	if !limiter.Allow("alice") {
+		t.Fatalf("got request rejected after refill, want allowed")
+	}
+}
+
+func TestRateLimiterEvictsIdleBuckets(t *testing.T) {
+	now := time.Date(2026, time.June, 19, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(1, 2)
+	limiter.now = func() time.Time { return now }
+
+	for _, client := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"} {
+		limiter.Allow(client)
+	}
+	limiter.Allow("alice")
+	limiter.Allow("alice")
+
+	now = now.Add(time.Second)
+	limiter.Allow("alice")
+	if got := len(limiter.buckets); got != 4 {
+		t.Fatalf("got %d buckets before refill, want 4", got)
+	}
+
+	// two seconds refill a burst of two, so only alice, seen a second ago,
+	// is still drawn down
+	now = now.Add(time.Second)
+	if !limiter.Allow("alice") {
+		t.Fatalf("got alice rejected after refill, want allowed")
+	}
+	if got := len(limiter.buckets); got != 1 {
+		t.Fatalf("got %d buckets after refill, want 1", got)
+	}
+	if limiter.Allow("alice") {
+		t.Fatalf("got alice allowed, want its bucket kept and drawn down")
+	}
+}
+
+func TestQueryLimitsCheck(t *testing.T) {
+	limits := QueryLimits{MaxTimeRange: 24 * time.Hour, MaxResults: 100}
+	base := time.Date(2026, time.June, 19, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		req      any
+		wantCode codes.Code
+	}{
+		{
+			name:     "range within limit is allowed",
+			req:      &pb.SearchRequest{StartTime: timestamppb.New(base), EndTime: timestamppb.New(base.Add(time.Hour))},
+			wantCode: codes.OK,
+		},
+		{
+			name:     "range over limit is rejected",
+			req:      &pb.SearchRequest{StartTime: timestamppb.New(base), EndTime: timestamppb.New(base.Add(48 * time.Hour))},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "inverted range is rejected",
+			req:      &pb.SearchRequest{StartTime: timestamppb.New(base), EndTime: timestamppb.New(base.Add(-time.Hour))},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "top n over limit is rejected",
+			req:      &pb.TopNRequest{Field: "process_name", N: 500},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "list limit over limit is rejected",
+			req:      &pb.ListAlertsRequest{Limit: 101},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "timeline limit up to the timeline cap is allowed",
+			req:      &pb.TimelineRequest{SourceIp: "198.51.100.99", Limit: 2000},
+			wantCode: codes.OK,
+		},
+		{
+			name:     "timeline limit over the timeline cap is rejected",
+			req:      &pb.TimelineRequest{SourceIp: "198.51.100.99", Limit: maxTimelineLimit + 1},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := status.Code(limits.Check(tt.req)); code != tt.wantCode {
+				t.Fatalf("got code %s, want %s", code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestQueryLimitsBoundFillsMissingRange(t *testing.T) {
+	limits := QueryLimits{MaxTimeRange: 24 * time.Hour}
+	req := &pb.TopNRequest{Field: "process_name", N: 5}
+
+	limits.bound(req)
+
+	if req.StartTime.GetSeconds() == 0 || req.EndTime.GetSeconds() == 0 {
+		t.Fatalf("got unbounded range, want start and end time set")
+	}
+	if span := req.EndTime.AsTime().Sub(req.StartTime.AsTime()); span != limits.MaxTimeRange {
+		t.Fatalf("got span %s, want %s", span, limits.MaxTimeRange)
+	}
+}
+
+func TestQueryLimitsStreamTimeoutExemptions(t *testing.T) {
+	interceptor := QueryLimits{Timeout: time.Minute}.StreamInterceptor()
+
+	tests := []struct {
+		method   string
+		deadline bool
+	}{
+		{method: "/nox.NoxService/SearchEvents", deadline: true},
+		{method: "/grpc.health.v1.Health/Watch", deadline: false},
+		{method: "/grpc.reflection.v1.ServerReflection/ServerReflectionInfo", deadline: false},
+		{method: "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo", deadline: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			ss := &contextServerStream{ctx: context.Background()}
+			var deadline bool
+			err := interceptor(nil, ss, &grpc.StreamServerInfo{FullMethod: tt.method}, func(srv any, stream grpc.ServerStream) error {
+				_, deadline = stream.Context().Deadline()
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("interceptor: %v", err)
+			}
+			if deadline != tt.deadline {
+				t.Fatalf("got deadline %v, want %v", deadline, tt.deadline)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"nox/internal/baseline"
+	pb "nox/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// maxProfileValues bounds the ASNs and process pairs returned per profile.
+const maxProfileValues = 50
+
+// ProfileSource looks up learned baselines; baseline.Profiler implements it.
+type ProfileSource interface {
+	Profile(entity string) (profile baseline.Profile, learning bool, ok bool)
+}
+
+func WithProfiles(profiles ProfileSource) Option {
+	return func(s *NoxAPIServer) {
+		s.profiles = profiles
+	}
+}
+
+func (s *NoxAPIServer) GetProfile(ctx context.Context, req *pb.ProfileRequest) (*pb.ProfileResponse, error) {
+	slog.Info("Handling GetProfile request", "user", req.User, "host", req.Host)
+
+	var entity string
+	switch {
+	case req.User != "" && req.Host != "":
+		return nil, status.Error(codes.InvalidArgument, "set only one of user or host")
+	case req.User != "":
+		entity = baseline.UserEntity(req.User)
+	case req.Host != "":
+		entity = baseline.HostEntity(req.Host)
+	default:
+		return nil, status.Error(codes.InvalidArgument, "one of user or host is required")
+	}
+
+	if s.profiles == nil {
+		return nil, status.Error(codes.Unavailable, "behavioral baselines are not enabled")
+	}
+
+	profile, learning, ok := s.profiles.Profile(entity)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no baseline for %s", entity)
+	}
+
+	return &pb.ProfileResponse{
+		Entity:         profile.Entity,
+		FirstSeen:      timestamppb.New(profile.FirstSeen),
+		LastSeen:       timestamppb.New(profile.LastSeen),
+		Learning:       learning,
+		Logins:         profile.Logins,
+		LoginHours:     profile.LoginHours[:],
+		SourceAsns:     weightedValues(profile.SourceASNs),
+		Executions:     profile.Executions,
+		ProcessPairs:   weightedValues(profile.ProcessPairs),
+		ExecRateMean:   profile.ExecRate.Mean,
+		ExecRateStddev: profile.ExecRate.Stddev(),
+	}, nil
+}
+
+func weightedValues(weights map[string]float64) []*pb.WeightedValue {
+	var values []*pb.WeightedValue
+	for _, w := range baseline.Top(weights, maxProfileValues) {
+		values = append(values, &pb.WeightedValue{Value: w.Value, Weight: w.Weight})
+	}
+	return values
+}
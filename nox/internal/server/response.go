@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"nox/internal/response"
+	"nox/internal/storage"
+	pb "nox/proto"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ResponseAdmin decides on response actions waiting for approval;
+// response.Responder implements it.
+type ResponseAdmin interface {
+	Approve(ctx context.Context, id, by, note string) (response.Execution, error)
+	Reject(ctx context.Context, id, by, note string) (response.Execution, error)
+}
+
+func WithResponder(responder ResponseAdmin) Option {
+	return func(s *NoxAPIServer) {
+		s.responder = responder
+	}
+}
+
+func (s *NoxAPIServer) ListResponseActions(ctx context.Context, req *pb.ListResponseActionsRequest) (*pb.ListResponseActionsResponse, error) {
+	slog.Info("Handling ListResponseActions request", "alert_id", req.AlertId, "action_id", req.ActionId, "status", req.Status)
+
+	if req.Status != "" && !slices.Contains(response.Statuses, req.Status) {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown status %q, expected one of %s", req.Status, strings.Join(response.Statuses, ", "))
+	}
+	if s.esClient == nil {
+		return nil, status.Error(codes.Unavailable, "alert storage is not available")
+	}
+
+	executions, err := s.esClient.SearchResponseActions(ctx, storage.ResponseActionQuery{
+		AlertID:  req.AlertId,
+		ActionID: req.ActionId,
+		Status:   req.Status,
+		Limit:    caseLimit(req.Limit),
+	})
+	if err != nil {
+		slog.Error("Failed to search response actions", "error", err)
+		return nil, status.Error(codes.Internal, "failed to search response actions")
+	}
+
+	resp := &pb.ListResponseActionsResponse{}
+	for _, x := range executions {
+		resp.Actions = append(resp.Actions, responseAction(x))
+	}
+	return resp, nil
+}
+
+func (s *NoxAPIServer) ApproveResponseAction(ctx context.Context, req *pb.ResponseActionDecision) (*pb.ResponseAction, error) {
+	return s.decideResponseAction(ctx, req, "approve", ResponseAdmin.Approve)
+}
+
+func (s *NoxAPIServer) RejectResponseAction(ctx context.Context, req *pb.ResponseActionDecision) (*pb.ResponseAction, error) {
+	return s.decideResponseAction(ctx, req, "reject", ResponseAdmin.Reject)
+}
+
+func (s *NoxAPIServer) decideResponseAction(ctx context.Context, req *pb.ResponseActionDecision, verb string,
+	decide func(ResponseAdmin, context.Context, string, string, string) (response.Execution, error)) (*pb.ResponseAction, error) {
+	slog.Info("Handling response action decision", "decision", verb, "id", req.Id)
+
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if s.responder == nil {
+		return nil, status.Error(codes.Unavailable, "response actions are not enabled")
+	}
+
+	x, err := decide(s.responder, ctx, req.Id, callerName(ctx), req.Note)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, response.ErrNotPending), errors.Is(err, response.ErrUnknownAction):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case err != nil:
+		slog.Error("Failed to decide on response action", "decision", verb, "id", req.Id, "error", err)
+		return nil, status.Errorf(codes.Internal, "failed to %s response action", verb)
+	}
+	return responseAction(x), nil
+}
+
+func responseAction(x response.Execution) *pb.ResponseAction {
+	out := &pb.ResponseAction{
+		Id:        x.ID,
+		ActionId:  x.ActionID,
+		Type:      x.Type,
+		AlertId:   x.AlertID,
+		RuleId:    x.Alert.RuleID,
+		RuleName:  x.Alert.RuleName,
+		Severity:  x.Alert.Severity,
+		Status:    x.Status,
+		DryRun:    x.DryRun,
+		Target:    x.Target,
+		Output:    x.Output,
+		Error:     x.Error,
+		CreatedAt: timestamppb.New(x.CreatedAt),
+		DecidedBy: x.DecidedBy,
+		Note:      x.Note,
+	}
+	if !x.CompletedAt.IsZero() {
+		out.CompletedAt = timestamppb.New(x.CompletedAt)
+	}
+	return out
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"nox/internal/rules"
+	pb "nox/proto"
+	"slices"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultRiskLimit = 50
+	maxRiskLimit     = 1000
+)
+
+// RiskSource reports the accumulated risk of users, IPs and hosts;
+// rules.Engine implements it.
+type RiskSource interface {
+	RiskyEntities(minScore float64) []rules.EntityRisk
+	RiskThreshold() float64
+}
+
+func WithRisk(risk RiskSource) Option {
+	return func(s *NoxAPIServer) {
+		s.risk = risk
+	}
+}
+
+func (s *NoxAPIServer) GetRiskyEntities(ctx context.Context, req *pb.RiskyEntitiesRequest) (*pb.RiskyEntitiesResponse, error) {
+	slog.Info("Handling GetRiskyEntities request", "entity_type", req.EntityType, "min_score", req.MinScore)
+
+	if req.EntityType != "" && !slices.Contains(rules.EntityTypes, req.EntityType) {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown entity_type %q, expected one of user, ip or host", req.EntityType)
+	}
+	if req.MinScore < 0 {
+		return nil, status.Error(codes.InvalidArgument, "min_score must not be negative")
+	}
+	if s.risk == nil {
+		return nil, status.Error(codes.Unavailable, "risk scoring is not available")
+	}
+
+	limit := int(req.Limit)
+	switch {
+	case limit <= 0:
+		limit = defaultRiskLimit
+	case limit > maxRiskLimit:
+		limit = maxRiskLimit
+	}
+
+	resp := &pb.RiskyEntitiesResponse{Threshold: s.risk.RiskThreshold()}
+	for _, risk := range s.risk.RiskyEntities(req.MinScore) {
+		if req.EntityType != "" && risk.Type != req.EntityType {
+			continue
+		}
+		if len(resp.Entities) == limit {
+			break
+		}
+		resp.Entities = append(resp.Entities, riskyEntity(risk))
+	}
+	return resp, nil
+}
+
+func riskyEntity(risk rules.EntityRisk) *pb.RiskyEntity {
+	out := &pb.RiskyEntity{
+		EntityType: risk.Type,
+		Entity:     risk.Entity,
+		Score:      risk.Score,
+		UpdatedAt:  timestamppb.New(risk.UpdatedAt),
+	}
+	if !risk.AlertedAt.IsZero() {
+		out.AlertedAt = timestamppb.New(risk.AlertedAt)
+	}
+	for _, c := range risk.Alerts {
+		out.Alerts = append(out.Alerts, &pb.RiskContribution{
+			AlertId:  c.AlertID,
+			RuleId:   c.RuleID,
+			RuleName: c.RuleName,
+			Severity: c.Severity,
+			Score:    c.Score,
+			Time:     timestamppb.New(c.Time),
+		})
+	}
+	return out
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"nox/internal/rules"
+	pb "nox/proto"
+	"slices"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RuleAdmin changes the running rule set and persists it;
+// rules.RuleManager implements it.
+type RuleAdmin interface {
+	SetMode(id, mode string) (rules.RuleMetadata, error)
+	Upsert(data []byte) ([]rules.RuleMetadata, []rules.Finding, error)
+	Delete(id string) (rules.RuleMetadata, error)
+}
+
+func WithRuleAdmin(admin RuleAdmin) Option {
+	return func(s *NoxAPIServer) {
+		s.ruleAdmin = admin
+	}
+}
+
+func (s *NoxAPIServer) ListRules(ctx context.Context, req *pb.ListRulesRequest) (*pb.ListRulesResponse, error) {
+	slog.Info("Handling ListRules request", "kind", req.Kind, "tag", req.Tag, "technique_id", req.TechniqueId, "enabled_only", req.EnabledOnly)
+
+	switch req.Kind {
+	case "", rules.RuleKindYAML, rules.RuleKindStateful, rules.RuleKindCorrelation:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown rule kind %q", req.Kind)
+	}
+
+	if s.rules == nil {
+		return nil, status.Error(codes.Unavailable, "rule engine is not available")
+	}
+
+	resp := &pb.ListRulesResponse{}
+	for _, meta := range s.rules.Rules() {
+		if !matchesRuleFilter(meta, req) {
+			continue
+		}
+		resp.Rules = append(resp.Rules, ruleInfo(meta))
+	}
+	return resp, nil
+}
+
+func matchesRuleFilter(meta rules.RuleMetadata, req *pb.ListRulesRequest) bool {
+	switch {
+	case req.Kind != "" && meta.Kind != req.Kind:
+		return false
+	case req.Tag != "" && !slices.Contains(meta.Tags, req.Tag):
+		return false
+	case req.TechniqueId != "" && !slices.Contains(meta.Techniques, req.TechniqueId):
+		return false
+	case req.EnabledOnly && !meta.Enabled:
+		return false
+	}
+	return true
+}
+
+func ruleInfo(meta rules.RuleMetadata) *pb.RuleInfo {
+	return &pb.RuleInfo{
+		Id:              meta.ID,
+		Name:            meta.Name,
+		Kind:            meta.Kind,
+		Version:         int32(meta.Version),
+		Author:          meta.Author,
+		Description:     meta.Description,
+		Severity:        meta.Severity,
+		DefaultSeverity: meta.DefaultSeverity,
+		Enabled:         meta.Enabled,
+		Techniques:      meta.Techniques,
+		Tags:            meta.Tags,
+		References:      meta.References,
+		FalsePositives:  meta.FalsePositives,
+		Mode:            meta.Mode,
+		Replaces:        meta.Replaces,
+		RiskWeight:      meta.RiskWeight,
+	}
+}
+
+func (s *NoxAPIServer) EnableRule(ctx context.Context, req *pb.RuleRequest) (*pb.RuleInfo, error) {
+	slog.Info("Handling EnableRule request", "rule_id", req.Id)
+	return s.setRuleMode(req.Id, rules.ModeActive)
+}
+
+func (s *NoxAPIServer) DisableRule(ctx context.Context, req *pb.RuleRequest) (*pb.RuleInfo, error) {
+	slog.Info("Handling DisableRule request", "rule_id", req.Id)
+	return s.setRuleMode(req.Id, rules.ModeDisabled)
+}
+
+func (s *NoxAPIServer) SetRuleMode(ctx context.Context, req *pb.RuleModeRequest) (*pb.RuleInfo, error) {
+	slog.Info("Handling SetRuleMode request", "rule_id", req.Id, "mode", req.Mode)
+	return s.setRuleMode(req.Id, req.Mode)
+}
+
+func (s *NoxAPIServer) setRuleMode(id, mode string) (*pb.RuleInfo, error) {
+	if id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if s.ruleAdmin == nil {
+		return nil, status.Error(codes.Unavailable, "rule management is not enabled")
+	}
+
+	meta, err := s.ruleAdmin.SetMode(id, mode)
+	if err != nil {
+		return nil, ruleAdminError(err)
+	}
+	return ruleInfo(meta), nil
+}
+
+func (s *NoxAPIServer) UpsertYAMLRule(ctx context.Context, req *pb.UpsertRuleRequest) (*pb.UpsertRuleResponse, error) {
+	slog.Info("Handling UpsertYAMLRule request", "bytes", len(req.Yaml))
+
+	if s.ruleAdmin == nil {
+		return nil, status.Error(codes.Unavailable, "rule management is not enabled")
+	}
+
+	applied, warnings, err := s.ruleAdmin.Upsert([]byte(req.Yaml))
+	if err != nil {
+		return nil, ruleAdminError(err)
+	}
+
+	resp := &pb.UpsertRuleResponse{}
+	for _, meta := range applied {
+		resp.Rules = append(resp.Rules, ruleInfo(meta))
+	}
+	for _, f := range warnings {
+		resp.Warnings = append(resp.Warnings, f.String())
+	}
+	return resp, nil
+}
+
+func (s *NoxAPIServer) DeleteRule(ctx context.Context, req *pb.RuleRequest) (*pb.RuleInfo, error) {
+	slog.Info("Handling DeleteRule request", "rule_id", req.Id)
+
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if s.ruleAdmin == nil {
+		return nil, status.Error(codes.Unavailable, "rule management is not enabled")
+	}
+
+	meta, err := s.ruleAdmin.Delete(req.Id)
+	if err != nil {
+		return nil, ruleAdminError(err)
+	}
+	return ruleInfo(meta), nil
+}
+
+func ruleAdminError(err error) error {
+	var invalid *rules.InvalidRuleError
+	switch {
+	case errors.As(err, &invalid), errors.Is(err, rules.ErrUnknownMode):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, rules.ErrRuleNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, rules.ErrRuleConflict):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, rules.ErrBuiltinRule):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		slog.Error("Failed to change detection rules", "error", err)
+		return status.Error(codes.Internal, "failed to change detection rules")
+	}
+}
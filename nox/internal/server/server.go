@@ -9,8 +9,11 @@ import (
 	"nox/internal/model"
 	"nox/internal/storage"
 	pb "nox/proto"
+	"sync"
 	"time"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -31,8 +34,18 @@ type Query struct {
 }
 
 type BoolClause struct {
-	Must   []any `json:"must"`
-	Filter []any `json:"filter,omitempty"`
+	Must               []any `json:"must"`
+	Filter             []any `json:"filter,omitempty"`
+	Should             []any `json:"should,omitempty"`
+	MinimumShouldMatch int   `json:"minimum_should_match,omitempty"`
+}
+
+type TermClause struct {
+	Term map[string]string `json:"term"`
+}
+
+type TermsClause struct {
+	Terms map[string][]string `json:"terms"`
 }
 
 type RangeClause struct {
@@ -50,9 +63,23 @@ type TimeRange struct {
 
 type NoxAPIServer struct {
 	pb.UnimplementedNoxServiceServer
-	esClient *storage.ESClient
+	esClient  *storage.ESClient
+	profiles  ProfileSource
+	rules     RuleSource
+	ruleAdmin RuleAdmin
+	risk      RiskSource
+	responder ResponseAdmin
+
+	// casesMu serializes case updates, which read, change and write back
+	// alert and incident documents, so updates made through this server
+	// queue rather than abort each other. Updates from other replicas are
+	// caught by the document versions they are written with.
+	casesMu sync.Mutex
 }
 
+// Option wires an engine component the API reads from.
+type Option func(*NoxAPIServer)
+
 type esSearchResponse struct {
 	Hits struct {
 		Hits []struct {
@@ -72,8 +99,12 @@ type esAggregationResponse struct {
 	} `json:"aggregations"`
 }
 
-func NewNoxAPIServer(esClient *storage.ESClient) *NoxAPIServer {
-	return &NoxAPIServer{esClient: esClient}
+func NewNoxAPIServer(esClient *storage.ESClient, opts ...Option) *NoxAPIServer {
+	s := &NoxAPIServer{esClient: esClient}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *NoxAPIServer) SearchEvents(ctx context.Context, req *pb.SearchRequest) (*pb.SearchResponse, error) {
@@ -231,7 +262,7 @@ func (s *NoxAPIServer) GetProcessAncestry(ctx context.Context, req *pb.PIDReques
 func (s *NoxAPIServer) GetTopEvents(ctx context.Context, req *pb.TopNRequest) (*pb.TopNResponse, error) {
 	slog.Info("Handling GetTopEvents request", "field", req.Field, "n", req.N)
 	if req.Field == "" || req.N <= 0 {
-		return nil, fmt.Errorf("field must be specified and N must be positive")
+		return nil, status.Error(codes.InvalidArgument, "field must be specified and N must be positive")
 	}
 
 	size := 0
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"nox/internal/rules"
+	pb "nox/proto"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// defaultShadowPeriod is how far back a shadow report without a start time
+// looks.
+const defaultShadowPeriod = 7 * 24 * time.Hour
+
+func (s *NoxAPIServer) GetShadowReport(ctx context.Context, req *pb.ShadowReportRequest) (*pb.ShadowReportResponse, error) {
+	slog.Info("Handling GetShadowReport request", "start_time", req.StartTime.AsTime(), "end_time", req.EndTime.AsTime())
+
+	if s.rules == nil {
+		return nil, status.Error(codes.Unavailable, "rule engine is not available")
+	}
+	if s.esClient == nil {
+		return nil, status.Error(codes.Unavailable, "alert storage is not available")
+	}
+
+	end := time.Now()
+	if req.EndTime.GetSeconds() > 0 {
+		end = req.EndTime.AsTime()
+	}
+	start := end.Add(-defaultShadowPeriod)
+	if req.StartTime.GetSeconds() > 0 {
+		start = req.StartTime.AsTime()
+	}
+	if end.Before(start) {
+		return nil, status.Error(codes.InvalidArgument, "end_time is before start_time")
+	}
+
+	shadow, err := s.esClient.CountShadowAlertsByRule(ctx, start, end)
+	if err != nil {
+		slog.Error("Failed to count shadow alerts", "error", err)
+		return nil, status.Error(codes.Internal, "failed to count shadow alerts")
+	}
+	active, err := s.esClient.CountAlertsByRule(ctx, start, end)
+	if err != nil {
+		slog.Error("Failed to count alerts", "error", err)
+		return nil, status.Error(codes.Internal, "failed to count alerts")
+	}
+
+	report := rules.BuildShadowReport(s.rules.Rules(), shadow, active, end.Sub(start))
+
+	resp := &pb.ShadowReportResponse{
+		StartTime: timestamppb.New(start),
+		EndTime:   timestamppb.New(end),
+	}
+	for _, cmp := range report.Rules {
+		resp.Rules = append(resp.Rules, &pb.ShadowRuleReport{
+			Id:             cmp.Rule.ID,
+			Name:           cmp.Rule.Name,
+			Replaces:       cmp.Baseline.ID,
+			ShadowAlerts:   cmp.ShadowAlerts,
+			ShadowPerDay:   report.PerDay(cmp.ShadowAlerts),
+			BaselineAlerts: cmp.BaselineAlerts,
+			BaselinePerDay: report.PerDay(cmp.BaselineAlerts),
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,311 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"nox/internal/storage"
+	pb "nox/proto"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultTimelineLimit = 500
+	maxTimelineLimit     = 5000
+	defaultTimelineGap   = 30 * time.Minute
+)
+
+// timelineIndices are searched together so a single sorted query returns
+// logins, process executions and alerts for an entity.
+var timelineIndices = []string{
+	"sshd_accepted_password",
+	"sshd_failed_password",
+	"process_executed",
+	storage.AlertIndex,
+}
+
+// timelineDoc covers the fields of both model.Event and model.Alert documents.
+type timelineDoc struct {
+	Timestamp time.Time
+	EventType string
+	Source    string
+	RuleName  string
+	Message   string
+	Severity  string
+	Metadata  map[string]string
+}
+
+type timelineHit struct {
+	ID     string      `json:"_id"`
+	Index  string      `json:"_index"`
+	Source timelineDoc `json:"_source"`
+}
+
+type esTimelineResponse struct {
+	Hits struct {
+		Hits []timelineHit `json:"hits"`
+	} `json:"hits"`
+}
+
+func (s *NoxAPIServer) GetEntityTimeline(ctx context.Context, req *pb.TimelineRequest) (*pb.TimelineResponse, error) {
+	slog.Info("Handling GetEntityTimeline request", "user", req.User, "source_ip", req.SourceIp, "host", req.Host)
+
+	entityClause, err := timelineEntityClause(req)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = defaultTimelineLimit
+	}
+	if limit > maxTimelineLimit {
+		limit = maxTimelineLimit
+	}
+
+	var filterClauses []any
+	if req.StartTime.GetSeconds() > 0 && req.EndTime.GetSeconds() > 0 {
+		filterClauses = append(filterClauses, RangeClause{
+			Range: map[string]TimeRange{
+				"Timestamp": {
+					GTE: req.StartTime.AsTime().Format(time.RFC3339),
+					LTE: req.EndTime.AsTime().Format(time.RFC3339),
+				},
+			},
+		})
+	}
+
+	hits, err := s.searchTimeline(ctx, timelineIndices, ESQuery{
+		Query: &Query{
+			Bool: &BoolClause{
+				Must:   []any{entityClause},
+				Filter: filterClauses,
+			},
+		},
+		// newest first, so a truncated timeline keeps the latest activity
+		Sort: []map[string]string{{"Timestamp": "desc"}},
+		Size: &limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Processes executed from the entity's SSH sessions don't carry the user or
+	// source IP, so follow each accepted login's sshd PID to its children.
+	var sessionHits []timelineHit
+	if sshdPIDs := sessionPIDs(hits); len(sshdPIDs) > 0 {
+		sessionHits, err = s.searchTimeline(ctx, []string{"process_executed"}, ESQuery{
+			Query: &Query{
+				Bool: &BoolClause{
+					Must:   []any{TermsClause{Terms: map[string][]string{"Metadata.ppid": sshdPIDs}}},
+					Filter: filterClauses,
+				},
+			},
+			Sort: []map[string]string{{"Timestamp": "desc"}},
+			Size: &limit,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	hits = mergeTimelineHits(limit, hits, sessionHits)
+
+	gap := defaultTimelineGap
+	if req.GapThresholdSeconds > 0 {
+		gap = time.Duration(req.GapThresholdSeconds) * time.Second
+	}
+
+	entries := buildTimeline(hits, gap)
+	slog.Info("GetEntityTimeline request completed successfully", "entries", len(entries))
+	return &pb.TimelineResponse{Entries: entries}, nil
+}
+
+func timelineEntityClause(req *pb.TimelineRequest) (any, error) {
+	set := 0
+	for _, v := range []string{req.User, req.SourceIp, req.Host} {
+		if v != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, status.Error(codes.InvalidArgument, "exactly one of user, source_ip or host must be specified")
+	}
+
+	switch {
+	case req.User != "":
+		// correlation alerts record the account as "username"
+		return map[string]any{
+			"bool": BoolClause{
+				Should: []any{
+					TermClause{Term: map[string]string{"Metadata.user": req.User}},
+					TermClause{Term: map[string]string{"Metadata.username": req.User}},
+				},
+				MinimumShouldMatch: 1,
+			},
+		}, nil
+	case req.SourceIp != "":
+		if net.ParseIP(req.SourceIp) == nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid source_ip %q", req.SourceIp)
+		}
+		return TermClause{Term: map[string]string{"Source": req.SourceIp}}, nil
+	default:
+		// Indices created before host was mapped as a keyword only have it
+		// under the dynamic Metadata.host.keyword sub-field.
+		return map[string]any{
+			"bool": BoolClause{
+				Should: []any{
+					TermClause{Term: map[string]string{"Metadata.host": req.Host}},
+					TermClause{Term: map[string]string{"Metadata.host.keyword": req.Host}},
+				},
+				MinimumShouldMatch: 1,
+			},
+		}, nil
+	}
+}
+
+// sessionPIDs returns the sshd PIDs of the accepted logins among hits, once
+// each, in the order they were logged in.
+func sessionPIDs(hits []timelineHit) []string {
+	var pids []string
+	seen := make(map[string]bool)
+	for _, hit := range hits {
+		pid := hit.Source.Metadata["sshd_pid"]
+		if hit.Source.EventType != "SSHD_Accepted_Password" || pid == "" || seen[pid] {
+			continue
+		}
+		seen[pid] = true
+		pids = append(pids, pid)
+	}
+	return pids
+}
+
+func (s *NoxAPIServer) searchTimeline(ctx context.Context, indices []string, query ESQuery) ([]timelineHit, error) {
+	queryBytes, err := json.Marshal(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	slog.Debug("Executing GetEntityTimeline Elasticsearch query", "indices", indices, "query", string(queryBytes))
+
+	res, err := s.esClient.Client.Search(
+		s.esClient.Client.Search.WithContext(ctx),
+		s.esClient.Client.Search.WithIndex(indices...),
+		s.esClient.Client.Search.WithBody(bytes.NewReader(queryBytes)),
+		s.esClient.Client.Search.WithIgnoreUnavailable(true),
+	)
+	if err != nil {
+		slog.Error("Elasticsearch timeline request failed", "error", err)
+		return nil, fmt.Errorf("search returned an error: %s", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.IsError() {
+		slog.Error("Elasticsearch timeline search returned an error", "status", res.Status())
+		return nil, fmt.Errorf("search returned an error: %s", res.Status())
+	}
+
+	var r esTimelineResponse
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return r.Hits.Hits, nil
+}
+
+// mergeTimelineHits combines two result sets, dropping documents present in
+// both, and returns the newest limit of them in chronological order.
+func mergeTimelineHits(limit int, a, b []timelineHit) []timelineHit {
+	seen := make(map[string]bool, len(a))
+	merged := make([]timelineHit, 0, len(a)+len(b))
+
+	for _, hits := range [][]timelineHit{a, b} {
+		for _, hit := range hits {
+			key := hit.Index + "/" + hit.ID
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged = append(merged, hit)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Source.Timestamp.Before(merged[j].Source.Timestamp)
+	})
+
+	if len(merged) > limit {
+		merged = merged[len(merged)-limit:]
+	}
+	return merged
+}
+
+// buildTimeline converts sorted hits to timeline entries, inserting a gap
+// marker wherever consecutive entries are further apart than gap.
+func buildTimeline(hits []timelineHit, gap time.Duration) []*pb.TimelineEntry {
+	var entries []*pb.TimelineEntry
+	var last time.Time
+
+	for _, hit := range hits {
+		doc := hit.Source
+
+		if !last.IsZero() {
+			if quiet := doc.Timestamp.Sub(last); quiet > gap {
+				entries = append(entries, &pb.TimelineEntry{
+					Kind:       "gap",
+					Timestamp:  timestamppb.New(last),
+					Summary:    fmt.Sprintf("no activity for %s", quiet.Round(time.Second)),
+					GapSeconds: int64(quiet.Seconds()),
+				})
+			}
+		}
+		last = doc.Timestamp
+
+		entries = append(entries, timelineEntry(hit.Index, doc))
+	}
+
+	return entries
+}
+
+func timelineEntry(index string, doc timelineDoc) *pb.TimelineEntry {
+	entry := &pb.TimelineEntry{
+		Timestamp: timestamppb.New(doc.Timestamp),
+		EventType: doc.EventType,
+		Source:    doc.Source,
+		Metadata:  doc.Metadata,
+	}
+
+	if index == storage.AlertIndex {
+		entry.Kind = "alert"
+		entry.EventType = doc.RuleName
+		entry.Severity = doc.Severity
+		entry.Summary = fmt.Sprintf("[%s] %s: %s", doc.Severity, doc.RuleName, doc.Message)
+		return entry
+	}
+
+	switch doc.EventType {
+	case "SSHD_Accepted_Password":
+		entry.Kind = "login"
+		entry.Summary = fmt.Sprintf("Accepted password for %s from %s", doc.Metadata["user"], doc.Source)
+	case "SSHD_Failed_Password":
+		entry.Kind = "failed_login"
+		entry.Summary = fmt.Sprintf("Failed password for %s from %s", doc.Metadata["user"], doc.Source)
+	case "Process_Executed":
+		entry.Kind = "process"
+		entry.Summary = fmt.Sprintf("pid=%s ppid=%s uid=%s %s",
+			doc.Metadata["pid"], doc.Metadata["ppid"], doc.Metadata["uid"], doc.Metadata["command"])
+	default:
+		entry.Kind = "event"
+		entry.Summary = doc.EventType
+	}
+
+	return entry
+}
@@ -0,0 +1,241 @@
+package server
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"nox/internal/storage"
+	pb "nox/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTimelineEntityClause(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *pb.TimelineRequest
+		want string
+		code codes.Code
+	}{
+		{
+			name: "user",
+			req:  &pb.TimelineRequest{User: "alice"},
+			want: `{"bool":{"must":null,"should":[{"term":{"Metadata.user":"alice"}},{"term":{"Metadata.username":"alice"}}],"minimum_should_match":1}}`,
+		},
+		{
+			name: "source ip",
+			req:  &pb.TimelineRequest{SourceIp: "198.51.100.99"},
+			want: `{"term":{"Source":"198.51.100.99"}}`,
+		},
+		{
+			name: "host falls back to the keyword sub-field",
+			req:  &pb.TimelineRequest{Host: "web-1"},
+			want: `{"bool":{"must":null,"should":[{"term":{"Metadata.host":"web-1"}},{"term":{"Metadata.host.keyword":"web-1"}}],"minimum_should_match":1}}`,
+		},
+		{name: "no entity", req: &pb.TimelineRequest{}, code: codes.InvalidArgument},
+		{name: "two entities", req: &pb.TimelineRequest{User: "alice", Host: "web-1"}, code: codes.InvalidArgument},
+		{name: "invalid source ip", req: &pb.TimelineRequest{SourceIp: "not-an-ip"}, code: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clause, err := timelineEntityClause(tt.req)
+			if tt.code != codes.OK {
+				if status.Code(err) != tt.code {
+					t.Fatalf("got error %v, want code %s", err, tt.code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("timelineEntityClause: %v", err)
+			}
+
+			got, err := json.Marshal(clause)
+			if err != nil {
+				t.Fatalf("marshal clause: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("got clause %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeTimelineHits(t *testing.T) {
+	base := time.Date(2026, time.June, 19, 12, 0, 0, 0, time.UTC)
+	hit := func(index, id string, offset time.Duration) timelineHit {
+		return timelineHit{ID: id, Index: index, Source: timelineDoc{Timestamp: base.Add(offset)}}
+	}
+
+	tests := []struct {
+		name  string
+		limit int
+		a, b  []timelineHit
+		want  []string
+	}{
+		{
+			name: "interleaves chronologically",
+			a:    []timelineHit{hit("ssh", "1", 0), hit("ssh", "2", 2*time.Minute)},
+			b:    []timelineHit{hit("proc", "1", time.Minute)},
+			want: []string{"ssh/1", "proc/1", "ssh/2"},
+		},
+		{
+			name: "drops documents in both sets",
+			a:    []timelineHit{hit("proc", "1", 0)},
+			b:    []timelineHit{hit("proc", "1", 0), hit("proc", "2", time.Minute)},
+			want: []string{"proc/1", "proc/2"},
+		},
+		{
+			name: "keeps same id in different indices",
+			a:    []timelineHit{hit("ssh", "1", 0)},
+			b:    []timelineHit{hit("proc", "1", 0)},
+			want: []string{"ssh/1", "proc/1"},
+		},
+		{
+			name:  "keeps the newest when truncated",
+			limit: 2,
+			a:     []timelineHit{hit("ssh", "3", 3*time.Minute), hit("ssh", "1", time.Minute)},
+			b:     []timelineHit{hit("proc", "2", 2*time.Minute), hit("proc", "0", 0)},
+			want:  []string{"proc/2", "ssh/3"},
+		},
+		{name: "empty", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit := tt.limit
+			if limit == 0 {
+				limit = defaultTimelineLimit
+			}
+			got := []string{}
+			for _, h := range mergeTimelineHits(limit, tt.a, tt.b) {
+				got = append(got, h.Index+"/"+h.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildTimeline(t *testing.T) {
+	base := time.Date(2026, time.June, 19, 12, 0, 0, 0, time.UTC)
+	login := timelineHit{Index: "ssh", Source: timelineDoc{
+		Timestamp: base,
+		EventType: "SSHD_Accepted_Password",
+		Source:    "198.51.100.99",
+		Metadata:  map[string]string{"user": "alice"},
+	}}
+	process := timelineHit{Index: "process_executed", Source: timelineDoc{
+		Timestamp: base.Add(time.Minute),
+		EventType: "Process_Executed",
+		Metadata:  map[string]string{"pid": "200", "ppid": "100", "uid": "1000", "command": "id"},
+	}}
+	alert := timelineHit{Index: storage.AlertIndex, Source: timelineDoc{
+		Timestamp: base.Add(2 * time.Hour),
+		RuleName:  "HistoryCleared",
+		Severity:  "HIGH",
+		Message:   "history cleared",
+	}}
+
+	tests := []struct {
+		name      string
+		hits      []timelineHit
+		gap       time.Duration
+		kinds     []string
+		summaries []string
+	}{
+		{
+			name:      "gap marker after a quiet period",
+			hits:      []timelineHit{login, process, alert},
+			gap:       30 * time.Minute,
+			kinds:     []string{"login", "process", "gap", "alert"},
+			summaries: []string{"Accepted password for alice from 198.51.100.99", "pid=200 ppid=100 uid=1000 id", "no activity for 1h59m0s", "[HIGH] HistoryCleared: history cleared"},
+		},
+		{
+			name:  "no gap within the threshold",
+			hits:  []timelineHit{login, process, alert},
+			gap:   3 * time.Hour,
+			kinds: []string{"login", "process", "alert"},
+		},
+		{
+			name:  "quiet period equal to the threshold is not a gap",
+			hits:  []timelineHit{login, process},
+			gap:   time.Minute,
+			kinds: []string{"login", "process"},
+		},
+		{name: "empty", kinds: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := buildTimeline(tt.hits, tt.gap)
+
+			kinds := []string{}
+			summaries := []string{}
+			for _, e := range entries {
+				kinds = append(kinds, e.Kind)
+				summaries = append(summaries, e.Summary)
+			}
+			if !reflect.DeepEqual(kinds, tt.kinds) {
+				t.Fatalf("got kinds %v, want %v", kinds, tt.kinds)
+			}
+			if tt.summaries != nil && !reflect.DeepEqual(summaries, tt.summaries) {
+				t.Fatalf("got summaries %q, want %q", summaries, tt.summaries)
+			}
+		})
+	}
+
+	entries := buildTimeline([]timelineHit{process, alert}, 30*time.Minute)
+	gap := entries[1]
+	if gap.GapSeconds != int64((119 * time.Minute).Seconds()) {
+		t.Fatalf("got gap of %ds, want %ds", gap.GapSeconds, int64((119 * time.Minute).Seconds()))
+	}
+	if !gap.Timestamp.AsTime().Equal(process.Source.Timestamp) {
+		t.Fatalf("got gap at %v, want it at the last entry before it (%v)", gap.Timestamp.AsTime(), process.Source.Timestamp)
+	}
+}
+
+func TestSessionPIDs(t *testing.T) {
+	login := func(pid string) timelineHit {
+		return timelineHit{Source: timelineDoc{EventType: "SSHD_Accepted_Password", Metadata: map[string]string{"sshd_pid": pid}}}
+	}
+
+	tests := []struct {
+		name string
+		hits []timelineHit
+		want []string
+	}{
+		{
+			name: "follows accepted logins",
+			hits: []timelineHit{login("100"), login("300")},
+			want: []string{"100", "300"},
+		},
+		{
+			name: "ignores failed logins and processes",
+			hits: []timelineHit{
+				{Source: timelineDoc{EventType: "SSHD_Failed_Password", Metadata: map[string]string{"sshd_pid": "101"}}},
+				{Source: timelineDoc{EventType: "Process_Executed", Metadata: map[string]string{"pid": "200", "ppid": "100"}}},
+				login("100"),
+			},
+			want: []string{"100"},
+		},
+		{
+			name: "skips logins without a pid and repeats",
+			hits: []timelineHit{login(""), login("100"), login("100")},
+			want: []string{"100"},
+		},
+		{name: "no logins", hits: nil, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sessionPIDs(tt.hits); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,336 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"nox/internal/cases"
+	"nox/internal/model"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// IncidentIndex holds the incidents related alerts are merged into.
+const IncidentIndex = "incidents"
+
+var (
+	ErrNotFound = errors.New("document not found")
+	// ErrConflict is returned when a document changed since it was read.
+	ErrConflict = errors.New("document changed concurrently")
+)
+
+// Version identifies the revision of a stored document. Writes made with
+// the version they read fail with ErrConflict if another writer, in this
+// process or another replica, got there first. The zero Version creates a
+// document that must not exist yet.
+type Version struct {
+	SeqNo       int
+	PrimaryTerm int
+}
+
+// AlertRecord is a stored alert with its ID and triage state.
+type AlertRecord struct {
+	ID string `json:"-"`
+	model.Alert
+	Triage  cases.Triage
+	Version Version `json:"-"`
+}
+
+// AlertQuery selects alerts; unset fields match every alert. Status
+// "new" also matches alerts that were never triaged.
+type AlertQuery struct {
+	Start, End time.Time
+	Status     string
+	Assignee   string
+	RuleID     string
+	IncidentID string
+	Limit      int
+}
+
+// IncidentQuery selects incidents; unset fields match every incident.
+type IncidentQuery struct {
+	Status   string
+	Assignee string
+	Limit    int
+}
+
+// GetAlert returns a raised alert, or ErrNotFound.
+func (c *ESClient) GetAlert(ctx context.Context, id string) (AlertRecord, error) {
+	var record AlertRecord
+	version, err := c.getDocument(ctx, AlertIndex, id, &record)
+	if err != nil {
+		return AlertRecord{}, err
+	}
+	record.ID, record.Version = id, version
+	return record, nil
+}
+
+// UpdateAlertTriage replaces the triage state of a raised alert read at
+// version, and returns the alert's new version. A partial document would be
+// merged into the stored triage, keeping the fields the update leaves empty,
+// such as an assignee that was removed.
+func (c *ESClient) UpdateAlertTriage(ctx context.Context, id string, version Version, triage cases.Triage) (Version, error) {
+	body, err := json.Marshal(map[string]any{
+		"script": map[string]any{
+			"source": "ctx._source.Triage = params.triage",
+			"params": map[string]any{"triage": triage},
+		},
+	})
+	if err != nil {
+		return Version{}, fmt.Errorf("[es] failed to marshal alert triage: %w", err)
+	}
+
+	res, err := c.Client.Update(
+		AlertIndex,
+		id,
+		bytes.NewReader(body),
+		c.Client.Update.WithContext(ctx),
+		c.Client.Update.WithRefresh("wait_for"),
+		c.Client.Update.WithIfSeqNo(version.SeqNo),
+		c.Client.Update.WithIfPrimaryTerm(version.PrimaryTerm),
+	)
+	if err != nil {
+		return Version{}, fmt.Errorf("[es] failed to update alert triage: %w - AlertID: %s", err, id)
+	}
+	defer res.Body.Close()
+
+	if err := documentError(res, "updating alert triage", id); err != nil {
+		return Version{}, err
+	}
+	return decodeVersion(res, id)
+}
+
+// SearchAlerts returns raised alerts matching q, newest first.
+func (c *ESClient) SearchAlerts(ctx context.Context, q AlertQuery) ([]AlertRecord, error) {
+	var filters []any
+	if !q.Start.IsZero() || !q.End.IsZero() {
+		timeRange := map[string]string{}
+		if !q.Start.IsZero() {
+			timeRange["gte"] = q.Start.Format(time.RFC3339)
+		}
+		if !q.End.IsZero() {
+			timeRange["lte"] = q.End.Format(time.RFC3339)
+		}
+		filters = append(filters, map[string]any{"range": map[string]any{"Timestamp": timeRange}})
+	}
+	filters = appendTerm(filters, "Triage.Assignee", q.Assignee)
+	filters = appendTerm(filters, "RuleID", q.RuleID)
+	filters = appendTerm(filters, "Triage.IncidentID", q.IncidentID)
+	filters = appendStatus(filters, q.Status)
+
+	var hits []struct {
+		ID     string      `json:"_id"`
+		Source AlertRecord `json:"_source"`
+	}
+	if err := c.search(ctx, AlertIndex, filters, q.Limit, "Timestamp", &hits); err != nil {
+		return nil, err
+	}
+
+	records := make([]AlertRecord, len(hits))
+	for i, hit := range hits {
+		records[i] = hit.Source
+		records[i].ID = hit.ID
+	}
+	return records, nil
+}
+
+// SaveIncident replaces an incident read at version, or creates it when
+// version is zero.
+func (c *ESClient) SaveIncident(ctx context.Context, inc cases.Incident, version Version) error {
+	body, err := json.Marshal(inc)
+	if err != nil {
+		return fmt.Errorf("[es] failed to marshal incident: %w", err)
+	}
+
+	opts := []func(*esapi.IndexRequest){
+		c.Client.Index.WithDocumentID(inc.ID),
+		c.Client.Index.WithContext(ctx),
+		c.Client.Index.WithRefresh("wait_for"),
+	}
+	if version == (Version{}) {
+		opts = append(opts, c.Client.Index.WithOpType("create"))
+	} else {
+		opts = append(opts, c.Client.Index.WithIfSeqNo(version.SeqNo), c.Client.Index.WithIfPrimaryTerm(version.PrimaryTerm))
+	}
+
+	res, err := c.Client.Index(IncidentIndex, bytes.NewReader(body), opts...)
+	if err != nil {
+		return fmt.Errorf("[es] failed to index incident: %w - IncidentID: %s", err, inc.ID)
+	}
+	defer res.Body.Close()
+
+	return documentError(res, "indexing incident", inc.ID)
+}
+
+// DeleteIncident removes an incident, or returns ErrNotFound.
+func (c *ESClient) DeleteIncident(ctx context.Context, id string) error {
+	res, err := c.Client.Delete(
+		IncidentIndex,
+		id,
+		c.Client.Delete.WithContext(ctx),
+		c.Client.Delete.WithRefresh("wait_for"),
+	)
+	if err != nil {
+		return fmt.Errorf("[es] failed to delete incident: %w - IncidentID: %s", err, id)
+	}
+	defer res.Body.Close()
+
+	return documentError(res, "deleting incident", id)
+}
+
+// GetIncident returns an incident and its version, or ErrNotFound.
+func (c *ESClient) GetIncident(ctx context.Context, id string) (cases.Incident, Version, error) {
+	var inc cases.Incident
+	version, err := c.getDocument(ctx, IncidentIndex, id, &inc)
+	if err != nil {
+		return cases.Incident{}, Version{}, err
+	}
+	return inc, version, nil
+}
+
+// SearchIncidents returns incidents matching q, newest first.
+func (c *ESClient) SearchIncidents(ctx context.Context, q IncidentQuery) ([]cases.Incident, error) {
+	var filters []any
+	filters = appendTerm(filters, "Triage.Assignee", q.Assignee)
+	filters = appendStatus(filters, q.Status)
+
+	var hits []struct {
+		Source cases.Incident `json:"_source"`
+	}
+	if err := c.search(ctx, IncidentIndex, filters, q.Limit, "CreatedAt", &hits); err != nil {
+		return nil, err
+	}
+
+	incidents := make([]cases.Incident, len(hits))
+	for i, hit := range hits {
+		incidents[i] = hit.Source
+	}
+	return incidents, nil
+}
+
+func appendTerm(filters []any, field, value string) []any {
+	if value == "" {
+		return filters
+	}
+	return append(filters, map[string]any{"term": map[string]any{field: value}})
+}
+
+// appendStatus filters on triage status, counting documents without one as
+// new.
+func appendStatus(filters []any, status string) []any {
+	if status != cases.StatusNew {
+		return appendTerm(filters, "Triage.Status", status)
+	}
+	return append(filters, map[string]any{
+		"bool": map[string]any{
+			"should": []any{
+				map[string]any{"term": map[string]any{"Triage.Status": status}},
+				map[string]any{"bool": map[string]any{
+					"must_not": map[string]any{"exists": map[string]any{"field": "Triage.Status"}},
+				}},
+			},
+			"minimum_should_match": 1,
+		},
+	})
+}
+
+func (c *ESClient) getDocument(ctx context.Context, index, id string, v any) (Version, error) {
+	res, err := c.Client.Get(index, id, c.Client.Get.WithContext(ctx))
+	if err != nil {
+		return Version{}, fmt.Errorf("[es] failed to get document: %w - index: %s, id: %s", err, index, id)
+	}
+	defer res.Body.Close()
+
+	if err := documentError(res, "getting document", id); err != nil {
+		return Version{}, err
+	}
+
+	var doc struct {
+		Source      json.RawMessage `json:"_source"`
+		SeqNo       int             `json:"_seq_no"`
+		PrimaryTerm int             `json:"_primary_term"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		return Version{}, fmt.Errorf("[es] failed to decode document: %w - index: %s, id: %s", err, index, id)
+	}
+	if err := json.Unmarshal(doc.Source, v); err != nil {
+		return Version{}, fmt.Errorf("[es] failed to decode document: %w - index: %s, id: %s", err, index, id)
+	}
+	return Version{SeqNo: doc.SeqNo, PrimaryTerm: doc.PrimaryTerm}, nil
+}
+
+// decodeVersion reads the version a write left a document at.
+func decodeVersion(res *esapi.Response, id string) (Version, error) {
+	var r struct {
+		SeqNo       int `json:"_seq_no"`
+		PrimaryTerm int `json:"_primary_term"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return Version{}, fmt.Errorf("[es] failed to decode write response: %w - id: %s", err, id)
+	}
+	return Version{SeqNo: r.SeqNo, PrimaryTerm: r.PrimaryTerm}, nil
+}
+
+func (c *ESClient) search(ctx context.Context, index string, filters []any, limit int, sortField string, hits any) error {
+	query := map[string]any{
+		"size": limit,
+		"sort": []any{map[string]any{sortField: map[string]string{"order": "desc"}}},
+	}
+	if len(filters) > 0 {
+		query["query"] = map[string]any{"bool": map[string]any{"filter": filters}}
+	}
+
+	body, err := json.Marshal(query)
+	if err != nil {
+		return fmt.Errorf("[es] failed to build %s query: %w", index, err)
+	}
+
+	res, err := c.Client.Search(
+		c.Client.Search.WithContext(ctx),
+		c.Client.Search.WithIndex(index),
+		c.Client.Search.WithBody(bytes.NewReader(body)),
+		c.Client.Search.WithIgnoreUnavailable(true),
+	)
+	if err != nil {
+		return fmt.Errorf("[es] failed to search %s: %w", index, err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		body, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("[es] error searching %s. status: %s - response: %s", index, res.Status(), string(body))
+	}
+
+	var r struct {
+		Hits struct {
+			Hits json.RawMessage `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return fmt.Errorf("[es] failed to decode %s search: %w", index, err)
+	}
+	if err := json.Unmarshal(r.Hits.Hits, hits); err != nil {
+		return fmt.Errorf("[es] failed to decode %s search: %w", index, err)
+	}
+	return nil
+}
+
+// documentError turns a 404 into ErrNotFound, a 409 into ErrConflict and
+// any other error response into an error.
+func documentError(res *esapi.Response, action, id string) error {
+	switch res.StatusCode {
+	case 404:
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	case 409:
+		return fmt.Errorf("%w: %s", ErrConflict, id)
+	}
+	if res.IsError() {
+		body, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("[es] error %s. status: %s - id: %s - response: %s", action, res.Status(), id, string(body))
+	}
+	return nil
+}
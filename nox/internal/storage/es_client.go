@@ -8,10 +8,22 @@ import (
 	"io"
 	"nox/internal/model"
 	"strings"
+	"time"
 
 	"github.com/elastic/go-elasticsearch/v8"
 )
 
+// AlertIndex is the index triggered alerts are persisted to so they can be
+// queried alongside the raw events.
+const AlertIndex = "alerts"
+
+// SuppressedAlertIndex keeps the alerts an exception suppressed, for
+// review, apart from the alerts that were raised.
+const SuppressedAlertIndex = "suppressed_alerts"
+
+// ShadowAlertIndex keeps the would-be alerts of rules in shadow mode.
+const ShadowAlertIndex = "shadow_alerts"
+
 type ESClient struct {
 	Client *elasticsearch.Client
 }
@@ -62,6 +74,133 @@ func (c *ESClient) IndexEvent(ctx context.Context, event model.Event) error {
 	return nil
 }
 
+// IndexAlert stores a raised alert in AlertIndex, a shadow one in
+// ShadowAlertIndex and a suppressed one in SuppressedAlertIndex. An alert
+// that is already stored is left as it is.
+func (c *ESClient) IndexAlert(ctx context.Context, id string, alert model.Alert) error {
+	jsonData, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("[es] failed to marshal alert for ES: %w - RuleName: %s", err, alert.RuleName)
+	}
+
+	index := AlertIndex
+	switch {
+	case alert.Shadow:
+		index = ShadowAlertIndex
+	case alert.IsSuppressed():
+		index = SuppressedAlertIndex
+	}
+
+	res, err := c.Client.Index(
+		index,
+		bytes.NewReader(jsonData),
+		c.Client.Index.WithDocumentID(id),
+		c.Client.Index.WithOpType("create"),
+		c.Client.Index.WithContext(ctx),
+	)
+
+	if err != nil {
+		return fmt.Errorf("[es] failed to index alert for ES: %w - RuleName: %s", err, alert.RuleName)
+	}
+
+	defer res.Body.Close()
+
+	// a replayed alert has the ID of the one already stored, which may
+	// have been triaged since
+	if res.StatusCode == 409 {
+		return nil
+	}
+
+	if res.IsError() {
+		body, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("[es] error during alert indexing. status: %s - response: %s",
+			res.Status(),
+			string(body),
+		)
+	}
+
+	return nil
+}
+
+// maxAlertRules bounds the rules CountAlertsByRule reports on.
+const maxAlertRules = 1000
+
+// CountAlertsByRule counts persisted alerts per rule name with timestamps in
+// [start, end]. A zero start or end leaves that side of the range open.
+func (c *ESClient) CountAlertsByRule(ctx context.Context, start, end time.Time) (map[string]int64, error) {
+	return c.countByRule(ctx, AlertIndex, start, end)
+}
+
+// CountShadowAlertsByRule is CountAlertsByRule for the would-be alerts of
+// rules in shadow mode.
+func (c *ESClient) CountShadowAlertsByRule(ctx context.Context, start, end time.Time) (map[string]int64, error) {
+	return c.countByRule(ctx, ShadowAlertIndex, start, end)
+}
+
+func (c *ESClient) countByRule(ctx context.Context, index string, start, end time.Time) (map[string]int64, error) {
+	timeRange := map[string]string{}
+	if !start.IsZero() {
+		timeRange["gte"] = start.Format(time.RFC3339)
+	}
+	if !end.IsZero() {
+		timeRange["lte"] = end.Format(time.RFC3339)
+	}
+
+	query := map[string]any{
+		"size": 0,
+		"aggs": map[string]any{
+			"by_rule": map[string]any{
+				"terms": map[string]any{"field": "RuleName", "size": maxAlertRules},
+			},
+		},
+	}
+	if len(timeRange) > 0 {
+		query["query"] = map[string]any{
+			"range": map[string]any{"Timestamp": timeRange},
+		}
+	}
+
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, fmt.Errorf("[es] failed to build alert count query: %w", err)
+	}
+
+	res, err := c.Client.Search(
+		c.Client.Search.WithContext(ctx),
+		c.Client.Search.WithIndex(index),
+		c.Client.Search.WithBody(bytes.NewReader(body)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("[es] failed to count alerts in %s: %w", index, err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		body, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("[es] error counting alerts. status: %s - response: %s", res.Status(), string(body))
+	}
+
+	var r struct {
+		Aggregations struct {
+			ByRule struct {
+				Buckets []struct {
+					Key      string `json:"key"`
+					DocCount int64  `json:"doc_count"`
+				} `json:"buckets"`
+			} `json:"by_rule"`
+		} `json:"aggregations"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("[es] failed to decode alert counts: %w", err)
+	}
+
+	counts := make(map[string]int64, len(r.Aggregations.ByRule.Buckets))
+	for _, bucket := range r.Aggregations.ByRule.Buckets {
+		counts[bucket.Key] = bucket.DocCount
+	}
+	return counts, nil
+}
+
 func (c *ESClient) EnsureIndex(ctx context.Context, indexName string) error {
 	res, err := c.Client.Indices.Exists([]string{indexName}, c.Client.Indices.Exists.WithContext(ctx))
 	if err != nil {
@@ -77,26 +216,15 @@ func (c *ESClient) EnsureIndex(ctx context.Context, indexName string) error {
 		return nil
 	}
 
-	mapping := `{
-		"mappings": {
-			"properties": {
-				"Timestamp": { "type": "date" },
-				"EventType": { "type": "keyword" },
-				"Source": 	 { "type": "ip" },
-				"Metadata": {
-					"properties": {
-						"process_name": { "type": "keyword" },
-						"command": 		{ "type": "text" },
-						"pid":			{ "type": "keyword" },
-						"ppid":			{ "type": "keyword" },
-						"uid":			{ "type": "keyword" },
-						"user":			{ "type": "keyword" },
-						"sshd_pid":		{ "type": "keyword" }
-					}
-				}
-			}
-		}
-	}`
+	mapping := eventMapping
+	switch indexName {
+	case AlertIndex, SuppressedAlertIndex, ShadowAlertIndex:
+		mapping = alertMapping
+	case IncidentIndex:
+		mapping = incidentMapping
+	case ResponseActionIndex:
+		mapping = responseActionMapping
+	}
 
 	res, err = c.Client.Indices.Create(
 		indexName,
@@ -120,3 +248,152 @@ func (c *ESClient) EnsureIndex(ctx context.Context, indexName string) error {
 
 	return nil
 }
+
+const eventMapping = `{
+	"mappings": {
+		"properties": {
+			"Timestamp": { "type": "date" },
+			"EventType": { "type": "keyword" },
+			"Source": 	 { "type": "ip" },
+			"Metadata": {
+				"properties": {
+					"process_name": { "type": "keyword" },
+					"command": 		{ "type": "text" },
+					"pid":			{ "type": "keyword" },
+					"ppid":			{ "type": "keyword" },
+					"uid":			{ "type": "keyword" },
+					"user":			{ "type": "keyword" },
+					"sshd_pid":		{ "type": "keyword" },
+					"host":			{ "type": "keyword" }
+				}
+			},
+			"Input":	 { "type": "keyword" },
+			"Raw":		 { "type": "text" }
+		}
+	}
+}`
+
+const alertMapping = `{
+	"mappings": {
+		"properties": {
+			"Timestamp": { "type": "date" },
+			"RuleName":  { "type": "keyword" },
+			"RuleID":    { "type": "keyword" },
+			"RuleVersion": { "type": "integer" },
+			"ExceptionID": { "type": "keyword" },
+			"Shadow":    { "type": "boolean" },
+			"RiskScore": { "type": "float" },
+			"Severity":  { "type": "keyword" },
+			"Source": 	 { "type": "keyword" },
+			"Message":	 { "type": "text" },
+			"Metadata": {
+				"properties": {
+					"user":			{ "type": "keyword" },
+					"username":		{ "type": "keyword" },
+					"host":			{ "type": "keyword" },
+					"pid":			{ "type": "keyword" }
+				}
+			},
+			"Events": {
+				"properties": {
+					"Timestamp":	{ "type": "date" },
+					"EventType":	{ "type": "keyword" },
+					"Source":		{ "type": "keyword" },
+					"Input":		{ "type": "keyword" },
+					"Raw":			{ "type": "text" },
+					"Metadata":		{ "type": "object", "enabled": false }
+				}
+			},
+			"Ancestry": {
+				"properties": {
+					"Timestamp":	{ "type": "date" },
+					"PID":			{ "type": "keyword" },
+					"PPID":			{ "type": "keyword" },
+					"ProcessName":	{ "type": "keyword" },
+					"Command":		{ "type": "text" },
+					"UID":			{ "type": "keyword" }
+				}
+			},
+			"Triage": {
+				"properties": {
+					"Status":		{ "type": "keyword" },
+					"Assignee":		{ "type": "keyword" },
+					"Tags":			{ "type": "keyword" },
+					"IncidentID":	{ "type": "keyword" },
+					"UpdatedAt":	{ "type": "date" },
+					"UpdatedBy":	{ "type": "keyword" },
+					"Notes": {
+						"properties": {
+							"Author":	{ "type": "keyword" },
+							"Text":		{ "type": "text" },
+							"Time":		{ "type": "date" }
+						}
+					}
+				}
+			}
+		}
+	}
+}`
+
+const responseActionMapping = `{
+	"mappings": {
+		"properties": {
+			"ID":          { "type": "keyword" },
+			"ActionID":    { "type": "keyword" },
+			"Type":        { "type": "keyword" },
+			"AlertID":     { "type": "keyword" },
+			"Status":      { "type": "keyword" },
+			"DryRun":      { "type": "boolean" },
+			"Target":      { "type": "keyword" },
+			"Output":      { "type": "text" },
+			"Error":       { "type": "text" },
+			"CreatedAt":   { "type": "date" },
+			"CompletedAt": { "type": "date" },
+			"DecidedBy":   { "type": "keyword" },
+			"Note":        { "type": "text" },
+			"Alert": {
+				"properties": {
+					"Timestamp": { "type": "date" },
+					"RuleName":  { "type": "keyword" },
+					"RuleID":    { "type": "keyword" },
+					"Severity":  { "type": "keyword" },
+					"Source":    { "type": "keyword" },
+					"Message":   { "type": "text" },
+					"Events":    { "type": "object", "enabled": false },
+					"Ancestry":  { "type": "object", "enabled": false }
+				}
+			}
+		}
+	}
+}`
+
+const incidentMapping = `{
+	"mappings": {
+		"properties": {
+			"ID":        { "type": "keyword" },
+			"Title":     { "type": "text" },
+			"Severity":  { "type": "keyword" },
+			"AlertIDs":  { "type": "keyword" },
+			"Rules":     { "type": "keyword" },
+			"CreatedAt": { "type": "date" },
+			"CreatedBy": { "type": "keyword" },
+			"Triage": {
+				"properties": {
+					"Status":		{ "type": "keyword" },
+					"Assignee":		{ "type": "keyword" },
+					"Tags":			{ "type": "keyword" },
+					"IncidentID":	{ "type": "keyword" },
+					"UpdatedAt":	{ "type": "date" },
+					"UpdatedBy":	{ "type": "keyword" },
+					"Notes": {
+						"properties": {
+							"Author":	{ "type": "keyword" },
+							"Text":		{ "type": "text" },
+							"Time":		{ "type": "date" }
+						}
+					}
+				}
+			}
+		}
+	}
+}`
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"nox/internal/response"
+)
+
+// ResponseActionIndex records every response action an alert triggered and
+// its outcome.
+const ResponseActionIndex = "response_actions"
+
+// ResponseActionQuery selects executions; unset fields match every one.
+type ResponseActionQuery struct {
+	AlertID  string
+	ActionID string
+	Status   string
+	Limit    int
+}
+
+// SaveResponseAction creates or replaces an execution record.
+func (c *ESClient) SaveResponseAction(ctx context.Context, x response.Execution) error {
+	body, err := json.Marshal(x)
+	if err != nil {
+		return fmt.Errorf("[es] failed to marshal response action: %w", err)
+	}
+
+	res, err := c.Client.Index(
+		ResponseActionIndex,
+		bytes.NewReader(body),
+		c.Client.Index.WithDocumentID(x.ID),
+		c.Client.Index.WithContext(ctx),
+		c.Client.Index.WithRefresh("wait_for"),
+	)
+	if err != nil {
+		return fmt.Errorf("[es] failed to index response action: %w - ExecutionID: %s", err, x.ID)
+	}
+	defer res.Body.Close()
+
+	return documentError(res, "indexing response action", x.ID)
+}
+
+// GetResponseAction returns an execution record, or ErrNotFound.
+func (c *ESClient) GetResponseAction(ctx context.Context, id string) (response.Execution, error) {
+	var x response.Execution
+	if _, err := c.getDocument(ctx, ResponseActionIndex, id, &x); err != nil {
+		return response.Execution{}, err
+	}
+	return x, nil
+}
+
+// SearchResponseActions returns executions matching q, newest first.
+func (c *ESClient) SearchResponseActions(ctx context.Context, q ResponseActionQuery) ([]response.Execution, error) {
+	var filters []any
+	filters = appendTerm(filters, "AlertID", q.AlertID)
+	filters = appendTerm(filters, "ActionID", q.ActionID)
+	filters = appendTerm(filters, "Status", q.Status)
+
+	var hits []struct {
+		Source response.Execution `json:"_source"`
+	}
+	if err := c.search(ctx, ResponseActionIndex, filters, q.Limit, "CreatedAt", &hits); err != nil {
+		return nil, err
+	}
+
+	executions := make([]response.Execution, len(hits))
+	for i, hit := range hits {
+		executions[i] = hit.Source
+	}
+	return executions, nil
+}
@@ -27,6 +27,25 @@ type NoxServiceClient interface {
 	SearchEvents(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
 	GetProcessAncestry(ctx context.Context, in *PIDRequest, opts ...grpc.CallOption) (*ProcessHistoryResponse, error)
 	GetTopEvents(ctx context.Context, in *TopNRequest, opts ...grpc.CallOption) (*TopNResponse, error)
+	GetEntityTimeline(ctx context.Context, in *TimelineRequest, opts ...grpc.CallOption) (*TimelineResponse, error)
+	GetProfile(ctx context.Context, in *ProfileRequest, opts ...grpc.CallOption) (*ProfileResponse, error)
+	GetCoverage(ctx context.Context, in *CoverageRequest, opts ...grpc.CallOption) (*CoverageResponse, error)
+	ListRules(ctx context.Context, in *ListRulesRequest, opts ...grpc.CallOption) (*ListRulesResponse, error)
+	EnableRule(ctx context.Context, in *RuleRequest, opts ...grpc.CallOption) (*RuleInfo, error)
+	DisableRule(ctx context.Context, in *RuleRequest, opts ...grpc.CallOption) (*RuleInfo, error)
+	UpsertYAMLRule(ctx context.Context, in *UpsertRuleRequest, opts ...grpc.CallOption) (*UpsertRuleResponse, error)
+	DeleteRule(ctx context.Context, in *RuleRequest, opts ...grpc.CallOption) (*RuleInfo, error)
+	SetRuleMode(ctx context.Context, in *RuleModeRequest, opts ...grpc.CallOption) (*RuleInfo, error)
+	GetShadowReport(ctx context.Context, in *ShadowReportRequest, opts ...grpc.CallOption) (*ShadowReportResponse, error)
+	ListAlerts(ctx context.Context, in *ListAlertsRequest, opts ...grpc.CallOption) (*ListAlertsResponse, error)
+	UpdateAlert(ctx context.Context, in *UpdateAlertRequest, opts ...grpc.CallOption) (*TriagedAlert, error)
+	CreateIncident(ctx context.Context, in *CreateIncidentRequest, opts ...grpc.CallOption) (*Incident, error)
+	UpdateIncident(ctx context.Context, in *UpdateIncidentRequest, opts ...grpc.CallOption) (*Incident, error)
+	ListIncidents(ctx context.Context, in *ListIncidentsRequest, opts ...grpc.CallOption) (*ListIncidentsResponse, error)
+	GetRiskyEntities(ctx context.Context, in *RiskyEntitiesRequest, opts ...grpc.CallOption) (*RiskyEntitiesResponse, error)
+	ListResponseActions(ctx context.Context, in *ListResponseActionsRequest, opts ...grpc.CallOption) (*ListResponseActionsResponse, error)
+	ApproveResponseAction(ctx context.Context, in *ResponseActionDecision, opts ...grpc.CallOption) (*ResponseAction, error)
+	RejectResponseAction(ctx context.Context, in *ResponseActionDecision, opts ...grpc.CallOption) (*ResponseAction, error)
 }
 
 type noxServiceClient struct {
@@ -82,6 +101,177 @@ func (c *noxServiceClient) GetTopEvents(ctx context.Context, in *TopNRequest, op
 	return out, nil
 }
 
+func (c *noxServiceClient) GetEntityTimeline(ctx context.Context, in *TimelineRequest, opts ...grpc.CallOption) (*TimelineResponse, error) {
+	out := new(TimelineResponse)
+	err := c.cc.Invoke(ctx, "/nox.NoxService/GetEntityTimeline", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *noxServiceClient) GetProfile(ctx context.Context, in *ProfileRequest, opts ...grpc.CallOption) (*ProfileResponse, error) {
+	out := new(ProfileResponse)
+	err := c.cc.Invoke(ctx, "/nox.NoxService/GetProfile", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *noxServiceClient) GetCoverage(ctx context.Context, in *CoverageRequest, opts ...grpc.CallOption) (*CoverageResponse, error) {
+	out := new(CoverageResponse)
+	err := c.cc.Invoke(ctx, "/nox.NoxService/GetCoverage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *noxServiceClient) ListRules(ctx context.Context, in *ListRulesRequest, opts ...grpc.CallOption) (*ListRulesResponse, error) {
+	out := new(ListRulesResponse)
+	err := c.cc.Invoke(ctx, "/nox.NoxService/ListRules", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *noxServiceClient) EnableRule(ctx context.Context, in *RuleRequest, opts ...grpc.CallOption) (*RuleInfo, error) {
+	out := new(RuleInfo)
+	err := c.cc.Invoke(ctx, "/nox.NoxService/EnableRule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *noxServiceClient) DisableRule(ctx context.Context, in *RuleRequest, opts ...grpc.CallOption) (*RuleInfo, error) {
+	out := new(RuleInfo)
+	err := c.cc.Invoke(ctx, "/nox.NoxService/DisableRule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *noxServiceClient) UpsertYAMLRule(ctx context.Context, in *UpsertRuleRequest, opts ...grpc.CallOption) (*UpsertRuleResponse, error) {
+	out := new(UpsertRuleResponse)
+	err := c.cc.Invoke(ctx, "/nox.NoxService/UpsertYAMLRule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *noxServiceClient) DeleteRule(ctx context.Context, in *RuleRequest, opts ...grpc.CallOption) (*RuleInfo, error) {
+	out := new(RuleInfo)
+	err := c.cc.Invoke(ctx, "/nox.NoxService/DeleteRule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *noxServiceClient) SetRuleMode(ctx context.Context, in *RuleModeRequest, opts ...grpc.CallOption) (*RuleInfo, error) {
+	out := new(RuleInfo)
+	err := c.cc.Invoke(ctx, "/nox.NoxService/SetRuleMode", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *noxServiceClient) GetShadowReport(ctx context.Context, in *ShadowReportRequest, opts ...grpc.CallOption) (*ShadowReportResponse, error) {
+	out := new(ShadowReportResponse)
+	err := c.cc.Invoke(ctx, "/nox.NoxService/GetShadowReport", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *noxServiceClient) ListAlerts(ctx context.Context, in *ListAlertsRequest, opts ...grpc.CallOption) (*ListAlertsResponse, error) {
+	out := new(ListAlertsResponse)
+	err := c.cc.Invoke(ctx, "/nox.NoxService/ListAlerts", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *noxServiceClient) UpdateAlert(ctx context.Context, in *UpdateAlertRequest, opts ...grpc.CallOption) (*TriagedAlert, error) {
+	out := new(TriagedAlert)
+	err := c.cc.Invoke(ctx, "/nox.NoxService/UpdateAlert", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *noxServiceClient) CreateIncident(ctx context.Context, in *CreateIncidentRequest, opts ...grpc.CallOption) (*Incident, error) {
+	out := new(Incident)
+	err := c.cc.Invoke(ctx, "/nox.NoxService/CreateIncident", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *noxServiceClient) UpdateIncident(ctx context.Context, in *UpdateIncidentRequest, opts ...grpc.CallOption) (*Incident, error) {
+	out := new(Incident)
+	err := c.cc.Invoke(ctx, "/nox.NoxService/UpdateIncident", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *noxServiceClient) ListIncidents(ctx context.Context, in *ListIncidentsRequest, opts ...grpc.CallOption) (*ListIncidentsResponse, error) {
+	out := new(ListIncidentsResponse)
+	err := c.cc.Invoke(ctx, "/nox.NoxService/ListIncidents", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *noxServiceClient) GetRiskyEntities(ctx context.Context, in *RiskyEntitiesRequest, opts ...grpc.CallOption) (*RiskyEntitiesResponse, error) {
+	out := new(RiskyEntitiesResponse)
+	err := c.cc.Invoke(ctx, "/nox.NoxService/GetRiskyEntities", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *noxServiceClient) ListResponseActions(ctx context.Context, in *ListResponseActionsRequest, opts ...grpc.CallOption) (*ListResponseActionsResponse, error) {
+	out := new(ListResponseActionsResponse)
+	err := c.cc.Invoke(ctx, "/nox.NoxService/ListResponseActions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *noxServiceClient) ApproveResponseAction(ctx context.Context, in *ResponseActionDecision, opts ...grpc.CallOption) (*ResponseAction, error) {
+	out := new(ResponseAction)
+	err := c.cc.Invoke(ctx, "/nox.NoxService/ApproveResponseAction", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *noxServiceClient) RejectResponseAction(ctx context.Context, in *ResponseActionDecision, opts ...grpc.CallOption) (*ResponseAction, error) {
+	out := new(ResponseAction)
+	err := c.cc.Invoke(ctx, "/nox.NoxService/RejectResponseAction", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // NoxServiceServer is the server API for NoxService service.
 // All implementations must embed UnimplementedNoxServiceServer
 // for forward compatibility
@@ -91,6 +281,25 @@ type NoxServiceServer interface {
 	SearchEvents(context.Context, *SearchRequest) (*SearchResponse, error)
 	GetProcessAncestry(context.Context, *PIDRequest) (*ProcessHistoryResponse, error)
 	GetTopEvents(context.Context, *TopNRequest) (*TopNResponse, error)
+	GetEntityTimeline(context.Context, *TimelineRequest) (*TimelineResponse, error)
+	GetProfile(context.Context, *ProfileRequest) (*ProfileResponse, error)
+	GetCoverage(context.Context, *CoverageRequest) (*CoverageResponse, error)
+	ListRules(context.Context, *ListRulesRequest) (*ListRulesResponse, error)
+	EnableRule(context.Context, *RuleRequest) (*RuleInfo, error)
+	DisableRule(context.Context, *RuleRequest) (*RuleInfo, error)
+	UpsertYAMLRule(context.Context, *UpsertRuleRequest) (*UpsertRuleResponse, error)
+	DeleteRule(context.Context, *RuleRequest) (*RuleInfo, error)
+	SetRuleMode(context.Context, *RuleModeRequest) (*RuleInfo, error)
+	GetShadowReport(context.Context, *ShadowReportRequest) (*ShadowReportResponse, error)
+	ListAlerts(context.Context, *ListAlertsRequest) (*ListAlertsResponse, error)
+	UpdateAlert(context.Context, *UpdateAlertRequest) (*TriagedAlert, error)
+	CreateIncident(context.Context, *CreateIncidentRequest) (*Incident, error)
+	UpdateIncident(context.Context, *UpdateIncidentRequest) (*Incident, error)
+	ListIncidents(context.Context, *ListIncidentsRequest) (*ListIncidentsResponse, error)
+	GetRiskyEntities(context.Context, *RiskyEntitiesRequest) (*RiskyEntitiesResponse, error)
+	ListResponseActions(context.Context, *ListResponseActionsRequest) (*ListResponseActionsResponse, error)
+	ApproveResponseAction(context.Context, *ResponseActionDecision) (*ResponseAction, error)
+	RejectResponseAction(context.Context, *ResponseActionDecision) (*ResponseAction, error)
 	mustEmbedUnimplementedNoxServiceServer()
 }
 
@@ -113,6 +322,63 @@ func (UnimplementedNoxServiceServer) GetProcessAncestry(context.Context, *PIDReq
 func (UnimplementedNoxServiceServer) GetTopEvents(context.Context, *TopNRequest) (*TopNResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetTopEvents not implemented")
 }
+func (UnimplementedNoxServiceServer) GetEntityTimeline(context.Context, *TimelineRequest) (*TimelineResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetEntityTimeline not implemented")
+}
+func (UnimplementedNoxServiceServer) GetProfile(context.Context, *ProfileRequest) (*ProfileResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetProfile not implemented")
+}
+func (UnimplementedNoxServiceServer) GetCoverage(context.Context, *CoverageRequest) (*CoverageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCoverage not implemented")
+}
+func (UnimplementedNoxServiceServer) ListRules(context.Context, *ListRulesRequest) (*ListRulesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRules not implemented")
+}
+func (UnimplementedNoxServiceServer) EnableRule(context.Context, *RuleRequest) (*RuleInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EnableRule not implemented")
+}
+func (UnimplementedNoxServiceServer) DisableRule(context.Context, *RuleRequest) (*RuleInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DisableRule not implemented")
+}
+func (UnimplementedNoxServiceServer) UpsertYAMLRule(context.Context, *UpsertRuleRequest) (*UpsertRuleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpsertYAMLRule not implemented")
+}
+func (UnimplementedNoxServiceServer) DeleteRule(context.Context, *RuleRequest) (*RuleInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteRule not implemented")
+}
+func (UnimplementedNoxServiceServer) SetRuleMode(context.Context, *RuleModeRequest) (*RuleInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRuleMode not implemented")
+}
+func (UnimplementedNoxServiceServer) GetShadowReport(context.Context, *ShadowReportRequest) (*ShadowReportResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetShadowReport not implemented")
+}
+func (UnimplementedNoxServiceServer) ListAlerts(context.Context, *ListAlertsRequest) (*ListAlertsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAlerts not implemented")
+}
+func (UnimplementedNoxServiceServer) UpdateAlert(context.Context, *UpdateAlertRequest) (*TriagedAlert, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateAlert not implemented")
+}
+func (UnimplementedNoxServiceServer) CreateIncident(context.Context, *CreateIncidentRequest) (*Incident, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateIncident not implemented")
+}
+func (UnimplementedNoxServiceServer) UpdateIncident(context.Context, *UpdateIncidentRequest) (*Incident, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateIncident not implemented")
+}
+func (UnimplementedNoxServiceServer) ListIncidents(context.Context, *ListIncidentsRequest) (*ListIncidentsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListIncidents not implemented")
+}
+func (UnimplementedNoxServiceServer) GetRiskyEntities(context.Context, *RiskyEntitiesRequest) (*RiskyEntitiesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRiskyEntities not implemented")
+}
+func (UnimplementedNoxServiceServer) ListResponseActions(context.Context, *ListResponseActionsRequest) (*ListResponseActionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListResponseActions not implemented")
+}
+func (UnimplementedNoxServiceServer) ApproveResponseAction(context.Context, *ResponseActionDecision) (*ResponseAction, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ApproveResponseAction not implemented")
+}
+func (UnimplementedNoxServiceServer) RejectResponseAction(context.Context, *ResponseActionDecision) (*ResponseAction, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RejectResponseAction not implemented")
+}
 func (UnimplementedNoxServiceServer) mustEmbedUnimplementedNoxServiceServer() {}
 
 // UnsafeNoxServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -216,6 +482,348 @@ func _NoxService_GetTopEvents_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _NoxService_GetEntityTimeline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TimelineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NoxServiceServer).GetEntityTimeline(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nox.NoxService/GetEntityTimeline",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NoxServiceServer).GetEntityTimeline(ctx, req.(*TimelineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NoxService_GetProfile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProfileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NoxServiceServer).GetProfile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nox.NoxService/GetProfile",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NoxServiceServer).GetProfile(ctx, req.(*ProfileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NoxService_GetCoverage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CoverageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NoxServiceServer).GetCoverage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nox.NoxService/GetCoverage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NoxServiceServer).GetCoverage(ctx, req.(*CoverageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NoxService_ListRules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRulesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NoxServiceServer).ListRules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nox.NoxService/ListRules",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NoxServiceServer).ListRules(ctx, req.(*ListRulesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NoxService_EnableRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NoxServiceServer).EnableRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nox.NoxService/EnableRule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NoxServiceServer).EnableRule(ctx, req.(*RuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NoxService_DisableRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NoxServiceServer).DisableRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nox.NoxService/DisableRule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NoxServiceServer).DisableRule(ctx, req.(*RuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NoxService_UpsertYAMLRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpsertRuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NoxServiceServer).UpsertYAMLRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nox.NoxService/UpsertYAMLRule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NoxServiceServer).UpsertYAMLRule(ctx, req.(*UpsertRuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NoxService_DeleteRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NoxServiceServer).DeleteRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nox.NoxService/DeleteRule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NoxServiceServer).DeleteRule(ctx, req.(*RuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NoxService_SetRuleMode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RuleModeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NoxServiceServer).SetRuleMode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nox.NoxService/SetRuleMode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NoxServiceServer).SetRuleMode(ctx, req.(*RuleModeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NoxService_GetShadowReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ShadowReportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NoxServiceServer).GetShadowReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nox.NoxService/GetShadowReport",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NoxServiceServer).GetShadowReport(ctx, req.(*ShadowReportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NoxService_ListAlerts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAlertsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NoxServiceServer).ListAlerts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nox.NoxService/ListAlerts",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NoxServiceServer).ListAlerts(ctx, req.(*ListAlertsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NoxService_UpdateAlert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateAlertRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NoxServiceServer).UpdateAlert(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nox.NoxService/UpdateAlert",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NoxServiceServer).UpdateAlert(ctx, req.(*UpdateAlertRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NoxService_CreateIncident_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateIncidentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NoxServiceServer).CreateIncident(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nox.NoxService/CreateIncident",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NoxServiceServer).CreateIncident(ctx, req.(*CreateIncidentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NoxService_UpdateIncident_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateIncidentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NoxServiceServer).UpdateIncident(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nox.NoxService/UpdateIncident",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NoxServiceServer).UpdateIncident(ctx, req.(*UpdateIncidentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NoxService_ListIncidents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListIncidentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NoxServiceServer).ListIncidents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nox.NoxService/ListIncidents",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NoxServiceServer).ListIncidents(ctx, req.(*ListIncidentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NoxService_GetRiskyEntities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RiskyEntitiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NoxServiceServer).GetRiskyEntities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nox.NoxService/GetRiskyEntities",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NoxServiceServer).GetRiskyEntities(ctx, req.(*RiskyEntitiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NoxService_ListResponseActions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListResponseActionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NoxServiceServer).ListResponseActions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nox.NoxService/ListResponseActions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NoxServiceServer).ListResponseActions(ctx, req.(*ListResponseActionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NoxService_ApproveResponseAction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResponseActionDecision)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NoxServiceServer).ApproveResponseAction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nox.NoxService/ApproveResponseAction",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NoxServiceServer).ApproveResponseAction(ctx, req.(*ResponseActionDecision))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NoxService_RejectResponseAction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResponseActionDecision)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NoxServiceServer).RejectResponseAction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nox.NoxService/RejectResponseAction",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NoxServiceServer).RejectResponseAction(ctx, req.(*ResponseActionDecision))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // NoxService_ServiceDesc is the grpc.ServiceDesc for NoxService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -243,6 +851,82 @@ var NoxService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetTopEvents",
 			Handler:    _NoxService_GetTopEvents_Handler,
 		},
+		{
+			MethodName: "GetEntityTimeline",
+			Handler:    _NoxService_GetEntityTimeline_Handler,
+		},
+		{
+			MethodName: "GetProfile",
+			Handler:    _NoxService_GetProfile_Handler,
+		},
+		{
+			MethodName: "GetCoverage",
+			Handler:    _NoxService_GetCoverage_Handler,
+		},
+		{
+			MethodName: "ListRules",
+			Handler:    _NoxService_ListRules_Handler,
+		},
+		{
+			MethodName: "EnableRule",
+			Handler:    _NoxService_EnableRule_Handler,
+		},
+		{
+			MethodName: "DisableRule",
+			Handler:    _NoxService_DisableRule_Handler,
+		},
+		{
+			MethodName: "UpsertYAMLRule",
+			Handler:    _NoxService_UpsertYAMLRule_Handler,
+		},
+		{
+			MethodName: "DeleteRule",
+			Handler:    _NoxService_DeleteRule_Handler,
+		},
+		{
+			MethodName: "SetRuleMode",
+			Handler:    _NoxService_SetRuleMode_Handler,
+		},
+		{
+			MethodName: "GetShadowReport",
+			Handler:    _NoxService_GetShadowReport_Handler,
+		},
+		{
+			MethodName: "ListAlerts",
+			Handler:    _NoxService_ListAlerts_Handler,
+		},
+		{
+			MethodName: "UpdateAlert",
+			Handler:    _NoxService_UpdateAlert_Handler,
+		},
+		{
+			MethodName: "CreateIncident",
+			Handler:    _NoxService_CreateIncident_Handler,
+		},
+		{
+			MethodName: "UpdateIncident",
+			Handler:    _NoxService_UpdateIncident_Handler,
+		},
+		{
+			MethodName: "ListIncidents",
+			Handler:    _NoxService_ListIncidents_Handler,
+		},
+		{
+			MethodName: "GetRiskyEntities",
+			Handler:    _NoxService_GetRiskyEntities_Handler,
+		},
+		{
+			MethodName: "ListResponseActions",
+			Handler:    _NoxService_ListResponseActions_Handler,
+		},
+		{
+			MethodName: "ApproveResponseAction",
+			Handler:    _NoxService_ApproveResponseAction_Handler,
+		},
+		{
+			MethodName: "RejectResponseAction",
+			Handler:    _NoxService_RejectResponseAction_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/nox.proto",